@@ -0,0 +1,309 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"runtime"
+	"text/tabwriter"
+	"time"
+	"unsafe"
+
+	"github.com/aertje/sparse-store/store"
+	"github.com/aertje/sparse-store/storetest"
+)
+
+// benchConfig holds the flags of bench.
+type benchConfig struct {
+	workload      string
+	log           string
+	ops           int
+	length        int64
+	block         int64
+	seed          int64
+	minContiguous int
+	maxContiguous int
+	lazy          int
+	bitmap        bool
+	copyOnSet     bool
+	maxOccupancy  int64
+	eviction      string
+}
+
+// workloads generate the operations of the synthetic workloads of bench.
+var workloads = map[string]func(r *rand.Rand, cfg benchConfig) []storetest.Op[byte]{
+	"sequential": sequentialWorkload,
+	"random":     randomWorkload,
+	"torrent":    torrentWorkload,
+}
+
+// evictionPolicies return the eviction policies by the name of their flag.
+var evictionPolicies = map[string]func() store.Evictor{
+	"oldest":        func() store.Evictor { return store.EvictOldest },
+	"lowest-offset": func() store.Evictor { return store.EvictLowestOffset },
+	"lru":           func() store.Evictor { return store.EvictLRU },
+	"lfu":           func() store.Evictor { return store.EvictLFU },
+	"arc":           func() store.Evictor { return store.NewARC() },
+}
+
+// bench runs a recorded operation log or a synthetic workload against a store,
+// and reports its throughput, allocations and fragmentation.
+func bench(args []string, w io.Writer) error {
+	flags := flag.NewFlagSet("bench", flag.ContinueOnError)
+	var cfg benchConfig
+	flags.StringVar(&cfg.workload, "workload", "random", "synthetic workload to run: "+keys(workloads))
+	flags.StringVar(&cfg.log, "log", "", "operation log written by a store.Recorder to replay instead of a workload")
+	width := flags.Int("width", 1, "size in bytes of the values of the log: 1, 2, 4 or 8")
+	flags.IntVar(&cfg.ops, "ops", 100000, "number of operations of the workload")
+	flags.Int64Var(&cfg.length, "length", 64<<20, "number of values the workload spans")
+	flags.Int64Var(&cfg.block, "block", 16<<10, "number of values of the writes of the workload")
+	flags.Int64Var(&cfg.seed, "seed", 1, "seed of the workload")
+	flags.IntVar(&cfg.minContiguous, "min-contiguous", 0, "WithMinContiguous, if not 0")
+	flags.IntVar(&cfg.maxContiguous, "max-contiguous", 0, "WithMaxContiguous, if not 0")
+	flags.IntVar(&cfg.lazy, "lazy", 0, "WithLazyCompaction with this many pending writes, if not 0")
+	flags.BoolVar(&cfg.bitmap, "bitmap", false, "WithPresenceBitmap")
+	flags.BoolVar(&cfg.copyOnSet, "copy-on-set", false, "WithCopyOnSet")
+	flags.Int64Var(&cfg.maxOccupancy, "max-occupancy", 0, "WithMaxOccupancy, if not 0")
+	flags.StringVar(&cfg.eviction, "eviction", "oldest", "eviction policy of -max-occupancy: "+keys(evictionPolicies))
+	if err := flags.Parse(args); err != nil {
+		return fmt.Errorf("%w: %v", errUsage, err)
+	}
+	if flags.NArg() != 0 {
+		return fmt.Errorf("%w: bench takes no arguments", errUsage)
+	}
+	if _, ok := evictionPolicies[cfg.eviction]; !ok {
+		return fmt.Errorf("%w: unknown eviction policy %q, policies: %s", errUsage, cfg.eviction, keys(evictionPolicies))
+	}
+
+	if cfg.log != "" {
+		switch *width {
+		case 1:
+			return benchLog[uint8](w, cfg)
+		case 2:
+			return benchLog[uint16](w, cfg)
+		case 4:
+			return benchLog[uint32](w, cfg)
+		case 8:
+			return benchLog[uint64](w, cfg)
+		default:
+			return fmt.Errorf("%w: invalid width %d", errUsage, *width)
+		}
+	}
+
+	generate, ok := workloads[cfg.workload]
+	if !ok {
+		return fmt.Errorf("%w: unknown workload %q, workloads: %s", errUsage, cfg.workload, keys(workloads))
+	}
+	if cfg.ops <= 0 || cfg.length <= 0 || cfg.block <= 0 {
+		return fmt.Errorf("%w: -ops, -length and -block must be positive", errUsage)
+	}
+	ops := generate(rand.New(rand.NewSource(cfg.seed)), cfg)
+	s := store.NewStore(options[byte](cfg)...)
+
+	var values int64
+	result := measure(func() error {
+		for _, op := range ops {
+			values += op.Length
+			switch op.Kind {
+			case storetest.OpSet:
+				if err := s.Set(make([]byte, op.Length), op.Offset); err != nil {
+					return err
+				}
+			case storetest.OpGet:
+				s.Get(make([]byte, op.Length), op.Offset)
+			case storetest.OpHas:
+				s.Has(op.Length, op.Offset)
+			case storetest.OpDelete:
+				s.Delete(op.Length, op.Offset)
+			}
+		}
+		return nil
+	})
+	if result.err != nil {
+		return result.err
+	}
+	return report(w, s, "workload "+cfg.workload, len(ops), values, result)
+}
+
+// benchLog replays the operation log of `cfg` against a store of values of
+// type T.
+func benchLog[T any](w io.Writer, cfg benchConfig) error {
+	data, err := os.ReadFile(cfg.log)
+	if err != nil {
+		return err
+	}
+	s := store.NewStore(options[T](cfg)...)
+	result := measure(func() error {
+		return store.Replay(bytes.NewReader(data), s)
+	})
+	if result.err != nil {
+		return result.err
+	}
+	return report(w, s, "log "+cfg.log, bytes.Count(data, []byte("\n")), 0, result)
+}
+
+// options returns the options of the store selected by the flags of `cfg`.
+func options[T any](cfg benchConfig) []store.Option[T] {
+	var opts []store.Option[T]
+	if cfg.minContiguous != 0 {
+		opts = append(opts, store.WithMinContiguous[T](cfg.minContiguous))
+	}
+	if cfg.maxContiguous != 0 {
+		opts = append(opts, store.WithMaxContiguous[T](cfg.maxContiguous))
+	}
+	if cfg.lazy != 0 {
+		opts = append(opts, store.WithLazyCompaction[T](cfg.lazy, 0))
+	}
+	if cfg.bitmap {
+		opts = append(opts, store.WithPresenceBitmap[T]())
+	}
+	if cfg.copyOnSet {
+		opts = append(opts, store.WithCopyOnSet[T]())
+	}
+	if cfg.maxOccupancy != 0 {
+		opts = append(opts, store.WithMaxOccupancy[T](cfg.maxOccupancy, evictionPolicies[cfg.eviction]()))
+	}
+	return opts
+}
+
+// benchResult holds the time and allocations of a benchmark run.
+type benchResult struct {
+	elapsed time.Duration
+	allocs  uint64
+	bytes   uint64
+	err     error
+}
+
+// measure runs `fn`, measuring its time and allocations.
+func measure(fn func() error) benchResult {
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+	err := fn()
+	elapsed := time.Since(start)
+	runtime.ReadMemStats(&after)
+	return benchResult{
+		elapsed: elapsed,
+		allocs:  after.Mallocs - before.Mallocs,
+		bytes:   after.TotalAlloc - before.TotalAlloc,
+		err:     err,
+	}
+}
+
+// report prints the throughput and allocations of `result`, for `ops`
+// operations reading or writing `values` values, if known, and the
+// fragmentation of `s` after them.
+func report[T any](w io.Writer, s *store.Store[T], name string, ops int, values int64, result benchResult) error {
+	extents := s.Extents()
+	merged := coalesce(extents)
+	seconds := result.elapsed.Seconds()
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "run\t%s\n", name)
+	fmt.Fprintf(tw, "ops\t%d in %v (%.0f ops/s)\n", ops, result.elapsed.Round(time.Microsecond), float64(ops)/seconds)
+	if values > 0 {
+		size := int64(unsafe.Sizeof(*new(T)))
+		fmt.Fprintf(tw, "throughput\t%.1f MB/s\n", float64(values*size)/seconds/1e6)
+	}
+	fmt.Fprintf(tw, "allocs\t%d (%.1f per op)\n", result.allocs, float64(result.allocs)/float64(max(ops, 1)))
+	fmt.Fprintf(tw, "allocated\t%d bytes (%.0f per op)\n", result.bytes, float64(result.bytes)/float64(max(ops, 1)))
+	fmt.Fprintln(tw)
+	fmt.Fprintf(tw, "length\t%d\n", s.Length())
+	fmt.Fprintf(tw, "occupancy\t%d (%s)\n", s.Occupancy(), percent(s.Occupancy(), s.Length()-s.Start()))
+	fmt.Fprintf(tw, "extents\t%d in %d runs\n", len(extents), len(merged))
+	if len(merged) > 0 {
+		fmt.Fprintf(tw, "mean run\t%d\n", s.Occupancy()/int64(len(merged)))
+	}
+	fmt.Fprintf(tw, "memory\t%d bytes\n", s.MemoryUsage().Total())
+	return tw.Flush()
+}
+
+// sequentialWorkload writes blocks front to back, wrapping around at the end,
+// and reads every fourth block back.
+func sequentialWorkload(r *rand.Rand, cfg benchConfig) []storetest.Op[byte] {
+	ops := make([]storetest.Op[byte], 0, cfg.ops)
+	var offset int64
+	for len(ops) < cfg.ops {
+		if offset+cfg.block > cfg.length {
+			offset = 0
+		}
+		n := min(cfg.block, cfg.length)
+		ops = append(ops, storetest.Op[byte]{Kind: storetest.OpSet, Offset: offset, Length: n})
+		if len(ops)%4 == 0 && len(ops) < cfg.ops {
+			ops = append(ops, storetest.Op[byte]{Kind: storetest.OpGet, Offset: offset, Length: n})
+		}
+		offset += n
+	}
+	return ops
+}
+
+// randomWorkload writes up to a block of values at random offsets, and reads a
+// quarter of the time.
+func randomWorkload(r *rand.Rand, cfg benchConfig) []storetest.Op[byte] {
+	ops := make([]storetest.Op[byte], cfg.ops)
+	for i := range ops {
+		n := 1 + r.Int63n(min(cfg.block, cfg.length))
+		op := storetest.Op[byte]{Kind: storetest.OpSet, Offset: r.Int63n(cfg.length - n + 1), Length: n}
+		if r.Intn(4) == 0 {
+			op.Kind = storetest.OpGet
+		}
+		ops[i] = op
+	}
+	return ops
+}
+
+// torrentPieceBlocks is the number of writes a piece of the torrent workload
+// arrives in, and torrentInFlight the number of pieces downloaded at once.
+const (
+	torrentPieceBlocks = 16
+	torrentInFlight    = 8
+)
+
+// torrentWorkload downloads pieces of a block in random order, several at a
+// time, each in a number of writes in order, and checks and reads back every
+// piece once complete. Once all pieces are downloaded, it starts over.
+func torrentWorkload(r *rand.Rand, cfg benchConfig) []storetest.Op[byte] {
+	pieces := (cfg.length + cfg.block - 1) / cfg.block
+	piece := func(i int64) (int64, int64) {
+		offset := i * cfg.block
+		return offset, min(cfg.block, cfg.length-offset)
+	}
+
+	type download struct {
+		piece, written int64
+	}
+	ops := make([]storetest.Op[byte], 0, cfg.ops)
+	var order []int64
+	var inFlight []download
+	for len(ops) < cfg.ops {
+		for len(inFlight) < torrentInFlight {
+			if len(order) == 0 {
+				order = make([]int64, pieces)
+				for i := range order {
+					order[i] = int64(i)
+				}
+				r.Shuffle(len(order), func(i, j int) { order[i], order[j] = order[j], order[i] })
+			}
+			inFlight = append(inFlight, download{piece: order[0]})
+			order = order[1:]
+		}
+
+		i := r.Intn(len(inFlight))
+		d := &inFlight[i]
+		offset, length := piece(d.piece)
+		n := min(max(length/torrentPieceBlocks, 1), length-d.written)
+		ops = append(ops, storetest.Op[byte]{Kind: storetest.OpSet, Offset: offset + d.written, Length: n})
+		if d.written += n; d.written == length {
+			ops = append(ops,
+				storetest.Op[byte]{Kind: storetest.OpHas, Offset: offset, Length: length},
+				storetest.Op[byte]{Kind: storetest.OpGet, Offset: offset, Length: length},
+			)
+			inFlight = append(inFlight[:i], inFlight[i+1:]...)
+		}
+	}
+	return ops[:cfg.ops]
+}
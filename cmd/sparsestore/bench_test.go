@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aertje/sparse-store/store"
+	"github.com/aertje/sparse-store/storetest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBenchWorkloads(t *testing.T) {
+	for name := range workloads {
+		t.Run(name, func(t *testing.T) {
+			var out bytes.Buffer
+			require.NoError(t, run([]string{"bench", "-workload", name, "-ops", "100", "-length", "1000", "-block", "64", "-lazy", "8"}, &out))
+			assert.Contains(t, out.String(), "run         workload "+name+"\nops         100 in ")
+			assert.Contains(t, out.String(), "\nthroughput  ")
+			assert.Contains(t, out.String(), "\nextents    ")
+		})
+	}
+}
+
+func TestBenchEviction(t *testing.T) {
+	for name := range evictionPolicies {
+		t.Run(name, func(t *testing.T) {
+			var out bytes.Buffer
+			require.NoError(t, run([]string{"bench", "-workload", "random", "-ops", "100", "-length", "1000", "-block", "64", "-max-occupancy", "256", "-eviction", name}, &out))
+			assert.Contains(t, out.String(), "\noccupancy  ")
+		})
+	}
+}
+
+func TestTorrentWorkload(t *testing.T) {
+	// Enough operations to download every piece, and check all of them,
+	// before running out in the middle of starting over.
+	cfg := benchConfig{ops: 1000, length: 1000, block: 64}
+	model := storetest.NewDenseModel[byte]()
+	var checked int64
+	for _, op := range torrentWorkload(rand.New(rand.NewSource(1)), cfg) {
+		switch op.Kind {
+		case storetest.OpSet:
+			model.Set(make([]byte, op.Length), op.Offset)
+		case storetest.OpHas:
+			assert.True(t, model.Has(op.Length, op.Offset), "piece at %d checked before it is complete", op.Offset)
+			checked += op.Length
+		}
+	}
+	assert.Equal(t, []store.Range{{Offset: 0, Length: 1000}}, model.Extents())
+	assert.GreaterOrEqual(t, checked, int64(1000))
+}
+
+func TestBenchLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log")
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	recorder := store.NewRecorder[uint16](f)
+	s := store.NewStore(store.WithRecorder(recorder))
+	require.NoError(t, s.Set([]uint16{1, 2, 3}, 0))
+	require.NoError(t, s.Set([]uint16{4}, 5))
+	s.Get(make([]uint16, 2), 0)
+	require.NoError(t, recorder.Err())
+	require.NoError(t, f.Close())
+
+	var out bytes.Buffer
+	require.NoError(t, run([]string{"bench", "-log", path, "-width", "2"}, &out))
+	assert.Contains(t, out.String(), "run        log "+path+"\nops        3 in ")
+	assert.NotContains(t, out.String(), "throughput")
+	assert.Contains(t, out.String(), "\noccupancy  4 (66.7%)\nextents    2 in 2 runs\n")
+}
+
+func TestBenchUsage(t *testing.T) {
+	assert.ErrorContains(t, run([]string{"bench", "-workload", "bursty"}, &bytes.Buffer{}), `unknown workload "bursty", workloads: random, sequential, torrent`)
+	assert.ErrorContains(t, run([]string{"bench", "-eviction", "mru"}, &bytes.Buffer{}), `unknown eviction policy "mru", policies: arc, lfu, lowest-offset, lru, oldest`)
+	assert.ErrorIs(t, run([]string{"bench", "-ops", "0"}, &bytes.Buffer{}), errUsage)
+	assert.ErrorIs(t, run([]string{"bench", "-log", "log", "-width", "3"}, &bytes.Buffer{}), errUsage)
+}
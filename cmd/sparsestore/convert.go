@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/aertje/sparse-store/store"
+)
+
+// format reads and writes byte stores in a file format.
+type format struct {
+	// read reads a store from `f`, which may be a pipe.
+	read func(f *os.File, opts convertOptions) (*store.Store[byte], error)
+	// write writes `s` to `f`, which may be a pipe.
+	write func(s *store.Store[byte], f *os.File, opts convertOptions) error
+}
+
+// convertOptions are the flags of convert that apply to some formats only.
+type convertOptions struct {
+	// entry is the name of the tar entry to read, or to write.
+	entry string
+	// clusterBits is the log2 of the cluster size of the qcow2 images written.
+	clusterBits int
+}
+
+var formats = map[string]format{
+	"raw":   {read: readRaw, write: writeRaw},
+	"store": {read: readStore, write: writeStore},
+	"tar":   {read: readTar, write: writeTar},
+	"qcow2": {read: readQcow2, write: writeQcow2},
+}
+
+// convert converts a byte store from one format to another.
+func convert(args []string, w io.Writer) error {
+	flags := flag.NewFlagSet("convert", flag.ContinueOnError)
+	from := flags.String("from", "", "format of the input, detected from its contents by default: "+formatNames())
+	to := flags.String("to", "store", "format of the output: "+formatNames())
+	var opts convertOptions
+	flags.StringVar(&opts.entry, "entry", "", "name of the tar entry to read, the first file by default, or to write, the input name by default")
+	flags.IntVar(&opts.clusterBits, "cluster-bits", 16, "log2 of the cluster size of the qcow2 images written, from 9 to 21")
+	if err := flags.Parse(args); err != nil {
+		return fmt.Errorf("%w: %v", errUsage, err)
+	}
+	if flags.NArg() != 2 {
+		return fmt.Errorf("%w: convert takes an input and an output, - for stdin or stdout", errUsage)
+	}
+	out, ok := formats[*to]
+	if !ok {
+		return fmt.Errorf("%w: unknown format %q", errUsage, *to)
+	}
+	if opts.clusterBits < 9 || opts.clusterBits > 21 {
+		return fmt.Errorf("%w: invalid cluster bits %d", errUsage, opts.clusterBits)
+	}
+
+	s, err := load(flags.Arg(0), *from, opts)
+	if err != nil {
+		return err
+	}
+
+	if opts.entry == "" && flags.Arg(0) != "-" {
+		opts.entry = flags.Arg(0)
+	}
+	name := flags.Arg(1)
+	if name == "-" {
+		if w, ok := w.(*os.File); ok {
+			return out.write(s, w, opts)
+		}
+		return errors.New("cannot write to stdout")
+	}
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err := out.write(s, f, opts); err != nil {
+		f.Close()
+		return fmt.Errorf("writing %s: %w", name, err)
+	}
+	return f.Close()
+}
+
+// load reads the byte store in the file at `name`, or stdin for -, in format
+// `from`, or the format detected if empty.
+func load(name, from string, opts convertOptions) (*store.Store[byte], error) {
+	in := os.Stdin
+	if name != "-" {
+		var err error
+		if in, err = os.Open(name); err != nil {
+			return nil, err
+		}
+		defer in.Close()
+	}
+
+	if from == "" {
+		var err error
+		if from, err = detect(in); err != nil {
+			return nil, err
+		}
+	}
+	src, ok := formats[from]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown format %q", errUsage, from)
+	}
+	s, err := src.read(in, opts)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", in.Name(), err)
+	}
+	return s, nil
+}
+
+// detect returns the format of `f` from its first bytes. Pipes cannot be
+// detected.
+func detect(f *os.File) (string, error) {
+	head := make([]byte, 512)
+	n, err := f.ReadAt(head, 0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("%w: cannot detect the format of %s, use -from", errUsage, f.Name())
+	}
+	head = head[:n]
+	switch {
+	case bytes.HasPrefix(head, []byte(storeMagic)):
+		return "store", nil
+	case bytes.HasPrefix(head, []byte(qcow2Magic)):
+		return "qcow2", nil
+	case len(head) == 512 && bytes.HasPrefix(head[257:], []byte("ustar")):
+		return "tar", nil
+	default:
+		return "raw", nil
+	}
+}
+
+// formatNames returns the names of the formats, sorted.
+func formatNames() string {
+	return keys(formats)
+}
+
+// readStore reads a store written by WriteTo.
+func readStore(f *os.File, _ convertOptions) (*store.Store[byte], error) {
+	s := store.NewStore[byte]()
+	if _, err := s.ReadFrom(bufio.NewReader(f)); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// writeStore writes `s` with WriteTo.
+func writeStore(s *store.Store[byte], f *os.File, _ convertOptions) error {
+	w := bufio.NewWriter(f)
+	if _, err := s.WriteTo(w); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// chunks calls `fn` with the values of every extent of `s`, in order, at most
+// `size` at a time.
+func chunks(s *store.Store[byte], size int64, fn func(p []byte, offset int64) error) error {
+	buf := make([]byte, size)
+	for _, e := range coalesce(s.Extents()) {
+		for offset := e.Offset; offset < e.End(); offset += size {
+			p := buf[:min(size, e.End()-offset)]
+			s.Get(p, offset)
+			if err := fn(p, offset); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// coalesce merges the adjacent ranges of `extents`.
+func coalesce(extents []store.Range) []store.Range {
+	var merged []store.Range
+	for _, r := range extents {
+		merged = appendRange(merged, r)
+	}
+	return merged
+}
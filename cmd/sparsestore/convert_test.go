@@ -0,0 +1,155 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aertje/sparse-store/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sample returns a byte store with values in two clusters of 4kiB, and a hole
+// at its end.
+func sample(t *testing.T) *store.Store[byte] {
+	s := store.NewStore[byte]()
+	require.NoError(t, s.Set(bytes.Repeat([]byte{1}, 4096), 0))
+	require.NoError(t, s.Set(bytes.Repeat([]byte{2}, 4096), 3*4096))
+	require.NoError(t, s.Set(nil, 6*4096))
+	return s
+}
+
+// loadStore reads the store file at `path`.
+func loadStore(t *testing.T, path string) *store.Store[byte] {
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	s, err := readStore(f, convertOptions{})
+	require.NoError(t, err)
+	return s
+}
+
+// assertSame asserts that `got` holds the values of `want`, and has its length.
+func assertSame(t *testing.T, want, got *store.Store[byte]) {
+	assert.Equal(t, want.Length(), got.Length())
+	assert.Equal(t, coalesce(want.Extents()), coalesce(got.Extents()))
+	for _, e := range want.Extents() {
+		wantValues, gotValues := make([]byte, e.Length), make([]byte, e.Length)
+		want.Get(wantValues, e.Offset)
+		got.Get(gotValues, e.Offset)
+		assert.Equal(t, wantValues, gotValues)
+	}
+}
+
+func TestConvertRoundTrip(t *testing.T) {
+	for _, format := range []string{"store", "tar", "qcow2"} {
+		t.Run(format, func(t *testing.T) {
+			dir := t.TempDir()
+			in := saveStore(t, sample(t))
+			converted := filepath.Join(dir, "converted")
+			back := filepath.Join(dir, "back")
+
+			require.NoError(t, run([]string{"convert", "-to", format, "-cluster-bits", "12", in, converted}, io.Discard))
+			require.NoError(t, run([]string{"convert", converted, back}, io.Discard))
+			assertSame(t, sample(t), loadStore(t, back))
+		})
+	}
+}
+
+func TestConvertRaw(t *testing.T) {
+	dir := t.TempDir()
+	in := saveStore(t, sample(t))
+	raw := filepath.Join(dir, "raw")
+	require.NoError(t, run([]string{"convert", "-to", "raw", in, raw}, io.Discard))
+
+	data, err := os.ReadFile(raw)
+	require.NoError(t, err)
+	want := make([]byte, 6*4096)
+	sample(t).Get(want[:4096], 0)
+	sample(t).Get(want[3*4096:4*4096], 3*4096)
+	assert.Equal(t, want, data)
+
+	// Whether the holes are reported depends on the file system, but the
+	// values and length are kept either way.
+	back := filepath.Join(dir, "back")
+	require.NoError(t, run([]string{"convert", "-from", "raw", raw, back}, io.Discard))
+	s := loadStore(t, back)
+	assert.Equal(t, int64(6*4096), s.Length())
+	got := make([]byte, 4096)
+	assert.True(t, s.Get(got, 3*4096))
+	assert.Equal(t, bytes.Repeat([]byte{2}, 4096), got)
+}
+
+func TestConvertTarReadable(t *testing.T) {
+	dir := t.TempDir()
+	in := saveStore(t, sample(t))
+	archive := filepath.Join(dir, "archive.tar")
+	require.NoError(t, run([]string{"convert", "-to", "tar", "-entry", "disk.img", in, archive}, io.Discard))
+
+	// archive/tar reads sparse entries with zeros for the holes.
+	f, err := os.Open(archive)
+	require.NoError(t, err)
+	defer f.Close()
+	tr := tar.NewReader(f)
+	hdr, err := tr.Next()
+	require.NoError(t, err)
+	assert.Equal(t, "disk.img", hdr.Name)
+	assert.Equal(t, int64(6*4096), hdr.Size)
+	data, err := io.ReadAll(tr)
+	require.NoError(t, err)
+	assert.Equal(t, bytes.Repeat([]byte{2}, 4096), data[3*4096:4*4096])
+	assert.Equal(t, make([]byte, 2*4096), data[4*4096:])
+	_, err = tr.Next()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestConvertTarEntry(t *testing.T) {
+	dir := t.TempDir()
+	archive := filepath.Join(dir, "archive.tar")
+	f, err := os.Create(archive)
+	require.NoError(t, err)
+	tw := tar.NewWriter(f)
+	for name, data := range map[string]string{"a": "first", "b": "second"} {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Size: int64(len(data)), Mode: 0o644}))
+		_, err = tw.Write([]byte(data))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, f.Close())
+
+	out := filepath.Join(dir, "b.store")
+	require.NoError(t, run([]string{"convert", "-entry", "b", archive, out}, io.Discard))
+	data := make([]byte, 6)
+	assert.True(t, loadStore(t, out).Get(data, 0))
+	assert.Equal(t, "second", string(data))
+
+	assert.ErrorContains(t, run([]string{"convert", "-entry", "c", archive, out}, io.Discard), `no entry "c"`)
+}
+
+func TestReadTarInvalidSize(t *testing.T) {
+	overflow := tarHeader("data", tar.TypeReg, 0)
+	overflow[124] = 0x80
+	for i := 125; i < 136; i++ {
+		overflow[i] = 0xff
+	}
+
+	for _, header := range [][]byte{
+		tarHeader("data", tar.TypeReg, -1000),
+		tarHeader("PaxHeaders.0/data", tar.TypeXHeader, -1000),
+		tarHeader("PaxHeaders.0/data", tar.TypeXHeader, 1<<40),
+		tarHeader("././@LongLink", tar.TypeGNULongName, 1<<30),
+		overflow,
+	} {
+		path := filepath.Join(t.TempDir(), "archive.tar")
+		require.NoError(t, os.WriteFile(path, append(header, make([]byte, 2*tarBlock)...), 0o644))
+		f, err := os.Open(path)
+		require.NoError(t, err)
+		_, err = readTar(f, convertOptions{})
+		assert.Error(t, err)
+		f.Close()
+	}
+}
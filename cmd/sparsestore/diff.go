@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/aertje/sparse-store/store"
+)
+
+// errDiffer is returned by diff when the stores differ, for an exit status of 1,
+// as diff(1) has.
+var errDiffer = errors.New("stores differ")
+
+// diff reports the ranges only one of two byte stores holds, and those both
+// hold with different values.
+func diff(args []string, w io.Writer) error {
+	flags := flag.NewFlagSet("diff", flag.ContinueOnError)
+	patch := flags.String("patch", "", "file to write the binary delta bringing the first store up to date with the second to, for store.Patch")
+	blockSize := flags.Int("block-size", 4096, "size of the blocks the delta reuses from the first store")
+	var opts convertOptions
+	flags.StringVar(&opts.entry, "entry", "", "name of the tar entries to read, the first files by default")
+	if err := flags.Parse(args); err != nil {
+		return fmt.Errorf("%w: %v", errUsage, err)
+	}
+	if flags.NArg() != 2 {
+		return fmt.Errorf("%w: diff takes two files", errUsage)
+	}
+	if *blockSize <= 0 {
+		return fmt.Errorf("%w: invalid block size %d", errUsage, *blockSize)
+	}
+
+	nameA, nameB := flags.Arg(0), flags.Arg(1)
+	a, err := load(nameA, "", opts)
+	if err != nil {
+		return err
+	}
+	b, err := load(nameB, "", opts)
+	if err != nil {
+		return err
+	}
+
+	if *patch != "" {
+		sig, err := store.Sign(a, *blockSize)
+		if err != nil {
+			return err
+		}
+		data, err := store.Diff(b, sig).MarshalBinary()
+		if err == nil {
+			err = os.WriteFile(*patch, data, 0o644)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	presentA, presentB := coalesce(a.Extents()), coalesce(b.Extents())
+	onlyA, onlyB := subtract(presentA, presentB), subtract(presentB, presentA)
+	mismatched := mismatches(a, b, intersect(presentA, presentB))
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	if a.Length() != b.Length() {
+		fmt.Fprintf(tw, "length\t%d in %s, %d in %s\n", a.Length(), nameA, b.Length(), nameB)
+	}
+	for _, section := range []struct {
+		title  string
+		ranges []store.Range
+	}{
+		{"only in " + nameA, onlyA},
+		{"only in " + nameB, onlyB},
+		{"different", mismatched},
+	} {
+		if len(section.ranges) == 0 {
+			continue
+		}
+		fmt.Fprintf(tw, "\n%s\n", section.title)
+		printRanges(tw, section.ranges)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if a.Length() != b.Length() || len(onlyA) > 0 || len(onlyB) > 0 || len(mismatched) > 0 {
+		return errDiffer
+	}
+	return nil
+}
+
+// mismatches returns the ranges, within `ranges`, where the values of `a` and
+// `b` differ.
+func mismatches(a, b *store.Store[byte], ranges []store.Range) []store.Range {
+	var mismatched []store.Range
+	bufA, bufB := make([]byte, rawChunk), make([]byte, rawChunk)
+	for _, r := range ranges {
+		for offset := r.Offset; offset < r.End(); offset += rawChunk {
+			n := min(rawChunk, r.End()-offset)
+			pa, pb := bufA[:n], bufB[:n]
+			a.Get(pa, offset)
+			b.Get(pb, offset)
+			if bytes.Equal(pa, pb) {
+				continue
+			}
+			for i := range pa {
+				if pa[i] != pb[i] {
+					mismatched = appendRange(mismatched, store.Range{Offset: offset + int64(i), Length: 1})
+				}
+			}
+		}
+	}
+	return mismatched
+}
+
+// subtract returns the parts of the sorted ranges `x` that are not in the
+// sorted ranges `y`.
+func subtract(x, y []store.Range) []store.Range {
+	var result []store.Range
+	j := 0
+	for _, r := range x {
+		offset := r.Offset
+		for ; j < len(y) && y[j].End() <= offset; j++ {
+		}
+		for k := j; k < len(y) && y[k].Offset < r.End(); k++ {
+			if y[k].Offset > offset {
+				result = append(result, store.Range{Offset: offset, Length: y[k].Offset - offset})
+			}
+			offset = max(offset, y[k].End())
+		}
+		if offset < r.End() {
+			result = append(result, store.Range{Offset: offset, Length: r.End() - offset})
+		}
+	}
+	return result
+}
+
+// intersect returns the parts of the sorted ranges `x` that are in the sorted
+// ranges `y`.
+func intersect(x, y []store.Range) []store.Range {
+	var result []store.Range
+	for i, j := 0, 0; i < len(x) && j < len(y); {
+		from, to := max(x[i].Offset, y[j].Offset), min(x[i].End(), y[j].End())
+		if from < to {
+			result = append(result, store.Range{Offset: from, Length: to - from})
+		}
+		if x[i].End() < y[j].End() {
+			i++
+		} else {
+			j++
+		}
+	}
+	return result
+}
+
+// appendRange appends `r` to the sorted `ranges`, merging it with the last one
+// if they are adjacent.
+func appendRange(ranges []store.Range, r store.Range) []store.Range {
+	if n := len(ranges); n > 0 && ranges[n-1].End() == r.Offset {
+		ranges[n-1].Length += r.Length
+		return ranges
+	}
+	return append(ranges, r)
+}
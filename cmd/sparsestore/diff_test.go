@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aertje/sparse-store/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiff(t *testing.T) {
+	a := store.NewStore[byte]()
+	require.NoError(t, a.Set(bytes.Repeat([]byte{1}, 100), 0))
+	require.NoError(t, a.Set(bytes.Repeat([]byte{2}, 50), 200))
+	b := store.NewStore[byte]()
+	changed := bytes.Repeat([]byte{1}, 100)
+	changed[10], changed[11], changed[40] = 9, 9, 9
+	require.NoError(t, b.Set(changed, 20))
+	require.NoError(t, b.Set(bytes.Repeat([]byte{3}, 20), 300))
+	pathA, pathB := saveStore(t, a), saveStore(t, b)
+
+	var out bytes.Buffer
+	patch := filepath.Join(t.TempDir(), "patch")
+	err := run([]string{"diff", "-patch", patch, "-block-size", "16", pathA, pathB}, &out)
+	assert.ErrorIs(t, err, errDiffer)
+	assert.Equal(t, "length  250 in "+pathA+", 320 in "+pathB+`
+
+only in `+pathA+`
+offset  end  length
+0       20   20
+200     250  50
+
+only in `+pathB+`
+offset  end  length
+100     120  20
+300     320  20
+
+different
+offset  end  length
+30      32   2
+60      61   1
+`, out.String())
+
+	// The patch brings a up to date with b.
+	data, err := os.ReadFile(patch)
+	require.NoError(t, err)
+	var delta store.Delta
+	require.NoError(t, delta.UnmarshalBinary(data))
+	require.NoError(t, store.Patch(a, &delta))
+	assert.NoError(t, run([]string{"diff", saveStore(t, a), pathB}, &out))
+}
@@ -0,0 +1,19 @@
+//go:build !(linux || darwin || freebsd)
+
+package main
+
+import (
+	"os"
+
+	"github.com/aertje/sparse-store/store"
+)
+
+// dataRanges returns the whole of `f` as holding data, and its size, as holes
+// are not reported on this system. It returns no ranges for pipes.
+func dataRanges(f *os.File) ([]store.Range, int64, error) {
+	info, err := f.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		return nil, 0, nil
+	}
+	return []store.Range{{Offset: 0, Length: info.Size()}}, info.Size(), nil
+}
@@ -0,0 +1,45 @@
+//go:build linux || darwin || freebsd
+
+package main
+
+import (
+	"errors"
+	"os"
+
+	"github.com/aertje/sparse-store/store"
+	"golang.org/x/sys/unix"
+)
+
+// dataRanges returns the ranges of `f` holding data, as reported by its file
+// system, and its size. It returns no ranges if `f` cannot report them, such as
+// a pipe.
+func dataRanges(f *os.File) ([]store.Range, int64, error) {
+	info, err := f.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		return nil, 0, nil
+	}
+	size := info.Size()
+
+	ranges := []store.Range{}
+	for offset := int64(0); offset < size; {
+		data, err := f.Seek(offset, unix.SEEK_DATA)
+		if errors.Is(err, unix.ENXIO) {
+			// No data past offset.
+			break
+		}
+		if err != nil {
+			if offset == 0 {
+				// The file system does not report holes.
+				return []store.Range{{Offset: 0, Length: size}}, size, nil
+			}
+			return nil, 0, err
+		}
+		hole, err := f.Seek(data, unix.SEEK_HOLE)
+		if err != nil {
+			return nil, 0, err
+		}
+		ranges = append(ranges, store.Range{Offset: data, Length: hole - data})
+		offset = hole
+	}
+	return ranges, size, nil
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/aertje/sparse-store/httprange"
+	"github.com/aertje/sparse-store/store"
+)
+
+// storeMagic starts the encoding of a store written by WriteTo, followed by
+// its version.
+const storeMagic = "SPST"
+
+// inspect prints the header, summary and extents of a store file, or of a
+// download state.
+func inspect(args []string, w io.Writer) error {
+	flags := flag.NewFlagSet("inspect", flag.ContinueOnError)
+	width := flags.Int("width", 1, "size in bytes of the values of the store: 1, 2, 4 or 8")
+	if err := flags.Parse(args); err != nil {
+		return fmt.Errorf("%w: %v", errUsage, err)
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("%w: inspect takes a file", errUsage)
+	}
+
+	f, err := os.Open(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	header, err := r.Peek(len(storeMagic) + 1)
+	if err != nil && len(header) == 0 {
+		return fmt.Errorf("reading %s: %w", f.Name(), err)
+	}
+	switch {
+	case bytes.HasPrefix(header, []byte(storeMagic)) && len(header) > len(storeMagic):
+		version := header[len(storeMagic)]
+		switch *width {
+		case 1:
+			return inspectStore[uint8](w, r, version)
+		case 2:
+			return inspectStore[uint16](w, r, version)
+		case 4:
+			return inspectStore[uint32](w, r, version)
+		case 8:
+			return inspectStore[uint64](w, r, version)
+		default:
+			return fmt.Errorf("%w: invalid width %d", errUsage, *width)
+		}
+	case bytes.HasPrefix(bytes.TrimLeft(header, " \t\r\n"), []byte("{")):
+		return inspectState(w, r)
+	default:
+		return fmt.Errorf("%s is neither a store nor a download state", f.Name())
+	}
+}
+
+// inspectStore prints the store of values of type T read from `r`.
+func inspectStore[T any](w io.Writer, r io.Reader, version byte) error {
+	s := store.NewStore[T]()
+	n, err := s.ReadFrom(r)
+	if err != nil {
+		return err
+	}
+
+	extents := s.Extents()
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "format\t%s version %d\n", storeMagic, version)
+	fmt.Fprintf(tw, "size\t%d bytes\n", n)
+	fmt.Fprintf(tw, "length\t%d\n", s.Length())
+	fmt.Fprintf(tw, "start\t%d\n", s.Start())
+	fmt.Fprintf(tw, "occupancy\t%d (%s)\n", s.Occupancy(), percent(s.Occupancy(), s.Length()-s.Start()))
+	fmt.Fprintf(tw, "extents\t%d\n", len(extents))
+	fmt.Fprintf(tw, "gaps\t%d\n", len(s.Gaps(s.Length()-s.Start(), s.Start())))
+	fmt.Fprintln(tw)
+	printRanges(tw, extents)
+	return tw.Flush()
+}
+
+// inspectState prints the download state read from `r`.
+func inspectState(w io.Writer, r io.Reader) error {
+	state, err := httprange.LoadState(r)
+	if err != nil {
+		return err
+	}
+
+	var present, pending int64
+	for _, r := range state.Present {
+		present += r.Length
+	}
+	for _, r := range state.Pending {
+		pending += r.Length
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "format\thttprange state\n")
+	fmt.Fprintf(tw, "url\t%s\n", state.URL)
+	fmt.Fprintf(tw, "size\t%d\n", state.Size)
+	if state.Validator != "" {
+		fmt.Fprintf(tw, "validator\t%s\n", state.Validator)
+	}
+	fmt.Fprintf(tw, "present\t%d in %d ranges (%s)\n", present, len(state.Present), percent(present, state.Size))
+	fmt.Fprintf(tw, "pending\t%d in %d requests\n", pending, len(state.Pending))
+	fmt.Fprintln(tw)
+	printRanges(tw, state.Present)
+	if len(state.Pending) > 0 {
+		fmt.Fprintln(tw)
+		fmt.Fprintln(tw, "pending")
+		printRanges(tw, state.Pending)
+	}
+	return tw.Flush()
+}
+
+// printRanges prints a table of `ranges`.
+func printRanges(w io.Writer, ranges []store.Range) {
+	fmt.Fprintln(w, "offset\tend\tlength")
+	for _, r := range ranges {
+		fmt.Fprintf(w, "%d\t%d\t%d\n", r.Offset, r.End(), r.Length)
+	}
+}
+
+// percent formats `n` as a percentage of `total`.
+func percent(n, total int64) string {
+	if total <= 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f%%", 100*float64(n)/float64(total))
+}
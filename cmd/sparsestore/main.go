@@ -0,0 +1,102 @@
+// Command sparsestore inspects stores persisted with WriteTo, and the states of
+// downloads saved by the httprange package, without writing Go.
+//
+// Usage:
+//
+//	sparsestore inspect [-width n] file
+//	sparsestore bench [-workload name | -log file [-width n]] [-ops n] [-length n] [-block n] [-seed n] [store flags]
+//	sparsestore convert [-from format] [-to format] [-entry name] [-cluster-bits n] input output
+//	sparsestore diff [-patch file] [-block-size n] [-entry name] a b
+//	sparsestore map [-width n] [-rows n] [-svg file] [-html file] [-from format] [-entry name] file
+//
+// Inspect prints the header, summary and extent table of a store of values of
+// `width` bytes, or the ranges present and pending of a download state.
+//
+// Convert converts a byte store between formats, keeping track of the values
+// missing from it: raw files, with holes where the file system reports them;
+// stores written by WriteTo; tar archives, with sparse entries in the PAX
+// formats of GNU tar; and qcow2 images, with unallocated clusters. The input
+// format is detected from its contents unless given. Either file may be - for
+// stdin or stdout, except qcow2 input, which needs random access.
+//
+// Diff reports the ranges only one of two byte stores, in any of the formats of
+// convert, holds, and those both hold with different values. It exits with
+// status 1 if there are any. With -patch, it writes the delta bringing the
+// first store up to date with the second, for store.Patch, in the encoding of
+// Delta.MarshalBinary.
+//
+// Bench runs a synthetic workload of byte writes and reads, or replays an
+// operation log written by a store.Recorder, against a store configured by its
+// flags, and reports the throughput, the allocations and the fragmentation of
+// the store after it, so that options can be tuned per workload. The workloads
+// are sequential, random and torrent, which downloads pieces in random order.
+// Allocations include the values written, and the time of a log includes
+// parsing it.
+//
+// Map renders which values of a byte store are present as a bar of text,
+// wrapped in rows, to eyeball download progress and fragmentation. With -svg or
+// -html, it writes the same grid of cells as a heatmap too.
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// command runs a subcommand with its arguments, writing its output to `w`.
+type command func(args []string, w io.Writer) error
+
+var commands = map[string]command{
+	"bench":   bench,
+	"convert": convert,
+	"diff":    diff,
+	"inspect": inspect,
+	"map":     occupancyMap,
+}
+
+// errUsage is returned for invalid arguments.
+var errUsage = errors.New("usage: sparsestore <command> [flags] [args]")
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		if errors.Is(err, errDiffer) {
+			os.Exit(1)
+		}
+		fmt.Fprintln(os.Stderr, "sparsestore:", err)
+		if errors.Is(err, errUsage) {
+			os.Exit(2)
+		}
+		os.Exit(1)
+	}
+}
+
+// run runs the subcommand named by the first of `args`.
+func run(args []string, w io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w; commands: %s", errUsage, names())
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		return fmt.Errorf("%w; unknown command %q, commands: %s", errUsage, args[0], names())
+	}
+	return cmd(args[1:], w)
+}
+
+// names returns the names of the subcommands, sorted.
+func names() string {
+	return keys(commands)
+}
+
+// keys returns the keys of `m`, sorted and separated by commas.
+func keys[V any](m map[string]V) string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
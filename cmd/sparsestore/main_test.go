@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aertje/sparse-store/httprange"
+	"github.com/aertje/sparse-store/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// saveStore writes `s` to a file in a temporary directory, and returns its
+// path.
+func saveStore[T any](t *testing.T, s *store.Store[T]) string {
+	path := filepath.Join(t.TempDir(), "store")
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	defer f.Close()
+	_, err = s.WriteTo(f)
+	require.NoError(t, err)
+	return path
+}
+
+func TestRunUsage(t *testing.T) {
+	assert.ErrorIs(t, run(nil, &bytes.Buffer{}), errUsage)
+	assert.ErrorContains(t, run([]string{"resize"}, &bytes.Buffer{}), `unknown command "resize", commands: bench, convert, diff, inspect, map`)
+	assert.ErrorIs(t, run([]string{"inspect"}, &bytes.Buffer{}), errUsage)
+}
+
+func TestInspectStore(t *testing.T) {
+	s := store.NewStore[byte]()
+	require.NoError(t, s.Set([]byte{1, 2, 3, 4}, 2))
+	require.NoError(t, s.Set([]byte{5, 6}, 10))
+	require.NoError(t, s.Set(nil, 20))
+	path := saveStore(t, s)
+
+	var out bytes.Buffer
+	require.NoError(t, run([]string{"inspect", path}, &out))
+	assert.Equal(t, `format     SPST version 1
+size       19 bytes
+length     20
+start      0
+occupancy  6 (30.0%)
+extents    2
+gaps       3
+
+offset  end  length
+2       6    4
+10      12   2
+`, out.String())
+}
+
+func TestInspectStoreWidth(t *testing.T) {
+	s := store.NewStore[uint32]()
+	require.NoError(t, s.Set([]uint32{1, 2}, 4))
+	path := saveStore(t, s)
+
+	var out bytes.Buffer
+	require.NoError(t, run([]string{"inspect", "-width", "4", path}, &out))
+	assert.Contains(t, out.String(), "occupancy  2 (33.3%)\n")
+	assert.Contains(t, out.String(), "4       6    2\n")
+
+	assert.ErrorIs(t, run([]string{"inspect", "-width", "3", path}, &out), errUsage)
+}
+
+func TestInspectState(t *testing.T) {
+	state := &httprange.State{
+		URL:     "https://example.com/image.iso",
+		Size:    100,
+		Present: []store.Range{{Offset: 0, Length: 40}},
+		Pending: []store.Range{{Offset: 40, Length: 30}, {Offset: 70, Length: 30}},
+	}
+	path := filepath.Join(t.TempDir(), "state.json")
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	require.NoError(t, state.Save(f))
+	require.NoError(t, f.Close())
+
+	var out bytes.Buffer
+	require.NoError(t, run([]string{"inspect", path}, &out))
+	assert.Contains(t, out.String(), "url      https://example.com/image.iso\n")
+	assert.Contains(t, out.String(), "present  40 in 1 ranges (40.0%)\n")
+	assert.Contains(t, out.String(), "pending  60 in 2 requests\n")
+}
+
+func TestInspectUnknown(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data")
+	require.NoError(t, os.WriteFile(path, []byte("plain data"), 0o644))
+	assert.ErrorContains(t, run([]string{"inspect", path}, &bytes.Buffer{}), "is neither a store nor a download state")
+}
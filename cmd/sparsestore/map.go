@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"html"
+	"io"
+	"math/bits"
+	"os"
+	"strings"
+
+	"github.com/aertje/sparse-store/store"
+)
+
+// mapCellSize is the size in pixels of the cells of the SVG maps.
+const mapCellSize = 12
+
+// occupancyMap renders which values of a byte store are present, as a bar of
+// text, and optionally as an SVG or HTML heatmap.
+func occupancyMap(args []string, w io.Writer) error {
+	flags := flag.NewFlagSet("map", flag.ContinueOnError)
+	width := flags.Int("width", 64, "number of cells per row")
+	rows := flags.Int("rows", 1, "number of rows")
+	svg := flags.String("svg", "", "file to write an SVG heatmap to")
+	page := flags.String("html", "", "file to write an HTML page with the heatmap to")
+	from := flags.String("from", "", "format of the input, detected from its contents by default: "+formatNames())
+	var opts convertOptions
+	flags.StringVar(&opts.entry, "entry", "", "name of the tar entry to read, the first file by default")
+	if err := flags.Parse(args); err != nil {
+		return fmt.Errorf("%w: %v", errUsage, err)
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("%w: map takes a file", errUsage)
+	}
+	if *width <= 0 || *rows <= 0 {
+		return fmt.Errorf("%w: invalid width %d or rows %d", errUsage, *width, *rows)
+	}
+
+	name := flags.Arg(0)
+	s, err := load(name, *from, opts)
+	if err != nil {
+		return err
+	}
+
+	bar := []rune(s.RenderMap(*width * *rows))
+	digits := len(fmt.Sprint(s.Length()))
+	for row := 0; row < *rows; row++ {
+		offset, _ := cellRange(s, row**width, *width**rows)
+		fmt.Fprintf(w, "%*d %s\n", digits, offset, string(bar[row**width:(row+1)**width]))
+	}
+	fmt.Fprintf(w, "%s of %d present in %d extents\n", percent(s.Occupancy(), s.Length()-s.Start()), s.Length()-s.Start(), len(coalesce(s.Extents())))
+
+	for _, out := range []struct {
+		path  string
+		write func(io.Writer, *store.Store[byte], string, int, int) error
+	}{{*svg, writeSVG}, {*page, writeHTML}} {
+		if out.path == "" {
+			continue
+		}
+		f, err := os.Create(out.path)
+		if err != nil {
+			return err
+		}
+		bw := bufio.NewWriter(f)
+		err = out.write(bw, s, name, *width, *rows)
+		if err == nil {
+			err = bw.Flush()
+		}
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cellRange returns the range of values covered by cell `i` out of `cells`,
+// the way RenderMap divides a store.
+func cellRange(s *store.Store[byte], i, cells int) (int64, int64) {
+	offset := func(i int) int64 {
+		hi, lo := bits.Mul64(uint64(s.Length()-s.Start()), uint64(i))
+		q, _ := bits.Div64(hi, lo, uint64(cells))
+		return s.Start() + int64(q)
+	}
+	from, to := offset(i), offset(i+1)
+	return from, max(to, from+1)
+}
+
+// writeSVG writes a heatmap of `s` as an SVG grid of `width` by `rows` cells,
+// shaded by the fraction of their values present, with the range and fraction
+// of every cell as its tooltip.
+func writeSVG(w io.Writer, s *store.Store[byte], name string, width, rows int) error {
+	fmt.Fprintf(w, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d">`+"\n", width*mapCellSize, rows*mapCellSize)
+	fmt.Fprintf(w, "<title>%s</title>\n", html.EscapeString(name))
+	for i := 0; i < width*rows; i++ {
+		from, to := cellRange(s, i, width*rows)
+		fraction := float64(s.Coverage(to-from, from)) / float64(to-from)
+		fmt.Fprintf(w, `<rect x="%d" y="%d" width="%d" height="%d" fill="%s"><title>%d-%d: %.1f%%</title></rect>`+"\n",
+			i%width*mapCellSize, i/width*mapCellSize, mapCellSize, mapCellSize, shade(fraction), from, to-1, 100*fraction)
+	}
+	_, err := io.WriteString(w, "</svg>\n")
+	return err
+}
+
+// writeHTML writes a page with a summary and the SVG heatmap of `s`.
+func writeHTML(w io.Writer, s *store.Store[byte], name string, width, rows int) error {
+	title := html.EscapeString(name)
+	fmt.Fprintf(w, "<!DOCTYPE html>\n<html>\n<head><meta charset=\"utf-8\"><title>%s</title></head>\n<body>\n", title)
+	fmt.Fprintf(w, "<h1>%s</h1>\n<p>%s of %d values present in %d extents.</p>\n", title, percent(s.Occupancy(), s.Length()-s.Start()), s.Length()-s.Start(), len(coalesce(s.Extents())))
+	var svg strings.Builder
+	writeSVG(&svg, s, name, width, rows)
+	io.WriteString(w, svg.String())
+	_, err := io.WriteString(w, "</body>\n</html>\n")
+	return err
+}
+
+// shade returns the color of a cell with `fraction` of its values present,
+// from light gray for none to green for all.
+func shade(fraction float64) string {
+	if fraction == 0 {
+		return "#eeeeee"
+	}
+	from, to := [3]float64{0xc6, 0xe9, 0xc1}, [3]float64{0x1a, 0x7f, 0x37}
+	var rgb [3]int
+	for i := range rgb {
+		rgb[i] = int(from[i] + (to[i]-from[i])*fraction)
+	}
+	return fmt.Sprintf("#%02x%02x%02x", rgb[0], rgb[1], rgb[2])
+}
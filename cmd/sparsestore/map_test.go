@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aertje/sparse-store/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMap(t *testing.T) {
+	s := store.NewStore[byte]()
+	require.NoError(t, s.Set(make([]byte, 4), 0))
+	require.NoError(t, s.Set(make([]byte, 2), 10))
+	require.NoError(t, s.Set(nil, 16))
+	path := saveStore(t, s)
+
+	var out bytes.Buffer
+	require.NoError(t, run([]string{"map", "-width", "4", "-rows", "2", path}, &out))
+	assert.Equal(t, " 0 ██··\n 8 ·█··\n37.5% of 16 present in 2 extents\n", out.String())
+
+	assert.ErrorIs(t, run([]string{"map", "-width", "0", path}, &bytes.Buffer{}), errUsage)
+}
+
+func TestMapHeatmap(t *testing.T) {
+	s := store.NewStore[byte]()
+	require.NoError(t, s.Set(make([]byte, 3), 0))
+	require.NoError(t, s.Set(nil, 8))
+	path := saveStore(t, s)
+
+	dir := t.TempDir()
+	svg, page := filepath.Join(dir, "map.svg"), filepath.Join(dir, "map.html")
+	require.NoError(t, run([]string{"map", "-width", "2", "-svg", svg, "-html", page, path}, &bytes.Buffer{}))
+
+	data, err := os.ReadFile(svg)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `<svg xmlns="http://www.w3.org/2000/svg" width="24" height="12">`)
+	assert.Contains(t, string(data), `fill="#459959"><title>0-3: 75.0%</title>`)
+	assert.Contains(t, string(data), `fill="#eeeeee"><title>4-7: 0.0%</title>`)
+
+	data, err = os.ReadFile(page)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "<p>37.5% of 8 values present in 1 extents.</p>")
+	assert.Contains(t, string(data), "<svg")
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/aertje/sparse-store/store"
+)
+
+// qcow2Magic starts a qcow2 image.
+const qcow2Magic = "QFI\xfb"
+
+// Bits of the L1 and L2 entries of a qcow2 image.
+const (
+	qcow2Copied     = 1 << 63
+	qcow2Compressed = 1 << 62
+	qcow2Zero       = 1
+	qcow2OffsetMask = 0x00fffffffffffe00
+)
+
+// qcow2Incompatible are the incompatible features of qcow2 version 3 images
+// that readQcow2 supports: the dirty and corrupt bits, and the compression
+// type, as compressed clusters are rejected anyway.
+const qcow2Incompatible = 1<<0 | 1<<1 | 1<<3
+
+// readQcow2 reads the clusters allocated in a qcow2 image, of version 2 or 3.
+// Unallocated clusters, including those that would be read from a backing file,
+// are missing from the store. Encrypted images, compressed clusters and
+// external data files are not supported.
+func readQcow2(f *os.File, _ convertOptions) (*store.Store[byte], error) {
+	header := make([]byte, 80)
+	if _, err := f.ReadAt(header, 0); err != nil {
+		return nil, err
+	}
+	be := binary.BigEndian
+	if string(header[:4]) != qcow2Magic {
+		return nil, errors.New("not a qcow2 image")
+	}
+	version := be.Uint32(header[4:])
+	if version != 2 && version != 3 {
+		return nil, fmt.Errorf("unsupported qcow2 version %d", version)
+	}
+	if version == 3 {
+		if features := be.Uint64(header[72:]); features&^qcow2Incompatible != 0 {
+			return nil, fmt.Errorf("unsupported qcow2 features %#x", features)
+		}
+	}
+	clusterBits := be.Uint32(header[20:])
+	size := int64(be.Uint64(header[24:]))
+	if be.Uint32(header[32:]) != 0 {
+		return nil, errors.New("encrypted qcow2 images are not supported")
+	}
+	if clusterBits < 9 || clusterBits > 21 || size < 0 {
+		return nil, errors.New("invalid qcow2 header")
+	}
+	clusterSize := int64(1) << clusterBits
+	perL2 := clusterSize / 8
+
+	l1 := make([]byte, 8*int64(be.Uint32(header[36:])))
+	if _, err := f.ReadAt(l1, int64(be.Uint64(header[40:]))); err != nil {
+		return nil, fmt.Errorf("reading L1 table: %w", err)
+	}
+	s := store.NewStore[byte]()
+	l2 := make([]byte, clusterSize)
+	for i := int64(0); i < int64(len(l1))/8; i++ {
+		l2Offset := int64(be.Uint64(l1[8*i:]) & qcow2OffsetMask)
+		if l2Offset == 0 {
+			continue
+		}
+		if _, err := f.ReadAt(l2, l2Offset); err != nil {
+			return nil, fmt.Errorf("reading L2 table: %w", err)
+		}
+		for j := int64(0); j < perL2; j++ {
+			offset := (i*perL2 + j) * clusterSize
+			if offset >= size {
+				break
+			}
+			n := min(clusterSize, size-offset)
+
+			entry := be.Uint64(l2[8*j:])
+			cluster := int64(entry & qcow2OffsetMask)
+			switch {
+			case entry&qcow2Compressed != 0:
+				return nil, errors.New("compressed qcow2 clusters are not supported")
+			case version == 3 && entry&qcow2Zero != 0:
+				if err := s.Fill(0, n, offset); err != nil {
+					return nil, err
+				}
+			case cluster != 0:
+				p := make([]byte, n)
+				if _, err := f.ReadAt(p, cluster); err != nil {
+					return nil, fmt.Errorf("reading cluster at %d: %w", cluster, err)
+				}
+				if err := s.SetOwned(p, offset); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return s, s.Set(nil, size)
+}
+
+// writeQcow2 writes `s` as a qcow2 version 2 image, allocating the clusters
+// holding values. Values missing from an allocated cluster read as zeros, so
+// the image only keeps the holes at the granularity of the cluster size.
+//
+// The image is laid out as the header, the L1 table, the refcount table and
+// blocks, the L2 tables and the data clusters, so that it can be written in
+// order.
+func writeQcow2(s *store.Store[byte], f *os.File, opts convertOptions) error {
+	clusterSize := int64(1) << opts.clusterBits
+	perL2 := clusterSize / 8
+	clustersOf := func(n int64) int64 {
+		return (n + clusterSize - 1) / clusterSize
+	}
+
+	// The clusters holding values, and the L2 tables mapping them, in order.
+	var data, tables []int64
+	for _, e := range coalesce(s.Extents()) {
+		for c := e.Offset / clusterSize; c <= (e.End()-1)/clusterSize; c++ {
+			if n := len(data); n == 0 || data[n-1] < c {
+				data = append(data, c)
+			}
+			if n := len(tables); n == 0 || tables[n-1] < c/perL2 {
+				tables = append(tables, c/perL2)
+			}
+		}
+	}
+
+	l1Size := (clustersOf(s.Length()) + perL2 - 1) / perL2
+	l1Clusters := max(clustersOf(8*l1Size), 1)
+
+	// The refcount blocks count the references to every cluster, themselves
+	// and the refcount table included, with 16 bits each.
+	fixed := 1 + l1Clusters + int64(len(tables)) + int64(len(data))
+	tableClusters, blocks := int64(1), int64(1)
+	for {
+		total := fixed + tableClusters + blocks
+		needBlocks := clustersOf(2 * total)
+		needTable := clustersOf(8 * needBlocks)
+		if needBlocks == blocks && needTable == tableClusters {
+			break
+		}
+		blocks, tableClusters = needBlocks, needTable
+	}
+	total := fixed + tableClusters + blocks
+
+	l1Offset := clusterSize
+	tableOffset := l1Offset + l1Clusters*clusterSize
+	blocksOffset := tableOffset + tableClusters*clusterSize
+	tablesOffset := blocksOffset + blocks*clusterSize
+	dataOffset := tablesOffset + int64(len(tables))*clusterSize
+
+	be := binary.BigEndian
+	w := bufio.NewWriter(f)
+	cluster := make([]byte, clusterSize)
+	writeCluster := func() {
+		w.Write(cluster)
+		clear(cluster)
+	}
+
+	copy(cluster, qcow2Magic)
+	be.PutUint32(cluster[4:], 2)
+	be.PutUint32(cluster[20:], uint32(opts.clusterBits))
+	be.PutUint64(cluster[24:], uint64(s.Length()))
+	be.PutUint32(cluster[36:], uint32(l1Size))
+	be.PutUint64(cluster[40:], uint64(l1Offset))
+	be.PutUint64(cluster[48:], uint64(tableOffset))
+	be.PutUint32(cluster[56:], uint32(tableClusters))
+	writeCluster()
+
+	l1 := make([]byte, l1Clusters*clusterSize)
+	for i, t := range tables {
+		be.PutUint64(l1[8*t:], uint64(tablesOffset+int64(i)*clusterSize)|qcow2Copied)
+	}
+	w.Write(l1)
+
+	refcounts := make([]byte, (tableClusters+blocks)*clusterSize)
+	for i := int64(0); i < blocks; i++ {
+		be.PutUint64(refcounts[8*i:], uint64(blocksOffset+i*clusterSize))
+	}
+	for i := int64(0); i < total; i++ {
+		be.PutUint16(refcounts[tableClusters*clusterSize+2*i:], 1)
+	}
+	w.Write(refcounts)
+
+	next := 0
+	for _, t := range tables {
+		for ; next < len(data) && data[next]/perL2 == t; next++ {
+			be.PutUint64(cluster[8*(data[next]%perL2):], uint64(dataOffset+int64(next)*clusterSize)|qcow2Copied)
+		}
+		writeCluster()
+	}
+
+	for _, c := range data {
+		s.Get(cluster[:min(clusterSize, s.Length()-c*clusterSize)], c*clusterSize)
+		writeCluster()
+	}
+	return w.Flush()
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"os"
+
+	"github.com/aertje/sparse-store/store"
+)
+
+// rawChunk is the number of bytes raw files are read and written in at a time.
+const rawChunk = 1 << 20
+
+// readRaw reads the data of a raw file, leaving out the holes its file system
+// reports. Pipes, and files on systems that do not report holes, are read as a
+// whole.
+func readRaw(f *os.File, _ convertOptions) (*store.Store[byte], error) {
+	s := store.NewStore[byte]()
+	ranges, size, err := dataRanges(f)
+	if err != nil {
+		return nil, err
+	}
+	if ranges == nil {
+		return readRawStream(s, f)
+	}
+
+	for _, r := range ranges {
+		for offset := r.Offset; offset < r.End(); offset += rawChunk {
+			p := make([]byte, min(rawChunk, r.End()-offset))
+			if _, err := f.ReadAt(p, offset); err != nil {
+				return nil, err
+			}
+			if err := s.SetOwned(p, offset); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return s, s.Set(nil, size)
+}
+
+// readRawStream reads `r` as a whole into `s`.
+func readRawStream(s *store.Store[byte], r io.Reader) (*store.Store[byte], error) {
+	for offset := int64(0); ; {
+		p := make([]byte, rawChunk)
+		n, err := io.ReadFull(r, p)
+		if n > 0 {
+			if err := s.SetOwned(p[:n], offset); err != nil {
+				return nil, err
+			}
+			offset += int64(n)
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return s, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// writeRaw writes the values of `s` at their offsets. Regular files are
+// truncated to the length of the store, leaving holes where it is missing
+// values; pipes get zeros instead.
+func writeRaw(s *store.Store[byte], f *os.File, _ convertOptions) error {
+	if info, err := f.Stat(); err != nil || !info.Mode().IsRegular() {
+		return writeRawStream(s, f)
+	}
+
+	if err := f.Truncate(0); err != nil {
+		return err
+	}
+	err := chunks(s, rawChunk, func(p []byte, offset int64) error {
+		_, err := f.WriteAt(p, offset)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return f.Truncate(s.Length())
+}
+
+// writeRawStream writes the values of `s` to `w`, with zeros for those missing.
+func writeRawStream(s *store.Store[byte], w io.Writer) error {
+	buf := make([]byte, rawChunk)
+	for offset := int64(0); offset < s.Length(); offset += rawChunk {
+		p := buf[:min(rawChunk, s.Length()-offset)]
+		clear(p)
+		s.Get(p, offset)
+		if _, err := w.Write(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
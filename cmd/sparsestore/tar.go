@@ -0,0 +1,354 @@
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/aertje/sparse-store/store"
+)
+
+// tarBlock is the size of the blocks of a tar archive.
+const tarBlock = 512
+
+// maxTarHeader is the maximum size of the data of a PAX or long name header,
+// which is read into memory, as archive/tar limits it.
+const maxTarHeader = 1 << 20
+
+// writeTar writes `s` as a tar archive holding a single sparse entry, in the
+// PAX 1.0 sparse format of GNU tar, so that the values missing from the store
+// are holes of the entry.
+func writeTar(s *store.Store[byte], f *os.File, opts convertOptions) error {
+	name := path.Base(opts.entry)
+	if opts.entry == "" {
+		name = "data"
+	}
+
+	// The entry starts with its sparse map, padded to a block: the number of
+	// extents, then the offset and length of each. An entry ending in a hole
+	// ends with an empty extent at its end.
+	extents := coalesce(s.Extents())
+	if n := len(extents); n == 0 || extents[n-1].End() < s.Length() {
+		extents = append(extents, store.Range{Offset: s.Length()})
+	}
+	var sparseMap []byte
+	sparseMap = append(strconv.AppendInt(sparseMap, int64(len(extents)), 10), '\n')
+	size := int64(0)
+	for _, e := range extents {
+		sparseMap = append(strconv.AppendInt(sparseMap, e.Offset, 10), '\n')
+		sparseMap = append(strconv.AppendInt(sparseMap, e.Length, 10), '\n')
+		size += e.Length
+	}
+	sparseMap = append(sparseMap, make([]byte, padding(int64(len(sparseMap))))...)
+
+	// archive/tar does not write sparse entries, so the headers are written
+	// here, the way GNU tar does: a PAX header describing the entry, then the
+	// entry itself, with a placeholder name.
+	w := bufio.NewWriter(f)
+	records := paxRecords(map[string]string{
+		"GNU.sparse.major":    "1",
+		"GNU.sparse.minor":    "0",
+		"GNU.sparse.name":     name,
+		"GNU.sparse.realsize": strconv.FormatInt(s.Length(), 10),
+	})
+	w.Write(tarHeader("PaxHeaders.0/"+name, tar.TypeXHeader, int64(len(records))))
+	w.Write(records)
+	w.Write(make([]byte, padding(int64(len(records)))))
+
+	size += int64(len(sparseMap))
+	w.Write(tarHeader("GNUSparseFile.0/"+name, tar.TypeReg, size))
+	w.Write(sparseMap)
+	err := chunks(s, rawChunk, func(p []byte, _ int64) error {
+		_, err := w.Write(p)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	// The archive ends with two zero blocks.
+	w.Write(make([]byte, padding(size)+2*tarBlock))
+	return w.Flush()
+}
+
+// paxRecords encodes `records` as the data of a PAX header, in a stable order.
+func paxRecords(records map[string]string) []byte {
+	var b []byte
+	for _, key := range []string{"GNU.sparse.major", "GNU.sparse.minor", "GNU.sparse.name", "GNU.sparse.realsize"} {
+		// Each record starts with its own length, digits included.
+		record := " " + key + "=" + records[key] + "\n"
+		n := len(record)
+		for n != len(strconv.Itoa(n))+len(record) {
+			n = len(strconv.Itoa(n)) + len(record)
+		}
+		b = append(b, strconv.Itoa(n)+record...)
+	}
+	return b
+}
+
+// tarHeader returns a USTAR header block for an entry of `size` bytes. Names
+// longer than the header holds are truncated, as the PAX header holds the
+// actual name.
+func tarHeader(name string, typeflag byte, size int64) []byte {
+	b := make([]byte, tarBlock)
+	copy(b[0:100], name)
+	copy(b[100:108], "0000644\x00")
+	copy(b[108:116], "0000000\x00")
+	copy(b[116:124], "0000000\x00")
+	if size < 1<<33 {
+		copy(b[124:136], fmt.Sprintf("%011o\x00", size))
+	} else {
+		// Sizes of 8GiB and more are in base 256, as GNU tar writes them.
+		binary.BigEndian.PutUint64(b[128:136], uint64(size))
+		b[124] = 0x80
+	}
+	copy(b[136:148], "00000000000\x00")
+	b[156] = typeflag
+	copy(b[257:265], "ustar\x0000")
+
+	// The checksum is computed with its own field as spaces.
+	copy(b[148:156], "        ")
+	var sum int64
+	for _, c := range b {
+		sum += int64(c)
+	}
+	copy(b[148:156], fmt.Sprintf("%06o\x00 ", sum))
+	return b
+}
+
+// padding returns the number of bytes padding `n` bytes to a block.
+func padding(n int64) int64 {
+	return -n & (tarBlock - 1)
+}
+
+// readTar reads an entry of a tar archive: the one named by the entry option,
+// or the first regular file. Entries in the PAX sparse formats of GNU tar keep
+// their holes, other entries are read as a whole.
+func readTar(f *os.File, opts convertOptions) (*store.Store[byte], error) {
+	r := bufio.NewReader(f)
+	var pax map[string]string
+	var longName string
+	block := make([]byte, tarBlock)
+	for {
+		if _, err := io.ReadFull(r, block); err != nil {
+			return nil, err
+		}
+		if bytes.Count(block, []byte{0}) == tarBlock {
+			if opts.entry != "" {
+				return nil, fmt.Errorf("no entry %q", opts.entry)
+			}
+			return nil, errors.New("no regular file")
+		}
+
+		name := cString(block[0:100])
+		if bytes.HasPrefix(block[257:], []byte("ustar")) {
+			if prefix := cString(block[345:500]); prefix != "" {
+				name = prefix + "/" + name
+			}
+		}
+		size, err := parseNumeric(block[124:136])
+		if err != nil {
+			return nil, err
+		}
+		if size < 0 {
+			return nil, fmt.Errorf("invalid size %d", size)
+		}
+
+		typeflag := block[156]
+		switch typeflag {
+		case tar.TypeXHeader, tar.TypeGNULongName:
+			if size > maxTarHeader {
+				return nil, fmt.Errorf("header of %d bytes is too large", size)
+			}
+			data := make([]byte, size+padding(size))
+			if _, err := io.ReadFull(r, data); err != nil {
+				return nil, err
+			}
+			if typeflag == tar.TypeGNULongName {
+				longName = cString(data[:size])
+			} else if pax, err = parsePAX(data[:size]); err != nil {
+				return nil, err
+			}
+			continue
+		case tar.TypeGNUSparse:
+			return nil, errors.New("old GNU sparse entries are not supported")
+		}
+
+		if longName != "" {
+			name = longName
+		}
+		if v, ok := pax["path"]; ok {
+			name = v
+		}
+		if v, ok := pax["GNU.sparse.name"]; ok {
+			name = v
+		}
+		if v, ok := pax["size"]; ok {
+			if size, err = strconv.ParseInt(v, 10, 64); err != nil || size < 0 {
+				return nil, fmt.Errorf("invalid size %q", v)
+			}
+		}
+
+		entry := io.LimitReader(r, size)
+		if (typeflag == tar.TypeReg || typeflag == tar.TypeRegA) && (opts.entry == "" || strings.TrimPrefix(name, "./") == strings.TrimPrefix(opts.entry, "./")) {
+			return readTarEntry(entry, pax)
+		}
+		if _, err := io.CopyN(io.Discard, r, size+padding(size)); err != nil {
+			return nil, err
+		}
+		pax, longName = nil, ""
+	}
+}
+
+// readTarEntry reads the data of an entry with PAX records `pax` from `r`.
+func readTarEntry(r io.Reader, pax map[string]string) (*store.Store[byte], error) {
+	s := store.NewStore[byte]()
+	var extents []store.Range
+	var length int64
+	switch {
+	case pax["GNU.sparse.major"] == "1" && pax["GNU.sparse.minor"] == "0":
+		var n int
+		var err error
+		if extents, n, err = readSparseMap(r); err != nil {
+			return nil, err
+		}
+		if _, err := io.CopyN(io.Discard, r, padding(int64(n))); err != nil {
+			return nil, err
+		}
+		length, err = strconv.ParseInt(pax["GNU.sparse.realsize"], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid real size %q", pax["GNU.sparse.realsize"])
+		}
+	case pax["GNU.sparse.map"] != "":
+		fields := strings.Split(pax["GNU.sparse.map"], ",")
+		if len(fields)%2 != 0 {
+			return nil, errors.New("invalid sparse map")
+		}
+		for i := 0; i < len(fields); i += 2 {
+			offset, err1 := strconv.ParseInt(fields[i], 10, 64)
+			n, err2 := strconv.ParseInt(fields[i+1], 10, 64)
+			if err1 != nil || err2 != nil {
+				return nil, errors.New("invalid sparse map")
+			}
+			extents = append(extents, store.Range{Offset: offset, Length: n})
+		}
+		var err error
+		length, err = strconv.ParseInt(pax["GNU.sparse.size"], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid size %q", pax["GNU.sparse.size"])
+		}
+	default:
+		return readRawStream(s, r)
+	}
+
+	for _, e := range extents {
+		for offset := e.Offset; offset < e.End(); offset += rawChunk {
+			p := make([]byte, min(rawChunk, e.End()-offset))
+			if _, err := io.ReadFull(r, p); err != nil {
+				return nil, err
+			}
+			if err := s.SetOwned(p, offset); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return s, s.Set(nil, length)
+}
+
+// readSparseMap reads a sparse map in the PAX 1.0 format from `r`, and returns
+// it with the number of bytes read.
+func readSparseMap(r io.Reader) ([]store.Range, int, error) {
+	read := 0
+	next := func() (int64, error) {
+		var digits []byte
+		b := make([]byte, 1)
+		for {
+			if _, err := io.ReadFull(r, b); err != nil {
+				return 0, err
+			}
+			read++
+			if b[0] == '\n' {
+				break
+			}
+			digits = append(digits, b[0])
+		}
+		return strconv.ParseInt(string(digits), 10, 64)
+	}
+
+	count, err := next()
+	if err != nil {
+		return nil, read, fmt.Errorf("invalid sparse map: %w", err)
+	}
+	var extents []store.Range
+	for i := int64(0); i < count; i++ {
+		offset, err1 := next()
+		n, err2 := next()
+		if err := errors.Join(err1, err2); err != nil {
+			return nil, read, fmt.Errorf("invalid sparse map: %w", err)
+		}
+		extents = append(extents, store.Range{Offset: offset, Length: n})
+	}
+	return extents, read, nil
+}
+
+// parsePAX parses the records of a PAX header.
+func parsePAX(data []byte) (map[string]string, error) {
+	records := map[string]string{}
+	for len(data) > 0 {
+		length, rest, ok := bytes.Cut(data, []byte(" "))
+		n, err := strconv.Atoi(string(length))
+		if !ok || err != nil || n <= len(length) || n > len(data) {
+			return nil, errors.New("invalid PAX record")
+		}
+		key, value, ok := strings.Cut(string(rest[:n-len(length)-1]), "=")
+		if !ok || !strings.HasSuffix(value, "\n") {
+			return nil, errors.New("invalid PAX record")
+		}
+		records[key] = strings.TrimSuffix(value, "\n")
+		data = data[n:]
+	}
+	return records, nil
+}
+
+// parseNumeric parses a numeric field of a tar header, in octal or, as GNU tar
+// writes large values, in base 256.
+func parseNumeric(b []byte) (int64, error) {
+	if len(b) > 0 && b[0]&0x80 != 0 {
+		var n int64
+		for i, c := range b {
+			if i == 0 {
+				c &= 0x7f
+			}
+			if n > math.MaxInt64>>8 {
+				return 0, errors.New("numeric field overflows")
+			}
+			n = n<<8 | int64(c)
+		}
+		return n, nil
+	}
+	s := strings.Trim(string(b), " \x00")
+	if s == "" {
+		return 0, nil
+	}
+	n, err := strconv.ParseInt(s, 8, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid numeric field %q", s)
+	}
+	return n, nil
+}
+
+// cString returns the string in `b` up to its first NUL.
+func cString(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	return string(b)
+}
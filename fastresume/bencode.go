@@ -0,0 +1,134 @@
+package fastresume
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// maxDepth is the nesting depth of lists and dictionaries parseBencode accepts.
+const maxDepth = 32
+
+// appendBencode appends the bencoding of `v`, an int64, string, []byte, []any
+// or map[string]any, to `b`.
+func appendBencode(b []byte, v any) []byte {
+	switch v := v.(type) {
+	case int64:
+		b = append(b, 'i')
+		b = strconv.AppendInt(b, v, 10)
+		return append(b, 'e')
+	case string:
+		b = strconv.AppendInt(b, int64(len(v)), 10)
+		b = append(b, ':')
+		return append(b, v...)
+	case []byte:
+		return appendBencode(b, string(v))
+	case []any:
+		b = append(b, 'l')
+		for _, item := range v {
+			b = appendBencode(b, item)
+		}
+		return append(b, 'e')
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		// Keys are sorted as raw strings.
+		sort.Strings(keys)
+		b = append(b, 'd')
+		for _, key := range keys {
+			b = appendBencode(b, key)
+			b = appendBencode(b, v[key])
+		}
+		return append(b, 'e')
+	default:
+		panic(fmt.Sprintf("fastresume: cannot bencode %T", v))
+	}
+}
+
+// parseBencode parses the bencoded value at the start of `data`, into an int64,
+// string, []any or map[string]any, and returns it with the rest of `data`.
+func parseBencode(data []byte) (any, []byte, error) {
+	return parseValue(data, 0)
+}
+
+func parseValue(data []byte, depth int) (any, []byte, error) {
+	if len(data) == 0 {
+		return nil, nil, fmt.Errorf("%w: truncated", ErrFormat)
+	}
+	if depth > maxDepth {
+		return nil, nil, fmt.Errorf("%w: nested too deeply", ErrFormat)
+	}
+
+	switch c := data[0]; {
+	case c == 'i':
+		end := indexByte(data, 'e')
+		if end < 0 {
+			return nil, nil, fmt.Errorf("%w: truncated integer", ErrFormat)
+		}
+		n, err := strconv.ParseInt(string(data[1:end]), 10, 64)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: invalid integer %q", ErrFormat, data[1:end])
+		}
+		return n, data[end+1:], nil
+	case c >= '0' && c <= '9':
+		colon := indexByte(data, ':')
+		if colon < 0 {
+			return nil, nil, fmt.Errorf("%w: truncated string", ErrFormat)
+		}
+		n, err := strconv.ParseInt(string(data[:colon]), 10, 64)
+		if err != nil || n > int64(len(data)-colon-1) {
+			return nil, nil, fmt.Errorf("%w: invalid string length %q", ErrFormat, data[:colon])
+		}
+		end := colon + 1 + int(n)
+		return string(data[colon+1 : end]), data[end:], nil
+	case c == 'l':
+		list := []any{}
+		data = data[1:]
+		for len(data) > 0 && data[0] != 'e' {
+			var item any
+			var err error
+			if item, data, err = parseValue(data, depth+1); err != nil {
+				return nil, nil, err
+			}
+			list = append(list, item)
+		}
+		if len(data) == 0 {
+			return nil, nil, fmt.Errorf("%w: truncated list", ErrFormat)
+		}
+		return list, data[1:], nil
+	case c == 'd':
+		dict := map[string]any{}
+		data = data[1:]
+		for len(data) > 0 && data[0] != 'e' {
+			key, rest, err := parseValue(data, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+			k, ok := key.(string)
+			if !ok {
+				return nil, nil, fmt.Errorf("%w: dictionary key is not a string", ErrFormat)
+			}
+			if dict[k], data, err = parseValue(rest, depth+1); err != nil {
+				return nil, nil, err
+			}
+		}
+		if len(data) == 0 {
+			return nil, nil, fmt.Errorf("%w: truncated dictionary", ErrFormat)
+		}
+		return dict, data[1:], nil
+	default:
+		return nil, nil, fmt.Errorf("%w: unexpected %q", ErrFormat, c)
+	}
+}
+
+// indexByte returns the index of the first `c` in `data`, or -1.
+func indexByte(data []byte, c byte) int {
+	for i, b := range data {
+		if b == c {
+			return i
+		}
+	}
+	return -1
+}
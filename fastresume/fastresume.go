@@ -0,0 +1,262 @@
+// Package fastresume exports and imports which pieces of a torrent a byte store
+// holds in the layout of libtorrent fastresume files, so that a download can
+// be handed off between a store and existing torrent tooling.
+//
+// A fastresume file is a bencoded dictionary. The "pieces" string holds a byte
+// per piece, with the lowest bit set for pieces held completely, and the
+// "unfinished" list holds the pieces held partly, each with a bitmask of its
+// blocks, most significant bit first. Libtorrent reads the piece size from the
+// torrent itself, so it is written as "piece length" as well, which libtorrent
+// ignores, so that the file can be imported on its own.
+package fastresume
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/aertje/sparse-store/store"
+)
+
+// ErrFormat is returned for data that is not a valid fastresume file.
+var ErrFormat = errors.New("invalid fastresume file")
+
+// DefaultBlockSize is the size of the blocks of unfinished pieces libtorrent
+// uses.
+const DefaultBlockSize = 16 << 10
+
+// fileFormat is the value of the "file-format" key of fastresume files.
+const fileFormat = "libtorrent resume file"
+
+// Resume is the piece presence of a torrent of `Length` bytes.
+type Resume struct {
+	// InfoHash is the SHA-1 info hash of the torrent, if known.
+	InfoHash  []byte
+	Length    int64
+	PieceSize int64
+	// BlockSize is the size of the blocks of unfinished pieces,
+	// DefaultBlockSize if zero.
+	BlockSize int64
+	// Pieces holds whether every piece is held completely.
+	Pieces []bool
+	// Unfinished holds, for the pieces held partly, whether each of their
+	// blocks is held.
+	Unfinished map[int][]bool
+}
+
+// FromStore returns the pieces of `pieceSize` bytes of a torrent of `length`
+// bytes that `s` holds, and the blocks of DefaultBlockSize bytes of those it
+// holds partly. Pieces are only checked for presence, not verified.
+func FromStore(s *store.Store[byte], length, pieceSize int64) (*Resume, error) {
+	r := &Resume{Length: length, PieceSize: pieceSize, BlockSize: DefaultBlockSize}
+	if err := r.check(); err != nil {
+		return nil, err
+	}
+
+	r.Pieces = make([]bool, r.numPieces())
+	r.Unfinished = map[int][]bool{}
+	for i := range r.Pieces {
+		piece := r.piece(i)
+		if r.Pieces[i] = s.Has(piece.Length, piece.Offset); r.Pieces[i] || s.Coverage(piece.Length, piece.Offset) == 0 {
+			continue
+		}
+
+		blocks := make([]bool, r.blocksPerPiece())
+		held := false
+		for j := range blocks {
+			if block, ok := r.block(i, j); ok {
+				blocks[j] = s.Has(block.Length, block.Offset)
+				held = held || blocks[j]
+			}
+		}
+		if held {
+			r.Unfinished[i] = blocks
+		}
+	}
+	return r, nil
+}
+
+// Ranges returns the ranges of the pieces and blocks held, in order and
+// coalesced.
+func (r *Resume) Ranges() []store.Range {
+	var ranges []store.Range
+	add := func(rng store.Range) {
+		if n := len(ranges); n > 0 && ranges[n-1].End() == rng.Offset {
+			ranges[n-1].Length += rng.Length
+			return
+		}
+		ranges = append(ranges, rng)
+	}
+
+	for i, have := range r.Pieces {
+		if have {
+			add(r.piece(i))
+			continue
+		}
+		for j, held := range r.Unfinished[i] {
+			if block, ok := r.block(i, j); ok && held {
+				add(block)
+			}
+		}
+	}
+	return ranges
+}
+
+// Fill sets the ranges held, read from `data`, such as the files of the
+// torrent, in `s`.
+func (r *Resume) Fill(s *store.Store[byte], data io.ReaderAt) error {
+	for _, rng := range r.Ranges() {
+		p := make([]byte, rng.Length)
+		if n, err := data.ReadAt(p, rng.Offset); n < len(p) {
+			return fmt.Errorf("reading bytes %d-%d: %w", rng.Offset, rng.End()-1, err)
+		}
+		if err := s.SetOwned(p, rng.Offset); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MarshalBinary encodes the presence as a bencoded fastresume file.
+func (r *Resume) MarshalBinary() ([]byte, error) {
+	if err := r.check(); err != nil {
+		return nil, err
+	}
+	if len(r.Pieces) != r.numPieces() {
+		return nil, fmt.Errorf("expected %d pieces, got %d", r.numPieces(), len(r.Pieces))
+	}
+
+	pieces := make([]byte, len(r.Pieces))
+	for i, have := range r.Pieces {
+		if have {
+			pieces[i] = 1
+		}
+	}
+	unfinished := []any{}
+	for i := range r.Pieces {
+		blocks, ok := r.Unfinished[i]
+		if !ok {
+			continue
+		}
+		bitmask := make([]byte, (len(blocks)+7)/8)
+		for j, held := range blocks {
+			if held {
+				bitmask[j/8] |= 0x80 >> (j % 8)
+			}
+		}
+		unfinished = append(unfinished, map[string]any{"piece": int64(i), "bitmask": bitmask})
+	}
+
+	dict := map[string]any{
+		"file-format":      fileFormat,
+		"file-version":     int64(1),
+		"pieces":           pieces,
+		"unfinished":       unfinished,
+		"blocks per piece": int64(r.blocksPerPiece()),
+		"piece length":     r.PieceSize,
+		"total length":     r.Length,
+	}
+	if r.InfoHash != nil {
+		dict["info-hash"] = r.InfoHash
+	}
+	return appendBencode(nil, dict), nil
+}
+
+// UnmarshalBinary decodes a fastresume file encoded by MarshalBinary, or by
+// libtorrent with the piece length and total length set beforehand.
+func (r *Resume) UnmarshalBinary(data []byte) error {
+	v, rest, err := parseBencode(data)
+	if err != nil {
+		return err
+	}
+	dict, ok := v.(map[string]any)
+	if !ok || len(rest) > 0 {
+		return fmt.Errorf("%w: not a dictionary", ErrFormat)
+	}
+	if format, _ := dict["file-format"].(string); format != fileFormat {
+		return fmt.Errorf("%w: file-format %q", ErrFormat, format)
+	}
+
+	resume := Resume{Length: r.Length, PieceSize: r.PieceSize, BlockSize: r.BlockSize}
+	if n, ok := dict["piece length"].(int64); ok {
+		resume.PieceSize = n
+	}
+	if n, ok := dict["total length"].(int64); ok {
+		resume.Length = n
+	}
+	if hash, ok := dict["info-hash"].(string); ok {
+		resume.InfoHash = []byte(hash)
+	}
+	if err := resume.check(); err != nil {
+		return err
+	}
+	if n, ok := dict["blocks per piece"].(int64); ok && n <= 0 {
+		return fmt.Errorf("%w: blocks per piece %d", ErrFormat, n)
+	} else if ok && n != int64(resume.blocksPerPiece()) {
+		resume.BlockSize = (resume.PieceSize + n - 1) / n
+	}
+
+	pieces, _ := dict["pieces"].(string)
+	if len(pieces) != resume.numPieces() {
+		return fmt.Errorf("%w: expected %d pieces, got %d", ErrFormat, resume.numPieces(), len(pieces))
+	}
+	resume.Pieces = make([]bool, len(pieces))
+	for i := range pieces {
+		resume.Pieces[i] = pieces[i]&1 != 0
+	}
+
+	resume.Unfinished = map[int][]bool{}
+	unfinished, _ := dict["unfinished"].([]any)
+	for _, u := range unfinished {
+		u, _ := u.(map[string]any)
+		piece, ok1 := u["piece"].(int64)
+		bitmask, ok2 := u["bitmask"].(string)
+		if !ok1 || !ok2 || piece < 0 || piece >= int64(len(pieces)) {
+			return fmt.Errorf("%w: invalid unfinished piece", ErrFormat)
+		}
+		blocks := make([]bool, resume.blocksPerPiece())
+		for j := range blocks {
+			blocks[j] = j/8 < len(bitmask) && bitmask[j/8]&(0x80>>(j%8)) != 0
+		}
+		resume.Unfinished[int(piece)] = blocks
+	}
+
+	*r = resume
+	return nil
+}
+
+// check checks the sizes, and sets the default block size.
+func (r *Resume) check() error {
+	if r.BlockSize == 0 {
+		r.BlockSize = DefaultBlockSize
+	}
+	if r.PieceSize <= 0 || r.BlockSize <= 0 || r.Length < 0 {
+		return fmt.Errorf("invalid length %d, piece size %d or block size %d", r.Length, r.PieceSize, r.BlockSize)
+	}
+	return nil
+}
+
+func (r *Resume) numPieces() int {
+	return int((r.Length + r.PieceSize - 1) / r.PieceSize)
+}
+
+func (r *Resume) blocksPerPiece() int {
+	return int((r.PieceSize + r.BlockSize - 1) / r.BlockSize)
+}
+
+// piece returns the range of piece `i`. Only the last piece may be shorter.
+func (r *Resume) piece(i int) store.Range {
+	offset := int64(i) * r.PieceSize
+	return store.Range{Offset: offset, Length: min(r.PieceSize, r.Length-offset)}
+}
+
+// block returns the range of block `j` of piece `i`, if the piece is long
+// enough to have it.
+func (r *Resume) block(i, j int) (store.Range, bool) {
+	piece := r.piece(i)
+	offset := piece.Offset + int64(j)*r.BlockSize
+	if offset >= piece.End() {
+		return store.Range{}, false
+	}
+	return store.Range{Offset: offset, Length: min(r.BlockSize, piece.End()-offset)}, true
+}
@@ -0,0 +1,99 @@
+package fastresume_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/aertje/sparse-store/fastresume"
+	"github.com/aertje/sparse-store/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const pieceSize = 4 * fastresume.DefaultBlockSize
+
+func TestFromStore(t *testing.T) {
+	length := int64(3*pieceSize + 100)
+	s := store.NewStore[byte]()
+	// Piece 0 completely, blocks 1 and 3 of piece 2, and the last piece.
+	require.NoError(t, s.Set(make([]byte, pieceSize), 0))
+	require.NoError(t, s.Set(make([]byte, fastresume.DefaultBlockSize), 2*pieceSize+fastresume.DefaultBlockSize))
+	require.NoError(t, s.Set(make([]byte, fastresume.DefaultBlockSize), 2*pieceSize+3*fastresume.DefaultBlockSize))
+	require.NoError(t, s.Set(make([]byte, 100), 3*pieceSize))
+	// Part of a block of piece 1 holds no block.
+	require.NoError(t, s.Set(make([]byte, 10), pieceSize))
+
+	r, err := fastresume.FromStore(s, length, pieceSize)
+	require.NoError(t, err)
+	assert.Equal(t, []bool{true, false, false, true}, r.Pieces)
+	assert.Equal(t, map[int][]bool{2: {false, true, false, true}}, r.Unfinished)
+
+	assert.Equal(t, []store.Range{
+		{Offset: 0, Length: pieceSize},
+		{Offset: 2*pieceSize + fastresume.DefaultBlockSize, Length: fastresume.DefaultBlockSize},
+		{Offset: 2*pieceSize + 3*fastresume.DefaultBlockSize, Length: fastresume.DefaultBlockSize + 100},
+	}, r.Ranges())
+
+	_, err = fastresume.FromStore(s, length, 0)
+	assert.Error(t, err)
+}
+
+func TestMarshal(t *testing.T) {
+	r := &fastresume.Resume{
+		InfoHash:   bytes.Repeat([]byte{0xab}, 20),
+		Length:     2*pieceSize + 1,
+		PieceSize:  pieceSize,
+		Pieces:     []bool{true, false, false},
+		Unfinished: map[int][]bool{1: {true, false, false, true}},
+	}
+	data, err := r.MarshalBinary()
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "11:file-format22:libtorrent resume file12:file-versioni1e")
+	assert.Contains(t, string(data), "6:pieces3:\x01\x00\x00")
+	assert.Contains(t, string(data), "10:unfinishedld7:bitmask1:\x905:piecei1eee")
+	assert.Contains(t, string(data), "16:blocks per piecei4e")
+
+	var decoded fastresume.Resume
+	require.NoError(t, decoded.UnmarshalBinary(data))
+	assert.Equal(t, r, &decoded)
+
+	r.Pieces = r.Pieces[:2]
+	_, err = r.MarshalBinary()
+	assert.Error(t, err)
+}
+
+func TestUnmarshalLibtorrent(t *testing.T) {
+	// Without the piece and total length, which libtorrent does not write.
+	data := []byte("d11:file-format22:libtorrent resume file12:file-versioni1e" +
+		"6:pieces2:\x01\x0016:blocks per piecei2e10:unfinishedld7:bitmask1:\x405:piecei1eeee")
+
+	var r fastresume.Resume
+	assert.Error(t, r.UnmarshalBinary(data))
+
+	r = fastresume.Resume{Length: 2 * pieceSize, PieceSize: pieceSize}
+	require.NoError(t, r.UnmarshalBinary(data))
+	assert.Equal(t, int64(2*fastresume.DefaultBlockSize), r.BlockSize)
+	assert.Equal(t, []bool{true, false}, r.Pieces)
+	assert.Equal(t, map[int][]bool{1: {false, true}}, r.Unfinished)
+
+	content := bytes.Repeat([]byte{7}, 2*pieceSize)
+	s := store.NewStore[byte]()
+	require.NoError(t, r.Fill(s, bytes.NewReader(content)))
+	assert.Equal(t, int64(pieceSize+2*fastresume.DefaultBlockSize), s.Occupancy())
+	assert.True(t, s.Has(2*fastresume.DefaultBlockSize, pieceSize+2*fastresume.DefaultBlockSize))
+
+	for _, invalid := range []string{
+		"",
+		"i1e",
+		"d11:file-format3:fooe",
+		"d11:file-format22:libtorrent resume file6:pieces1:\x01e",
+		"d11:file-format22:libtorrent resume file6:pieces2:\x01\x0010:unfinishedld5:piecei9eeee",
+		"d11:file-format22:libtorrent resume file",
+		"d11:file-format99:x",
+		"d16:blocks per piecei0e11:file-format22:libtorrent resume file6:pieces2:\x01\x00e",
+		"d16:blocks per piecei-2e11:file-format22:libtorrent resume file6:pieces2:\x01\x00e",
+	} {
+		r := fastresume.Resume{Length: 2 * pieceSize, PieceSize: pieceSize}
+		assert.ErrorIs(t, r.UnmarshalBinary([]byte(invalid)), fastresume.ErrFormat, "%q", invalid)
+	}
+}
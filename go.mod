@@ -2,7 +2,10 @@ module github.com/aertje/sparse-store
 
 go 1.21
 
-require github.com/stretchr/testify v1.8.4
+require (
+	github.com/stretchr/testify v1.8.4
+	golang.org/x/sys v0.0.0-20220520151302-bc2c85ada10a
+)
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
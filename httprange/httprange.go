@@ -0,0 +1,278 @@
+// Package httprange fills stores from URLs with HTTP range requests, fetching
+// only the ranges a store is missing. This makes downloads resumable: filling
+// a store that already holds part of a resource fetches the rest. Transport
+// applies the same to the requests of an http.Client.
+package httprange
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/aertje/sparse-store/store"
+)
+
+// ErrChanged is returned when the resource changed while it was being filled.
+var ErrChanged = errors.New("resource changed")
+
+const (
+	defaultParallelism = 4
+	defaultRetries     = 3
+	defaultMaxRequest  = 4 << 20 // 4 Mi
+	initialBackoff     = 100 * time.Millisecond
+)
+
+// Filler fills stores from a URL. The zero value of any field selects its
+// default.
+type Filler struct {
+	// Client is the client requests are made with, http.DefaultClient by
+	// default.
+	Client *http.Client
+	// Parallelism is the number of requests made at once, 4 by default.
+	Parallelism int
+	// Retries is the number of times a failed request is retried, 3 by
+	// default. Only network errors and server errors are retried.
+	Retries int
+	// Plan configures the requests, which are at most 4 MiB by default.
+	Plan store.PlanOptions
+	// OnProgress, if set, is called with the state of the download after
+	// every request that completes, so that it can be saved to resume the
+	// download later. Calls are serialized.
+	OnProgress func(*State)
+}
+
+// Fill fills `s` with the resource at `url`, using the default Filler.
+func Fill(ctx context.Context, url string, s *store.Store[byte]) error {
+	return (&Filler{}).Fill(ctx, url, s)
+}
+
+// resource is what is known about the resource being filled from.
+type resource struct {
+	url  string
+	size int64
+	// validator is the value of the If-Range header, if the resource has a
+	// strong ETag or a modification time.
+	validator string
+	// header holds the headers to send along with requests, if any.
+	header http.Header
+}
+
+// Fill fills `s` with the resource at `url`, fetching only the ranges it is
+// missing. It validates that the resource does not change between requests
+// with its ETag or modification time, and returns ErrChanged if it does.
+// Whatever was fetched is kept in `s` if Fill fails.
+func (f *Filler) Fill(ctx context.Context, url string, s *store.Store[byte]) error {
+	return f.Resume(ctx, &State{URL: url}, s)
+}
+
+// Resume is like Fill, but continues the download described by `state`, which
+// it keeps up to date. A state with only a URL starts a new download. When
+// resuming, the resource is validated against the validator in the state.
+func (f *Filler) Resume(ctx context.Context, state *State, s *store.Store[byte]) error {
+	if state.Size == 0 {
+		res, err := f.probe(ctx, state.URL, s)
+		if err != nil {
+			return err
+		}
+		if res == nil {
+			// The whole resource was fetched at once.
+			state.Size = s.Length()
+			state.update(s, nil)
+			return nil
+		}
+		state.Size, state.Validator = res.size, res.validator
+	}
+	res := &resource{url: state.URL, size: state.Size, validator: state.Validator}
+
+	opts := f.Plan
+	if opts.MaxRequest <= 0 {
+		opts.MaxRequest = defaultMaxRequest
+	}
+	plan := s.Plan(res.size, 0, opts)
+	state.update(s, plan)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu       sync.Mutex
+		firstErr error
+		wg       sync.WaitGroup
+	)
+	parallelism := f.Parallelism
+	if parallelism <= 0 {
+		parallelism = defaultParallelism
+	}
+	requests := make(chan store.Range)
+	for i := 0; i < parallelism; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for r := range requests {
+				data, err := f.fetch(ctx, res, r)
+
+				mu.Lock()
+				if err != nil {
+					if firstErr == nil {
+						firstErr = err
+					}
+					cancel()
+				} else if err := s.SetOwned(data, r.Offset); err != nil {
+					// The values are lost, so the range is not complete.
+					if firstErr == nil {
+						firstErr = fmt.Errorf("setting bytes %d-%d: %w", r.Offset, r.End()-1, err)
+					}
+					cancel()
+				} else {
+					state.complete(s, r)
+					if f.OnProgress != nil {
+						f.OnProgress(state)
+					}
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+send:
+	for _, r := range plan {
+		select {
+		case requests <- r:
+		case <-ctx.Done():
+			break send
+		}
+	}
+	close(requests)
+	wg.Wait()
+
+	if firstErr == nil {
+		firstErr = ctx.Err()
+	}
+	return firstErr
+}
+
+// probe requests the first byte of the resource to learn its size and
+// validator. If the server does not support range requests, it fills `s` with
+// the whole resource instead, and returns nil.
+func (f *Filler) probe(ctx context.Context, url string, s *store.Store[byte]) (*resource, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Range", "bytes=0-0")
+
+	resp, err := f.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		data, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.SetOwned(data, 0); err != nil {
+			return nil, fmt.Errorf("setting bytes 0-%d: %w", len(data)-1, err)
+		}
+		return nil, nil
+	case http.StatusPartialContent:
+	default:
+		return nil, fmt.Errorf("probing %s: %s", url, resp.Status)
+	}
+
+	contentRange := resp.Header.Get("Content-Range")
+	_, size, ok := parseContentRange(contentRange)
+	if !ok || size < 0 {
+		return nil, fmt.Errorf("probing %s: invalid Content-Range %q", url, contentRange)
+	}
+
+	return &resource{url: url, size: size, validator: validator(resp.Header)}, nil
+}
+
+// fetch fetches the range `r` of the resource, retrying if it fails with a
+// network or server error.
+func (f *Filler) fetch(ctx context.Context, res *resource, r store.Range) ([]byte, error) {
+	retries := f.Retries
+	if retries == 0 {
+		retries = defaultRetries
+	}
+
+	backoff := initialBackoff
+	for attempt := 0; ; attempt++ {
+		data, retry, err := f.fetchOnce(ctx, res, r)
+		if err == nil || !retry || attempt >= retries {
+			return data, err
+		}
+
+		select {
+		case <-time.After(backoff):
+			backoff *= 2
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// fetchOnce fetches the range `r` of the resource, and reports whether a
+// failure is worth retrying.
+func (f *Filler) fetchOnce(ctx context.Context, res *resource, r store.Range) ([]byte, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, res.url, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	if res.header != nil {
+		req.Header = res.header.Clone()
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", r.Offset, r.End()-1))
+	if res.validator != "" {
+		req.Header.Set("If-Range", res.validator)
+	}
+
+	resp, err := f.do(req)
+	if err != nil {
+		return nil, ctx.Err() == nil, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusPartialContent:
+	case resp.StatusCode == http.StatusOK && res.validator != "":
+		// The server ignored If-Range because the validator did not match.
+		return nil, false, ErrChanged
+	case resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests:
+		return nil, true, fmt.Errorf("fetching bytes %d-%d: %s", r.Offset, r.End()-1, resp.Status)
+	default:
+		return nil, false, fmt.Errorf("fetching bytes %d-%d: %s", r.Offset, r.End()-1, resp.Status)
+	}
+
+	// A server or proxy may respond with another range than requested, which
+	// must not be taken for it.
+	header := resp.Header.Get("Content-Range")
+	got, size, ok := parseContentRange(header)
+	if !ok || got != r {
+		return nil, false, fmt.Errorf("fetching bytes %d-%d: unexpected Content-Range %q", r.Offset, r.End()-1, header)
+	}
+	if size >= 0 && size != res.size {
+		return nil, false, ErrChanged
+	}
+
+	data := make([]byte, r.Length)
+	if _, err := io.ReadFull(resp.Body, data); err != nil {
+		return nil, true, fmt.Errorf("fetching bytes %d-%d: %w", r.Offset, r.End()-1, err)
+	}
+	return data, false, nil
+}
+
+func (f *Filler) do(req *http.Request) (*http.Response, error) {
+	client := f.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return client.Do(req)
+}
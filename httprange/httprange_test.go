@@ -0,0 +1,159 @@
+package httprange_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aertje/sparse-store/httprange"
+	"github.com/aertje/sparse-store/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// server serves `content` with range support, recording the ranges requested.
+type server struct {
+	mu      sync.Mutex
+	content []byte
+	etag    string
+	ranges  []string
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.ranges = append(s.ranges, r.Header.Get("Range"))
+	content, etag := s.content, s.etag
+	s.mu.Unlock()
+
+	w.Header().Set("ETag", etag)
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+}
+
+func newContent(n int) []byte {
+	content := make([]byte, n)
+	for i := range content {
+		content[i] = byte(i * 7)
+	}
+	return content
+}
+
+func TestFill(t *testing.T) {
+	srv := &server{content: newContent(1000), etag: `"v1"`}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	s := store.NewStore[byte]()
+	s.Set(append([]byte(nil), srv.content[100:500]...), 100)
+
+	f := &httprange.Filler{Plan: store.PlanOptions{MaxRequest: 200}}
+	require.NoError(t, f.Fill(context.Background(), ts.URL, s))
+
+	data := make([]byte, 1000)
+	assert.True(t, s.Get(data, 0))
+	assert.Equal(t, srv.content, data)
+	assert.ElementsMatch(t, []string{"bytes=0-0", "bytes=0-99", "bytes=500-699", "bytes=700-899", "bytes=900-999"}, srv.ranges)
+}
+
+func TestFillChanged(t *testing.T) {
+	srv := &server{content: newContent(1000), etag: `"v1"`}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	// Change the resource after it was probed.
+	client := &http.Client{Transport: roundTripper(func(r *http.Request) (*http.Response, error) {
+		resp, err := http.DefaultTransport.RoundTrip(r)
+		srv.mu.Lock()
+		srv.etag = `"v2"`
+		srv.mu.Unlock()
+		return resp, err
+	})}
+
+	f := &httprange.Filler{Client: client, Parallelism: 1}
+	assert.ErrorIs(t, f.Fill(context.Background(), ts.URL, store.NewStore[byte]()), httprange.ErrChanged)
+}
+
+func TestFillRetries(t *testing.T) {
+	srv := &server{content: newContent(100), etag: `"v1"`}
+	var failures atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Range") != "bytes=0-0" && failures.Add(1) <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		srv.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+
+	s := store.NewStore[byte]()
+	require.NoError(t, httprange.Fill(context.Background(), ts.URL, s))
+	assert.True(t, s.Has(100, 0))
+	assert.Equal(t, int32(3), failures.Load())
+}
+
+func TestFillWithoutRanges(t *testing.T) {
+	content := newContent(100)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(content)
+	}))
+	defer ts.Close()
+
+	s := store.NewStore[byte]()
+	require.NoError(t, httprange.Fill(context.Background(), ts.URL, s))
+	data := make([]byte, 100)
+	assert.True(t, s.Get(data, 0))
+	assert.Equal(t, content, data)
+
+	// Values the store rejects are reported.
+	s = store.NewStore(store.WithByteBudget[byte](50))
+	assert.ErrorIs(t, httprange.Fill(context.Background(), ts.URL, s), store.ErrFull)
+}
+
+func TestFillUnexpectedRange(t *testing.T) {
+	srv := &server{content: newContent(1000), etag: `"v1"`}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Serve the range one byte past the one requested.
+		if spec := r.Header.Get("Range"); spec != "bytes=0-0" {
+			var from, to int64
+			fmt.Sscanf(spec, "bytes=%d-%d", &from, &to)
+			r.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", from+1, to+1))
+		}
+		srv.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+
+	s := store.NewStore[byte]()
+	err := httprange.Fill(context.Background(), ts.URL, s)
+	assert.ErrorContains(t, err, "unexpected Content-Range")
+	assert.False(t, s.Has(2, 1))
+}
+
+func TestFillStoreFull(t *testing.T) {
+	srv := &server{content: newContent(1000), etag: `"v1"`}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	s := store.NewStore(store.WithByteBudget[byte](500))
+	state := &httprange.State{URL: ts.URL}
+	f := &httprange.Filler{Parallelism: 1, Plan: store.PlanOptions{MaxRequest: 200}}
+	assert.ErrorIs(t, f.Resume(context.Background(), state, s), store.ErrFull)
+
+	// The range that did not fit is still pending.
+	var present int64
+	for _, r := range state.Present {
+		present += r.Length
+	}
+	assert.Equal(t, s.Occupancy(), present)
+	assert.Contains(t, state.Pending, store.Range{Offset: present, Length: 200})
+}
+
+type roundTripper func(*http.Request) (*http.Response, error)
+
+func (f roundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
@@ -0,0 +1,224 @@
+package httprange
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strconv"
+
+	"github.com/aertje/sparse-store/store"
+)
+
+// FillMultipart writes the parts of a multipart/byteranges body read from `r`,
+// as returned for requests of several ranges, into `s` at the offsets of their
+// Content-Range headers. `boundary` is the boundary parameter of the
+// Content-Type of the body. It returns the ranges written, in the order of the
+// parts, including those written before an error.
+func FillMultipart(s *store.Store[byte], r io.Reader, boundary string) ([]store.Range, error) {
+	mr := multipart.NewReader(r, boundary)
+	var written []store.Range
+	for {
+		part, err := mr.NextPart()
+		if errors.Is(err, io.EOF) {
+			return written, nil
+		}
+		if err != nil {
+			return written, err
+		}
+
+		contentRange := part.Header.Get("Content-Range")
+		rng, _, ok := parseContentRange(contentRange)
+		if !ok {
+			return written, fmt.Errorf("part %d: invalid Content-Range %q", len(written), contentRange)
+		}
+		// Read at most a value more than the range, so that a wrong header
+		// cannot make it allocate more than the part holds.
+		data, err := io.ReadAll(io.LimitReader(part, rng.Length+1))
+		if err != nil {
+			return written, fmt.Errorf("part %d: %w", len(written), err)
+		}
+		if int64(len(data)) != rng.Length {
+			return written, fmt.Errorf("part %d: got %d bytes for %s", len(written), len(data), contentRange)
+		}
+		if err := s.SetOwned(data, rng.Offset); err != nil {
+			return written, err
+		}
+		written = append(written, rng)
+	}
+}
+
+// FillFromResponse writes the body of `resp` into `s`: the whole resource for a
+// 200 response, and the range or ranges of a 206 response, whether single or
+// multipart/byteranges. It returns the ranges written, including those written
+// before an error.
+func FillFromResponse(s *store.Store[byte], resp *http.Response) ([]store.Range, error) {
+	switch resp.StatusCode {
+	case http.StatusOK:
+		data, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.SetOwned(data, 0); err != nil {
+			return nil, err
+		}
+		return []store.Range{{Offset: 0, Length: int64(len(data))}}, nil
+	case http.StatusPartialContent:
+	default:
+		return nil, fmt.Errorf("filling from response: %s", resp.Status)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err == nil && mediaType == "multipart/byteranges" {
+		return FillMultipart(s, resp.Body, params["boundary"])
+	}
+
+	contentRange := resp.Header.Get("Content-Range")
+	rng, _, ok := parseContentRange(contentRange)
+	if !ok {
+		return nil, fmt.Errorf("filling from response: invalid Content-Range %q", contentRange)
+	}
+	// Copy the range a chunk at a time, so that a wrong header cannot make
+	// it allocate more than the body holds.
+	written := store.Range{Offset: rng.Offset}
+	for written.End() < rng.End() {
+		data := make([]byte, min(multipartChunk, rng.End()-written.End()))
+		if _, err := io.ReadFull(resp.Body, data); err != nil {
+			return nonEmpty(written), fmt.Errorf("filling bytes %d-%d: %w", rng.Offset, rng.End()-1, err)
+		}
+		if err := s.SetOwned(data, written.End()); err != nil {
+			return nonEmpty(written), err
+		}
+		written.Length += int64(len(data))
+	}
+	return []store.Range{rng}, nil
+}
+
+// nonEmpty returns `r` as the ranges written, unless it is empty.
+func nonEmpty(r store.Range) []store.Range {
+	if r.Length == 0 {
+		return nil
+	}
+	return []store.Range{r}
+}
+
+// multipartChunk is the number of values MultipartBody copies at a time.
+const multipartChunk = 1 << 20
+
+// MultipartBody serializes ranges of a byte store as a multipart/byteranges
+// body, the response to a request for several ranges, for servers and proxies
+// of ranges.
+type MultipartBody struct {
+	store       *store.Store[byte]
+	ranges      []store.Range
+	contentType string
+	size        int64
+	boundary    string
+}
+
+// NewMultipartBody returns the body holding the `ranges` of `s`, in order,
+// with a random boundary. The parts have a Content-Type of `contentType`,
+// unless it is empty, and a Content-Range relative to the current length of
+// `s`.
+func NewMultipartBody(s *store.Store[byte], ranges []store.Range, contentType string) *MultipartBody {
+	return &MultipartBody{
+		store:       s,
+		ranges:      ranges,
+		contentType: contentType,
+		size:        s.Length(),
+		boundary:    multipart.NewWriter(io.Discard).Boundary(),
+	}
+}
+
+// ContentType returns the Content-Type of the body, with its boundary.
+func (b *MultipartBody) ContentType() string {
+	return "multipart/byteranges; boundary=" + b.boundary
+}
+
+// ContentLength returns the length of the body.
+func (b *MultipartBody) ContentLength() int64 {
+	// The body is as long as its headers and delimiters, plus the values.
+	var counter countingWriter
+	b.write(&counter, func(io.Writer, store.Range) error { return nil })
+	n := counter.n
+	for _, r := range b.ranges {
+		n += r.Length
+	}
+	return n
+}
+
+// SetHeaders sets the Content-Type and Content-Length of the body in `h`.
+func (b *MultipartBody) SetHeaders(h http.Header) {
+	h.Set("Content-Type", b.ContentType())
+	h.Set("Content-Length", strconv.FormatInt(b.ContentLength(), 10))
+}
+
+// WriteTo writes the body to `w`. It fails before writing anything with an
+// error wrapping store.ErrMissing if any of the values of the ranges are
+// missing.
+func (b *MultipartBody) WriteTo(w io.Writer) (int64, error) {
+	for _, r := range b.ranges {
+		if r.Length <= 0 || !b.store.Has(r.Length, r.Offset) {
+			return 0, fmt.Errorf("bytes %d-%d: %w", r.Offset, r.End()-1, store.ErrMissing)
+		}
+	}
+
+	counter := countingWriter{w: w}
+	buf := make([]byte, multipartChunk)
+	err := b.write(&counter, func(part io.Writer, r store.Range) error {
+		for offset := r.Offset; offset < r.End(); offset += int64(len(buf)) {
+			p := buf[:min(int64(len(buf)), r.End()-offset)]
+			if !b.store.Get(p, offset) {
+				return fmt.Errorf("bytes %d-%d: %w", offset, offset+int64(len(p))-1, store.ErrMissing)
+			}
+			if _, err := part.Write(p); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return counter.n, err
+}
+
+// write writes the parts to `w`, with their values written by `values`.
+func (b *MultipartBody) write(w io.Writer, values func(part io.Writer, r store.Range) error) error {
+	mw := multipart.NewWriter(w)
+	if err := mw.SetBoundary(b.boundary); err != nil {
+		return err
+	}
+	for _, r := range b.ranges {
+		header := textproto.MIMEHeader{}
+		if b.contentType != "" {
+			header.Set("Content-Type", b.contentType)
+		}
+		header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", r.Offset, r.End()-1, b.size))
+		part, err := mw.CreatePart(header)
+		if err != nil {
+			return err
+		}
+		if err := values(part, r); err != nil {
+			return err
+		}
+	}
+	return mw.Close()
+}
+
+// countingWriter counts the bytes written to it, passing them on to `w` if it
+// is set.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	if c.w == nil {
+		c.n += int64(len(p))
+		return len(p), nil
+	}
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
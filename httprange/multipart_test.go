@@ -0,0 +1,139 @@
+package httprange_test
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aertje/sparse-store/httprange"
+	"github.com/aertje/sparse-store/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFillFromResponse(t *testing.T) {
+	content := []byte("0123456789abcdefghijklmnopqrstuvwxyz")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+	}))
+	defer srv.Close()
+
+	for _, tt := range []struct {
+		rangeHeader string
+		want        []store.Range
+	}{
+		{"bytes=2-4,10-12,30-", []store.Range{{Offset: 2, Length: 3}, {Offset: 10, Length: 3}, {Offset: 30, Length: 6}}},
+		{"bytes=5-9", []store.Range{{Offset: 5, Length: 5}}},
+		{"", []store.Range{{Offset: 0, Length: 36}}},
+	} {
+		req, err := http.NewRequest(http.MethodGet, srv.URL, nil)
+		require.NoError(t, err)
+		if tt.rangeHeader != "" {
+			req.Header.Set("Range", tt.rangeHeader)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+
+		s := store.NewStore[byte]()
+		written, err := httprange.FillFromResponse(s, resp)
+		resp.Body.Close()
+		require.NoError(t, err, tt.rangeHeader)
+		assert.Equal(t, tt.want, written, tt.rangeHeader)
+		assert.Equal(t, tt.want, s.Extents(), tt.rangeHeader)
+		for _, r := range written {
+			got := make([]byte, r.Length)
+			require.True(t, s.Get(got, r.Offset))
+			assert.Equal(t, content[r.Offset:r.End()], got)
+		}
+	}
+}
+
+func TestFillMultipartErrors(t *testing.T) {
+	body := func(contentRange, data string) *bytes.Buffer {
+		var buf bytes.Buffer
+		w := multipart.NewWriter(&buf)
+		require.NoError(t, w.SetBoundary("b"))
+		part, err := w.CreatePart(textproto.MIMEHeader{"Content-Range": {contentRange}})
+		require.NoError(t, err)
+		part.Write([]byte(data))
+		require.NoError(t, w.Close())
+		return &buf
+	}
+
+	s := store.NewStore[byte]()
+	_, err := httprange.FillMultipart(s, body("bytes 0-3/10", "abc"), "b")
+	assert.ErrorContains(t, err, "part 0: got 3 bytes for bytes 0-3/10")
+	_, err = httprange.FillMultipart(s, body("bytes 0-1/10", "abc"), "b")
+	assert.ErrorContains(t, err, "part 0: got 3 bytes")
+	_, err = httprange.FillMultipart(s, body("bytes 5-2/10", "abc"), "b")
+	assert.ErrorContains(t, err, `invalid Content-Range "bytes 5-2/10"`)
+	assert.Zero(t, s.Occupancy())
+
+	written, err := httprange.FillMultipart(s, body("bytes 4-6/*", "abc"), "b")
+	require.NoError(t, err)
+	assert.Equal(t, []store.Range{{Offset: 4, Length: 3}}, written)
+}
+
+func TestFillFromResponseTruncated(t *testing.T) {
+	// A Content-Range much larger than the body fails at its end, rather than
+	// allocating the range up front.
+	resp := &http.Response{
+		StatusCode: http.StatusPartialContent,
+		Header:     http.Header{"Content-Range": {"bytes 10-9999999999/*"}},
+		Body:       io.NopCloser(strings.NewReader("abc")),
+	}
+	s := store.NewStore[byte]()
+	written, err := httprange.FillFromResponse(s, resp)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	assert.Nil(t, written)
+	assert.Zero(t, s.Occupancy())
+}
+
+func TestMultipartBody(t *testing.T) {
+	s := store.NewStore[byte]()
+	require.NoError(t, s.Set([]byte("0123456789"), 0))
+	require.NoError(t, s.Set([]byte("xyz"), 20))
+	ranges := []store.Range{{Offset: 2, Length: 3}, {Offset: 20, Length: 3}}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := httprange.NewMultipartBody(s, ranges, "text/plain")
+		body.SetHeaders(w.Header())
+		w.WriteHeader(http.StatusPartialContent)
+		body.WriteTo(w)
+	}))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Regexp(t, "^multipart/byteranges; boundary=[0-9a-f]+$", resp.Header.Get("Content-Type"))
+	assert.Greater(t, resp.ContentLength, int64(0))
+
+	filled := store.NewStore[byte]()
+	written, err := httprange.FillFromResponse(filled, resp)
+	require.NoError(t, err)
+	assert.Equal(t, ranges, written)
+	got := make([]byte, 3)
+	require.True(t, filled.Get(got, 20))
+	assert.Equal(t, "xyz", string(got))
+
+	var buf bytes.Buffer
+	body := httprange.NewMultipartBody(s, ranges, "")
+	n, err := body.WriteTo(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, body.ContentLength(), n)
+	assert.Contains(t, buf.String(), "Content-Range: bytes 20-22/23\r\n\r\nxyz\r\n")
+	assert.NotContains(t, buf.String(), "Content-Type")
+
+	buf.Reset()
+	_, err = httprange.NewMultipartBody(s, []store.Range{{Offset: 8, Length: 4}}, "").WriteTo(&buf)
+	assert.ErrorIs(t, err, store.ErrMissing)
+	assert.ErrorContains(t, err, "bytes 8-11")
+	assert.Zero(t, buf.Len())
+}
@@ -0,0 +1,76 @@
+package httprange
+
+import (
+	"encoding/json"
+	"io"
+	"slices"
+
+	"github.com/aertje/sparse-store/store"
+)
+
+// State is the state of a download, which can be saved to resume it after a
+// restart with Filler.Resume. The data itself is not part of it: it needs to
+// be saved separately, for example by a store written through to a file, and
+// restored with Restore.
+type State struct {
+	URL  string `json:"url"`
+	Size int64  `json:"size"`
+	// Validator is the ETag or modification time of the resource.
+	Validator string `json:"validator,omitempty"`
+	// Present holds the ranges fetched, and Pending the requests planned for
+	// the rest, in order.
+	Present []store.Range `json:"present"`
+	Pending []store.Range `json:"pending"`
+}
+
+// LoadState reads a state saved with Save.
+func LoadState(r io.Reader) (*State, error) {
+	var state State
+	if err := json.NewDecoder(r).Decode(&state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// Save writes the state to `w` as JSON.
+func (st *State) Save(w io.Writer) error {
+	return json.NewEncoder(w).Encode(st)
+}
+
+// Restore reads the ranges present from `r`, which holds the data at its own
+// offsets, into `s`.
+func (st *State) Restore(r io.ReaderAt, s *store.Store[byte]) error {
+	for _, present := range st.Present {
+		data := make([]byte, present.Length)
+		if _, err := r.ReadAt(data, present.Offset); err != nil {
+			return err
+		}
+		if err := s.SetOwned(data, present.Offset); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// update sets the ranges present from `s`, and the pending requests to
+// `plan`.
+func (st *State) update(s *store.Store[byte], plan []store.Range) {
+	st.Present = st.Present[:0]
+	for _, e := range s.Extents() {
+		if n := len(st.Present); n > 0 && st.Present[n-1].End() == e.Offset {
+			st.Present[n-1].Length += e.Length
+			continue
+		}
+		st.Present = append(st.Present, e)
+	}
+	st.Pending = slices.Clone(plan)
+}
+
+// complete records that the request `r` completed.
+func (st *State) complete(s *store.Store[byte], r store.Range) {
+	i := slices.Index(st.Pending, r)
+	if i < 0 {
+		return
+	}
+	st.update(s, slices.Delete(st.Pending, i, i+1))
+}
@@ -0,0 +1,80 @@
+package httprange_test
+
+import (
+	"bytes"
+	"context"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aertje/sparse-store/httprange"
+	"github.com/aertje/sparse-store/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResume(t *testing.T) {
+	srv := &server{content: newContent(1000), etag: `"v1"`}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	file, err := os.Create(filepath.Join(t.TempDir(), "download"))
+	require.NoError(t, err)
+	defer file.Close()
+
+	// Interrupt the download after two requests, saving its state.
+	ctx, cancel := context.WithCancel(context.Background())
+	var saved bytes.Buffer
+	completed := 0
+	f := &httprange.Filler{
+		Parallelism: 1,
+		Plan:        store.PlanOptions{MaxRequest: 200},
+		OnProgress: func(state *httprange.State) {
+			saved.Reset()
+			require.NoError(t, state.Save(&saved))
+			if completed++; completed == 2 {
+				cancel()
+			}
+		},
+	}
+	s := store.NewStore(store.WithWriteThrough(file))
+	assert.ErrorIs(t, f.Fill(ctx, ts.URL, s), context.Canceled)
+
+	state, err := httprange.LoadState(&saved)
+	require.NoError(t, err)
+	assert.Equal(t, ts.URL, state.URL)
+	assert.Equal(t, int64(1000), state.Size)
+	assert.Equal(t, `"v1"`, state.Validator)
+	assert.Equal(t, []store.Range{{Offset: 0, Length: 400}}, state.Present)
+	assert.Equal(t, []store.Range{{Offset: 400, Length: 200}, {Offset: 600, Length: 200}, {Offset: 800, Length: 200}}, state.Pending)
+
+	// Resume in a new store, restored from the file.
+	srv.ranges = nil
+	s = store.NewStore(store.WithWriteThrough(file))
+	require.NoError(t, state.Restore(file, s))
+	f.OnProgress = nil
+	require.NoError(t, f.Resume(context.Background(), state, s))
+
+	data := make([]byte, 1000)
+	assert.True(t, s.Get(data, 0))
+	assert.Equal(t, srv.content, data)
+	assert.Equal(t, []string{"bytes=400-599", "bytes=600-799", "bytes=800-999"}, srv.ranges)
+	assert.Equal(t, []store.Range{{Offset: 0, Length: 1000}}, state.Present)
+	assert.Empty(t, state.Pending)
+
+	// The file holds the whole resource.
+	written, err := os.ReadFile(file.Name())
+	require.NoError(t, err)
+	assert.Equal(t, srv.content, written)
+}
+
+func TestResumeChanged(t *testing.T) {
+	srv := &server{content: newContent(1000), etag: `"v2"`}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	state := &httprange.State{URL: ts.URL, Size: 1000, Validator: `"v1"`}
+	f := &httprange.Filler{}
+	assert.ErrorIs(t, f.Resume(context.Background(), state, store.NewStore[byte]()), httprange.ErrChanged)
+}
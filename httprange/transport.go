@@ -0,0 +1,440 @@
+package httprange
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/aertje/sparse-store/store"
+)
+
+// Transport is an http.RoundTripper that caches the bodies of resources in
+// stores, one per URL, and serves GET requests for a single range from them.
+// Only the ranges missing from the cache are fetched from upstream, validated
+// against the ETag or modification time of the cached resource. If the
+// resource changed, its cache is dropped. Other requests are passed on as is.
+//
+// As a resource is cached by URL alone, and served from the cache to any
+// request for it, requests with credentials, such as an Authorization or Cookie
+// header, are passed on as is, and responses that are private, must not be
+// stored, must be revalidated or vary with the request headers are not cached.
+//
+// Responses served from the cache are streamed from the store and from the
+// requests for the ranges it is missing, holding at most 4 MiB of values in
+// memory at a time. If the resource turns out to have changed after the first
+// 4 MiB were sent, reading the body fails with ErrChanged.
+//
+// At most MaxResources resources are cached, dropping the least recently used
+// one beyond that. A resource whose values its store rejects is dropped as
+// well, and fetched again on the next request for it.
+type Transport struct {
+	// Base is the transport requests are made with, http.DefaultTransport by
+	// default.
+	Base http.RoundTripper
+	// NewStore returns the store to cache a resource in, a default store if
+	// nil. It can be used to bound the memory used per resource.
+	NewStore func() *store.Store[byte]
+	// MaxResources is the maximum number of resources cached,
+	// DefaultMaxResources if zero. If negative, it is unbounded.
+	MaxResources int
+
+	mu        sync.Mutex
+	resources map[string]*cachedResource
+	// clock is incremented for every use of a cached resource.
+	clock uint64
+}
+
+// DefaultMaxResources is the maximum number of resources a Transport caches if
+// MaxResources is zero.
+const DefaultMaxResources = 64
+
+// cachedResource is a resource cached by a Transport.
+type cachedResource struct {
+	resource
+	header http.Header
+	// used is the clock of the Transport when the resource was last used.
+	// It is guarded by the mutex of the Transport.
+	used uint64
+
+	mu    sync.Mutex
+	store *store.Store[byte]
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	spec := req.Header.Get("Range")
+	if req.Method != http.MethodGet || strings.Contains(spec, ",") || req.Header.Get("If-Range") != "" || hasCredentials(req.Header) {
+		return t.base().RoundTrip(req)
+	}
+
+	key := req.URL.String()
+	t.mu.Lock()
+	cached := t.resources[key]
+	if cached != nil {
+		t.clock++
+		cached.used = t.clock
+	}
+	t.mu.Unlock()
+
+	if cached == nil {
+		return t.roundTripUncached(req, key)
+	}
+
+	r, ok := parseRange(spec, cached.size)
+	if !ok {
+		return t.base().RoundTrip(req)
+	}
+
+	// The first chunk is read before responding, so that a resource that
+	// changed is fetched again rather than failing the body.
+	first, err := t.read(req, cached, store.Range{Offset: r.Offset, Length: min(r.Length, transportChunk)})
+	if errors.Is(err, ErrChanged) {
+		t.drop(cached)
+		return t.roundTripUncached(req, key)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var body io.ReadCloser = io.NopCloser(bytes.NewReader(first))
+	if int64(len(first)) < r.Length {
+		body = t.stream(req, cached, r, first)
+	}
+	return cached.response(req, r, spec != "", body), nil
+}
+
+// transportChunk is the number of values a Transport reads from the cache, or
+// requests from upstream, at a time.
+const transportChunk = defaultMaxRequest
+
+// stream returns a body with the range `r` of the cached resource, which
+// starts with `first`, reading the rest a chunk at a time as the body is read.
+func (t *Transport) stream(req *http.Request, cached *cachedResource, r store.Range, first []byte) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		_, err := pw.Write(first)
+		for offset := r.Offset + int64(len(first)); err == nil && offset < r.End(); offset += transportChunk {
+			var data []byte
+			data, err = t.read(req, cached, store.Range{Offset: offset, Length: min(r.End()-offset, transportChunk)})
+			if errors.Is(err, ErrChanged) {
+				t.drop(cached)
+			}
+			if err == nil {
+				_, err = pw.Write(data)
+			}
+		}
+		pw.CloseWithError(err)
+	}()
+	return pr
+}
+
+// read returns the range `r` of the cached resource, fetching the values
+// missing from its store. If the store rejects them, the resource is dropped.
+func (t *Transport) read(req *http.Request, cached *cachedResource, r store.Range) ([]byte, error) {
+	cached.mu.Lock()
+	data := make([]byte, r.Length)
+	complete := cached.store.Get(data, r.Offset)
+	var gaps []store.Range
+	if !complete {
+		gaps = cached.store.Gaps(r.Length, r.Offset)
+	}
+	cached.mu.Unlock()
+
+	f := &Filler{Client: &http.Client{Transport: t.base()}}
+	for _, gap := range gaps {
+		values, err := f.fetch(req.Context(), &cached.resource, gap)
+		if err != nil {
+			return nil, err
+		}
+		copy(data[gap.Offset-r.Offset:], values)
+
+		cached.mu.Lock()
+		err = cached.store.SetOwned(values, gap.Offset)
+		cached.mu.Unlock()
+		if err != nil {
+			t.drop(cached)
+		}
+	}
+
+	return data, nil
+}
+
+// roundTripUncached passes `req` on, and starts caching the resource if the
+// response tells its size.
+func (t *Transport) roundTripUncached(req *http.Request, key string) (*http.Response, error) {
+	resp, err := t.base().RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var offset, size int64
+	switch resp.StatusCode {
+	case http.StatusOK:
+		offset, size = 0, resp.ContentLength
+	case http.StatusPartialContent:
+		r, total, ok := parseContentRange(resp.Header.Get("Content-Range"))
+		if !ok {
+			return resp, nil
+		}
+		offset, size = r.Offset, total
+	default:
+		return resp, nil
+	}
+	if size < 0 {
+		return resp, nil
+	}
+
+	if !cacheable(resp.Header) {
+		return resp, nil
+	}
+
+	cached := &cachedResource{
+		resource: resource{url: key, size: size, validator: validator(resp.Header), header: requestHeader(req)},
+		header:   resp.Header.Clone(),
+	}
+	if t.NewStore != nil {
+		cached.store = t.NewStore()
+	} else {
+		cached.store = store.NewStore[byte]()
+	}
+	resp.Body = &teeBody{ReadCloser: resp.Body, transport: t, cached: cached, offset: offset}
+
+	t.mu.Lock()
+	if t.resources == nil {
+		t.resources = map[string]*cachedResource{}
+	}
+	t.clock++
+	cached.used = t.clock
+	t.resources[key] = cached
+	t.evict()
+	t.mu.Unlock()
+
+	return resp, nil
+}
+
+// evict drops the least recently used resources beyond MaxResources. The mutex
+// of the Transport must be held.
+func (t *Transport) evict() {
+	limit := t.MaxResources
+	if limit == 0 {
+		limit = DefaultMaxResources
+	}
+	for limit > 0 && len(t.resources) > limit {
+		var oldest *cachedResource
+		for _, cached := range t.resources {
+			if oldest == nil || cached.used < oldest.used {
+				oldest = cached
+			}
+		}
+		delete(t.resources, oldest.url)
+	}
+}
+
+// drop stops caching the resource, unless it was replaced already.
+func (t *Transport) drop(cached *cachedResource) {
+	t.mu.Lock()
+	if t.resources[cached.url] == cached {
+		delete(t.resources, cached.url)
+	}
+	t.mu.Unlock()
+}
+
+// teeBody is the body of a response being cached, which sets the values read
+// from it in the store of the resource as they are read, so that the response
+// is streamed rather than buffered. If the store rejects them, the resource is
+// dropped, and the rest of the body is no longer cached.
+type teeBody struct {
+	io.ReadCloser
+	transport *Transport
+	cached    *cachedResource
+	offset    int64
+}
+
+func (b *teeBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 && b.cached != nil {
+		b.cached.mu.Lock()
+		setErr := b.cached.store.SetOwned(bytes.Clone(p[:n]), b.offset)
+		b.cached.mu.Unlock()
+		if setErr != nil {
+			b.transport.drop(b.cached)
+			b.cached = nil
+		}
+		b.offset += int64(n)
+	}
+	return n, err
+}
+
+// response returns a response to `req` with the range `r` of the resource,
+// read from `body`.
+func (c *cachedResource) response(req *http.Request, r store.Range, partial bool, body io.ReadCloser) *http.Response {
+	header := http.Header{}
+	for _, name := range []string{"Content-Type", "ETag", "Last-Modified", "Cache-Control"} {
+		if v := c.header.Get(name); v != "" {
+			header.Set(name, v)
+		}
+	}
+	header.Set("Accept-Ranges", "bytes")
+	header.Set("Content-Length", strconv.FormatInt(r.Length, 10))
+
+	status := http.StatusOK
+	if partial {
+		status = http.StatusPartialContent
+		header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", r.Offset, r.End()-1, c.size))
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          body,
+		ContentLength: r.Length,
+		Request:       req,
+	}
+}
+
+func (t *Transport) base() http.RoundTripper {
+	if t.Base == nil {
+		return http.DefaultTransport
+	}
+	return t.Base
+}
+
+// credentialHeaders are the request headers that make a response specific to
+// the client.
+var credentialHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie"}
+
+// hasCredentials reports whether a request with `header` carries credentials.
+func hasCredentials(header http.Header) bool {
+	for _, name := range credentialHeaders {
+		if header.Get(name) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// cacheable reports whether a response with `header` may be cached and served
+// to any request for its URL.
+func cacheable(header http.Header) bool {
+	for _, directive := range strings.Split(strings.ToLower(strings.Join(header.Values("Cache-Control"), ",")), ",") {
+		directive, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		switch directive {
+		case "private", "no-store", "no-cache":
+			return false
+		case "max-age", "s-maxage":
+			// Responses that are stale at once would have to be
+			// revalidated, which the cache does not do.
+			if age, err := strconv.Atoi(strings.Trim(value, `"`)); err != nil || age <= 0 {
+				return false
+			}
+		}
+	}
+	// Responses varying only with Accept-Encoding are cached unless encoded,
+	// as their values are then the same whatever the encodings accepted.
+	for _, vary := range header.Values("Vary") {
+		for _, name := range strings.Split(vary, ",") {
+			if name = strings.TrimSpace(name); name != "" && (!strings.EqualFold(name, "Accept-Encoding") || header.Get("Content-Encoding") != "") {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// requestHeader returns the headers of `req` to send along with the requests
+// for missing ranges.
+func requestHeader(req *http.Request) http.Header {
+	header := req.Header.Clone()
+	for _, name := range []string{"Range", "If-Range", "If-None-Match", "If-Modified-Since"} {
+		header.Del(name)
+	}
+	for _, name := range credentialHeaders {
+		header.Del(name)
+	}
+	return header
+}
+
+// validator returns the value for If-Range for a response with `header`.
+func validator(header http.Header) string {
+	// Weak ETags cannot be used with If-Range.
+	if etag := header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		return etag
+	}
+	return header.Get("Last-Modified")
+}
+
+// parseRange parses a Range header for a single range of a resource of `size`
+// bytes. An empty header is the whole resource.
+func parseRange(spec string, size int64) (store.Range, bool) {
+	if spec == "" {
+		return store.Range{Offset: 0, Length: size}, true
+	}
+	spec, ok := strings.CutPrefix(spec, "bytes=")
+	if !ok {
+		return store.Range{}, false
+	}
+	first, last, ok := strings.Cut(spec, "-")
+	if !ok {
+		return store.Range{}, false
+	}
+
+	if first == "" {
+		// A suffix of the resource.
+		n, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || n <= 0 {
+			return store.Range{}, false
+		}
+		n = min(n, size)
+		return store.Range{Offset: size - n, Length: n}, true
+	}
+
+	from, err := strconv.ParseInt(first, 10, 64)
+	if err != nil || from < 0 || from >= size {
+		return store.Range{}, false
+	}
+	to := size - 1
+	if last != "" {
+		if to, err = strconv.ParseInt(last, 10, 64); err != nil || to < from {
+			return store.Range{}, false
+		}
+		to = min(to, size-1)
+	}
+	return store.Range{Offset: from, Length: to - from + 1}, true
+}
+
+// parseContentRange parses a Content-Range header, returning the range and the
+// size of the resource, or -1 if it is unknown.
+func parseContentRange(header string) (r store.Range, size int64, ok bool) {
+	spec, ok := strings.CutPrefix(header, "bytes ")
+	if !ok {
+		return store.Range{}, 0, false
+	}
+	rng, total, ok := strings.Cut(spec, "/")
+	if !ok {
+		return store.Range{}, 0, false
+	}
+	first, last, ok := strings.Cut(rng, "-")
+	if !ok {
+		return store.Range{}, 0, false
+	}
+
+	from, err1 := strconv.ParseInt(first, 10, 64)
+	to, err2 := strconv.ParseInt(last, 10, 64)
+	size, err3 := int64(-1), error(nil)
+	if total != "*" {
+		size, err3 = strconv.ParseInt(total, 10, 64)
+	}
+	if err1 != nil || err2 != nil || err3 != nil || from < 0 || to < from || (size >= 0 && to >= size) {
+		return store.Range{}, 0, false
+	}
+	return store.Range{Offset: from, Length: to - from + 1}, size, true
+}
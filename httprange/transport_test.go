@@ -0,0 +1,190 @@
+package httprange_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/aertje/sparse-store/httprange"
+	"github.com/aertje/sparse-store/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func get(t *testing.T, client *http.Client, url, spec string, header http.Header) (*http.Response, []byte) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	require.NoError(t, err)
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	if spec != "" {
+		req.Header.Set("Range", spec)
+	}
+
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp, body
+}
+
+func TestTransport(t *testing.T) {
+	srv := &server{content: newContent(1000), etag: `"v1"`}
+	var clients []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		clients = append(clients, r.Header.Get("X-Client"))
+		srv.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+	client := &http.Client{Transport: &httprange.Transport{}}
+	header := http.Header{"X-Client": {"test"}}
+
+	resp, body := get(t, client, ts.URL, "bytes=100-199", header)
+	assert.Equal(t, http.StatusPartialContent, resp.StatusCode)
+	assert.Equal(t, srv.content[100:200], body)
+
+	resp, body = get(t, client, ts.URL, "bytes=150-299", header)
+	assert.Equal(t, http.StatusPartialContent, resp.StatusCode)
+	assert.Equal(t, "bytes 150-299/1000", resp.Header.Get("Content-Range"))
+	assert.Equal(t, `"v1"`, resp.Header.Get("ETag"))
+	assert.Equal(t, srv.content[150:300], body)
+
+	_, body = get(t, client, ts.URL, "bytes=-50", header)
+	assert.Equal(t, srv.content[950:], body)
+
+	// Only the missing ranges were requested upstream.
+	assert.Equal(t, []string{"bytes=100-199", "bytes=200-299", "bytes=950-999"}, srv.ranges)
+	assert.Equal(t, []string{"test", "test", "test"}, clients)
+
+	// A changed resource is fetched again.
+	srv.mu.Lock()
+	srv.content = newContent(500)
+	srv.etag = `"v2"`
+	srv.mu.Unlock()
+	_, body = get(t, client, ts.URL, "bytes=250-449", header)
+	assert.Equal(t, srv.content[250:450], body)
+	resp, body = get(t, client, ts.URL, "bytes=250-349", header)
+	assert.Equal(t, "bytes 250-349/500", resp.Header.Get("Content-Range"))
+	assert.Equal(t, srv.content[250:350], body)
+	assert.Len(t, srv.ranges, 5)
+}
+
+func TestTransportStreams(t *testing.T) {
+	srv := &server{content: newContent(1000), etag: `"v1"`}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+	client := &http.Client{Transport: &httprange.Transport{}}
+
+	// The values of a response are cached as they are read.
+	resp, err := client.Get(ts.URL)
+	require.NoError(t, err)
+	part := make([]byte, 300)
+	_, err = io.ReadFull(resp.Body, part)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, srv.content[:300], part)
+
+	_, body := get(t, client, ts.URL, "bytes=0-99", nil)
+	assert.Equal(t, srv.content[:100], body)
+	assert.Equal(t, []string{""}, srv.ranges)
+}
+
+func TestTransportLarge(t *testing.T) {
+	srv := &server{content: newContent(10 << 20), etag: `"v1"`}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+	client := &http.Client{Transport: &httprange.Transport{}}
+
+	get(t, client, ts.URL, "bytes=0-99", nil)
+
+	// The rest is streamed, requesting the missing values a chunk at a time.
+	_, body := get(t, client, ts.URL, "", nil)
+	assert.Equal(t, srv.content, body)
+	assert.Equal(t, []string{"bytes=0-99", "bytes=100-4194303", "bytes=4194304-8388607", "bytes=8388608-10485759"}, srv.ranges)
+}
+
+func TestTransportPrivate(t *testing.T) {
+	srv := &server{content: newContent(1000), etag: `"v1"`}
+	var extra http.Header
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for name, values := range extra {
+			w.Header()[name] = values
+		}
+		srv.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+	client := &http.Client{Transport: &httprange.Transport{}}
+
+	// Requests with credentials are neither cached nor served from the cache.
+	for _, header := range []http.Header{{"Authorization": {"secret"}}, {"Cookie": {"session=1"}}, nil} {
+		_, body := get(t, client, ts.URL, "bytes=0-99", header)
+		assert.Equal(t, srv.content[:100], body)
+	}
+	_, body := get(t, client, ts.URL, "bytes=0-99", http.Header{"Authorization": {"other"}})
+	assert.Equal(t, srv.content[:100], body)
+	assert.Len(t, srv.ranges, 4)
+
+	// Nor are private responses, or those varying with the request.
+	for i, header := range []http.Header{
+		{"Cache-Control": {"private, max-age=60"}},
+		{"Cache-Control": {"no-store"}},
+		{"Vary": {"Accept-Encoding, User-Agent"}},
+		{"Cache-Control": {"no-cache"}},
+		{"Cache-Control": {"public, max-age=0"}},
+	} {
+		extra = header
+		for j := 0; j < 2; j++ {
+			get(t, client, ts.URL+"/"+strconv.Itoa(i), "bytes=0-99", nil)
+		}
+	}
+	assert.Len(t, srv.ranges, 14)
+
+	extra = http.Header{"Vary": {"Accept-Encoding"}, "Cache-Control": {"max-age=60"}}
+	for j := 0; j < 2; j++ {
+		get(t, client, ts.URL+"/encoding", "bytes=0-99", nil)
+	}
+	assert.Len(t, srv.ranges, 15)
+}
+
+func TestTransportMaxResources(t *testing.T) {
+	srv := &server{content: newContent(1000), etag: `"v1"`}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+	client := &http.Client{Transport: &httprange.Transport{MaxResources: 2}}
+
+	for _, path := range []string{"/a", "/b", "/a", "/c"} {
+		get(t, client, ts.URL+path, "bytes=0-99", nil)
+	}
+	assert.Len(t, srv.ranges, 3)
+
+	// The least recently used resource was dropped.
+	get(t, client, ts.URL+"/a", "bytes=0-99", nil)
+	get(t, client, ts.URL+"/c", "bytes=0-99", nil)
+	assert.Len(t, srv.ranges, 3)
+	get(t, client, ts.URL+"/b", "bytes=0-99", nil)
+	assert.Len(t, srv.ranges, 4)
+}
+
+func TestTransportRejected(t *testing.T) {
+	srv := &server{content: newContent(1000), etag: `"v1"`}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+	frozen := func() *store.Store[byte] {
+		s := store.NewStore[byte]()
+		s.Freeze()
+		return s
+	}
+	client := &http.Client{Transport: &httprange.Transport{NewStore: frozen}}
+
+	// Resources whose values the store rejects are dropped, and passed on as
+	// is rather than filled from upstream.
+	for i := 0; i < 2; i++ {
+		_, body := get(t, client, ts.URL, "", nil)
+		assert.Equal(t, srv.content, body)
+	}
+	assert.Equal(t, []string{"", ""}, srv.ranges)
+}
@@ -0,0 +1,17 @@
+module github.com/aertje/sparse-store/otelstore
+
+go 1.21
+
+require (
+	github.com/aertje/sparse-store v0.0.0-20261016073456-4f892ec5f604
+	github.com/stretchr/testify v1.8.4
+	go.opentelemetry.io/otel v1.24.0
+	go.opentelemetry.io/otel/metric v1.24.0
+	go.opentelemetry.io/otel/trace v1.24.0
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
@@ -0,0 +1,83 @@
+// Package otelstore instruments stores with OpenTelemetry. It records a span
+// for every compaction, so that compaction stalls show up in traces, and counts
+// reads and writes.
+package otelstore
+
+import (
+	"context"
+
+	"github.com/aertje/sparse-store/store"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Instrumentation implements store.Instrumentation with OpenTelemetry. Pass it
+// to store.WithInstrumentation.
+type Instrumentation struct {
+	tracer trace.Tracer
+
+	sets      metric.Int64Counter
+	setValues metric.Int64Counter
+	gets      metric.Int64Counter
+	getValues metric.Int64Counter
+}
+
+var _ store.Instrumentation = (*Instrumentation)(nil)
+
+var (
+	hit  = metric.WithAttributes(attribute.Bool("sparsestore.hit", true))
+	miss = metric.WithAttributes(attribute.Bool("sparsestore.hit", false))
+)
+
+// New returns instrumentation that records spans with `tracer` and counters
+// with `meter`.
+func New(tracer trace.Tracer, meter metric.Meter) (*Instrumentation, error) {
+	i := &Instrumentation{tracer: tracer}
+
+	var err error
+	if i.sets, err = meter.Int64Counter("sparsestore.sets", metric.WithDescription("Number of writes.")); err != nil {
+		return nil, err
+	}
+	if i.setValues, err = meter.Int64Counter("sparsestore.set.values", metric.WithDescription("Number of values written.")); err != nil {
+		return nil, err
+	}
+	if i.gets, err = meter.Int64Counter("sparsestore.gets", metric.WithDescription("Number of reads.")); err != nil {
+		return nil, err
+	}
+	if i.getValues, err = meter.Int64Counter("sparsestore.get.values", metric.WithDescription("Number of values read.")); err != nil {
+		return nil, err
+	}
+
+	return i, nil
+}
+
+// Compact starts a span for a compaction. The store methods do not take a
+// context, so it is a root span.
+func (i *Instrumentation) Compact(extents int) func(extents int) {
+	_, span := i.tracer.Start(context.Background(), "sparsestore.Compact",
+		trace.WithAttributes(attribute.Int("sparsestore.extents", extents)))
+
+	return func(extents int) {
+		span.SetAttributes(attribute.Int("sparsestore.compacted_extents", extents))
+		span.End()
+	}
+}
+
+// Set counts a write.
+func (i *Instrumentation) Set(length int64) {
+	ctx := context.Background()
+	i.sets.Add(ctx, 1)
+	i.setValues.Add(ctx, length)
+}
+
+// Get counts a read, along with whether it was a hit.
+func (i *Instrumentation) Get(length int64, ok bool) {
+	ctx := context.Background()
+	opt := miss
+	if ok {
+		opt = hit
+	}
+	i.gets.Add(ctx, 1, opt)
+	i.getValues.Add(ctx, length, opt)
+}
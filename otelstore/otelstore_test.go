@@ -0,0 +1,95 @@
+package otelstore_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aertje/sparse-store/otelstore"
+	"github.com/aertje/sparse-store/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	metricnoop "go.opentelemetry.io/otel/metric/noop"
+	"go.opentelemetry.io/otel/trace"
+	tracenoop "go.opentelemetry.io/otel/trace/noop"
+)
+
+// tracer records the names and attributes of the spans it starts.
+type tracer struct {
+	tracenoop.Tracer
+	spans []*span
+}
+
+type span struct {
+	tracenoop.Span
+	name       string
+	attributes []attribute.KeyValue
+	ended      bool
+}
+
+func (t *tracer) Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	config := trace.NewSpanStartConfig(opts...)
+	s := &span{name: name, attributes: config.Attributes()}
+	t.spans = append(t.spans, s)
+	return ctx, s
+}
+
+func (s *span) SetAttributes(kv ...attribute.KeyValue) { s.attributes = append(s.attributes, kv...) }
+
+func (s *span) End(...trace.SpanEndOption) { s.ended = true }
+
+// meter records the totals of its counters by name and attributes.
+type meter struct {
+	metricnoop.Meter
+	totals map[string]int64
+}
+
+type counter struct {
+	metricnoop.Int64Counter
+	name   string
+	totals map[string]int64
+}
+
+func (m *meter) Int64Counter(name string, _ ...metric.Int64CounterOption) (metric.Int64Counter, error) {
+	return &counter{name: name, totals: m.totals}, nil
+}
+
+func (c *counter) Add(_ context.Context, incr int64, opts ...metric.AddOption) {
+	key := c.name
+	attrs := metric.NewAddConfig(opts).Attributes()
+	for it := attrs.Iter(); it.Next(); {
+		key += "," + string(it.Attribute().Key) + "=" + it.Attribute().Value.Emit()
+	}
+	c.totals[key] += incr
+}
+
+func TestInstrumentation(t *testing.T) {
+	tr := &tracer{}
+	m := &meter{totals: map[string]int64{}}
+	instrumentation, err := otelstore.New(tr, m)
+	require.NoError(t, err)
+
+	s := store.NewStore(store.WithInstrumentation[byte](instrumentation), store.WithLazyCompaction[byte](100, 1<<10))
+	s.Set([]byte{0, 1}, 0)
+	s.Set([]byte{2, 3, 4}, 2)
+	s.Get(make([]byte, 5), 0)
+	s.Get(make([]byte, 2), 5)
+
+	require.Len(t, tr.spans, 1)
+	assert.Equal(t, "sparsestore.Compact", tr.spans[0].name)
+	assert.True(t, tr.spans[0].ended)
+	assert.Equal(t, []attribute.KeyValue{
+		attribute.Int("sparsestore.extents", 2),
+		attribute.Int("sparsestore.compacted_extents", 1),
+	}, tr.spans[0].attributes)
+
+	assert.Equal(t, map[string]int64{
+		"sparsestore.sets":                             2,
+		"sparsestore.set.values":                       5,
+		"sparsestore.gets,sparsestore.hit=true":        1,
+		"sparsestore.get.values,sparsestore.hit=true":  5,
+		"sparsestore.gets,sparsestore.hit=false":       1,
+		"sparsestore.get.values,sparsestore.hit=false": 2,
+	}, m.totals)
+}
@@ -0,0 +1,15 @@
+module github.com/aertje/sparse-store/ratefill
+
+go 1.21
+
+require (
+	github.com/aertje/sparse-store v0.0.0-20261016073456-4f892ec5f604
+	github.com/stretchr/testify v1.8.4
+	golang.org/x/time v0.5.0
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
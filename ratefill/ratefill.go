@@ -0,0 +1,180 @@
+// Package ratefill fills stores from a Fetcher under a rate limit, for sources
+// that throttle their clients. The values fetched are limited both per second,
+// by a rate.Limiter, and in flight, so that a slow source pushes back on the
+// fill loop instead of piling up requests.
+package ratefill
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+
+	"github.com/aertje/sparse-store/store"
+	"golang.org/x/time/rate"
+)
+
+const defaultWorkers = 4
+
+// Scheduler fills stores with the values they are missing, fetching them in
+// the ranges planned by Store.Plan. The zero value of any field selects its
+// default.
+type Scheduler[T any] struct {
+	// Fetcher fetches the missing values.
+	Fetcher store.Fetcher[T]
+	// Limiter limits the number of values fetched per second, with one token
+	// per value. Requests larger than its burst wait for it a burst at a time.
+	// There is no limit by default.
+	Limiter *rate.Limiter
+	// MaxInFlight is the maximum number of values being fetched at once,
+	// unlimited by default. A request larger than it is only made when no
+	// others are in flight.
+	MaxInFlight int64
+	// Workers is the maximum number of requests made at once, 4 by default.
+	Workers int
+	// Plan configures the requests.
+	Plan store.PlanOptions
+
+	mu sync.Mutex
+	// queue holds the requests yet to be made, sorted by offset.
+	queue []store.Range
+	// next is the offset to make requests from first.
+	next int64
+}
+
+// result is the outcome of a request.
+type result[T any] struct {
+	r      store.Range
+	values []T
+	err    error
+}
+
+// Fill fills the `length` values at `offset` in `s` that it is missing.
+// Requests are made in order of offset, starting from the offset given to
+// Prioritize, if any. Values fetched are kept in `s` if Fill fails. `s` must
+// not be used until Fill returns.
+func (sc *Scheduler[T]) Fill(ctx context.Context, s *store.Store[T], length, offset int64) error {
+	sc.mu.Lock()
+	sc.queue = s.Plan(length, offset, sc.Plan)
+	sc.mu.Unlock()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	workers := sc.Workers
+	if workers <= 0 {
+		workers = defaultWorkers
+	}
+	results := make(chan result[T], workers)
+
+	var (
+		firstErr error
+		running  int
+		inFlight int64
+	)
+	for {
+		// Make requests while there is room for them.
+		for firstErr == nil && running < workers {
+			r, ok := sc.pop(inFlight)
+			if !ok {
+				break
+			}
+			if err := sc.wait(ctx, r.Length); err != nil {
+				firstErr = err
+				break
+			}
+
+			running++
+			inFlight += r.Length
+			go func() {
+				values, err := sc.Fetcher.Fetch(ctx, r.Offset, r.Length)
+				if err == nil && int64(len(values)) < r.Length {
+					err = io.ErrUnexpectedEOF
+				}
+				results <- result[T]{r: r, values: values, err: err}
+			}()
+		}
+		if running == 0 {
+			break
+		}
+
+		res := <-results
+		running--
+		inFlight -= res.r.Length
+		if res.err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("fetching %d values at %d: %w", res.r.Length, res.r.Offset, res.err)
+			}
+			cancel()
+			continue
+		}
+		if err := s.SetOwned(res.values[:res.r.Length], res.r.Offset); err != nil && firstErr == nil {
+			firstErr = err
+			cancel()
+		}
+	}
+
+	sc.mu.Lock()
+	sc.queue = nil
+	sc.mu.Unlock()
+	return firstErr
+}
+
+// Prioritize makes the requests for the values from `offset` onwards be made
+// first, such as when a reader seeks there. It can be called while Fill runs.
+func (sc *Scheduler[T]) Prioritize(offset int64) {
+	sc.mu.Lock()
+	sc.next = offset
+	sc.mu.Unlock()
+}
+
+// pop removes the next request from the queue, if there is room for it with
+// `inFlight` values in flight.
+func (sc *Scheduler[T]) pop(inFlight int64) (store.Range, bool) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	if len(sc.queue) == 0 {
+		return store.Range{}, false
+	}
+	i, _ := slices.BinarySearchFunc(sc.queue, sc.next, func(r store.Range, next int64) int {
+		if r.End() <= next {
+			return -1
+		}
+		return 1
+	})
+	if i == len(sc.queue) {
+		// Wrap around to the requests before the priority.
+		i = 0
+	}
+
+	r := sc.queue[i]
+	if sc.MaxInFlight > 0 && inFlight > 0 && inFlight+r.Length > sc.MaxInFlight {
+		return store.Range{}, false
+	}
+	sc.queue = slices.Delete(sc.queue, i, i+1)
+	return r, true
+}
+
+// wait waits for the limiter to allow fetching `n` values.
+func (sc *Scheduler[T]) wait(ctx context.Context, n int64) error {
+	l := sc.Limiter
+	if l == nil || l.Limit() == rate.Inf {
+		return nil
+	}
+
+	burst := int64(l.Burst())
+	if burst <= 0 {
+		return errors.New("rate limiter has no burst")
+	}
+	for n > 0 {
+		k := min(n, burst)
+		if err := l.WaitN(ctx, int(k)); err != nil {
+			return err
+		}
+		n -= k
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package ratefill_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aertje/sparse-store/ratefill"
+	"github.com/aertje/sparse-store/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+)
+
+// source fetches values equal to their offset, recording the requests made
+// and the most values in flight at once.
+type source struct {
+	mu          sync.Mutex
+	requests    []store.Range
+	inFlight    int64
+	maxInFlight int64
+}
+
+func (s *source) Fetch(ctx context.Context, offset, length int64) ([]int64, error) {
+	s.mu.Lock()
+	s.requests = append(s.requests, store.Range{Offset: offset, Length: length})
+	s.inFlight += length
+	s.maxInFlight = max(s.maxInFlight, s.inFlight)
+	s.mu.Unlock()
+
+	time.Sleep(time.Millisecond)
+	values := make([]int64, length)
+	for i := range values {
+		values[i] = offset + int64(i)
+	}
+
+	s.mu.Lock()
+	s.inFlight -= length
+	s.mu.Unlock()
+	return values, nil
+}
+
+func assertFilled(t *testing.T, s *store.Store[int64], length, offset int64) {
+	t.Helper()
+	values := make([]int64, length)
+	require.True(t, s.Get(values, offset))
+	for i, v := range values {
+		assert.Equal(t, offset+int64(i), v)
+	}
+}
+
+func TestSchedulerFill(t *testing.T) {
+	src := &source{}
+	sc := &ratefill.Scheduler[int64]{
+		Fetcher:     src,
+		MaxInFlight: 250,
+		Plan:        store.PlanOptions{MaxRequest: 100},
+	}
+
+	s := store.NewStore[int64]()
+	s.Set(make([]int64, 100), 1000)
+	require.NoError(t, sc.Fill(context.Background(), s, 1000, 0))
+
+	assertFilled(t, s, 1000, 0)
+	assert.Len(t, src.requests, 10)
+	assert.LessOrEqual(t, src.maxInFlight, int64(250))
+
+	// The values present are not fetched again.
+	src.requests = nil
+	require.NoError(t, sc.Fill(context.Background(), s, 1100, 0))
+	assert.Empty(t, src.requests)
+}
+
+func TestSchedulerLimiter(t *testing.T) {
+	sc := &ratefill.Scheduler[int64]{
+		Fetcher: &source{},
+		// A burst of 100 values, then 1000 values per second.
+		Limiter: rate.NewLimiter(1000, 100),
+		Plan:    store.PlanOptions{MaxRequest: 200},
+	}
+
+	s := store.NewStore[int64]()
+	start := time.Now()
+	require.NoError(t, sc.Fill(context.Background(), s, 400, 0))
+	assert.GreaterOrEqual(t, time.Since(start), 250*time.Millisecond)
+	assertFilled(t, s, 400, 0)
+}
+
+func TestSchedulerPrioritize(t *testing.T) {
+	src := &source{}
+	sc := &ratefill.Scheduler[int64]{
+		Fetcher: src,
+		Workers: 1,
+		Plan:    store.PlanOptions{MaxRequest: 100},
+	}
+
+	sc.Prioritize(250)
+	require.NoError(t, sc.Fill(context.Background(), store.NewStore[int64](), 400, 0))
+	assert.Equal(t, []store.Range{
+		{Offset: 200, Length: 100},
+		{Offset: 300, Length: 100},
+		{Offset: 0, Length: 100},
+		{Offset: 100, Length: 100},
+	}, src.requests)
+}
+
+func TestSchedulerError(t *testing.T) {
+	errFetch := errors.New("fetch failed")
+	sc := &ratefill.Scheduler[int64]{
+		Fetcher: store.FetcherFunc[int64](func(ctx context.Context, offset, length int64) ([]int64, error) {
+			if offset == 100 {
+				return nil, errFetch
+			}
+			return make([]int64, length), nil
+		}),
+		Workers: 1,
+		Plan:    store.PlanOptions{MaxRequest: 100},
+	}
+
+	s := store.NewStore[int64]()
+	assert.ErrorIs(t, sc.Fill(context.Background(), s, 400, 0), errFetch)
+	assert.True(t, s.Has(100, 0))
+	assert.False(t, s.Has(1, 300))
+}
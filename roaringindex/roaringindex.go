@@ -0,0 +1,310 @@
+// Package roaringindex provides a presence index for sparse stores backed by a
+// roaring bitmap, for stores that hold very many small disjoint ranges.
+//
+// Like other roaring bitmaps, the index splits the offsets into chunks of 2^16
+// values, each held by a container suited to its contents: a list of runs for
+// chunks with few runs, or a plain bitmap for chunks with many.
+package roaringindex
+
+import (
+	"math/bits"
+	"slices"
+	"sort"
+
+	"github.com/aertje/sparse-store/store"
+)
+
+const (
+	chunkBits = 16
+	chunkSize = 1 << chunkBits
+	// maxRuns is the number of runs above which a container is converted to
+	// a bitmap, which takes chunkSize/8 bytes, as each run takes 8.
+	maxRuns = chunkSize / 8 / 8
+	// minRuns is the number of runs below which a bitmap container is
+	// converted back, leaving a margin to avoid converting back and forth.
+	minRuns = maxRuns / 2
+)
+
+// Index is a roaring bitmap tracking the presence of values. It implements
+// store.PresenceIndex, and can be used on its own as a compact representation
+// of a set of ranges. The zero value is an empty index.
+type Index struct {
+	// keys holds the offsets of the chunks, shifted right by chunkBits, in
+	// ascending order.
+	keys       []int64
+	containers []*container
+}
+
+var _ store.PresenceIndex = (*Index)(nil)
+
+// New returns an empty index.
+func New() *Index {
+	return &Index{}
+}
+
+// FromRanges returns an index holding `ranges`, such as those returned by
+// Extents on a store.
+func FromRanges(ranges []store.Range) *Index {
+	idx := New()
+	for _, r := range ranges {
+		idx.Add(r.Offset, r.End())
+	}
+	return idx
+}
+
+// Ranges returns the ranges of present values, in order. Adjacent ranges are
+// coalesced.
+func (idx *Index) Ranges() []store.Range {
+	var ranges []store.Range
+	for i, c := range idx.containers {
+		base := idx.keys[i] << chunkBits
+		c.eachRun(func(start, end int) {
+			from, to := base+int64(start), base+int64(end)
+			if n := len(ranges); n > 0 && ranges[n-1].End() == from {
+				ranges[n-1].Length += to - from
+				return
+			}
+			ranges = append(ranges, store.Range{Offset: from, Length: to - from})
+		})
+	}
+	return ranges
+}
+
+// Add marks the values in [from, to) as present.
+func (idx *Index) Add(from, to int64) {
+	idx.eachChunk(from, to, func(key int64, lo, hi int) {
+		i, ok := slices.BinarySearch(idx.keys, key)
+		if !ok {
+			idx.keys = slices.Insert(idx.keys, i, key)
+			idx.containers = slices.Insert(idx.containers, i, &container{})
+		}
+		idx.containers[i].add(lo, hi)
+	})
+}
+
+// Remove marks the values in [from, to) as absent.
+func (idx *Index) Remove(from, to int64) {
+	idx.eachChunk(from, to, func(key int64, lo, hi int) {
+		i, ok := slices.BinarySearch(idx.keys, key)
+		if !ok {
+			return
+		}
+		c := idx.containers[i]
+		c.remove(lo, hi)
+		if c.empty() {
+			idx.keys = slices.Delete(idx.keys, i, i+1)
+			idx.containers = slices.Delete(idx.containers, i, i+1)
+		}
+	})
+}
+
+// Contains reports whether all values in [from, to) are present.
+func (idx *Index) Contains(from, to int64) bool {
+	return idx.Count(from, to) == max(0, to-from)
+}
+
+// Count returns the number of present values in [from, to).
+func (idx *Index) Count(from, to int64) int64 {
+	var n int64
+	idx.eachChunk(from, to, func(key int64, lo, hi int) {
+		if i, ok := slices.BinarySearch(idx.keys, key); ok {
+			n += int64(idx.containers[i].count(lo, hi))
+		}
+	})
+	return n
+}
+
+// Clear marks all values as absent.
+func (idx *Index) Clear() {
+	idx.keys = nil
+	idx.containers = nil
+}
+
+// SizeInBytes returns an estimate of the memory held by the index.
+func (idx *Index) SizeInBytes() int64 {
+	size := int64(cap(idx.keys))*8 + int64(cap(idx.containers))*8
+	for _, c := range idx.containers {
+		size += c.sizeInBytes()
+	}
+	return size
+}
+
+// eachChunk calls `fn` for every chunk overlapping [from, to), with the part
+// of the range within that chunk, relative to its start.
+func (idx *Index) eachChunk(from, to int64, fn func(key int64, lo, hi int)) {
+	for from < to {
+		key := from >> chunkBits
+		base := key << chunkBits
+		end := min(to, base+chunkSize)
+		fn(key, int(from-base), int(end-base))
+		from = end
+	}
+}
+
+// run is a range [start, end) of present values within a chunk.
+type run struct {
+	start, end uint32
+}
+
+// container holds the present values in a chunk, either as runs or, if bitmap
+// is set, as a bitmap.
+type container struct {
+	runs   []run
+	bitmap []uint64
+}
+
+func (c *container) empty() bool {
+	if c.bitmap != nil {
+		return c.count(0, chunkSize) == 0
+	}
+	return len(c.runs) == 0
+}
+
+func (c *container) add(lo, hi int) {
+	if c.bitmap != nil {
+		eachWord(lo, hi, func(w int, mask uint64) {
+			c.bitmap[w] |= mask
+		})
+		return
+	}
+
+	// Replace the runs overlapping or adjoining [lo, hi) with one covering all
+	// of them.
+	start, end := uint32(lo), uint32(hi)
+	i := sort.Search(len(c.runs), func(i int) bool { return c.runs[i].end >= start })
+	j := i
+	for j < len(c.runs) && c.runs[j].start <= end {
+		start = min(start, c.runs[j].start)
+		end = max(end, c.runs[j].end)
+		j++
+	}
+	c.runs = slices.Replace(c.runs, i, j, run{start, end})
+
+	if len(c.runs) > maxRuns {
+		c.toBitmap()
+	}
+}
+
+func (c *container) remove(lo, hi int) {
+	if c.bitmap != nil {
+		eachWord(lo, hi, func(w int, mask uint64) {
+			c.bitmap[w] &^= mask
+		})
+		if c.countRuns() < minRuns {
+			c.toRuns()
+		}
+		return
+	}
+
+	// Replace the runs overlapping [lo, hi) with what remains of them on
+	// either side.
+	start, end := uint32(lo), uint32(hi)
+	i := sort.Search(len(c.runs), func(i int) bool { return c.runs[i].end > start })
+	j := i
+	var remaining []run
+	for j < len(c.runs) && c.runs[j].start < end {
+		if r := c.runs[j]; r.start < start {
+			remaining = append(remaining, run{r.start, start})
+		}
+		if r := c.runs[j]; r.end > end {
+			remaining = append(remaining, run{end, r.end})
+		}
+		j++
+	}
+	c.runs = slices.Replace(c.runs, i, j, remaining...)
+}
+
+func (c *container) count(lo, hi int) int {
+	n := 0
+	if c.bitmap != nil {
+		eachWord(lo, hi, func(w int, mask uint64) {
+			n += bits.OnesCount64(c.bitmap[w] & mask)
+		})
+		return n
+	}
+
+	start, end := uint32(lo), uint32(hi)
+	i := sort.Search(len(c.runs), func(i int) bool { return c.runs[i].end > start })
+	for ; i < len(c.runs) && c.runs[i].start < end; i++ {
+		n += int(min(end, c.runs[i].end) - max(start, c.runs[i].start))
+	}
+	return n
+}
+
+// eachRun calls `fn` for every run of present values, in order.
+func (c *container) eachRun(fn func(start, end int)) {
+	if c.bitmap == nil {
+		for _, r := range c.runs {
+			fn(int(r.start), int(r.end))
+		}
+		return
+	}
+
+	start := -1
+	for i := 0; i < chunkSize; i++ {
+		present := c.bitmap[i/64]&(1<<(i%64)) != 0
+		switch {
+		case present && start < 0:
+			start = i
+		case !present && start >= 0:
+			fn(start, i)
+			start = -1
+		}
+	}
+	if start >= 0 {
+		fn(start, chunkSize)
+	}
+}
+
+// countRuns returns the number of runs in a bitmap container.
+func (c *container) countRuns() int {
+	n := 0
+	for w, word := range c.bitmap {
+		// A run starts at every set bit whose predecessor is not set.
+		prev := word << 1
+		if w > 0 {
+			prev |= c.bitmap[w-1] >> 63
+		}
+		n += bits.OnesCount64(word &^ prev)
+	}
+	return n
+}
+
+func (c *container) toBitmap() {
+	bitmap := make([]uint64, chunkSize/64)
+	for _, r := range c.runs {
+		eachWord(int(r.start), int(r.end), func(w int, mask uint64) {
+			bitmap[w] |= mask
+		})
+	}
+	c.bitmap = bitmap
+	c.runs = nil
+}
+
+func (c *container) toRuns() {
+	var runs []run
+	c.eachRun(func(start, end int) {
+		runs = append(runs, run{uint32(start), uint32(end)})
+	})
+	c.runs = runs
+	c.bitmap = nil
+}
+
+func (c *container) sizeInBytes() int64 {
+	return 48 + int64(cap(c.runs))*8 + int64(cap(c.bitmap))*8
+}
+
+// eachWord calls `fn` for every bitmap word overlapping [lo, hi), with the
+// mask of the bits in the range.
+func eachWord(lo, hi int, fn func(w int, mask uint64)) {
+	for w := lo / 64; w*64 < hi; w++ {
+		mask := ^uint64(0)
+		if l := lo - w*64; l > 0 {
+			mask &= ^uint64(0) << l
+		}
+		if h := hi - w*64; h < 64 {
+			mask &= ^uint64(0) >> (64 - h)
+		}
+		fn(w, mask)
+	}
+}
@@ -0,0 +1,89 @@
+package roaringindex_test
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/aertje/sparse-store/roaringindex"
+	"github.com/aertje/sparse-store/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIndexRandom(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	idx := roaringindex.New()
+
+	// Span a few chunks, including negative offsets, with enough small ranges
+	// to have containers converted to bitmaps and back.
+	const from, to = -1 << 17, 1 << 17
+	present := make([]bool, to-from)
+
+	for i := 0; i < 20000; i++ {
+		offset := from + r.Int63n(to-from)
+		end := min(to, offset+r.Int63n(8))
+		add := i < 10000 || r.Intn(2) == 0
+		// Clear out larger ranges for a while, so that containers are
+		// converted back to runs.
+		if i >= 14000 && i < 15000 {
+			end = min(to, offset+r.Int63n(1<<10))
+			add = false
+		}
+		if add {
+			idx.Add(offset, end)
+		} else {
+			idx.Remove(offset, end)
+		}
+		for j := offset; j < end; j++ {
+			present[j-from] = add
+		}
+
+		if i%1000 != 0 {
+			continue
+		}
+
+		lo := from + r.Int63n(to-from)
+		hi := min(to, lo+r.Int63n(1<<17))
+		var count int64
+		for j := lo; j < hi; j++ {
+			if present[j-from] {
+				count++
+			}
+		}
+		assert.Equal(t, count, idx.Count(lo, hi))
+		assert.Equal(t, count == hi-lo, idx.Contains(lo, hi))
+	}
+
+	var expected []store.Range
+	for j := int64(from); j < to; j++ {
+		if !present[j-from] {
+			continue
+		}
+		if n := len(expected); n > 0 && expected[n-1].End() == j {
+			expected[n-1].Length++
+		} else {
+			expected = append(expected, store.Range{Offset: j, Length: 1})
+		}
+	}
+	assert.Equal(t, expected, idx.Ranges())
+	assert.Equal(t, expected, roaringindex.FromRanges(expected).Ranges())
+	assert.Positive(t, idx.SizeInBytes())
+}
+
+func TestIndexStore(t *testing.T) {
+	idx := roaringindex.New()
+	s := store.NewStore(store.WithPresenceIndex[byte](idx), store.WithMinContiguous[byte](1))
+
+	for i := int64(0); i < 1000; i++ {
+		s.Set([]byte{1}, i*3)
+	}
+	s.Set([]byte{1, 1, 1}, 3)
+
+	assert.True(t, s.Has(4, 3))
+	assert.False(t, s.Has(4, 6))
+	assert.Equal(t, int64(1002), s.Coverage(3000, 0))
+
+	assert.Equal(t, idx.Ranges(), roaringindex.FromRanges(s.Extents()).Ranges())
+
+	s.Clear()
+	assert.Nil(t, idx.Ranges())
+}
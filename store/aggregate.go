@@ -0,0 +1,74 @@
+package store
+
+// Number is a numeric type that can be aggregated.
+type Number interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr |
+		~float32 | ~float64
+}
+
+// within calls `fn` with the parts of the extents within the range at `offset`
+// with length `length`, in order.
+func (c *Store[T]) within(length, offset int64, fn func(e entry[T])) {
+	c.Compact()
+	c.expire()
+
+	end := endOf(length, offset)
+	for i := c.from(offset); i < len(c.entries) && c.entries[i].offset < end; i++ {
+		e := c.entries[i]
+		fn(e.slice(max(e.offset, offset), min(e.end(), end)))
+	}
+}
+
+// Sum returns the sum of the values of `s` held within the range at `offset`
+// with length `length`, and the number of values held, which are all that
+// count towards it.
+func Sum[T Number](s *Store[T], length, offset int64) (T, int64) {
+	var sum T
+	var coverage int64
+	s.within(length, offset, func(e entry[T]) {
+		coverage += e.size()
+		if e.run {
+			sum += e.value * T(e.runLength)
+			return
+		}
+		for _, v := range e.data {
+			sum += v
+		}
+	})
+	return sum, coverage
+}
+
+// Min returns the smallest of the values of `s` held within the range at
+// `offset` with length `length`, or zero if there are none, and the number of
+// values held.
+func Min[T Number](s *Store[T], length, offset int64) (T, int64) {
+	return extreme(s, length, offset, func(a, b T) bool { return a < b })
+}
+
+// Max returns the largest of the values of `s` held within the range at
+// `offset` with length `length`, or zero if there are none, and the number of
+// values held.
+func Max[T Number](s *Store[T], length, offset int64) (T, int64) {
+	return extreme(s, length, offset, func(a, b T) bool { return a > b })
+}
+
+// extreme returns the value held within the range that no other value is
+// `better` than, and the number of values held.
+func extreme[T Number](s *Store[T], length, offset int64, better func(a, b T) bool) (T, int64) {
+	var result T
+	var coverage int64
+	s.within(length, offset, func(e entry[T]) {
+		values := e.data
+		if e.run {
+			values = []T{e.value}
+		}
+		for i, v := range values {
+			if (coverage == 0 && i == 0) || better(v, result) {
+				result = v
+			}
+		}
+		coverage += e.size()
+	})
+	return result, coverage
+}
@@ -0,0 +1,45 @@
+package store_test
+
+import (
+	"testing"
+
+	"github.com/aertje/sparse-store/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAggregates(t *testing.T) {
+	s := store.NewStore(store.WithMinContiguous[float64](1))
+	s.Set([]float64{3, -1.5, 4}, 10)
+	s.Fill(2, 1000, 20)
+
+	sum, coverage := store.Sum(s, 27, 0)
+	assert.Equal(t, 5.5+2*7, sum)
+	assert.Equal(t, int64(10), coverage)
+
+	sum, coverage = store.Sum(s, 1<<20, 11)
+	assert.Equal(t, 2.5+2000, sum)
+	assert.Equal(t, int64(1002), coverage)
+
+	low, coverage := store.Min(s, 100, 0)
+	assert.Equal(t, -1.5, low)
+	assert.Equal(t, int64(83), coverage)
+	high, _ := store.Max(s, 100, 0)
+	assert.Equal(t, 4.0, high)
+	high, _ = store.Max(s, 100, 13)
+	assert.Equal(t, 2.0, high)
+
+	// A range without values.
+	low, coverage = store.Min(s, 5, 0)
+	assert.Equal(t, 0.0, low)
+	assert.Equal(t, int64(0), coverage)
+}
+
+func TestAggregatesIntegers(t *testing.T) {
+	s := store.NewStore[int16]()
+	s.Set([]int16{-7, 9}, 3)
+
+	sum, _ := store.Sum(s, 10, 0)
+	assert.Equal(t, int16(2), sum)
+	low, _ := store.Min(s, 10, 4)
+	assert.Equal(t, int16(9), low)
+}
@@ -0,0 +1,129 @@
+package store
+
+// ARC is an Evictor that balances recency and frequency by itself, after the
+// adaptive replacement cache. It tells the extents not read since they were set
+// from those read since, and evicts the least recently used of the former
+// while they hold more than a target number of values, and of the latter
+// otherwise. It remembers the ranges it evicted recently: writes to those of
+// the former kind raise the target, favoring recency, and writes to those of
+// the latter kind lower it, favoring frequency. This suits access patterns that
+// alternate between streaming and hot spots.
+//
+// An ARC keeps state, so it must only be used by a single store.
+type ARC struct {
+	// target is the number of values the extents not read since they were set
+	// should hold.
+	target int64
+	// recentFirst is set if those extents are evicted first in the current
+	// eviction.
+	recentFirst bool
+	// recent and frequent hold the ranges evicted recently of either kind.
+	recent, frequent ghosts
+	capacity         int64
+}
+
+var _ EvictionObserver = (*ARC)(nil)
+
+// NewARC returns an ARC, for WithMaxOccupancy.
+func NewARC() *ARC {
+	return &ARC{}
+}
+
+// EvictsBefore implements Evictor.
+func (a *ARC) EvictsBefore(x, y ExtentUsage) bool {
+	if xRecent, yRecent := x.Reads == 0, y.Reads == 0; xRecent != yRecent {
+		return xRecent == a.recentFirst
+	}
+	return x.Accessed < y.Accessed
+}
+
+// Written implements EvictionObserver.
+func (a *ARC) Written(r Range) {
+	// The target moves by the values written again, and by more if the ghosts
+	// of the other kind hold more values.
+	recent, frequent := a.recent.size, a.frequent.size
+	if n := a.recent.remove(r); n > 0 {
+		a.target = min(a.target+n*max(frequent/recent, 1), a.capacity)
+	}
+	if n := a.frequent.remove(r); n > 0 {
+		a.target = max(a.target-n*max(recent/frequent, 1), 0)
+	}
+}
+
+// Evicting implements EvictionObserver.
+func (a *ARC) Evicting(extents []ExtentUsage, occupancy, maxOccupancy int64) {
+	a.capacity = maxOccupancy
+	a.target = min(a.target, a.capacity)
+
+	var recent int64
+	for _, e := range extents {
+		if e.Reads == 0 {
+			recent += e.Length
+		}
+	}
+	a.recentFirst = recent > a.target
+}
+
+// Evicted implements EvictionObserver.
+func (a *ARC) Evicted(extent ExtentUsage) {
+	if extent.Reads == 0 {
+		a.recent.add(extent.Range, a.capacity)
+	} else {
+		a.frequent.add(extent.Range, a.capacity)
+	}
+}
+
+// ghosts holds the ranges of extents evicted recently, oldest first, up to a
+// number of values.
+type ghosts struct {
+	ranges []Range
+	size   int64
+}
+
+// add adds `r`, and forgets the oldest ranges while they hold more than
+// `capacity` values.
+func (g *ghosts) add(r Range, capacity int64) {
+	g.ranges = append(g.ranges, r)
+	g.size += r.Length
+	for g.size > capacity && len(g.ranges) > 0 {
+		g.size -= g.ranges[0].Length
+		g.ranges = g.ranges[1:]
+	}
+}
+
+// remove forgets the parts of the ranges that overlap `r`, and returns the
+// number of values they held.
+func (g *ghosts) remove(r Range) int64 {
+	overlaps := func(o Range) bool {
+		return o.Offset < r.End() && r.Offset < o.End()
+	}
+	i := 0
+	for i < len(g.ranges) && !overlaps(g.ranges[i]) {
+		i++
+	}
+	if i == len(g.ranges) {
+		return 0
+	}
+
+	// Ranges may be split in two, so they are copied.
+	kept := make([]Range, i, len(g.ranges)+1)
+	copy(kept, g.ranges)
+	var removed int64
+	for _, o := range g.ranges[i:] {
+		if !overlaps(o) {
+			kept = append(kept, o)
+			continue
+		}
+		from, to := max(o.Offset, r.Offset), min(o.End(), r.End())
+		removed += to - from
+		if o.Offset < from {
+			kept = append(kept, Range{Offset: o.Offset, Length: from - o.Offset})
+		}
+		if to < o.End() {
+			kept = append(kept, Range{Offset: to, Length: o.End() - to})
+		}
+	}
+	g.ranges = kept
+	g.size -= removed
+	return removed
+}
@@ -0,0 +1,58 @@
+package store_test
+
+import (
+	"testing"
+
+	"github.com/aertje/sparse-store/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestARCScan(t *testing.T) {
+	s := store.NewStore(store.WithMinContiguous[byte](1), store.WithMaxOccupancy[byte](8, store.NewARC()))
+	data := make([]byte, 2)
+	for offset := int64(0); offset < 12; offset += 4 {
+		s.Set([]byte{1, 2}, offset)
+		s.Get(data, offset)
+	}
+
+	// Values set once by a scan are evicted before those read since.
+	for offset := int64(100); offset < 200; offset += 4 {
+		s.Set([]byte{3, 4}, offset)
+	}
+	assert.Equal(t, int64(8), s.Occupancy())
+	assert.True(t, s.Has(2, 0))
+	assert.True(t, s.Has(2, 4))
+	assert.True(t, s.Has(2, 8))
+	assert.True(t, s.Has(2, 196))
+}
+
+func TestARCAdapts(t *testing.T) {
+	s := store.NewStore(store.WithMinContiguous[byte](1), store.WithMaxOccupancy[byte](8, store.NewARC()))
+	data := make([]byte, 2)
+	s.Set([]byte{1, 2}, 0)
+	s.Get(data, 0)
+	s.Set([]byte{1, 2}, 4)
+	s.Get(data, 4)
+
+	// A stream evicts its own values first at first.
+	for _, offset := range []int64{8, 12, 16, 20} {
+		s.Set([]byte{3, 4}, offset)
+	}
+	assert.Equal(t, []store.Range{{Offset: 0, Length: 2}, {Offset: 4, Length: 2}, {Offset: 16, Length: 2}, {Offset: 20, Length: 2}}, s.Extents())
+
+	// Writing the values evicted from the stream again raises the target for
+	// them, until the values read before are evicted instead.
+	for _, offset := range []int64{8, 12} {
+		s.Set([]byte{3, 4}, offset)
+	}
+	assert.Equal(t, []store.Range{{Offset: 0, Length: 2}, {Offset: 4, Length: 2}, {Offset: 8, Length: 2}, {Offset: 12, Length: 2}}, s.Extents())
+	s.Set([]byte{3, 4}, 16)
+	assert.Equal(t, []store.Range{{Offset: 4, Length: 2}, {Offset: 8, Length: 2}, {Offset: 12, Length: 2}, {Offset: 16, Length: 2}}, s.Extents())
+
+	// Writing the value read before again lowers the target, so the stream is
+	// evicted first again.
+	s.Set([]byte{1, 2}, 0)
+	s.Get(data, 0)
+	assert.True(t, s.Has(2, 0))
+	assert.True(t, s.Has(2, 4))
+}
@@ -0,0 +1,208 @@
+package store
+
+import (
+	"errors"
+	"io"
+	"slices"
+)
+
+// WithWriteThrough makes the store write the values set to `w` as well, before
+// storing them. If writing fails, the write returns the error and the store is
+// left unchanged. Delete and eviction do not affect `w`.
+func WithWriteThrough(w io.WriterAt) Option[byte] {
+	return func(c *Store[byte]) {
+		c.backing = writerAt(w)
+		c.writeBack = false
+	}
+}
+
+// WithWriteBack makes the store keep track of the values set, and write them
+// to `w` on Flush, or before they are evicted or expire. Errors writing them
+// on eviction or expiry are returned by the next Flush. Values that are
+// deleted, or cleared, before being written are not written at all.
+func WithWriteBack(w io.WriterAt) Option[byte] {
+	return func(c *Store[byte]) {
+		c.backing = writerAt(w)
+		c.writeBack = true
+	}
+}
+
+func writerAt(w io.WriterAt) func(p []byte, offset int64) error {
+	return func(p []byte, offset int64) error {
+		_, err := w.WriteAt(p, offset)
+		return err
+	}
+}
+
+// Flush writes the values set since the last flush to the writer configured
+// with WithWriteBack. Values that fail to be written are retried by the next
+// Flush.
+func (c *Store[T]) Flush() error {
+	defer c.enter("flush")()
+	// Values are written back before the store is frozen.
+	if !c.writeBack || c.frozen {
+		return nil
+	}
+	c.Compact()
+
+	err := c.backingErr
+	c.backingErr = nil
+	for _, e := range c.entries {
+		for _, r := range c.dirty.take(e.offset, e.end()) {
+			if werr := c.writeEntry(e.slice(r.Offset, r.End())); werr != nil {
+				c.dirty.add(r.Offset, r.End())
+				err = errors.Join(err, werr)
+			}
+		}
+	}
+
+	return err
+}
+
+// Dirty returns the number of values that were set but not written back yet.
+func (c *Store[T]) Dirty() int64 {
+	var dirty int64
+	for _, r := range c.dirty {
+		dirty += r.Length
+	}
+	return dirty
+}
+
+// writeThrough writes `e` to the backing writer, if it is to be written
+// through. Parts of `e` that values of a higher priority override are not
+// written.
+func (c *Store[T]) writeThrough(e entry[T]) error {
+	if c.backing == nil || c.writeBack {
+		return nil
+	}
+	if !c.prioritized {
+		return c.writeEntry(e)
+	}
+
+	unshadowed := rangeSet{{Offset: e.offset, Length: e.size()}}
+	shadow := func(other entry[T]) {
+		if other.priority > e.priority {
+			unshadowed.take(other.offset, other.end())
+		}
+	}
+	for i := c.from(e.offset); i < len(c.entries) && c.entries[i].offset < e.end(); i++ {
+		shadow(c.entries[i])
+	}
+	for _, other := range c.pending {
+		shadow(other)
+	}
+	for _, r := range unshadowed {
+		if err := c.writeEntry(e.slice(r.Offset, r.End())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// flushEntry writes the parts of `e` that are dirty to the backing writer, as
+// it is about to be removed. Errors are kept for the next Flush.
+func (c *Store[T]) flushEntry(e entry[T]) {
+	if !c.writeBack {
+		return
+	}
+
+	for _, r := range c.dirty.take(e.offset, e.end()) {
+		if err := c.writeEntry(e.slice(r.Offset, r.End())); err != nil {
+			c.backingErr = errors.Join(c.backingErr, err)
+		}
+	}
+}
+
+// writeEntry writes the values of `e` to the backing writer.
+func (c *Store[T]) writeEntry(e entry[T]) error {
+	if !e.run {
+		return c.backing(e.data, e.offset)
+	}
+
+	// Runs are written a chunk at a time.
+	chunk := make([]T, min(e.runLength, int64(c.mergeLimit())))
+	for i := range chunk {
+		chunk[i] = e.value
+	}
+	for offset := e.offset; offset < e.end(); offset += int64(len(chunk)) {
+		if err := c.backing(chunk[:min(int64(len(chunk)), e.end()-offset)], offset); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rangeSet is a set of values, as ranges that are sorted and neither overlap
+// nor adjoin.
+type rangeSet []Range
+
+// add adds the values in [from, to).
+func (s *rangeSet) add(from, to int64) {
+	if from >= to {
+		return
+	}
+
+	// Replace the ranges that overlap or adjoin with a single one.
+	i, _ := slices.BinarySearchFunc(*s, from, func(r Range, from int64) int {
+		if r.End() < from {
+			return -1
+		}
+		return 1
+	})
+	j := i
+	for j < len(*s) && (*s)[j].Offset <= to {
+		from = min(from, (*s)[j].Offset)
+		to = max(to, (*s)[j].End())
+		j++
+	}
+	*s = slices.Replace(*s, i, j, Range{Offset: from, Length: to - from})
+}
+
+// overlaps reports whether any of the values in [from, to) are in the set.
+func (s rangeSet) overlaps(from, to int64) bool {
+	i, _ := slices.BinarySearchFunc(s, from, func(r Range, from int64) int {
+		if r.End() <= from {
+			return -1
+		}
+		return 1
+	})
+	return i < len(s) && s[i].Offset < to && from < to
+}
+
+// take removes the values in [from, to), and returns the ranges of those that
+// were in the set.
+func (s *rangeSet) take(from, to int64) []Range {
+	if from >= to {
+		return nil
+	}
+
+	i, _ := slices.BinarySearchFunc(*s, from, func(r Range, from int64) int {
+		if r.End() <= from {
+			return -1
+		}
+		return 1
+	})
+	j := i
+	var taken []Range
+	for j < len(*s) && (*s)[j].Offset < to {
+		r := (*s)[j]
+		offset, end := max(r.Offset, from), min(r.End(), to)
+		taken = append(taken, Range{Offset: offset, Length: end - offset})
+		j++
+	}
+	if i == j {
+		return nil
+	}
+
+	// Keep the parts of the first and last range outside of [from, to).
+	var kept []Range
+	if first := (*s)[i]; first.Offset < from {
+		kept = append(kept, Range{Offset: first.Offset, Length: from - first.Offset})
+	}
+	if last := (*s)[j-1]; last.End() > to {
+		kept = append(kept, Range{Offset: to, Length: last.End() - to})
+	}
+	*s = slices.Replace(*s, i, j, kept...)
+
+	return taken
+}
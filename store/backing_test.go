@@ -0,0 +1,84 @@
+package store_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aertje/sparse-store/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// file is an in-memory io.WriterAt.
+type file struct {
+	data   []byte
+	writes int
+	err    error
+}
+
+func (f *file) WriteAt(p []byte, offset int64) (int, error) {
+	if f.err != nil {
+		return 0, f.err
+	}
+	if end := int(offset) + len(p); len(f.data) < end {
+		f.data = append(f.data, make([]byte, end-len(f.data))...)
+	}
+	f.writes++
+	return copy(f.data[offset:], p), nil
+}
+
+func TestStoreWriteThrough(t *testing.T) {
+	f := &file{}
+	s := store.NewStore(store.WithWriteThrough(f))
+
+	require.NoError(t, s.Set([]byte{1, 2}, 1))
+	require.NoError(t, s.Fill(7, 3, 4))
+	assert.Equal(t, []byte{0, 1, 2, 0, 7, 7, 7}, f.data)
+
+	f.err = errors.New("disk full")
+	assert.ErrorIs(t, s.Set([]byte{3}, 0), f.err)
+	assert.False(t, s.Has(1, 0))
+}
+
+func TestStoreWriteBack(t *testing.T) {
+	f := &file{}
+	s := store.NewStore(store.WithWriteBack(f), store.WithMinContiguous[byte](1))
+
+	s.Set([]byte{1, 2, 3}, 0)
+	s.Set([]byte{4, 5}, 1)
+	s.Set([]byte{6, 6}, 6)
+	s.Delete(1, 7)
+	assert.Equal(t, int64(4), s.Dirty())
+	assert.Empty(t, f.data)
+
+	require.NoError(t, s.Flush())
+	assert.Equal(t, []byte{1, 4, 5, 0, 0, 0, 6}, f.data)
+	assert.Equal(t, int64(0), s.Dirty())
+
+	writes := f.writes
+	require.NoError(t, s.Flush())
+	assert.Equal(t, writes, f.writes)
+}
+
+func TestStoreWriteBackOnEviction(t *testing.T) {
+	f := &file{}
+	s := store.NewStore(
+		store.WithWriteBack(f),
+		store.WithMinContiguous[byte](1),
+		store.WithMaxOccupancy[byte](2, store.EvictOldest),
+	)
+
+	s.Set([]byte{1, 2}, 0)
+	s.Set([]byte{3}, 4)
+	assert.Equal(t, []byte{1, 2}, f.data)
+	assert.Equal(t, int64(1), s.Dirty())
+
+	f.err = errors.New("disk full")
+	s.Set([]byte{5, 6}, 6)
+	assert.ErrorIs(t, s.Flush(), f.err)
+	assert.Equal(t, int64(2), s.Dirty())
+
+	f.err = nil
+	assert.NoError(t, s.Flush())
+	assert.Equal(t, []byte{1, 2, 0, 0, 0, 0, 5, 6}, f.data)
+}
@@ -0,0 +1,34 @@
+package store
+
+// BeginBatch starts a batch of writes, during which Set only records the new
+// extents, as in lazy mode, and compaction, the occupancy and eviction are
+// deferred until EndBatch, however many extents are pending. Reads during a
+// batch compact the extents set so far. Batches can be nested.
+func (c *Store[T]) BeginBatch() {
+	defer c.enter("begin-batch")()
+	c.batches++
+}
+
+// EndBatch ends the batch started by the matching BeginBatch, and compacts
+// the extents set during the outermost one. It panics if no batch is running.
+func (c *Store[T]) EndBatch() {
+	c.endBatch()
+	if c.batches == 0 && !c.frozen {
+		c.Compact()
+	}
+}
+
+func (c *Store[T]) endBatch() {
+	defer c.enter("end-batch")()
+	if c.batches == 0 {
+		panic("store: EndBatch without BeginBatch")
+	}
+	c.batches--
+}
+
+// Batch runs `fn` in a batch, as BeginBatch and EndBatch do, for bulk loading.
+func (c *Store[T]) Batch(fn func(*Store[T])) {
+	c.BeginBatch()
+	defer c.EndBatch()
+	fn(c)
+}
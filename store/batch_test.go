@@ -0,0 +1,68 @@
+package store_test
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/aertje/sparse-store/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatch(t *testing.T) {
+	batched := store.NewStore(store.WithInvariantChecks[byte](), store.WithMinContiguous[byte](1))
+	eager := store.NewStore(store.WithMinContiguous[byte](1))
+
+	r := rand.New(rand.NewSource(1))
+	batched.BeginBatch()
+	batched.Batch(func(s *store.Store[byte]) {
+		for i := 0; i < 1000; i++ {
+			p := make([]byte, 1+r.Intn(8))
+			r.Read(p)
+			offset := r.Int63n(4096)
+			require.NoError(t, s.Set(p, offset))
+			require.NoError(t, eager.Set(p, offset))
+		}
+	})
+	// Nothing is compacted until the outermost batch ends.
+	assert.Zero(t, batched.Stats().Extents)
+	assert.Equal(t, eager.Length(), batched.Length())
+	batched.EndBatch()
+
+	assert.Equal(t, eager.Stats().Extents, batched.Stats().Extents)
+	assert.Equal(t, eager.Occupancy(), batched.Occupancy())
+	assert.Equal(t, eager.Extents(), batched.Extents())
+	want, got := make([]byte, 4096), make([]byte, 4096)
+	eager.Get(want, 0)
+	batched.Get(got, 0)
+	assert.Equal(t, want, got)
+
+	assert.Panics(t, batched.EndBatch)
+}
+
+func TestBatchRead(t *testing.T) {
+	s := store.NewStore[byte]()
+	s.BeginBatch()
+	defer s.EndBatch()
+	require.NoError(t, s.Set([]byte{1, 2}, 0))
+	require.NoError(t, s.Set([]byte{3}, 1))
+
+	// Reads see the values set so far.
+	p := make([]byte, 2)
+	assert.True(t, s.Get(p, 0))
+	assert.Equal(t, []byte{1, 3}, p)
+}
+
+func BenchmarkStoreBatch(b *testing.B) {
+	buf := make([]byte, 16)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		s := store.NewStore(store.WithMinContiguous[byte](1))
+		s.Batch(func(s *store.Store[byte]) {
+			for j := 10000; j > 0; j-- {
+				s.Set(buf, int64(j*2*len(buf)))
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package store
+
+import (
+	"math/bits"
+)
+
+// PresenceIndex tracks which values a store contains, so that Has and Coverage
+// need not walk its extents.
+type PresenceIndex interface {
+	// Add marks the values in [from, to) as present.
+	Add(from, to int64)
+	// Remove marks the values in [from, to) as absent.
+	Remove(from, to int64)
+	// Contains reports whether all values in [from, to) are present.
+	Contains(from, to int64) bool
+	// Count returns the number of present values in [from, to).
+	Count(from, to int64) int64
+	// Clear marks all values as absent.
+	Clear()
+}
+
+// bitmap is a PresenceIndex that tracks the presence of individual values.
+// Offsets below zero are not tracked.
+type bitmap struct {
+	words []uint64
+}
+
+// eachWord calls `fn` for every word overlapping [from, to), with the mask of the
+// bits in the range.
+func (b *bitmap) eachWord(from, to int64, fn func(w int64, mask uint64) bool) {
+	from = max(from, 0)
+	for w := from / 64; w*64 < to; w++ {
+		mask := ^uint64(0)
+		if lo := from - w*64; lo > 0 {
+			mask &= ^uint64(0) << lo
+		}
+		if hi := to - w*64; hi < 64 {
+			mask &= ^uint64(0) >> (64 - hi)
+		}
+		if !fn(w, mask) {
+			return
+		}
+	}
+}
+
+// grow makes room for the bits up to `to`.
+func (b *bitmap) grow(to int64) {
+	if n := (to + 63) / 64; int64(len(b.words)) < n {
+		b.words = append(b.words, make([]uint64, n-int64(len(b.words)))...)
+	}
+}
+
+// Add marks [from, to) as present.
+func (b *bitmap) Add(from, to int64) {
+	b.grow(to)
+	b.eachWord(from, to, func(w int64, mask uint64) bool {
+		b.words[w] |= mask
+		return true
+	})
+}
+
+// Remove marks [from, to) as absent.
+func (b *bitmap) Remove(from, to int64) {
+	b.eachWord(from, min(to, int64(len(b.words))*64), func(w int64, mask uint64) bool {
+		b.words[w] &^= mask
+		return true
+	})
+}
+
+// Contains reports whether all of [from, to) is present.
+func (b *bitmap) Contains(from, to int64) bool {
+	if from < 0 || to > int64(len(b.words))*64 {
+		return from >= to
+	}
+	complete := true
+	b.eachWord(from, to, func(w int64, mask uint64) bool {
+		complete = b.words[w]&mask == mask
+		return complete
+	})
+	return complete
+}
+
+// Count returns the number of present values in [from, to).
+func (b *bitmap) Count(from, to int64) int64 {
+	var n int64
+	b.eachWord(from, min(to, int64(len(b.words))*64), func(w int64, mask uint64) bool {
+		n += int64(bits.OnesCount64(b.words[w] & mask))
+		return true
+	})
+	return n
+}
+
+// SizeInBytes returns the memory held by the bitmap.
+func (b *bitmap) SizeInBytes() int64 {
+	return int64(cap(b.words)) * 8
+}
+
+// Clear marks everything as absent.
+func (b *bitmap) Clear() {
+	b.words = b.words[:0]
+}
+
+// unmark updates the presence index, if any, for the removal of `e`.
+func (c *Store[T]) unmark(e entry[T]) {
+	if c.presence != nil {
+		c.presence.Remove(e.offset, e.end())
+	}
+}
@@ -0,0 +1,33 @@
+package store_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aertje/sparse-store/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStorePresenceBitmap(t *testing.T) {
+	s := store.NewStore(
+		store.WithPresenceBitmap[byte](),
+		store.WithMinContiguous[byte](1),
+		store.WithMaxOccupancy[byte](200, store.EvictOldest),
+	)
+
+	s.Set(make([]byte, 100), 10)
+	s.SetWithTTL(make([]byte, 50), 150, time.Millisecond)
+	assert.True(t, s.Has(100, 10))
+	assert.Equal(t, int64(150), s.Coverage(200, 0))
+
+	// Eviction and expiry are reflected in the bitmap.
+	s.Set(make([]byte, 60), 300)
+	assert.False(t, s.Has(100, 10))
+	time.Sleep(5 * time.Millisecond)
+	assert.Equal(t, int64(0), s.Coverage(50, 150))
+	assert.Equal(t, int64(60), s.Coverage(400, 0))
+
+	s.Clear()
+	assert.Equal(t, int64(0), s.Coverage(400, 0))
+	assert.False(t, s.Has(1, 300))
+}
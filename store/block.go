@@ -0,0 +1,128 @@
+package store
+
+import "errors"
+
+// ErrUnaligned is returned by writes that are not aligned to the block size of
+// a store configured with WithBlockSize.
+var ErrUnaligned = errors.New("write is not aligned to the block size")
+
+// AlignMode is what a store configured with WithBlockSize does with unaligned
+// writes.
+type AlignMode int
+
+const (
+	// RejectUnaligned makes unaligned writes fail with ErrUnaligned, and
+	// unaligned deletes delete nothing.
+	RejectUnaligned AlignMode = iota
+	// MergeUnaligned merges the partial blocks at either end of an unaligned
+	// write with the values the store holds for the rest of those blocks. The
+	// write fails with ErrUnaligned if those values are missing. Unaligned
+	// deletes only delete the whole blocks in their range.
+	MergeUnaligned
+)
+
+// WithBlockSize makes the store enforce that writes and deletes are aligned to
+// blocks of `size` values, such as the 512 or 4096 byte sectors of a block
+// device, handling unaligned ones according to `mode`. Runs set by Fill must
+// always be aligned.
+func WithBlockSize[T any](size int64, mode AlignMode) Option[T] {
+	return func(c *Store[T]) {
+		c.blockSize = size
+		c.alignMode = mode
+	}
+}
+
+// BlockSize returns the block size configured with WithBlockSize, or 1 if
+// there is none.
+func (c *Store[T]) BlockSize() int64 {
+	return max(c.blockSize, 1)
+}
+
+// Blocks returns the presence of the `count` blocks from block `first`
+// onwards. A block is present if all of its values are. It returns nil if
+// `count` is not positive.
+func (c *Store[T]) Blocks(first, count int64) []bool {
+	if count <= 0 {
+		return nil
+	}
+	size := c.BlockSize()
+	blocks := make([]bool, count)
+	for _, r := range present(c) {
+		// Only the blocks completely within the range are present.
+		from := max(first, (r.Offset+size-1)/size)
+		to := min(endOf(count, first), alignDown(r.End(), size)/size)
+		for b := from; b < to; b++ {
+			blocks[b-first] = true
+		}
+	}
+	return blocks
+}
+
+// aligned reports whether the range at `offset` with length `length` is
+// aligned to the block size.
+func (c *Store[T]) aligned(length, offset int64) bool {
+	return c.blockSize <= 1 || (offset%c.blockSize == 0 && length%c.blockSize == 0)
+}
+
+// align returns `e` aligned to the block size, merged with the values of its
+// partial blocks if the store is configured to do so.
+func (c *Store[T]) align(e entry[T]) (entry[T], error) {
+	if c.aligned(e.size(), e.offset) {
+		return e, nil
+	}
+	if c.alignMode != MergeUnaligned || e.run {
+		return e, ErrUnaligned
+	}
+
+	c.Compact()
+	c.expire()
+
+	from := alignDown(e.offset, c.blockSize)
+	to := alignDown(endOf(c.blockSize-1, e.end()), c.blockSize)
+	if to < e.end() {
+		return e, &BoundsError{Op: "set", Offset: e.offset, Length: e.size(), Err: ErrOverflow}
+	}
+	data := make([]T, to-from)
+	if !c.peek(data[:e.offset-from], from) || !c.peek(data[e.end()-from:], e.end()) {
+		return e, ErrUnaligned
+	}
+	copy(data[e.offset-from:], e.data)
+
+	e.offset, e.data, e.owned = from, data, true
+	return e, nil
+}
+
+// alignDelete returns the range at `offset` with length `length` to delete,
+// aligned to the block size, and whether anything is to be deleted.
+func (c *Store[T]) alignDelete(length, offset int64) (int64, int64, bool) {
+	if c.aligned(length, offset) {
+		return length, offset, true
+	}
+	if c.alignMode != MergeUnaligned {
+		return 0, 0, false
+	}
+
+	from := alignUp(offset, c.blockSize)
+	to := alignDown(endOf(length, offset), c.blockSize)
+	return to - from, from, to > from
+}
+
+// peek populates `p` with the values at `offset`, and reports whether all of
+// them are present. Unlike Get, it does not count as an access. The store must
+// be compacted.
+func (c *Store[T]) peek(p []T, offset int64) bool {
+	end := endOf(int64(len(p)), offset)
+	pos := offset
+	i := c.entries.Search(offset)
+	if i > 0 && c.entries[i-1].end() > offset {
+		i--
+	}
+	for ; i < len(c.entries) && c.entries[i].offset < end; i++ {
+		if c.entries[i].offset > pos {
+			return false
+		}
+		c.entries[i].read(p, offset)
+		pos = max(pos, c.entries[i].end())
+	}
+	return pos >= end
+}
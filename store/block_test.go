@@ -0,0 +1,49 @@
+package store_test
+
+import (
+	"testing"
+
+	"github.com/aertje/sparse-store/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStoreBlockSizeReject(t *testing.T) {
+	s := store.NewStore(store.WithBlockSize[byte](4, store.RejectUnaligned))
+	assert.Equal(t, int64(4), s.BlockSize())
+
+	require.NoError(t, s.Set([]byte("abcdefgh"), 4))
+	assert.ErrorIs(t, s.Set([]byte("xy"), 4), store.ErrUnaligned)
+	assert.ErrorIs(t, s.Set([]byte("wxyz"), 2), store.ErrUnaligned)
+	assert.ErrorIs(t, s.Fill('x', 3, 0), store.ErrUnaligned)
+	require.NoError(t, s.Fill('x', 4, 0))
+
+	assert.Zero(t, s.Delete(2, 4))
+	assert.Equal(t, int64(4), s.Delete(4, 4))
+	assert.Equal(t, []bool{true, false, true, false}, s.Blocks(0, 4))
+}
+
+func TestStoreBlockSizeMerge(t *testing.T) {
+	s := store.NewStore(store.WithBlockSize[byte](4, store.MergeUnaligned))
+
+	// The partial blocks need the rest of their values.
+	assert.ErrorIs(t, s.Set([]byte("xy"), 5), store.ErrUnaligned)
+	require.NoError(t, s.Set([]byte("abcdefghijkl"), 0))
+	require.NoError(t, s.Set([]byte("XYZ"), 3))
+
+	data := make([]byte, 12)
+	require.True(t, s.Get(data, 0))
+	assert.Equal(t, "abcXYZghijkl", string(data))
+	for _, e := range s.Extents() {
+		assert.Zero(t, e.Offset%4)
+		assert.Zero(t, e.Length%4)
+	}
+
+	// Deletes only delete whole blocks.
+	assert.Zero(t, s.Delete(3, 1))
+	assert.Equal(t, int64(4), s.Delete(7, 2))
+	assert.Equal(t, []bool{true, false, true}, s.Blocks(0, 3))
+	assert.Equal(t, []bool{false, true}, s.Blocks(1, 2))
+	assert.Nil(t, s.Blocks(1, -2))
+	assert.Nil(t, s.Blocks(0, 0))
+}
@@ -0,0 +1,146 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Errors wrapped by a BoundsError, for the ways a range can be out of bounds.
+var (
+	ErrNegativeOffset = errors.New("negative offset")
+	ErrNegativeLength = errors.New("negative length")
+	ErrOverflow       = errors.New("offset plus length overflows")
+)
+
+// BoundsError is returned for a range at `Offset` with length `Length` that is
+// out of bounds, with `Err` being the reason.
+type BoundsError struct {
+	Op     string
+	Offset int64
+	Length int64
+	Err    error
+}
+
+func (e *BoundsError) Error() string {
+	return fmt.Sprintf("%s of %d values at %d: %v", e.Op, e.Length, e.Offset, e.Err)
+}
+
+func (e *BoundsError) Unwrap() error {
+	return e.Err
+}
+
+// CheckBounds returns a BoundsError for operation `op` if the range at
+// `offset` with length `length` is out of bounds: if either is negative, or
+// its end overflows.
+func CheckBounds(op string, length, offset int64) error {
+	return checkBounds(op, length, offset, false)
+}
+
+// checkBounds is CheckBounds, accepting negative offsets if `signed` is set.
+func checkBounds(op string, length, offset int64, signed bool) error {
+	var err error
+	switch {
+	case offset < 0 && !signed:
+		err = ErrNegativeOffset
+	case length < 0:
+		err = ErrNegativeLength
+	case offset > math.MaxInt64-length:
+		err = ErrOverflow
+	default:
+		return nil
+	}
+	return &BoundsError{Op: op, Offset: offset, Length: length, Err: err}
+}
+
+// endOf returns the end of the range at `offset` with length `length`,
+// saturated at math.MaxInt64 rather than overflowing, for reads of ranges that
+// extend past the end of the offset domain.
+func endOf(length, offset int64) int64 {
+	if length > 0 && offset > math.MaxInt64-length {
+		return math.MaxInt64
+	}
+	return offset + length
+}
+
+// WithSignedOffsets documents that the store is used with negative offsets as
+// well, such as for a coordinate space centered on zero, and makes the checks
+// of WithStrictBounds and Checked accept them. The store supports negative
+// offsets either way, with Start returning the lowest one set, but the
+// heatmap, the presence bitmap and Verifier only cover offsets from zero.
+func WithSignedOffsets[T any]() Option[T] {
+	return func(c *Store[T]) {
+		c.signedOffsets = true
+	}
+}
+
+// WithStrictBounds makes writes, with Set, SetOwned, SetWithTTL or Fill,
+// return a BoundsError for negative offsets, rather than storing them. Writes
+// with a negative length, or whose end overflows, are always rejected. Checked
+// provides the reads and deletes that check their ranges.
+func WithStrictBounds[T any]() Option[T] {
+	return func(c *Store[T]) {
+		c.strictBounds = true
+	}
+}
+
+// checkBounds returns a BoundsError for operation `op` if the range at
+// `offset` with length `length` is out of bounds for the store, or empty with
+// EmptyReject.
+func (c *Store[T]) checkBounds(op string, length, offset int64) error {
+	if length == 0 && c.emptyPolicy == EmptyReject {
+		return &BoundsError{Op: op, Offset: offset, Err: ErrEmpty}
+	}
+	return checkBounds(op, length, offset, c.signedOffsets)
+}
+
+// Checked returns the reads and deletes of the store that return a BoundsError
+// for ranges out of bounds, rather than accepting them.
+func (c *Store[T]) Checked() Checked[T] {
+	return Checked[T]{store: c}
+}
+
+// Checked holds the reads and deletes of a store that check their ranges.
+type Checked[T any] struct {
+	store *Store[T]
+}
+
+// Get is like Store.Get, but checks the range first.
+func (c Checked[T]) Get(p []T, offset int64) (bool, error) {
+	if err := c.store.checkBounds("get", int64(len(p)), offset); err != nil {
+		return false, err
+	}
+	return c.store.Get(p, offset), nil
+}
+
+// Has is like Store.Has, but checks the range first.
+func (c Checked[T]) Has(length, offset int64) (bool, error) {
+	if err := c.store.checkBounds("has", length, offset); err != nil {
+		return false, err
+	}
+	return c.store.Has(length, offset), nil
+}
+
+// Coverage is like Store.Coverage, but checks the range first.
+func (c Checked[T]) Coverage(length, offset int64) (int64, error) {
+	if err := c.store.checkBounds("coverage", length, offset); err != nil {
+		return 0, err
+	}
+	return c.store.Coverage(length, offset), nil
+}
+
+// Gaps is like Store.Gaps, but checks the range first.
+func (c Checked[T]) Gaps(length, offset int64) ([]Range, error) {
+	if err := c.store.checkBounds("gaps", length, offset); err != nil {
+		return nil, err
+	}
+	return c.store.Gaps(length, offset), nil
+}
+
+// Delete is like Store.Delete, but checks the range first.
+func (c Checked[T]) Delete(length, offset int64) (int64, error) {
+	if err := c.store.checkBounds("delete", length, offset); err != nil {
+		return 0, err
+	}
+	return c.store.Delete(length, offset), nil
+}
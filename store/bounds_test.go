@@ -0,0 +1,101 @@
+package store_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/aertje/sparse-store/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStoreStrictBounds(t *testing.T) {
+	s := store.NewStore(store.WithStrictBounds[byte]())
+
+	err := s.Set([]byte{1}, -1)
+	assert.ErrorIs(t, err, store.ErrNegativeOffset)
+	var bounds *store.BoundsError
+	require.ErrorAs(t, err, &bounds)
+	assert.Equal(t, &store.BoundsError{Op: "set", Offset: -1, Length: 1, Err: store.ErrNegativeOffset}, bounds)
+
+	assert.ErrorIs(t, s.Fill(1, -5, 0), store.ErrNegativeLength)
+	assert.ErrorIs(t, s.Set([]byte{1, 2}, math.MaxInt64-1), store.ErrOverflow)
+	assert.Equal(t, int64(0), s.Occupancy())
+	assert.Equal(t, int64(0), s.Length())
+
+	require.NoError(t, s.Set([]byte{1, 2}, 0))
+}
+
+func TestStoreChecked(t *testing.T) {
+	s := store.NewStore[byte]()
+	s.Set([]byte{1, 2, 3}, 0)
+	checked := s.Checked()
+
+	has, err := checked.Has(2, 1)
+	require.NoError(t, err)
+	assert.True(t, has)
+
+	_, err = checked.Get(make([]byte, 2), -1)
+	assert.ErrorIs(t, err, store.ErrNegativeOffset)
+	_, err = checked.Coverage(-1, 0)
+	assert.ErrorIs(t, err, store.ErrNegativeLength)
+	_, err = checked.Gaps(math.MaxInt64, 1)
+	assert.ErrorIs(t, err, store.ErrOverflow)
+
+	_, err = checked.Delete(-1, 0)
+	assert.ErrorIs(t, err, store.ErrNegativeLength)
+	assert.Equal(t, int64(3), s.Occupancy())
+	removed, err := checked.Delete(1, 0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), removed)
+}
+
+func TestStoreOverflow(t *testing.T) {
+	s := store.NewStore(store.WithMinContiguous[byte](1))
+
+	// Sentinel regions near 2^62 are fine.
+	require.NoError(t, s.Fill(1, 1<<20, 1<<62))
+	require.NoError(t, s.Set([]byte{2}, math.MaxInt64-1))
+
+	// Writes whose end overflows are rejected, with or without strict bounds.
+	assert.ErrorIs(t, s.Set([]byte{1, 2}, math.MaxInt64-1), store.ErrOverflow)
+	assert.ErrorIs(t, s.Fill(1, math.MaxInt64, 1<<62), store.ErrOverflow)
+	assert.ErrorIs(t, s.Fill(1, -1, 0), store.ErrNegativeLength)
+	assert.Equal(t, int64(1<<20+1), s.Occupancy())
+	assert.Equal(t, int64(math.MaxInt64), s.Length())
+
+	// Reads of ranges extending past the end of the domain are clipped to it.
+	assert.True(t, s.Has(1, math.MaxInt64-1))
+	assert.False(t, s.Has(math.MaxInt64, math.MaxInt64-1))
+	assert.False(t, s.Has(math.MaxInt64, 1<<62))
+	assert.Equal(t, int64(1<<20+1), s.Coverage(math.MaxInt64, 1<<62))
+	assert.Equal(t, []store.Range{{Offset: 1<<62 + 1<<20, Length: math.MaxInt64 - 1 - (1<<62 + 1<<20)}}, s.Gaps(math.MaxInt64, 1<<62))
+	data := make([]byte, 4)
+	assert.False(t, s.Get(data, math.MaxInt64-1))
+	assert.Equal(t, []byte{2, 0, 0, 0}, data)
+	sum, coverage := store.Sum(s, math.MaxInt64, math.MaxInt64-2)
+	assert.Equal(t, byte(2), sum)
+	assert.Equal(t, int64(1), coverage)
+
+	assert.Equal(t, int64(1<<20+1), s.Delete(math.MaxInt64, 1<<62))
+	assert.Equal(t, int64(0), s.Occupancy())
+}
+
+func TestStoreOverflowBlocks(t *testing.T) {
+	s := store.NewStore(store.WithBlockSize[byte](4, store.MergeUnaligned))
+
+	// The last block of the domain is partial, so it cannot be completed.
+	assert.ErrorIs(t, s.Set([]byte{1}, math.MaxInt64-1), store.ErrOverflow)
+	require.NoError(t, s.Set([]byte{1, 2, 3, 4}, math.MaxInt64-7))
+	assert.Equal(t, int64(0), s.Delete(math.MaxInt64, math.MaxInt64-2))
+	assert.Equal(t, int64(4), s.Delete(math.MaxInt64, math.MaxInt64-8))
+}
+
+func TestPersistentOverflow(t *testing.T) {
+	var p store.Persistent[byte]
+	assert.NotPanics(t, func() { p.Set([]byte{1}, math.MaxInt64-1) })
+	assert.PanicsWithError(t, "set of 2 values at 9223372036854775806: offset plus length overflows", func() {
+		p.Set([]byte{1, 2}, math.MaxInt64-1)
+	})
+	assert.False(t, p.Has(math.MaxInt64, 0))
+}
@@ -0,0 +1,89 @@
+package store
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// width returns the size in bytes of the binary encoding of a T, or an error
+// if it is not of fixed size.
+func width[T any]() (int64, error) {
+	size := binary.Size(new(T))
+	if size <= 0 {
+		return 0, fmt.Errorf("%T has no fixed binary size", *new(T))
+	}
+	return int64(size), nil
+}
+
+// ToBytes returns a byte store, configured with `opts`, holding the values of
+// `s` encoded in `order` by encoding/binary, so that the value at offset `i`
+// is encoded at offset `i` times its size. T must be of fixed binary size,
+// such as a number or an array or struct of numbers.
+func ToBytes[T any](s *Store[T], order binary.ByteOrder, opts ...Option[byte]) (*Store[byte], error) {
+	size, err := width[T]()
+	if err != nil {
+		return nil, err
+	}
+
+	b := NewStore(opts...)
+	var buf bytes.Buffer
+	for _, e := range s.bridged() {
+		buf.Reset()
+		if err := binary.Write(&buf, order, e.data); err != nil {
+			return nil, err
+		}
+		if err := b.SetOwned(bytes.Clone(buf.Bytes()), e.offset*size); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// FromBytes returns a store, configured with `opts`, holding the values
+// decoded in `order` from `b`, the reverse of ToBytes. Values only partly held
+// by `b` are left out.
+func FromBytes[T any](b *Store[byte], order binary.ByteOrder, opts ...Option[T]) (*Store[T], error) {
+	size, err := width[T]()
+	if err != nil {
+		return nil, err
+	}
+
+	s := NewStore(opts...)
+	for _, e := range b.bridged() {
+		first := alignUp(e.offset, size) / size
+		last := alignDown(e.end(), size) / size
+		if first >= last {
+			continue
+		}
+
+		data := make([]T, last-first)
+		encoded := e.data[first*size-e.offset : last*size-e.offset]
+		if err := binary.Read(bytes.NewReader(encoded), order, data); err != nil {
+			return nil, err
+		}
+		if err := s.SetOwned(data, first); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// bridged returns the extents of the store as entries holding data, with
+// adjacent ones coalesced so that values are not split between them.
+func (c *Store[T]) bridged() entries[T] {
+	c.Compact()
+	c.expire()
+
+	var coalesced entries[T]
+	for _, e := range c.entries {
+		data := make([]T, e.size())
+		e.read(data, e.offset)
+		if n := len(coalesced); n > 0 && coalesced[n-1].end() == e.offset {
+			coalesced[n-1].data = append(coalesced[n-1].data, data...)
+			continue
+		}
+		coalesced = append(coalesced, entry[T]{offset: e.offset, data: data})
+	}
+	return coalesced
+}
@@ -0,0 +1,49 @@
+package store_test
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/aertje/sparse-store/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBytesBridge(t *testing.T) {
+	s := store.NewStore(store.WithMinContiguous[uint16](1))
+	s.Set([]uint16{0x0102, 0x0304}, 1)
+	s.Set([]uint16{0x0506}, 3)
+	s.Fill(0x0708, 2, 10)
+
+	b, err := store.ToBytes(s, binary.BigEndian)
+	require.NoError(t, err)
+	assert.Equal(t, []store.Range{{Offset: 2, Length: 6}, {Offset: 20, Length: 4}}, b.Extents())
+	data := make([]byte, 6)
+	assert.True(t, b.Get(data, 2))
+	assert.Equal(t, []byte{1, 2, 3, 4, 5, 6}, data)
+
+	back, err := store.FromBytes[uint16](b, binary.BigEndian)
+	require.NoError(t, err)
+	values := make([]uint16, 12)
+	assert.False(t, back.Get(values, 0))
+	assert.Equal(t, []uint16{0, 0x0102, 0x0304, 0x0506, 0, 0, 0, 0, 0, 0, 0x0708, 0x0708}, values)
+	assert.Equal(t, s.Occupancy(), back.Occupancy())
+}
+
+func TestFromBytesPartial(t *testing.T) {
+	b := store.NewStore[byte]()
+	b.Set([]byte{1, 0, 0, 0, 2, 0, 0}, 3)
+
+	s, err := store.FromBytes[int32](b, binary.LittleEndian)
+	require.NoError(t, err)
+	// Only the value at 4 to 8 is held entirely.
+	assert.Equal(t, []store.Range{{Offset: 1, Length: 1}}, s.Extents())
+	values := make([]int32, 1)
+	assert.True(t, s.Get(values, 1))
+	assert.Equal(t, []int32{0x02000000}, values)
+}
+
+func TestBytesBridgeVariableSize(t *testing.T) {
+	_, err := store.ToBytes(store.NewStore[string](), binary.LittleEndian)
+	assert.Error(t, err)
+}
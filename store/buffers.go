@@ -0,0 +1,128 @@
+package store
+
+import (
+	"math/bits"
+	"sync"
+)
+
+// minPooledBuffer is the capacity below which buffers are not worth pooling.
+const minPooledBuffer = 64
+
+// bufferPool recycles data buffers in power of two size classes. The classes
+// are allocated once the first buffer is recycled, which keeps stores that
+// never do so small.
+type bufferPool[T any] struct {
+	classes *[bits.UintSize]sync.Pool
+}
+
+// get returns a buffer of length `n`. Its contents are undefined.
+func (b *bufferPool[T]) get(n int) []T {
+	if n < minPooledBuffer {
+		return make([]T, n)
+	}
+
+	// Round up, so that any buffer in the class is large enough.
+	class := bits.Len(uint(n - 1))
+	if b.classes == nil {
+		return make([]T, n, 1<<class)
+	}
+	if buf, ok := b.classes[class].Get().(*[]T); ok {
+		return (*buf)[:n]
+	}
+
+	return make([]T, n, 1<<class)
+}
+
+// put makes `buf` available for reuse.
+func (b *bufferPool[T]) put(buf []T) {
+	if cap(buf) < minPooledBuffer {
+		return
+	}
+
+	if b.classes == nil {
+		b.classes = new([bits.UintSize]sync.Pool)
+	}
+
+	// Round down, so that every buffer in the class is large enough.
+	class := bits.Len(uint(cap(buf))) - 1
+	buf = buf[:cap(buf)]
+	// Don't keep any referenced values alive while pooled.
+	clear(buf)
+	b.classes[class].Put(&buf)
+}
+
+// alloc returns a buffer of length `n` owned by the store, from the arena if
+// there is one. Its contents are undefined.
+func (c *Store[T]) alloc(n int) []T {
+	if c.arena != nil {
+		return c.arena.alloc(n)
+	}
+	return c.buffers.get(n)
+}
+
+// release recycles the data of `e`, which must no longer be referenced, if it
+// is owned by the store. Data allocated from the arena is only released when
+// the arena is, and shared blocks when no entry refers to them anymore.
+func (c *Store[T]) release(e entry[T]) {
+	if e.shared != nil {
+		c.dedup.release(e.shared)
+		return
+	}
+	if e.owned && c.arena == nil {
+		c.buffers.put(e.data)
+	}
+}
+
+// arena allocates buffers from larger blocks, which are released all at once.
+type arena[T any] struct {
+	blockSize int
+	// block is the unallocated remainder of the current block.
+	block []T
+	// blocks holds all blocks allocated since the last reset, of which the
+	// first used are in use.
+	blocks [][]T
+	used   int
+}
+
+// alloc returns a buffer of length `n`. Its capacity is limited to its length,
+// so that appending to it never overwrites other buffers.
+func (a *arena[T]) alloc(n int) []T {
+	// Buffers that would take up most of a block are not worth sharing one.
+	if n > a.blockSize/2 {
+		return make([]T, n)
+	}
+
+	if len(a.block) < n {
+		if a.used < len(a.blocks) {
+			a.block = a.blocks[a.used]
+		} else {
+			a.block = make([]T, a.blockSize)
+			a.blocks = append(a.blocks, a.block)
+		}
+		a.used++
+	}
+
+	buf := a.block[:n:n]
+	a.block = a.block[n:]
+	return buf
+}
+
+// reset releases all blocks. Buffers allocated before remain valid, but are no
+// longer shared with new ones.
+func (a *arena[T]) reset() {
+	a.block = nil
+	clear(a.blocks)
+	a.blocks = a.blocks[:0]
+	a.used = 0
+}
+
+// rewind makes the blocks available for allocation again. Buffers allocated
+// before must no longer be used.
+func (a *arena[T]) rewind() {
+	for _, block := range a.blocks[:a.used] {
+		// Don't keep any referenced values alive.
+		clear(block)
+	}
+	a.block = nil
+	a.used = 0
+}
@@ -0,0 +1,65 @@
+package store_test
+
+import (
+	"testing"
+
+	"github.com/aertje/sparse-store/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStoreArena(t *testing.T) {
+	s := store.NewStore(store.WithArena[byte](8))
+
+	in := []byte{0, 1, 2}
+	s.Set(in, 0)
+	s.Set([]byte{3, 4, 5, 6, 7, 8}, 3)
+	s.Set([]byte{10}, 1)
+	in[0] = 20
+
+	data := make([]byte, 9)
+	assert.True(t, s.Get(data, 0))
+	assert.Equal(t, []byte{0, 10, 2, 3, 4, 5, 6, 7, 8}, data)
+
+	s.Clear()
+	assert.Equal(t, int64(0), s.Occupancy())
+	assert.Equal(t, int64(0), s.Length())
+	assert.False(t, s.Has(1, 0))
+
+	s.Set([]byte{1, 2}, 4)
+	data = make([]byte, 2)
+	assert.True(t, s.Get(data, 4))
+	assert.Equal(t, []byte{1, 2}, data)
+}
+
+func TestStoreClear(t *testing.T) {
+	s := store.NewStore(store.WithLazyCompaction[byte](4, 1<<10))
+
+	s.Set([]byte{0, 1}, 0)
+	s.Compact()
+	s.Set([]byte{2, 3}, 2)
+	s.Clear()
+
+	assert.Equal(t, int64(0), s.Occupancy())
+	assert.Equal(t, int64(0), s.Length())
+	assert.True(t, s.Has(0, 0))
+	assert.False(t, s.Has(1, 0))
+}
+
+func TestStoreSetExtendsInPlace(t *testing.T) {
+	s := store.NewStore(store.WithCopyOnSet[byte]())
+
+	in := make([]byte, 64)
+	s.Set(in, 0)
+	s.Set(in, 64)
+
+	// Appending to the merged extent uses its spare capacity most of the time.
+	one := []byte{1}
+	allocs := testing.AllocsPerRun(100, func() {
+		s.Set(one, s.Length())
+	})
+	assert.Less(t, allocs, 1.0)
+
+	data := make([]byte, 2)
+	assert.True(t, s.Get(data, 127))
+	assert.Equal(t, []byte{0, 1}, data)
+}
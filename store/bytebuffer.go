@@ -0,0 +1,328 @@
+package store
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"unicode/utf8"
+)
+
+// ErrMissing is returned by reads of a Buffer or ReadWriteSeeker that reach a
+// missing value.
+var ErrMissing = errors.New("value missing")
+
+// bufferChunk is the number of values ReadFrom and WriteTo copy at a time.
+const bufferChunk = 32 << 10
+
+// Buffer is a byte buffer backed by a byte store, with the methods of
+// bytes.Buffer except those exposing its memory, so that code written against a
+// *bytes.Buffer can switch to it. Writes append to the buffer, and WriteAt
+// writes anywhere past its end too, leaving gaps. Reads consume the values from
+// the read position onwards, deleting them from the store, and fail with
+// ErrMissing at a gap. The zero Buffer is empty and ready to use.
+type Buffer struct {
+	s *Store[byte]
+	// off is the read position, and end the end of the values written.
+	off, end int64
+
+	// lastRead holds the values read by the last operation if it was a
+	// successful read, for UnreadByte and UnreadRune, and lastRune whether it
+	// was ReadRune.
+	lastRead []byte
+	lastRune bool
+}
+
+// NewBuffer returns a buffer reading the values of `s` from Start() to
+// Length(), and appending to it.
+func NewBuffer(s *Store[byte]) *Buffer {
+	return &Buffer{s: s, off: s.Start(), end: s.Length()}
+}
+
+// Store returns the store backing the buffer.
+func (b *Buffer) Store() *Store[byte] {
+	if b.s == nil {
+		b.s = NewStore[byte]()
+	}
+	return b.s
+}
+
+// Len returns the number of unread values, including missing ones.
+func (b *Buffer) Len() int {
+	return int(b.end - b.off)
+}
+
+// Bytes returns a copy of the unread values, with missing values zero. Unlike
+// with bytes.Buffer, modifying it does not modify the buffer.
+func (b *Buffer) Bytes() []byte {
+	p := make([]byte, b.Len())
+	b.Store().Get(p, b.off)
+	return p
+}
+
+// String returns the unread values as a string, as Bytes does, or "<nil>" for
+// a nil buffer.
+func (b *Buffer) String() string {
+	if b == nil {
+		return "<nil>"
+	}
+	return string(b.Bytes())
+}
+
+// Grow is a no-op, as the store allocates for every write, and panics if `n`
+// is negative.
+func (b *Buffer) Grow(n int) {
+	if n < 0 {
+		panic("store.Buffer.Grow: negative count")
+	}
+	b.forget()
+}
+
+// Truncate discards all but the first `n` unread values. It panics if `n` is
+// negative or greater than the length of the buffer.
+func (b *Buffer) Truncate(n int) {
+	if n < 0 || n > b.Len() {
+		panic("store.Buffer: truncation out of range")
+	}
+	b.forget()
+	b.Store().Delete(int64(b.Len()-n), b.off+int64(n))
+	b.end = b.off + int64(n)
+}
+
+// Reset clears the store, emptying the buffer.
+func (b *Buffer) Reset() {
+	b.Store().Clear()
+	b.off, b.end = 0, 0
+	b.forget()
+}
+
+// Write appends `p` to the buffer. The store holds a copy of `p`.
+func (b *Buffer) Write(p []byte) (int, error) {
+	if _, err := b.WriteAt(p, b.end); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// WriteString appends `s` to the buffer.
+func (b *Buffer) WriteString(s string) (int, error) {
+	return b.Write([]byte(s))
+}
+
+// WriteByte appends `c` to the buffer.
+func (b *Buffer) WriteByte(c byte) error {
+	_, err := b.Write([]byte{c})
+	return err
+}
+
+// WriteRune appends the UTF-8 encoding of `r` to the buffer.
+func (b *Buffer) WriteRune(r rune) (int, error) {
+	return b.Write(utf8.AppendRune(nil, r))
+}
+
+// WriteAt writes `p` at `offset`, extending the buffer if it ends past it, and
+// leaving a gap if it starts past its end. The store holds a copy of `p`.
+func (b *Buffer) WriteAt(p []byte, offset int64) (int, error) {
+	b.forget()
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if err := b.Store().SetOwned(bytes.Clone(p), offset); err != nil {
+		return 0, err
+	}
+	b.end = max(b.end, offset+int64(len(p)))
+	return len(p), nil
+}
+
+// ReadFrom appends the values read from `r` until EOF, and returns the number
+// of values read.
+func (b *Buffer) ReadFrom(r io.Reader) (int64, error) {
+	buf := make([]byte, bufferChunk)
+	var total int64
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			if _, err := b.Write(buf[:n]); err != nil {
+				return total, err
+			}
+			total += int64(n)
+		}
+		if err == io.EOF {
+			return total, nil
+		}
+		if err != nil {
+			return total, err
+		}
+	}
+}
+
+// WriteTo writes the unread values to `w` until the buffer is drained or a
+// value is missing, and returns the number of values written.
+func (b *Buffer) WriteTo(w io.Writer) (int64, error) {
+	b.forget()
+	buf := make([]byte, min(b.Len(), bufferChunk))
+	var total int64
+	for b.Len() > 0 {
+		p := buf[:min(b.Len(), len(buf))]
+		n := b.Store().GetPrefix(p, b.off)
+		if n == 0 {
+			return total, b.short()
+		}
+		m, err := w.Write(p[:n])
+		b.consume(int64(m))
+		total += int64(m)
+		if err != nil {
+			return total, err
+		}
+		if m < n {
+			return total, io.ErrShortWrite
+		}
+	}
+	return total, nil
+}
+
+// Read reads up to len(`p`) values, stopping at the first missing one. It
+// returns io.EOF if the buffer is drained, and an error wrapping ErrMissing if
+// the value at the read position is missing.
+func (b *Buffer) Read(p []byte) (int, error) {
+	b.forget()
+	if len(p) == 0 {
+		return 0, nil
+	}
+	p = p[:min(len(p), b.Len())]
+	n := b.Store().GetPrefix(p, b.off)
+	if n == 0 {
+		return 0, b.short()
+	}
+	b.consume(int64(n))
+	b.lastRead = []byte{p[n-1]}
+	return n, nil
+}
+
+// Next returns the next `n` values, or all of them if there are fewer, with
+// missing values zero, and consumes them.
+func (b *Buffer) Next(n int) []byte {
+	p := make([]byte, min(max(n, 0), b.Len()))
+	b.Store().Get(p, b.off)
+	b.consume(int64(len(p)))
+	b.forget()
+	if len(p) > 0 {
+		b.lastRead = p[len(p)-1:]
+	}
+	return p
+}
+
+// ReadByte reads the next value.
+func (b *Buffer) ReadByte() (byte, error) {
+	var p [1]byte
+	if _, err := b.Read(p[:]); err != nil {
+		return 0, err
+	}
+	return p[0], nil
+}
+
+// ReadRune reads the next UTF-8 encoded rune, and returns it with its size. A
+// rune that is invalid, or cut short by a missing value, is read as
+// utf8.RuneError of size 1.
+func (b *Buffer) ReadRune() (rune, int, error) {
+	var p [utf8.UTFMax]byte
+	n := b.Store().GetPrefix(p[:min(utf8.UTFMax, b.Len())], b.off)
+	b.forget()
+	if n == 0 {
+		return 0, 0, b.short()
+	}
+	r, size := utf8.DecodeRune(p[:n])
+	b.consume(int64(size))
+	b.lastRead, b.lastRune = p[:size:size], true
+	return r, size, nil
+}
+
+// UnreadByte unreads the last value of the last successful read.
+func (b *Buffer) UnreadByte() error {
+	if len(b.lastRead) == 0 {
+		return errors.New("store.Buffer: UnreadByte: previous operation was not a successful read")
+	}
+	return b.unread(b.lastRead[len(b.lastRead)-1:])
+}
+
+// UnreadRune unreads the rune read by the last operation, if it was ReadRune.
+func (b *Buffer) UnreadRune() error {
+	if !b.lastRune || len(b.lastRead) == 0 {
+		return errors.New("store.Buffer: UnreadRune: previous operation was not a successful ReadRune")
+	}
+	return b.unread(b.lastRead)
+}
+
+// ReadBytes reads up to and including the first occurrence of `delim`. If
+// there is none, it reads the rest of the buffer and returns io.EOF, and if a
+// value is missing before it, it reads up to that value and returns an error
+// wrapping ErrMissing.
+func (b *Buffer) ReadBytes(delim byte) ([]byte, error) {
+	n := int64(b.Len())
+	i, found := Find(b.Store(), delim, b.off)
+	found = found && i < b.end
+	if found {
+		n = i + 1 - b.off
+	}
+
+	// Only the values up to the first missing one are read, so they are
+	// allocated for rather than the whole span, which may be mostly holes.
+	present := n
+	if gaps := b.Store().Gaps(n, b.off); len(gaps) > 0 {
+		present = gaps[0].Offset - b.off
+	}
+	p := make([]byte, present)
+	b.Store().Get(p, b.off)
+	b.consume(int64(len(p)))
+	b.forget()
+	if len(p) > 0 {
+		b.lastRead = p[len(p)-1:]
+	}
+	switch {
+	case int64(len(p)) < n:
+		return p, b.short()
+	case !found:
+		return p, io.EOF
+	}
+	return p, nil
+}
+
+// ReadString reads up to and including the first occurrence of `delim`, as
+// ReadBytes does, and returns the values read as a string.
+func (b *Buffer) ReadString(delim byte) (string, error) {
+	p, err := b.ReadBytes(delim)
+	return string(p), err
+}
+
+// consume advances the read position by `n`, deleting the values read.
+func (b *Buffer) consume(n int64) {
+	if n > 0 {
+		b.Store().Delete(n, b.off)
+		b.off += n
+	}
+}
+
+// unread moves the read position back before `p`, restoring its values.
+func (b *Buffer) unread(p []byte) error {
+	offset := b.off - int64(len(p))
+	if err := b.Store().SetOwned(bytes.Clone(p), offset); err != nil {
+		return err
+	}
+	b.off = offset
+	b.forget()
+	return nil
+}
+
+// forget forgets the values of the last read, which can no longer be unread.
+func (b *Buffer) forget() {
+	b.lastRead, b.lastRune = nil, false
+}
+
+// short returns the error of a read that could not read any value: io.EOF if
+// the buffer is drained, and ErrMissing otherwise.
+func (b *Buffer) short() error {
+	if b.off >= b.end {
+		return io.EOF
+	}
+	return fmt.Errorf("reading at %d: %w", b.off, ErrMissing)
+}
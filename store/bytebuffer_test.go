@@ -0,0 +1,153 @@
+package store_test
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/aertje/sparse-store/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// bufferLike is the part of bytes.Buffer that Buffer implements.
+type bufferLike interface {
+	io.ReadWriter
+	io.ByteScanner
+	io.RuneScanner
+	io.StringWriter
+	io.ReaderFrom
+	io.WriterTo
+	WriteByte(c byte) error
+	WriteRune(r rune) (int, error)
+	ReadBytes(delim byte) ([]byte, error)
+	ReadString(delim byte) (string, error)
+	Next(n int) []byte
+	Bytes() []byte
+	String() string
+	Len() int
+	Truncate(n int)
+	Reset()
+	Grow(n int)
+}
+
+var (
+	_ bufferLike = (*bytes.Buffer)(nil)
+	_ bufferLike = (*store.Buffer)(nil)
+)
+
+func TestBufferLikeBytesBuffer(t *testing.T) {
+	var want bytes.Buffer
+	var got store.Buffer
+	for _, b := range []bufferLike{&want, &got} {
+		b.WriteString("héllo\nworld")
+		b.WriteByte('!')
+		b.WriteRune('€')
+		b.Write([]byte("\nend"))
+	}
+	assert.Equal(t, want.Len(), got.Len())
+	assert.Equal(t, want.String(), got.String())
+
+	for _, step := range []func(b bufferLike) any{
+		func(b bufferLike) any { c, err := b.ReadByte(); return []any{c, err} },
+		func(b bufferLike) any { r, n, err := b.ReadRune(); return []any{r, n, err} },
+		func(b bufferLike) any { return b.UnreadRune() != nil },
+		func(b bufferLike) any { line, err := b.ReadString('\n'); return []any{line, err} },
+		func(b bufferLike) any { return b.UnreadByte() != nil },
+		func(b bufferLike) any { return b.UnreadByte() != nil },
+		func(b bufferLike) any { return b.Next(2) },
+		func(b bufferLike) any { p := make([]byte, 4); n, err := b.Read(p); return []any{p[:n], err} },
+		func(b bufferLike) any { return b.UnreadByte() != nil },
+		func(b bufferLike) any { r, n, err := b.ReadRune(); return []any{r, n, err} },
+		func(b bufferLike) any { return b.Len() },
+		func(b bufferLike) any { line, err := b.ReadBytes('x'); return []any{line, err} },
+		func(b bufferLike) any { p := make([]byte, 4); n, err := b.Read(p); return []any{n, err} },
+	} {
+		assert.Equal(t, step(&want), step(&got))
+	}
+
+	want.Reset()
+	got.Reset()
+	for _, b := range []bufferLike{&want, &got} {
+		b.ReadFrom(strings.NewReader("abcdef"))
+		b.Truncate(4)
+	}
+	var wantOut, gotOut bytes.Buffer
+	_, wantErr := want.WriteTo(&wantOut)
+	_, gotErr := got.WriteTo(&gotOut)
+	assert.Equal(t, wantErr, gotErr)
+	assert.Equal(t, "abcd", gotOut.String())
+	assert.Equal(t, 0, got.Len())
+}
+
+func TestBufferGaps(t *testing.T) {
+	var b store.Buffer
+	b.WriteString("ab")
+	_, err := b.WriteAt([]byte("de\nf"), 3)
+	require.NoError(t, err)
+	assert.Equal(t, 7, b.Len())
+	assert.Equal(t, "ab\x00de\nf", b.String())
+
+	p := make([]byte, 5)
+	n, err := b.Read(p)
+	assert.NoError(t, err)
+	assert.Equal(t, "ab", string(p[:n]))
+	n, err = b.Read(p)
+	assert.ErrorIs(t, err, store.ErrMissing)
+	assert.Zero(t, n)
+
+	// Filling the gap resumes reading.
+	_, err = b.WriteAt([]byte("c"), 2)
+	require.NoError(t, err)
+	line, err := b.ReadString('\n')
+	assert.NoError(t, err)
+	assert.Equal(t, "cde\n", line)
+
+	_, err = b.WriteAt([]byte("h"), 8)
+	require.NoError(t, err)
+	line, err = b.ReadString('h')
+	assert.ErrorIs(t, err, store.ErrMissing)
+	assert.Equal(t, "f", line)
+	assert.Equal(t, []byte{0, 'h'}, b.Next(5))
+
+	_, err = b.ReadByte()
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestBufferReadBytesFarGap(t *testing.T) {
+	var b store.Buffer
+	b.WriteString("ab")
+	// A write far past the end leaves a hole that is not allocated for.
+	_, err := b.WriteAt([]byte("c"), 1<<50)
+	require.NoError(t, err)
+
+	line, err := b.ReadBytes('\n')
+	assert.ErrorIs(t, err, store.ErrMissing)
+	assert.Equal(t, "ab", string(line))
+}
+
+func TestBufferConsumes(t *testing.T) {
+	s := store.NewStore[byte]()
+	require.NoError(t, s.Set([]byte("hello world"), 0))
+	b := store.NewBuffer(s)
+	assert.Same(t, s, b.Store())
+
+	word, err := b.ReadString(' ')
+	require.NoError(t, err)
+	assert.Equal(t, "hello ", word)
+	assert.Equal(t, int64(5), s.Occupancy())
+	assert.Equal(t, []store.Range{{Offset: 6, Length: 5}}, s.Extents())
+
+	b.WriteString("!")
+	var out bytes.Buffer
+	n, err := b.WriteTo(&out)
+	require.NoError(t, err)
+	assert.Equal(t, int64(6), n)
+	assert.Equal(t, "world!", out.String())
+	assert.Zero(t, s.Occupancy())
+	assert.Equal(t, int64(12), s.Length())
+
+	assert.Error(t, b.UnreadByte())
+	assert.Panics(t, func() { b.Truncate(1) })
+}
@@ -0,0 +1,137 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Fetcher fetches values from the source a Cache reads through to.
+type Fetcher[T any] interface {
+	// Fetch returns the `length` values at `offset`. It returns fewer values
+	// only along with an error. The cache retains the returned slice, so the
+	// fetcher must not modify it afterwards.
+	Fetch(ctx context.Context, offset, length int64) ([]T, error)
+}
+
+// FetcherFunc adapts a function to a Fetcher.
+type FetcherFunc[T any] func(ctx context.Context, offset, length int64) ([]T, error)
+
+// Fetch calls f.
+func (f FetcherFunc[T]) Fetch(ctx context.Context, offset, length int64) ([]T, error) {
+	return f(ctx, offset, length)
+}
+
+// Cache reads through a store to a Fetcher: it serves values from the store,
+// and fetches and stores the ones that are missing. Unlike the store, it is
+// safe for concurrent use, and does not hold its lock while fetching.
+// Concurrent reads of the same missing values fetch them more than once.
+type Cache[T any] struct {
+	mu        sync.Mutex
+	store     *Store[T]
+	fetcher   Fetcher[T]
+	readahead Readahead
+
+	// repairSample, onMismatch and equal are configured by WithReadRepair.
+	repairSample float64
+	onMismatch   func(Range)
+	equal        func(a, b T) bool
+}
+
+// CacheOption configures a Cache.
+type CacheOption[T any] func(*Cache[T])
+
+// WithReadahead makes the cache fetch the values following a read along with
+// it, as many as `readahead` decides.
+func WithReadahead[T any](readahead Readahead) CacheOption[T] {
+	return func(c *Cache[T]) {
+		c.readahead = readahead
+	}
+}
+
+// NewCache returns a cache that reads through `store` to `fetcher`. The store
+// must not be used directly afterwards.
+func NewCache[T any](store *Store[T], fetcher Fetcher[T], opts ...CacheOption[T]) *Cache[T] {
+	c := &Cache[T]{store: store, fetcher: fetcher}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Get populates `p` with the values at `offset`, fetching the ones the store
+// does not hold. Fetched values are stored even if Get fails later on; values
+// that do not fit the byte budget of the store are not.
+//
+// Values read ahead are fetched along with the missing ones, in the same
+// request where they follow them. Failing to read ahead does not fail Get.
+func (c *Cache[T]) Get(ctx context.Context, p []T, offset int64) error {
+	end := endOf(int64(len(p)), offset)
+
+	c.mu.Lock()
+	var fetches []Range
+	hit := c.store.Get(p, offset)
+	if !hit {
+		fetches = c.store.Gaps(int64(len(p)), offset)
+	}
+	if c.readahead != nil {
+		if n := c.readahead.Readahead(offset, int64(len(p))); n > 0 {
+			ahead := c.store.Gaps(n, end)
+			if i := len(fetches) - 1; i >= 0 && len(ahead) > 0 && fetches[i].End() == ahead[0].Offset {
+				fetches[i].Length += ahead[0].Length
+				ahead = ahead[1:]
+			}
+			fetches = append(fetches, ahead...)
+		}
+	}
+	c.mu.Unlock()
+
+	for _, fetch := range fetches {
+		// Only the values up to the end of `p` are required.
+		required := max(0, min(fetch.End(), end)-fetch.Offset)
+		values, err := c.fetcher.Fetch(ctx, fetch.Offset, fetch.Length)
+		if int64(len(values)) > fetch.Length {
+			values = values[:fetch.Length]
+		}
+		if int64(len(values)) >= required {
+			err = nil
+		} else if err == nil {
+			err = io.ErrUnexpectedEOF
+		}
+
+		if required > 0 {
+			copy(p[fetch.Offset-offset:], values[:min(int64(len(values)), required)])
+		}
+		if len(values) > 0 {
+			c.mu.Lock()
+			if serr := c.store.Set(values, fetch.Offset); serr != nil && !errors.Is(serr, ErrFull) {
+				err = errors.Join(err, serr)
+			}
+			c.mu.Unlock()
+		}
+
+		if err != nil {
+			return fmt.Errorf("fetching %d values at %d: %w", fetch.Length, fetch.Offset, err)
+		}
+	}
+
+	if hit && len(p) > 0 && c.sampleRepair() {
+		if values, mismatches, err := c.verify(ctx, int64(len(p)), offset); err == nil {
+			for _, r := range mismatches {
+				copy(p[r.Offset-offset:], values[r.Offset-offset:r.End()-offset])
+			}
+		}
+	}
+
+	return nil
+}
+
+// Do calls `fn` with the store while holding the lock of the cache, so that it
+// can be used directly.
+func (c *Cache[T]) Do(fn func(s *Store[T])) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	fn(c.store)
+}
@@ -0,0 +1,83 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+
+	"github.com/aertje/sparse-store/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// source is a fetcher of the values 0 to 99 that records the ranges fetched.
+type source struct {
+	mu      sync.Mutex
+	fetched []store.Range
+	err     error
+}
+
+func (s *source) Fetch(_ context.Context, offset, length int64) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fetched = append(s.fetched, store.Range{Offset: offset, Length: length})
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	var values []byte
+	for i := offset; i < min(offset+length, 100); i++ {
+		values = append(values, byte(i))
+	}
+	return values, nil
+}
+
+func TestCacheGet(t *testing.T) {
+	src := &source{}
+	s := store.NewStore[byte]()
+	s.Set([]byte{10, 11}, 10)
+	c := store.NewCache(s, src)
+
+	p := make([]byte, 8)
+	require.NoError(t, c.Get(context.Background(), p, 6))
+	assert.Equal(t, []byte{6, 7, 8, 9, 10, 11, 12, 13}, p)
+	assert.Equal(t, []store.Range{{Offset: 6, Length: 4}, {Offset: 12, Length: 2}}, src.fetched)
+
+	require.NoError(t, c.Get(context.Background(), p, 6))
+	assert.Len(t, src.fetched, 2)
+
+	c.Do(func(s *store.Store[byte]) {
+		assert.Equal(t, int64(8), s.Occupancy())
+	})
+}
+
+func TestCacheGetErrors(t *testing.T) {
+	src := &source{}
+	c := store.NewCache(store.NewStore[byte](), src)
+
+	err := c.Get(context.Background(), make([]byte, 10), 95)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	// The values that were fetched are kept.
+	assert.NoError(t, c.Get(context.Background(), make([]byte, 5), 95))
+
+	src.err = errors.New("unavailable")
+	assert.ErrorIs(t, c.Get(context.Background(), make([]byte, 5), 0), src.err)
+}
+
+func TestCacheConcurrent(t *testing.T) {
+	c := store.NewCache(store.NewStore[byte](), &source{})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(offset int64) {
+			defer wg.Done()
+			p := make([]byte, 20)
+			assert.NoError(t, c.Get(context.Background(), p, offset))
+			assert.Equal(t, byte(offset), p[0])
+		}(int64(i * 7))
+	}
+	wg.Wait()
+}
@@ -0,0 +1,24 @@
+package store
+
+import "time"
+
+// Clock tells the time for the time-based features of the store, such as TTLs.
+type Clock interface {
+	Now() time.Time
+}
+
+// WithClock makes the store tell the time with `clock` rather than the system
+// clock, for example to control expiry in tests.
+func WithClock[T any](clock Clock) Option[T] {
+	return func(c *Store[T]) {
+		c.clock = clock
+	}
+}
+
+// now returns the current time according to the clock of the store.
+func (c *Store[T]) now() time.Time {
+	if c.clock == nil {
+		return time.Now()
+	}
+	return c.clock.Now()
+}
@@ -0,0 +1,155 @@
+package store
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+// Codec encodes and decodes slices of values, for WriteTo and ReadFrom to
+// persist stores of any type.
+type Codec[T any] interface {
+	// Encode writes the values of `data` to `w`.
+	Encode(w io.Writer, data []T) error
+	// Decode reads len(data) values, as written by Encode, from `r` into
+	// `data`. ReadFrom decodes long slices in consecutive parts, so the
+	// encoding of a slice must be that of its parts, one after the other.
+	Decode(r io.Reader, data []T) error
+}
+
+// WithCodec makes WriteTo and ReadFrom encode and decode values with `codec`.
+// Without it, values of fixed binary size are encoded with BinaryCodec in
+// little-endian order, and others cannot be persisted.
+func WithCodec[T any](codec Codec[T]) Option[T] {
+	return func(c *Store[T]) {
+		c.codec = codec
+	}
+}
+
+// codecOrDefault returns the codec of the store, or the default one.
+func (c *Store[T]) codecOrDefault() (Codec[T], error) {
+	if c.codec != nil {
+		return c.codec, nil
+	}
+	if _, err := width[T](); err != nil {
+		return nil, fmt.Errorf("no codec configured: %w", err)
+	}
+	return BinaryCodec[T]{Order: binary.LittleEndian}, nil
+}
+
+// BinaryCodec encodes values of fixed binary size, such as numbers, or arrays
+// or structs of numbers, with encoding/binary.
+type BinaryCodec[T any] struct {
+	Order binary.ByteOrder
+}
+
+func (b BinaryCodec[T]) Encode(w io.Writer, data []T) error {
+	return binary.Write(w, b.Order, data)
+}
+
+func (b BinaryCodec[T]) Decode(r io.Reader, data []T) error {
+	return binary.Read(r, b.Order, data)
+}
+
+// StringCodec encodes strings prefixed with their length.
+type StringCodec struct{}
+
+func (StringCodec) Encode(w io.Writer, data []string) error {
+	bw := bufio.NewWriter(w)
+	for _, s := range data {
+		bw.Write(binary.AppendUvarint(nil, uint64(len(s))))
+		bw.WriteString(s)
+	}
+	return bw.Flush()
+}
+
+func (StringCodec) Decode(r io.Reader, data []string) error {
+	br := byteReader(r)
+	for i := range data {
+		n, err := binary.ReadUvarint(br)
+		if err != nil {
+			return err
+		}
+		if n > math.MaxInt64 {
+			return fmt.Errorf("%w: string of %d bytes", ErrFormat, n)
+		}
+		// Copy rather than allocate `n` bytes up front, so that a corrupt
+		// length fails at the end of the input.
+		var b strings.Builder
+		if _, err := io.CopyN(&b, br, int64(n)); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return err
+		}
+		data[i] = b.String()
+	}
+	return nil
+}
+
+// GobCodec encodes values of any type gob supports, such as structs with
+// variable-size fields. Slices are encoded as separate gob streams of at most
+// gobPart values, so that every part carries its own type information.
+type GobCodec[T any] struct{}
+
+// gobPart is the maximum number of values in a gob stream written by GobCodec.
+// persistChunk is a multiple of it, so that ReadFrom decodes whole streams.
+const gobPart = 1 << 12
+
+func (GobCodec[T]) Encode(w io.Writer, data []T) error {
+	for len(data) > 0 {
+		n := min(len(data), gobPart)
+		if err := gob.NewEncoder(w).Encode(data[:n]); err != nil {
+			return err
+		}
+		data = data[n:]
+	}
+	return nil
+}
+
+func (GobCodec[T]) Decode(r io.Reader, data []T) error {
+	br := byteReader(r)
+	for len(data) > 0 {
+		var decoded []T
+		if err := gob.NewDecoder(br).Decode(&decoded); err != nil {
+			return err
+		}
+		if len(decoded) == 0 || len(decoded) > len(data) {
+			return fmt.Errorf("decoded %d values, expected at most %d", len(decoded), len(data))
+		}
+		data = data[copy(data, decoded):]
+	}
+	return nil
+}
+
+// byteReader returns `r` as an io.ByteReader, so that decoding reads no more
+// from it than needed.
+func byteReader(r io.Reader) interface {
+	io.Reader
+	io.ByteReader
+} {
+	if br, ok := r.(interface {
+		io.Reader
+		io.ByteReader
+	}); ok {
+		return br
+	}
+	return &singleByteReader{Reader: r}
+}
+
+// singleByteReader implements io.ByteReader with single byte reads.
+type singleByteReader struct {
+	io.Reader
+	b [1]byte
+}
+
+func (r *singleByteReader) ReadByte() (byte, error) {
+	if _, err := io.ReadFull(r.Reader, r.b[:]); err != nil {
+		return 0, err
+	}
+	return r.b[0], nil
+}
@@ -0,0 +1,173 @@
+package store
+
+import (
+	"cmp"
+	"slices"
+	"time"
+)
+
+// CompactionBudget limits the work of a call to CompactSome. Zero values
+// disable the corresponding limit.
+type CompactionBudget struct {
+	// Values is the number of values the regions compacted may hold, which
+	// bounds the values copied.
+	Values int64
+	// Duration is the time CompactSome may take. It is checked between
+	// regions, so it may be exceeded by the time one region takes.
+	Duration time.Duration
+}
+
+// region is a region of pending entries that neither overlap nor adjoin the
+// pending entries outside of it.
+type region struct {
+	from, to int64
+	// pending holds the indices of the entries of the region in c.pending.
+	pending []int
+	volume  int64
+	score   int64
+}
+
+// CompactSome compacts the extents recorded by Set in lazy mode region by
+// region, the worst first, until `budget` is spent, rather than all of them
+// as Compact does. Regions are ranked by the number of extents pending in
+// them, weighed by how often they were read according to the heatmap, if the
+// store has one. It returns the number of extents left pending.
+//
+// Regions larger than the budget are skipped, unless nothing was compacted
+// yet, so that every call makes progress.
+func (c *Store[T]) CompactSome(budget CompactionBudget) int {
+	defer c.enter("compact-some")()
+	if len(c.pending) == 0 {
+		return 0
+	}
+
+	start := c.now()
+	regions := c.pendingRegions()
+	slices.SortStableFunc(regions, func(a, b region) int {
+		return cmp.Compare(b.score, a.score)
+	})
+
+	var spent int64
+	compacted := make([]bool, len(c.pending))
+	n := 0
+	for _, r := range regions {
+		if budget.Duration > 0 && n > 0 && c.now().Sub(start) >= budget.Duration {
+			break
+		}
+		if budget.Values > 0 && n > 0 && spent+r.volume > budget.Values {
+			continue
+		}
+
+		spent += c.compactRegion(r)
+		for _, i := range r.pending {
+			compacted[i] = true
+		}
+		n++
+	}
+
+	// Keep the entries left pending, renumbered like those compacted, so that
+	// they remain newer than the entries they overlap.
+	remaining := c.pending[:0]
+	c.pendingVolume = 0
+	for i, e := range c.pending {
+		if compacted[i] {
+			continue
+		}
+		e.order = c.insertCount
+		c.insertCount++
+		remaining = append(remaining, e)
+		c.pendingVolume += e.size()
+	}
+	clear(c.pending[len(remaining):])
+	c.pending = remaining
+
+	if c.debugging() {
+		c.debug("compacted regions", "regions", n, "of", len(regions), "values", spent, "pending", len(c.pending))
+	}
+	c.evict()
+	c.publish()
+
+	return len(c.pending)
+}
+
+// pendingRegions groups the pending entries into regions.
+func (c *Store[T]) pendingRegions() []region {
+	sorted := make([]int, len(c.pending))
+	for i := range sorted {
+		sorted[i] = i
+	}
+	slices.SortStableFunc(sorted, func(a, b int) int {
+		return cmp.Compare(c.pending[a].offset, c.pending[b].offset)
+	})
+
+	var regions []region
+	for _, i := range sorted {
+		e := c.pending[i]
+		if n := len(regions); n > 0 && e.offset <= regions[n-1].to {
+			r := &regions[n-1]
+			r.to = max(r.to, e.end())
+			r.pending = append(r.pending, i)
+			r.volume += e.size()
+			continue
+		}
+		regions = append(regions, region{from: e.offset, to: e.end(), pending: []int{i}, volume: e.size()})
+	}
+
+	for i := range regions {
+		r := &regions[i]
+		heat := int64(0)
+		if c.heatmap != nil {
+			first, last := max(r.from, 0)/c.heatmap.blockSize, max(r.to-1, 0)/c.heatmap.blockSize
+			for b := first; b <= last && b < int64(len(c.heatmap.counts)); b++ {
+				heat += c.heatmap.counts[b]
+			}
+		}
+		r.score = int64(len(r.pending)) * (1 + heat)
+	}
+
+	return regions
+}
+
+// compactRegion compacts the pending entries of `r` with the entries they
+// overlap or adjoin, and returns the number of values compacted.
+func (c *Store[T]) compactRegion(r region) int64 {
+	lo := c.entries.Search(r.from)
+	if lo > 0 && c.entries[lo-1].end() >= r.from {
+		lo--
+	}
+	hi := lo
+	for hi < len(c.entries) && c.entries[hi].offset <= r.to {
+		hi++
+	}
+
+	// The pending entries are newer than the entries they overlap, but merging
+	// may have given those the order of newer entries elsewhere. Renumber them,
+	// in insertion order, so that they win.
+	window := make(entries[T], 0, hi-lo+len(r.pending))
+	window = append(window, c.entries[lo:hi]...)
+	slices.Sort(r.pending)
+	for _, i := range r.pending {
+		e := c.pending[i]
+		e.order = c.insertCount
+		c.insertCount++
+		window = append(window, e)
+	}
+	slices.SortStableFunc(window, func(a, b entry[T]) int {
+		return cmp.Compare(a.offset, b.offset)
+	})
+
+	var volume int64
+	for _, e := range window {
+		volume += e.size()
+	}
+	for _, e := range c.entries[lo:hi] {
+		c.occupancy -= e.size()
+	}
+	compacted := c.merge(c.resolve(window))
+	for _, e := range compacted {
+		c.occupancy += e.size()
+	}
+	c.entries = slices.Replace(c.entries, lo, hi, compacted...)
+
+	return volume
+}
@@ -0,0 +1,75 @@
+package store_test
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/aertje/sparse-store/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStoreCompactSome(t *testing.T) {
+	s := store.NewStore(store.WithLazyCompaction[byte](1000, 1<<20), store.WithHeatmap[byte](10))
+	// Reading makes the last region hotter than the others.
+	s.Get(make([]byte, 2), 100)
+
+	s.Set([]byte{1, 2}, 0)
+	s.Set([]byte{3}, 1)
+	s.Set([]byte{4}, 2)
+	s.Set([]byte{5, 6}, 50)
+	s.Set([]byte{7, 8}, 100)
+	s.Set([]byte{9}, 101)
+	s.Set([]byte{1}, 101)
+
+	// The last region is compacted first, then the first one would exceed
+	// the budget, but the second one fits.
+	assert.Equal(t, 3, s.CompactSome(store.CompactionBudget{Values: 7}))
+	assert.Equal(t, 0, s.CompactSome(store.CompactionBudget{}))
+
+	p := make([]byte, 3)
+	require.True(t, s.Get(p, 0))
+	assert.Equal(t, []byte{1, 3, 4}, p)
+	require.True(t, s.Get(p[:2], 100))
+	assert.Equal(t, []byte{7, 1}, p[:2])
+}
+
+func TestStoreCompactSomeRandom(t *testing.T) {
+	for seed := int64(1); seed <= 10; seed++ {
+		testStoreCompactSomeRandom(t, seed)
+	}
+}
+
+func testStoreCompactSomeRandom(t *testing.T, seed int64) {
+	r := rand.New(rand.NewSource(seed))
+	s := store.NewStore(store.WithLazyCompaction[byte](1<<20, 1<<20), store.WithMinContiguous[byte](16))
+	model := make([]byte, 256)
+	present := make([]bool, 256)
+
+	for round := 0; round < 50; round++ {
+		for i := 0; i < 100; i++ {
+			offset, length := r.Int63n(200), r.Int63n(50)
+			data := make([]byte, length)
+			r.Read(data)
+			require.NoError(t, s.Set(data, offset))
+			copy(model[offset:], data)
+			for j := offset; j < offset+length; j++ {
+				present[j] = true
+			}
+
+			if r.Intn(5) == 0 {
+				s.CompactSome(store.CompactionBudget{Values: r.Int63n(100)})
+			}
+		}
+
+		for offset := range model {
+			if !present[offset] {
+				assert.False(t, s.Has(1, int64(offset)))
+				continue
+			}
+			p := make([]byte, 1)
+			require.True(t, s.Get(p, int64(offset)))
+			require.Equal(t, model[offset], p[0], "seed %d round %d offset %d", seed, round, offset)
+		}
+	}
+}
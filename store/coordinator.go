@@ -0,0 +1,326 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+	"time"
+)
+
+// ErrNoSources is returned by Coordinator.Fill when all of its sources failed.
+var ErrNoSources = errors.New("no sources left")
+
+const (
+	defaultStallTimeout = 30 * time.Second
+	defaultMaxFailures  = 3
+)
+
+// CoordinatorOptions configures a Coordinator. The zero value of any field
+// selects its default.
+type CoordinatorOptions struct {
+	// Plan configures the requests the values are fetched in.
+	Plan PlanOptions
+	// StallTimeout is the time after which a request is considered stalled,
+	// and its range is assigned to another source as well, 30 seconds by
+	// default. Whichever source returns it first wins.
+	StallTimeout time.Duration
+	// MaxFailures is the number of consecutive failures after which a source
+	// is no longer used, 3 by default.
+	MaxFailures int
+}
+
+// SourceStats holds the statistics of a source of a Coordinator.
+type SourceStats struct {
+	// Requests is the number of requests made, Failures the number of those
+	// that failed, and Stalls the number of those that stalled.
+	Requests, Failures, Stalls int
+	// Values is the number of values fetched by successful requests, which
+	// took Duration in total.
+	Values   int64
+	Duration time.Duration
+	// Disabled is whether the source is no longer used, after failing too
+	// often.
+	Disabled bool
+}
+
+// Throughput returns the number of values fetched per second.
+func (s SourceStats) Throughput() float64 {
+	if s.Duration <= 0 {
+		return 0
+	}
+	return float64(s.Values) / s.Duration.Seconds()
+}
+
+// Coordinator fills a store from several sources holding the same values,
+// such as mirrors or peers. Every source makes one request at a time, so
+// faster sources are assigned more of the ranges, and idle sources are
+// assigned ranges in order of their throughput. Ranges that fail are
+// reassigned, and ranges that stall are assigned to another source as well.
+type Coordinator[T any] struct {
+	sources []Fetcher[T]
+	opts    CoordinatorOptions
+
+	mu    sync.Mutex
+	stats []SourceStats
+}
+
+// NewCoordinator returns a coordinator filling from `sources`.
+func NewCoordinator[T any](opts CoordinatorOptions, sources ...Fetcher[T]) *Coordinator[T] {
+	if opts.StallTimeout <= 0 {
+		opts.StallTimeout = defaultStallTimeout
+	}
+	if opts.MaxFailures <= 0 {
+		opts.MaxFailures = defaultMaxFailures
+	}
+	return &Coordinator[T]{
+		sources: sources,
+		opts:    opts,
+		stats:   make([]SourceStats, len(sources)),
+	}
+}
+
+// Stats returns the statistics of the sources, in the order they were given.
+// It can be called while Fill runs.
+func (c *Coordinator[T]) Stats() []SourceStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return slices.Clone(c.stats)
+}
+
+// attempt is a request for a range made to a source.
+type attempt[T any] struct {
+	r       int
+	source  int
+	start   time.Time
+	stalled bool
+	cancel  context.CancelFunc
+
+	values []T
+	err    error
+}
+
+// coordinatedRange is the state of a range being filled.
+type coordinatedRange struct {
+	Range
+	done   bool
+	queued bool
+	// attempts is the number of attempts in flight.
+	attempts int
+}
+
+// fill is the state of a call to Fill.
+type fill[T any] struct {
+	ranges []coordinatedRange
+	// queue holds the indices of the ranges to assign, in order.
+	queue []int
+	// busy holds the attempt in flight per source, if any.
+	busy      []*attempt[T]
+	failures  []int
+	remaining int
+	lastErr   error
+}
+
+// Fill fills the `length` values at `offset` in `s` that it is missing. Values
+// fetched are kept in `s` if Fill fails. `s` must not be used until Fill
+// returns.
+func (c *Coordinator[T]) Fill(ctx context.Context, s *Store[T], length, offset int64) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	plan := s.Plan(length, offset, c.opts.Plan)
+	f := &fill[T]{
+		ranges:    make([]coordinatedRange, len(plan)),
+		busy:      make([]*attempt[T], len(c.sources)),
+		failures:  make([]int, len(c.sources)),
+		remaining: len(plan),
+	}
+	for i, r := range plan {
+		f.ranges[i] = coordinatedRange{Range: r, queued: true}
+		f.queue = append(f.queue, i)
+	}
+
+	// Every source has at most one attempt in flight, so sending results never
+	// blocks.
+	results := make(chan *attempt[T], len(c.sources))
+	stall := time.NewTimer(c.opts.StallTimeout)
+	defer stall.Stop()
+
+	for f.remaining > 0 {
+		c.assign(ctx, f, results)
+
+		deadline, running := c.deadline(f)
+		if !running {
+			if f.lastErr == nil {
+				return ErrNoSources
+			}
+			return fmt.Errorf("%w: %w", ErrNoSources, f.lastErr)
+		}
+		stall.Reset(time.Until(deadline))
+
+		select {
+		case a := <-results:
+			if err := c.complete(f, s, a); err != nil {
+				return err
+			}
+		case <-stall.C:
+			c.stalled(f)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return nil
+}
+
+// assign assigns the queued ranges to the idle sources, the fastest first.
+func (c *Coordinator[T]) assign(ctx context.Context, f *fill[T], results chan<- *attempt[T]) {
+	c.mu.Lock()
+	var idle []int
+	for i, a := range f.busy {
+		if a == nil && !c.stats[i].Disabled {
+			idle = append(idle, i)
+		}
+	}
+	stats := slices.Clone(c.stats)
+	c.mu.Unlock()
+
+	slices.SortStableFunc(idle, func(a, b int) int {
+		ta, tb := stats[a].Throughput(), stats[b].Throughput()
+		switch {
+		case ta > tb:
+			return -1
+		case ta < tb:
+			return 1
+		}
+		return 0
+	})
+
+	for _, source := range idle {
+		if len(f.queue) == 0 {
+			return
+		}
+		i := f.queue[0]
+		f.queue = f.queue[1:]
+		r := &f.ranges[i]
+		r.queued = false
+		r.attempts++
+
+		actx, cancel := context.WithCancel(ctx)
+		a := &attempt[T]{r: i, source: source, start: time.Now(), cancel: cancel}
+		f.busy[source] = a
+
+		c.mu.Lock()
+		c.stats[source].Requests++
+		c.mu.Unlock()
+
+		fetcher, rng := c.sources[source], r.Range
+		go func() {
+			a.values, a.err = fetcher.Fetch(actx, rng.Offset, rng.Length)
+			if a.err == nil && int64(len(a.values)) < rng.Length {
+				a.err = io.ErrUnexpectedEOF
+			}
+			results <- a
+		}()
+	}
+}
+
+// deadline returns the time the first attempt in flight that did not stall
+// yet stalls, and whether any attempts are in flight.
+func (c *Coordinator[T]) deadline(f *fill[T]) (time.Time, bool) {
+	var deadline time.Time
+	running := false
+	for _, a := range f.busy {
+		if a == nil {
+			continue
+		}
+		running = true
+		if a.stalled {
+			continue
+		}
+		if d := a.start.Add(c.opts.StallTimeout); deadline.IsZero() || d.Before(deadline) {
+			deadline = d
+		}
+	}
+	if deadline.IsZero() {
+		// Only stalled attempts are in flight, there is nothing to time out.
+		deadline = time.Now().Add(c.opts.StallTimeout)
+	}
+	return deadline, running
+}
+
+// complete handles the result of attempt `a`.
+func (c *Coordinator[T]) complete(f *fill[T], s *Store[T], a *attempt[T]) error {
+	a.cancel()
+	f.busy[a.source] = nil
+	r := &f.ranges[a.r]
+	r.attempts--
+
+	if a.err != nil {
+		if r.done {
+			// Another source won, and this attempt was cancelled.
+			return nil
+		}
+
+		f.lastErr = fmt.Errorf("fetching %d values at %d from source %d: %w", r.Length, r.Offset, a.source, a.err)
+		f.failures[a.source]++
+		c.mu.Lock()
+		c.stats[a.source].Failures++
+		if f.failures[a.source] >= c.opts.MaxFailures {
+			c.stats[a.source].Disabled = true
+		}
+		c.mu.Unlock()
+
+		if !r.queued && r.attempts == 0 {
+			r.queued = true
+			f.queue = append([]int{a.r}, f.queue...)
+		}
+		return nil
+	}
+
+	f.failures[a.source] = 0
+	c.mu.Lock()
+	c.stats[a.source].Values += r.Length
+	c.stats[a.source].Duration += time.Since(a.start)
+	c.mu.Unlock()
+	if r.done {
+		return nil
+	}
+
+	r.done = true
+	f.remaining--
+	if r.queued {
+		r.queued = false
+		f.queue = slices.DeleteFunc(f.queue, func(i int) bool { return i == a.r })
+	}
+	// Cancel the other attempts for the range.
+	for _, other := range f.busy {
+		if other != nil && other.r == a.r {
+			other.cancel()
+		}
+	}
+
+	return s.SetOwned(a.values[:r.Length], r.Offset)
+}
+
+// stalled marks the attempts that exceeded the stall timeout, and queues their
+// ranges to be assigned to other sources.
+func (c *Coordinator[T]) stalled(f *fill[T]) {
+	now := time.Now()
+	for _, a := range f.busy {
+		if a == nil || a.stalled || now.Sub(a.start) < c.opts.StallTimeout {
+			continue
+		}
+		a.stalled = true
+		c.mu.Lock()
+		c.stats[a.source].Stalls++
+		c.mu.Unlock()
+
+		if r := &f.ranges[a.r]; !r.done && !r.queued {
+			r.queued = true
+			f.queue = append([]int{a.r}, f.queue...)
+		}
+	}
+}
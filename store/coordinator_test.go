@@ -0,0 +1,98 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aertje/sparse-store/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// offsets is a source of values equal to their offset.
+var offsets = store.FetcherFunc[int64](func(ctx context.Context, offset, length int64) ([]int64, error) {
+	values := make([]int64, length)
+	for i := range values {
+		values[i] = offset + int64(i)
+	}
+	return values, nil
+})
+
+func assertOffsets(t *testing.T, s *store.Store[int64], length, offset int64) {
+	t.Helper()
+	values := make([]int64, length)
+	require.True(t, s.Get(values, offset))
+	for i, v := range values {
+		require.Equal(t, offset+int64(i), v)
+	}
+}
+
+func TestCoordinatorFailures(t *testing.T) {
+	errFetch := errors.New("fetch failed")
+	failing := store.FetcherFunc[int64](func(ctx context.Context, offset, length int64) ([]int64, error) {
+		return nil, errFetch
+	})
+
+	c := store.NewCoordinator(store.CoordinatorOptions{Plan: store.PlanOptions{MaxRequest: 10}}, failing, offsets)
+	s := store.NewStore[int64]()
+	require.NoError(t, c.Fill(context.Background(), s, 100, 0))
+	assertOffsets(t, s, 100, 0)
+
+	stats := c.Stats()
+	// The failing source is retried for as long as ranges are left.
+	assert.Positive(t, stats[0].Failures)
+	assert.Equal(t, stats[0].Requests, stats[0].Failures)
+	assert.Zero(t, stats[0].Values)
+	assert.Zero(t, stats[1].Failures)
+	assert.Equal(t, int64(100), stats[1].Values)
+	assert.Equal(t, 10, stats[1].Requests)
+}
+
+func TestCoordinatorStall(t *testing.T) {
+	stalling := store.FetcherFunc[int64](func(ctx context.Context, offset, length int64) ([]int64, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+
+	c := store.NewCoordinator(store.CoordinatorOptions{
+		Plan:         store.PlanOptions{MaxRequest: 10},
+		StallTimeout: 20 * time.Millisecond,
+	}, stalling, offsets)
+	s := store.NewStore[int64]()
+	require.NoError(t, c.Fill(context.Background(), s, 100, 0))
+	assertOffsets(t, s, 100, 0)
+
+	stats := c.Stats()
+	assert.Equal(t, 1, stats[0].Requests)
+	assert.Equal(t, 1, stats[0].Stalls)
+	assert.Equal(t, int64(100), stats[1].Values)
+}
+
+func TestCoordinatorNoSources(t *testing.T) {
+	errFetch := errors.New("fetch failed")
+	failing := store.FetcherFunc[int64](func(ctx context.Context, offset, length int64) ([]int64, error) {
+		if offset >= 50 {
+			return nil, errFetch
+		}
+		return offsets(ctx, offset, length)
+	})
+
+	c := store.NewCoordinator(store.CoordinatorOptions{Plan: store.PlanOptions{MaxRequest: 10}}, failing, failing)
+	s := store.NewStore[int64]()
+	err := c.Fill(context.Background(), s, 100, 0)
+	assert.ErrorIs(t, err, store.ErrNoSources)
+	assert.ErrorIs(t, err, errFetch)
+	assertOffsets(t, s, 50, 0)
+	for _, stats := range c.Stats() {
+		assert.Equal(t, 3, stats.Failures)
+		assert.True(t, stats.Disabled)
+	}
+
+	// The values fetched are kept.
+	c = store.NewCoordinator(store.CoordinatorOptions{}, offsets)
+	require.NoError(t, c.Fill(context.Background(), s, 100, 0))
+	assert.Equal(t, 1, c.Stats()[0].Requests)
+	assertOffsets(t, s, 100, 0)
+}
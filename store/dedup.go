@@ -0,0 +1,110 @@
+package store
+
+import (
+	"hash/maphash"
+	"slices"
+	"unsafe"
+)
+
+// WithDedup makes the store share the backing array of identical blocks of
+// `blockSize` values, aligned to multiples of it, such as the repeated blocks
+// of a disk image. Blocks are identified by a hash of their values, and shared
+// until no extent refers to them anymore. Shared blocks are never merged with
+// their neighbours, nor modified in place.
+//
+// Values that are equal but not identical in memory, such as strings with the
+// same contents at different addresses, are not shared.
+func WithDedup[T comparable](blockSize int) Option[T] {
+	return func(c *Store[T]) {
+		c.dedup = &dedupTable[T]{
+			blockSize: blockSize,
+			seed:      maphash.MakeSeed(),
+			equal:     slices.Equal[[]T],
+			blocks:    make(map[uint64][]*dedupBlock[T]),
+		}
+	}
+}
+
+// dedupTable holds the blocks shared between extents.
+type dedupTable[T any] struct {
+	blockSize int
+	seed      maphash.Seed
+	equal     func(a, b []T) bool
+
+	// blocks holds the blocks by the hash of their values.
+	blocks map[uint64][]*dedupBlock[T]
+}
+
+// dedupBlock is a block shared by `refs` extents.
+type dedupBlock[T any] struct {
+	data []T
+	hash uint64
+	refs int
+}
+
+// intern returns the shared block identical to `data`, adding a reference to
+// it. If there is none, `data`, or a copy of it if `copied` is set, becomes
+// one.
+func (d *dedupTable[T]) intern(data []T, copied bool) *dedupBlock[T] {
+	size := int(unsafe.Sizeof(*new(T)))
+	hash := maphash.Bytes(d.seed, unsafe.Slice((*byte)(unsafe.Pointer(unsafe.SliceData(data))), len(data)*size))
+	for _, b := range d.blocks[hash] {
+		if d.equal(b.data, data) {
+			b.refs++
+			return b
+		}
+	}
+
+	if copied {
+		data = slices.Clone(data)
+	}
+	b := &dedupBlock[T]{data: data, hash: hash, refs: 1}
+	d.blocks[hash] = append(d.blocks[hash], b)
+	return b
+}
+
+// release removes a reference to `b`, and forgets it once it has none left.
+func (d *dedupTable[T]) release(b *dedupBlock[T]) {
+	b.refs--
+	if b.refs > 0 {
+		return
+	}
+
+	blocks := slices.DeleteFunc(d.blocks[b.hash], func(other *dedupBlock[T]) bool {
+		return other == b
+	})
+	if len(blocks) == 0 {
+		delete(d.blocks, b.hash)
+	} else {
+		d.blocks[b.hash] = blocks
+	}
+}
+
+// setDeduplicated calls setSplit for the blocks of `e`, shared with identical
+// ones, and for the partial blocks at either end. Runs and empty writes are
+// passed on whole.
+func (c *Store[T]) setDeduplicated(e entry[T]) {
+	if e.run || len(e.data) == 0 {
+		c.setSplit(e)
+		return
+	}
+
+	size := int64(c.dedup.blockSize)
+	data := e.data
+	for start := 0; start < len(data); {
+		offset := e.offset + int64(start)
+		n := int(min(int64(len(data)-start), alignDown(offset, size)+size-offset))
+
+		part := e
+		part.offset = offset
+		part.data = data[start : start+n : start+n]
+		// The parts share the backing array of `e`, so none of them owns it.
+		part.owned = false
+		if n == int(size) {
+			part.shared = c.dedup.intern(part.data, c.copyOnSet && !e.owned)
+			part.data = part.shared.data
+		}
+		c.setSplit(part)
+		start += n
+	}
+}
@@ -0,0 +1,84 @@
+package store_test
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"github.com/aertje/sparse-store/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStoreDedup(t *testing.T) {
+	s := store.NewStore(store.WithDedup[byte](4096))
+
+	block := bytes.Repeat([]byte{1, 2, 3, 4}, 1024)
+	for i := int64(0); i < 16; i++ {
+		// Every write is a copy, so only deduplication shares them.
+		require.NoError(t, s.Set(bytes.Clone(block), i*4096))
+	}
+	require.NoError(t, s.Set(bytes.Repeat([]byte{5}, 4096), 16*4096))
+
+	assert.Equal(t, int64(17*4096), s.Occupancy())
+	assert.Equal(t, int64(2*4096), s.MemoryUsage().Retained)
+
+	// Overwriting part of a shared block leaves the others untouched.
+	require.NoError(t, s.Set([]byte{9, 9}, 4096+10))
+	data := make([]byte, 4096)
+	assert.True(t, s.Get(data, 0))
+	assert.Equal(t, block, data)
+	assert.True(t, s.Get(data, 2*4096))
+	assert.Equal(t, block, data)
+	assert.True(t, s.Get(data, 4096))
+	assert.Equal(t, []byte{9, 9}, data[10:12])
+}
+
+func TestStoreDedupPartialBlocks(t *testing.T) {
+	s := store.NewStore(store.WithDedup[int](4), store.WithCopyOnSet[int]())
+
+	in := []int{1, 2, 3, 4, 1, 2, 3, 4, 1, 2}
+	require.NoError(t, s.Set(in, 2))
+	// With WithCopyOnSet, the caller may modify what it set.
+	in[2] = 7
+
+	data := make([]int, 12)
+	assert.False(t, s.Get(data, 0))
+	assert.Equal(t, []int{0, 0, 1, 2, 3, 4, 1, 2, 3, 4, 1, 2}, data)
+}
+
+func TestStoreDedupRandom(t *testing.T) {
+	for seed := int64(0); seed < 10; seed++ {
+		r := rand.New(rand.NewSource(seed))
+		s := store.NewStore(store.WithDedup[byte](8), store.WithMinContiguous[byte](16), store.WithLazyCompaction[byte](4, 1<<10))
+		want := make([]byte, 256)
+		set := make([]bool, 256)
+
+		for i := 0; i < 200; i++ {
+			offset := r.Intn(240)
+			length := 1 + r.Intn(16)
+			switch r.Intn(4) {
+			case 0:
+				s.Delete(int64(length), int64(offset))
+				for j := offset; j < offset+length; j++ {
+					want[j], set[j] = 0, false
+				}
+			default:
+				// Few distinct values make for many identical blocks.
+				p := bytes.Repeat([]byte{byte(r.Intn(2))}, length)
+				require.NoError(t, s.Set(p, int64(offset)))
+				copy(want[offset:], p)
+				for j := offset; j < offset+length; j++ {
+					set[j] = true
+				}
+			}
+		}
+
+		got := make([]byte, 256)
+		s.Get(got, 0)
+		assert.Equal(t, want, got, "seed %d", seed)
+		for j := range set {
+			assert.Equal(t, set[j], s.Has(1, int64(j)), "seed %d, offset %d", seed, j)
+		}
+	}
+}
@@ -0,0 +1,421 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Signature describes the blocks of a byte store, so that the differences to
+// another store can be computed without transferring the store. It is the
+// first message of a delta sync, sent by the store to be brought up to date.
+type Signature struct {
+	BlockSize int              `json:"block_size"`
+	Blocks    []BlockSignature `json:"blocks"`
+}
+
+// BlockSignature holds the hashes of a block of values present in a store.
+type BlockSignature struct {
+	Offset int64 `json:"offset"`
+	Length int64 `json:"length"`
+	// Weak is a rolling checksum of the values, and Strong their SHA-256
+	// hash.
+	Weak   uint32 `json:"weak"`
+	Strong []byte `json:"strong"`
+}
+
+// Delta describes how to bring a byte store up to date with another one. It is
+// the second message of a delta sync, sent in response to a Signature.
+type Delta struct {
+	// Length is the length of the other store.
+	Length int64 `json:"length"`
+	// Present holds the ranges the other store holds values for, coalesced.
+	// Values outside of them are deleted.
+	Present []Range   `json:"present"`
+	Ops     []DeltaOp `json:"ops"`
+}
+
+// DeltaOp sets the `Length` values at `Offset`, either to `Data`, or to the
+// values at `Source` in the store being brought up to date if `Data` is nil.
+type DeltaOp struct {
+	Offset int64  `json:"offset"`
+	Length int64  `json:"length"`
+	Source int64  `json:"source,omitempty"`
+	Data   []byte `json:"data,omitempty"`
+}
+
+// Literal returns the number of values the delta transfers.
+func (d *Delta) Literal() int64 {
+	var n int64
+	for _, op := range d.Ops {
+		n += int64(len(op.Data))
+	}
+	return n
+}
+
+// DeltaSource computes deltas against the store it syncs from, such as over a
+// network connection to a remote store.
+type DeltaSource interface {
+	Delta(ctx context.Context, sig *Signature) (*Delta, error)
+}
+
+// StoreDeltaSource is a DeltaSource for a store in the same process.
+type StoreDeltaSource struct {
+	Store *Store[byte]
+}
+
+// Delta computes the delta with Diff.
+func (s StoreDeltaSource) Delta(ctx context.Context, sig *Signature) (*Delta, error) {
+	return Diff(s.Store, sig), nil
+}
+
+// Sync brings `s` up to date with the store behind `source`, transferring only
+// the values that differ in blocks of `blockSize` values.
+func Sync(ctx context.Context, s *Store[byte], source DeltaSource, blockSize int) error {
+	sig, err := Sign(s, blockSize)
+	if err != nil {
+		return err
+	}
+	delta, err := source.Delta(ctx, sig)
+	if err != nil {
+		return err
+	}
+	return Patch(s, delta)
+}
+
+// Sign returns the signature of the blocks of `blockSize` values that are
+// present in `s`. Blocks start at the start of every run of values present,
+// so the last block of a run may be shorter. It returns an error if
+// `blockSize` is not positive.
+func Sign(s *Store[byte], blockSize int) (*Signature, error) {
+	if blockSize <= 0 {
+		return nil, fmt.Errorf("invalid block size %d", blockSize)
+	}
+
+	sig := &Signature{BlockSize: blockSize}
+	buf := make([]byte, 0, blockSize)
+	for _, r := range present(s) {
+		// The values are read a block at a time, rather than a run at a
+		// time, as runs may be as large as the store.
+		for offset := r.Offset; offset < r.End(); offset += int64(blockSize) {
+			block := buf[:min(int64(blockSize), r.End()-offset)]
+			s.Get(block, offset)
+			strong := sha256.Sum256(block)
+			sig.Blocks = append(sig.Blocks, BlockSignature{
+				Offset: offset,
+				Length: int64(len(block)),
+				Weak:   newRollingSum(block).sum(),
+				Strong: strong[:],
+			})
+		}
+	}
+	return sig, nil
+}
+
+// Diff returns the delta that brings a store with signature `sig` up to date
+// with `s`. Values of `s` found in any block of the signature, at any offset,
+// are copied from that block instead of being transferred.
+func Diff(s *Store[byte], sig *Signature) *Delta {
+	blocks := map[uint32][]BlockSignature{}
+	for _, b := range sig.Blocks {
+		blocks[b.Weak] = append(blocks[b.Weak], b)
+	}
+	n := sig.BlockSize
+
+	delta := &Delta{Length: s.Length(), Present: present(s)}
+	for _, r := range delta.Present {
+		// The values of the run are read through a window, as runs may be
+		// as large as the store. Literals are emitted once they reach the
+		// size of the window, so that it only holds those and a block.
+		w := &deltaWindow{store: s, run: r}
+		length := int(r.Length)
+
+		literal := 0
+		emitLiteral := func(to int) {
+			for literal < to {
+				end := min(to, literal+deltaWindowSize)
+				data := bytes.Clone(w.slice(literal, end))
+				delta.Ops = append(delta.Ops, DeltaOp{Offset: r.Offset + int64(literal), Length: int64(end - literal), Data: data})
+				literal = end
+			}
+		}
+
+		var sum rollingSum
+		if n > 0 && length >= n {
+			sum = newRollingSum(w.slice(0, n))
+		}
+		for i := 0; n > 0 && i+n <= length; {
+			if i-literal >= deltaWindowSize {
+				emitLiteral(i)
+			}
+			if b, ok := match(blocks[sum.sum()], w.slice(literal, i+n)[i-literal:]); ok {
+				emitLiteral(i)
+				delta.appendCopy(r.Offset+int64(i), b.Offset, int64(n))
+				i += n
+				literal = i
+				if i+n <= length {
+					sum = newRollingSum(w.slice(literal, i+n))
+				}
+				continue
+			}
+			if i+n < length {
+				window := w.slice(literal, i+n+1)[i-literal:]
+				sum.roll(window[0], window[n])
+			}
+			i++
+		}
+		// The end of the run may match a shorter block.
+		if tail := length - literal; tail > 0 && tail < n {
+			values := w.slice(literal, length)
+			if b, ok := match(blocks[newRollingSum(values).sum()], values); ok {
+				delta.appendCopy(r.Offset+int64(literal), b.Offset, int64(tail))
+				literal = length
+			}
+		}
+		emitLiteral(length)
+	}
+
+	return delta
+}
+
+// deltaWindowSize is the number of values of a run Diff reads at a time.
+const deltaWindowSize = 1 << 20
+
+// deltaWindow holds the values of a run of a store from `start` onwards,
+// relative to the run, reading more as they are needed.
+type deltaWindow struct {
+	store *Store[byte]
+	run   Range
+	start int
+	data  []byte
+}
+
+// slice returns the values [from, to) of the run, relative to it. The values
+// before `from` are dropped once more values are read, so `from` must not
+// decrease between calls.
+func (w *deltaWindow) slice(from, to int) []byte {
+	if end := w.start + len(w.data); to > end {
+		next := make([]byte, min(int(w.run.Length), to+deltaWindowSize)-from)
+		kept := copy(next, w.data[from-w.start:])
+		w.store.Get(next[kept:], w.run.Offset+int64(from+kept))
+		w.start, w.data = from, next
+	}
+	return w.data[from-w.start : to-w.start]
+}
+
+// appendCopy appends an op copying `length` values from `source` to `offset`,
+// extending the last op if it is a copy that ends where this one starts.
+func (d *Delta) appendCopy(offset, source, length int64) {
+	if n := len(d.Ops); n > 0 {
+		last := &d.Ops[n-1]
+		if last.Data == nil && last.Offset+last.Length == offset && last.Source+last.Length == source {
+			last.Length += length
+			return
+		}
+	}
+	d.Ops = append(d.Ops, DeltaOp{Offset: offset, Length: length, Source: source})
+}
+
+// match returns the block of `candidates` that holds `data`, if any.
+func match(candidates []BlockSignature, data []byte) (BlockSignature, bool) {
+	if len(candidates) == 0 {
+		return BlockSignature{}, false
+	}
+	strong := sha256.Sum256(data)
+	for _, b := range candidates {
+		if b.Length == int64(len(data)) && bytes.Equal(b.Strong, strong[:]) {
+			return b, true
+		}
+	}
+	return BlockSignature{}, false
+}
+
+// Patch applies `delta` to `s`. The sources of copies must not have changed
+// since the signature was computed.
+func Patch(s *Store[byte], delta *Delta) error {
+	// Read the sources of the copies before anything is overwritten. Copies
+	// to their own offset leave the values as they are.
+	copies := make([][]byte, len(delta.Ops))
+	for i, op := range delta.Ops {
+		if op.Data != nil || op.Source == op.Offset {
+			continue
+		}
+		// The values to copy must be within the store, which also bounds
+		// the memory allocated for them.
+		if checkBounds("copy", op.Length, op.Source, true) != nil || op.Source+op.Length > s.Length() {
+			return fmt.Errorf("copying %d values from %d: out of bounds", op.Length, op.Source)
+		}
+		copies[i] = make([]byte, op.Length)
+		if !s.Get(copies[i], op.Source) {
+			return fmt.Errorf("copying %d values from %d: values missing", op.Length, op.Source)
+		}
+	}
+
+	// Delete the values the other store does not hold.
+	var stale rangeSet
+	for _, r := range present(s) {
+		stale.add(r.Offset, r.End())
+	}
+	for _, r := range delta.Present {
+		stale.take(r.Offset, r.End())
+	}
+	for _, r := range stale {
+		s.Delete(r.Length, r.Offset)
+	}
+
+	for i, op := range delta.Ops {
+		data := op.Data
+		if data == nil {
+			data = copies[i]
+		}
+		if data == nil {
+			continue
+		}
+		if err := s.Set(data, op.Offset); err != nil {
+			return err
+		}
+	}
+	switch {
+	case delta.Length > s.Length():
+		return s.extendTo(delta.Length)
+	case delta.Length < s.Length():
+		return s.Truncate(delta.Length)
+	}
+	return nil
+}
+
+// present returns the ranges `s` holds values for, coalesced.
+func present[T any](s *Store[T]) []Range {
+	var ranges rangeSet
+	for _, e := range s.Extents() {
+		ranges.add(e.Offset, e.End())
+	}
+	return ranges
+}
+
+// rollingSum is a checksum of a window of values that can be rolled forward a
+// value at a time, as used by rsync.
+type rollingSum struct {
+	a, b uint32
+	n    uint32
+}
+
+func newRollingSum(p []byte) rollingSum {
+	r := rollingSum{n: uint32(len(p))}
+	for i, v := range p {
+		r.a += uint32(v)
+		r.b += uint32(len(p)-i) * uint32(v)
+	}
+	return r
+}
+
+// roll removes `out` from the start of the window, and adds `in` to its end.
+func (r *rollingSum) roll(out, in byte) {
+	r.a += uint32(in) - uint32(out)
+	r.b += r.a - r.n*uint32(out)
+}
+
+func (r rollingSum) sum() uint32 {
+	return r.a&0xffff | r.b<<16
+}
+
+// deltaMagic starts the binary encoding of a delta, followed by its version.
+const (
+	deltaMagic   = "SPDL"
+	deltaVersion = 1
+)
+
+// Kinds of operations in the binary encoding of a delta.
+const (
+	deltaData byte = iota
+	deltaCopy
+)
+
+// MarshalBinary encodes the delta compactly, for patches stored in files.
+func (d *Delta) MarshalBinary() ([]byte, error) {
+	b := append([]byte(deltaMagic), deltaVersion)
+	b = binary.AppendUvarint(b, uint64(d.Length))
+	b = binary.AppendUvarint(b, uint64(len(d.Present)))
+	for _, r := range d.Present {
+		b = binary.AppendVarint(b, r.Offset)
+		b = binary.AppendUvarint(b, uint64(r.Length))
+	}
+	b = binary.AppendUvarint(b, uint64(len(d.Ops)))
+	for _, op := range d.Ops {
+		kind := deltaData
+		if op.Data == nil {
+			kind = deltaCopy
+		}
+		b = append(b, kind)
+		b = binary.AppendVarint(b, op.Offset)
+		b = binary.AppendUvarint(b, uint64(op.Length))
+		if kind == deltaCopy {
+			b = binary.AppendVarint(b, op.Source)
+		} else {
+			b = append(b, op.Data...)
+		}
+	}
+	return b, nil
+}
+
+// UnmarshalBinary decodes a delta encoded by MarshalBinary.
+func (d *Delta) UnmarshalBinary(data []byte) error {
+	r := bytes.NewReader(data)
+	header := make([]byte, len(deltaMagic)+1)
+	if _, err := io.ReadFull(r, header); err != nil || string(header[:len(deltaMagic)]) != deltaMagic || header[len(deltaMagic)] != deltaVersion {
+		return fmt.Errorf("%w: unknown delta header %q", ErrFormat, header)
+	}
+
+	var err error
+	uvarint := func() int64 {
+		n, e := binary.ReadUvarint(r)
+		if err == nil && (e != nil || n > math.MaxInt64) {
+			err = fmt.Errorf("%w: truncated delta", ErrFormat)
+		}
+		return int64(n)
+	}
+	varint := func() int64 {
+		n, e := binary.ReadVarint(r)
+		if err == nil && e != nil {
+			err = fmt.Errorf("%w: truncated delta", ErrFormat)
+		}
+		return n
+	}
+
+	delta := Delta{Length: uvarint()}
+	for n := uvarint(); err == nil && n > 0; n-- {
+		delta.Present = append(delta.Present, Range{Offset: varint(), Length: uvarint()})
+	}
+	for n := uvarint(); err == nil && n > 0; n-- {
+		kind, e := r.ReadByte()
+		if e != nil {
+			return fmt.Errorf("%w: truncated delta", ErrFormat)
+		}
+		op := DeltaOp{Offset: varint(), Length: uvarint()}
+		switch {
+		case err != nil:
+		case kind == deltaCopy:
+			op.Source = varint()
+		case kind == deltaData && op.Length <= int64(r.Len()):
+			op.Data = make([]byte, op.Length)
+			r.Read(op.Data)
+		case kind == deltaData:
+			err = fmt.Errorf("%w: truncated delta", ErrFormat)
+		default:
+			err = fmt.Errorf("%w: unknown delta operation %d", ErrFormat, kind)
+		}
+		delta.Ops = append(delta.Ops, op)
+	}
+	if err != nil {
+		return err
+	}
+	if r.Len() > 0 {
+		return fmt.Errorf("%w: %d trailing bytes", ErrFormat, r.Len())
+	}
+	*d = delta
+	return nil
+}
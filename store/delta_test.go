@@ -0,0 +1,172 @@
+package store_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math/rand"
+	"testing"
+
+	"github.com/aertje/sparse-store/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// jsonDeltaSource sends the messages of a sync through JSON, as a transport
+// would.
+type jsonDeltaSource struct {
+	store *store.Store[byte]
+	delta *store.Delta
+}
+
+func (s *jsonDeltaSource) Delta(ctx context.Context, sig *store.Signature) (*store.Delta, error) {
+	var received store.Signature
+	if err := roundTripJSON(sig, &received); err != nil {
+		return nil, err
+	}
+	delta := store.Diff(s.store, &received)
+	s.delta = &store.Delta{}
+	return s.delta, roundTripJSON(delta, s.delta)
+}
+
+func roundTripJSON(in, out any) error {
+	b, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, out)
+}
+
+func TestSync(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	data := make([]byte, 10000)
+	r.Read(data)
+
+	remote := store.NewStore[byte]()
+	remote.Set(bytes.Clone(data[0:4000]), 0)
+	remote.Set(bytes.Clone(data[5000:9000]), 5000)
+	remote.Set(nil, 12000)
+
+	// The local store is outdated: a few values changed, some were inserted
+	// and removed, one range is stale, and one is missing.
+	local := store.NewStore[byte]()
+	changed := bytes.Clone(data[0:4000])
+	changed[1000] ^= 0xff
+	local.Set(changed, 0)
+	local.Set(bytes.Clone(data[5000:6000]), 4500)
+	local.Set(bytes.Clone(data[6000:7000]), 6100)
+	local.Set(make([]byte, 100), 9500)
+
+	source := &jsonDeltaSource{store: remote}
+	require.NoError(t, store.Sync(context.Background(), local, source, 64))
+
+	assert.Equal(t, []store.Range{{Offset: 0, Length: 4000}, {Offset: 5000, Length: 4000}}, coalesced(local.Extents()))
+	assert.Equal(t, int64(12000), local.Length())
+	got := make([]byte, 4000)
+	require.True(t, local.Get(got, 0))
+	assert.Equal(t, data[0:4000], got)
+	require.True(t, local.Get(got, 5000))
+	assert.Equal(t, data[5000:9000], got)
+
+	// Only the changed block, the values around the moved ranges, and the
+	// missing range are transferred.
+	assert.Less(t, source.delta.Literal(), int64(2300))
+
+	// Syncing again transfers nothing.
+	require.NoError(t, store.Sync(context.Background(), local, source, 64))
+	assert.Zero(t, source.delta.Literal())
+}
+
+func TestSyncEmpty(t *testing.T) {
+	local := store.NewStore[byte]()
+	local.Set([]byte{1, 2, 3}, 10)
+
+	require.NoError(t, store.Sync(context.Background(), local, store.StoreDeltaSource{Store: store.NewStore[byte]()}, 16))
+	assert.Empty(t, local.Extents())
+}
+
+func TestSyncInvalid(t *testing.T) {
+	local := store.NewStore[byte]()
+	local.Set([]byte{1, 2, 3}, 10)
+
+	_, err := store.Sign(local, 0)
+	assert.Error(t, err)
+	assert.Error(t, store.Sync(context.Background(), local, store.StoreDeltaSource{Store: local}, -1))
+
+	// Copies from outside the store are rejected before allocating them.
+	for _, op := range []store.DeltaOp{{Offset: 20, Length: 1 << 50, Source: 10}, {Offset: 20, Length: -1, Source: 10}, {Offset: 20, Length: 3, Source: 11}} {
+		assert.Error(t, store.Patch(local, &store.Delta{Length: 13, Ops: []store.DeltaOp{op}}))
+	}
+}
+
+func coalesced(extents []store.Range) []store.Range {
+	var ranges []store.Range
+	for _, e := range extents {
+		if n := len(ranges); n > 0 && ranges[n-1].End() == e.Offset {
+			ranges[n-1].Length += e.Length
+			continue
+		}
+		ranges = append(ranges, e)
+	}
+	return ranges
+}
+
+func TestDeltaBinary(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	data := make([]byte, 4000)
+	r.Read(data)
+
+	old := store.NewStore[byte]()
+	old.Set(bytes.Clone(data[:3000]), 0)
+	updated := store.NewStore[byte]()
+	updated.Set(bytes.Clone(data[1000:]), 0)
+	updated.Set([]byte{1, 2, 3}, -10)
+	updated.Set(nil, 5000)
+
+	sig, err := store.Sign(old, 64)
+	require.NoError(t, err)
+	delta := store.Diff(updated, sig)
+	b, err := delta.MarshalBinary()
+	require.NoError(t, err)
+	var decoded store.Delta
+	require.NoError(t, decoded.UnmarshalBinary(b))
+	assert.Equal(t, delta, &decoded)
+
+	require.NoError(t, store.Patch(old, &decoded))
+	assert.Equal(t, updated.Extents(), old.Extents())
+	assert.Equal(t, int64(5000), old.Length())
+
+	for _, n := range []int{0, 3, 10, len(b) - 1} {
+		assert.ErrorIs(t, decoded.UnmarshalBinary(b[:n]), store.ErrFormat)
+	}
+	assert.ErrorIs(t, decoded.UnmarshalBinary(append(b, 0)), store.ErrFormat)
+}
+
+func TestSyncLarge(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	data := make([]byte, 3<<20+100)
+	r.Read(data)
+
+	// The run is larger than what Diff reads at a time, and the values are
+	// shifted, so that matches straddle the reads.
+	remote := store.NewStore[byte]()
+	remote.Set(bytes.Clone(data), 0)
+	local := store.NewStore[byte]()
+	local.Set(bytes.Clone(data[1:]), 0)
+	local.Set(nil, 5<<20)
+
+	source := &jsonDeltaSource{store: remote}
+	require.NoError(t, store.Sync(context.Background(), local, source, 1024))
+
+	assert.Equal(t, []store.Range{{Offset: 0, Length: int64(len(data))}}, coalesced(local.Extents()))
+	assert.Equal(t, int64(len(data)), local.Length())
+	got := make([]byte, len(data))
+	require.True(t, local.Get(got, 0))
+	assert.Equal(t, data, got)
+	assert.Less(t, source.delta.Literal(), int64(2048))
+
+	// Without a signature, the whole run is transferred in pieces.
+	delta := store.Diff(remote, &store.Signature{BlockSize: 1024})
+	assert.Equal(t, int64(len(data)), delta.Literal())
+	assert.Greater(t, len(delta.Ops), 1)
+}
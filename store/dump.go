@@ -0,0 +1,71 @@
+package store
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// dumpVersion is the version of the document DumpJSON writes, which is bumped
+// when fields are renamed or removed rather than added.
+const dumpVersion = 1
+
+// dump is the document DumpJSON writes.
+type dump struct {
+	Version   int         `json:"version"`
+	Length    int64       `json:"length"`
+	Start     int64       `json:"start"`
+	Occupancy int64       `json:"occupancy"`
+	Stats     Stats       `json:"stats"`
+	Memory    dumpMemory  `json:"memory"`
+	Extents   []dumpRange `json:"extents"`
+	Pinned    []dumpRange `json:"pinned"`
+	// ExtentLengths and GapLengths are the histograms returned by Histograms.
+	ExtentLengths Histogram `json:"extent_lengths"`
+	GapLengths    Histogram `json:"gap_lengths"`
+}
+
+type dumpMemory struct {
+	Data     int64 `json:"data"`
+	Retained int64 `json:"retained"`
+	Overhead int64 `json:"overhead"`
+}
+
+type dumpRange struct {
+	Offset int64 `json:"offset"`
+	Length int64 `json:"length"`
+}
+
+// DumpJSON writes the statistics of the store and its extent map to `w` as a
+// JSON document for dashboards and support tooling: the version of the
+// document, the length, start and occupancy of the store, its Stats, its
+// MemoryUsage in bytes, its extents and pinned values as objects with an
+// offset and a length, and the histograms of Histograms. The names of the
+// fields are snake_case and kept stable, and lists are empty rather than null.
+func (c *Store[T]) DumpJSON(w io.Writer) error {
+	defer c.enter("dump")()
+	extentLengths, gapLengths := c.Histograms()
+	memory := c.MemoryUsage()
+
+	d := dump{
+		Version:       dumpVersion,
+		Length:        c.Length(),
+		Start:         c.Start(),
+		Occupancy:     c.Occupancy(),
+		Stats:         c.Stats(),
+		Memory:        dumpMemory(memory),
+		Extents:       dumpRanges(c.Extents()),
+		Pinned:        dumpRanges(c.Pinned()),
+		ExtentLengths: append(Histogram{}, extentLengths...),
+		GapLengths:    append(Histogram{}, gapLengths...),
+	}
+	return json.NewEncoder(w).Encode(d)
+}
+
+// dumpRanges returns `ranges` for DumpJSON.
+func dumpRanges(ranges []Range) []dumpRange {
+	dumped := make([]dumpRange, len(ranges))
+	for i, r := range ranges {
+		dumped[i] = dumpRange(r)
+	}
+	return dumped
+}
@@ -0,0 +1,62 @@
+package store_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/aertje/sparse-store/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatsJSON(t *testing.T) {
+	stats := store.Stats{Sets: 1, Gets: 2, Hits: 3, Misses: 4, Served: 5, Has: 6, HasHits: 7, HasMisses: 8, Occupancy: 9, Extents: 10}
+	data, err := json.Marshal(stats)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"sets":1,"gets":2,"hits":3,"misses":4,"served":5,"has":6,"has_hits":7,"has_misses":8,"occupancy":9,"extents":10}`, string(data))
+
+	var decoded store.Stats
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, stats, decoded)
+}
+
+func TestStoreDumpJSON(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, store.NewStore[byte]().DumpJSON(&buf))
+	var empty map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &empty))
+	assert.Equal(t, []any{}, empty["extents"])
+	assert.Equal(t, []any{}, empty["gap_lengths"])
+
+	s := store.NewStore(store.WithLazyCompaction[byte](100, 1000))
+	require.NoError(t, s.Set([]byte{1, 2, 3}, 0))
+	require.NoError(t, s.Set([]byte{4, 5}, 8))
+	s.Get(make([]byte, 3), 0)
+	s.Pin(2, 8)
+
+	buf.Reset()
+	require.NoError(t, s.DumpJSON(&buf))
+	var d struct {
+		Version   int
+		Length    int64
+		Occupancy int64
+		Stats     store.Stats
+		Memory    struct{ Data int64 }
+		Extents   []store.Range
+		Pinned    []store.Range
+		// Histograms count lengths in power-of-two buckets.
+		ExtentLengths []int64 `json:"extent_lengths"`
+		GapLengths    []int64 `json:"gap_lengths"`
+	}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &d))
+	assert.Equal(t, 1, d.Version)
+	assert.Equal(t, int64(10), d.Length)
+	assert.Equal(t, int64(5), d.Occupancy)
+	assert.Equal(t, store.Stats{Sets: 2, Gets: 1, Hits: 1, Served: 3, Occupancy: 5, Extents: 2}, d.Stats)
+	assert.Equal(t, int64(5), d.Memory.Data)
+	assert.Equal(t, []store.Range{{Offset: 0, Length: 3}, {Offset: 8, Length: 2}}, d.Extents)
+	assert.Equal(t, []store.Range{{Offset: 8, Length: 2}}, d.Pinned)
+	assert.Equal(t, []int64{0, 2}, d.ExtentLengths)
+	assert.Equal(t, []int64{0, 0, 1}, d.GapLengths)
+}
@@ -0,0 +1,57 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrEmpty is wrapped by the BoundsError returned for ranges of no values with
+// EmptyReject.
+var ErrEmpty = errors.New("empty range")
+
+// EmptyPolicy determines how the store handles writes and reads of no values,
+// such as Set with a nil slice.
+type EmptyPolicy int
+
+const (
+	// EmptyExtend makes an empty write extend the length of the store to its
+	// offset, marking the end of the values without setting any, and empty
+	// reads succeed at any offset. This is the default.
+	EmptyExtend EmptyPolicy = iota
+	// EmptyIgnore makes empty writes no-ops, and empty reads succeed at any
+	// offset.
+	EmptyIgnore
+	// EmptyReject makes empty writes return a BoundsError wrapping ErrEmpty,
+	// and empty reads fail: Get and Has return false, and the reads of Checked
+	// return a BoundsError.
+	EmptyReject
+)
+
+func (p EmptyPolicy) String() string {
+	switch p {
+	case EmptyExtend:
+		return "extend"
+	case EmptyIgnore:
+		return "ignore"
+	case EmptyReject:
+		return "reject"
+	default:
+		return fmt.Sprintf("EmptyPolicy(%d)", int(p))
+	}
+}
+
+// WithEmptyPolicy sets how the store handles writes and reads of no values.
+func WithEmptyPolicy[T any](policy EmptyPolicy) Option[T] {
+	return func(c *Store[T]) {
+		c.emptyPolicy = policy
+	}
+}
+
+// extendTo extends the length of the store to `length`, as an empty write does
+// with EmptyExtend, whatever the policy.
+func (c *Store[T]) extendTo(length int64) error {
+	policy := c.emptyPolicy
+	c.emptyPolicy = EmptyExtend
+	defer func() { c.emptyPolicy = policy }()
+	return c.set(entry[T]{offset: length})
+}
@@ -0,0 +1,75 @@
+package store_test
+
+import (
+	"testing"
+
+	"github.com/aertje/sparse-store/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStoreEmptyPolicy(t *testing.T) {
+	for _, lazy := range []bool{false, true} {
+		opts := []store.Option[byte]{}
+		if lazy {
+			opts = append(opts, store.WithLazyCompaction[byte](10, 100))
+		}
+
+		t.Run("extend", func(t *testing.T) {
+			s := store.NewStore(opts...)
+			require.NoError(t, s.Set(nil, 100))
+			assert.Equal(t, int64(100), s.Length())
+			assert.Equal(t, int64(0), s.Occupancy())
+			assert.Empty(t, s.Extents())
+
+			assert.True(t, s.Get(nil, 1000))
+			assert.True(t, s.Has(0, -5))
+		})
+
+		t.Run("ignore", func(t *testing.T) {
+			s := store.NewStore(append(opts, store.WithEmptyPolicy[byte](store.EmptyIgnore))...)
+			require.NoError(t, s.Set(nil, 100))
+			require.NoError(t, s.Fill(1, 0, 50))
+			assert.Equal(t, int64(0), s.Length())
+			assert.Equal(t, int64(0), s.Stats().Sets)
+
+			assert.True(t, s.Get([]byte{}, 1000))
+			assert.True(t, s.Has(0, 5))
+		})
+
+		t.Run("reject", func(t *testing.T) {
+			s := store.NewStore(append(opts, store.WithEmptyPolicy[byte](store.EmptyReject))...)
+			require.NoError(t, s.Set([]byte{1, 2}, 0))
+
+			var bounds *store.BoundsError
+			err := s.Set(nil, 100)
+			require.ErrorAs(t, err, &bounds)
+			assert.ErrorIs(t, err, store.ErrEmpty)
+			assert.Equal(t, int64(100), bounds.Offset)
+			assert.Equal(t, int64(2), s.Length())
+
+			assert.False(t, s.Get(nil, 0))
+			assert.False(t, s.Has(0, 1))
+			assert.True(t, s.Has(2, 0))
+
+			_, err = s.Checked().Get(nil, 0)
+			assert.ErrorIs(t, err, store.ErrEmpty)
+			_, err = s.Checked().Has(1, 0)
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestStoreEmptyPolicyReadFrom(t *testing.T) {
+	s := store.NewStore[byte]()
+	require.NoError(t, s.Set([]byte{1}, 3))
+	require.NoError(t, s.Set(nil, 10))
+	data, err := s.MarshalBinary()
+	require.NoError(t, err)
+
+	// The length is restored whatever the policy.
+	restored := store.NewStore(store.WithEmptyPolicy[byte](store.EmptyReject))
+	require.NoError(t, restored.UnmarshalBinary(data))
+	assert.Equal(t, int64(10), restored.Length())
+	assert.Equal(t, s.Extents(), restored.Extents())
+}
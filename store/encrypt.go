@@ -0,0 +1,299 @@
+package store
+
+import (
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sync"
+)
+
+// EncryptedFile is a File that encrypts the values written to an underlying
+// File with an AEAD, such as AES-GCM, so that they never reach it in
+// plaintext. It can be used by a FileTier, or as the writer of
+// WithWriteThrough or WithWriteBack.
+//
+// Values are encrypted in blocks, each stored with a generation that is
+// incremented whenever the block is written. The nonce of a block is derived
+// from its offset and generation, so that it is never reused with the same
+// key, and blocks cannot be moved around undetected. The file starts with an
+// encrypted header holding the number of blocks, so that truncating the file
+// is detected as well, and reads fail with ErrTampered. Blocks within that
+// number that were never written, which are holes in the file, and blocks past
+// it read as zeros. Blocks past it are written at a generation above the one
+// they hold, if any, so that a header that failed to be written after them
+// does not make their nonces be reused.
+//
+// A block zeroed entirely in the file cannot be told from a hole, so it reads
+// as zeros, and a file emptied entirely reads as a new one. Restoring an older
+// copy of the file, such as a backup, cannot be detected either, and makes the
+// next writes reuse nonces, which breaks AEADs such as AES-GCM: the key must
+// not be used with the restored file.
+type EncryptedFile struct {
+	file      File
+	aead      cipher.AEAD
+	blockSize int64
+
+	// mu serializes the writes, which read and rewrite whole blocks, and the
+	// loading of the header.
+	mu sync.Mutex
+	// blocks is the number of blocks of the file, and headerGeneration the
+	// generation of its header, once loaded.
+	blocks           int64
+	headerGeneration uint64
+	loaded           bool
+}
+
+// ErrTampered is returned when an encrypted file was modified other than by
+// writing to it.
+var ErrTampered = errors.New("encrypted file was tampered with")
+
+// generationSize is the size of the generation stored before every block.
+const generationSize = 8
+
+// headerIndex is the block index the nonce of the header is derived from, which
+// no block has.
+const headerIndex = -1
+
+// NewEncryptedFile returns a file encrypting blocks of `blockSize` values
+// with `aead` into `file`. The AEAD must take nonces of at least 12 bytes.
+func NewEncryptedFile(file File, aead cipher.AEAD, blockSize int) (*EncryptedFile, error) {
+	if aead.NonceSize() < 12 {
+		return nil, fmt.Errorf("nonce size %d is too small", aead.NonceSize())
+	}
+	if blockSize <= 0 {
+		return nil, fmt.Errorf("invalid block size %d", blockSize)
+	}
+	return &EncryptedFile{file: file, aead: aead, blockSize: int64(blockSize)}, nil
+}
+
+// stride returns the size of a block in the underlying file.
+func (f *EncryptedFile) stride() int64 {
+	return generationSize + f.blockSize + int64(f.aead.Overhead())
+}
+
+// headerSize returns the size of the header in the underlying file.
+func (f *EncryptedFile) headerSize() int64 {
+	return generationSize + 8 + int64(f.aead.Overhead())
+}
+
+// position returns the position of block `i` in the underlying file.
+func (f *EncryptedFile) position(i int64) int64 {
+	return f.headerSize() + i*f.stride()
+}
+
+// load reads the header, unless it was read already. The caller must hold mu.
+func (f *EncryptedFile) load() error {
+	if f.loaded {
+		return nil
+	}
+	buf := make([]byte, f.headerSize())
+	n, err := f.file.ReadAt(buf, 0)
+	if n == 0 && (err == nil || errors.Is(err, io.EOF)) {
+		// A new file.
+		f.loaded = true
+		return nil
+	}
+	if n < len(buf) {
+		if err == nil || errors.Is(err, io.EOF) {
+			return fmt.Errorf("reading header: %w", ErrTampered)
+		}
+		return err
+	}
+
+	generation := binary.LittleEndian.Uint64(buf)
+	plain, err := f.aead.Open(nil, f.nonce(headerIndex, generation), buf[generationSize:], nil)
+	if err != nil || generation == 0 || generation > math.MaxUint32 {
+		return fmt.Errorf("decrypting header: %w", ErrTampered)
+	}
+	f.blocks = int64(binary.LittleEndian.Uint64(plain))
+	f.headerGeneration = generation
+	f.loaded = true
+	return nil
+}
+
+// grow records that the file holds `blocks` blocks, if it held fewer. The
+// caller must hold mu.
+func (f *EncryptedFile) grow(blocks int64) error {
+	if blocks <= f.blocks {
+		return nil
+	}
+	if f.headerGeneration == math.MaxUint32 {
+		return errors.New("header was written too often")
+	}
+
+	plain := binary.LittleEndian.AppendUint64(nil, uint64(blocks))
+	buf := make([]byte, generationSize, f.headerSize())
+	binary.LittleEndian.PutUint64(buf, f.headerGeneration+1)
+	buf = f.aead.Seal(buf, f.nonce(headerIndex, f.headerGeneration+1), plain, nil)
+	if _, err := f.file.WriteAt(buf, 0); err != nil {
+		return err
+	}
+	f.blocks = blocks
+	f.headerGeneration++
+	return nil
+}
+
+// nonce returns the nonce of block `i` at generation `generation`.
+func (f *EncryptedFile) nonce(i int64, generation uint64) []byte {
+	nonce := make([]byte, f.aead.NonceSize())
+	binary.LittleEndian.PutUint64(nonce, uint64(i))
+	binary.LittleEndian.PutUint32(nonce[8:], uint32(generation))
+	return nonce
+}
+
+// readBlock returns the values of block `i` of a file of `blocks` blocks, and
+// its generation, which is zero if it was never written.
+func (f *EncryptedFile) readBlock(i, blocks int64) ([]byte, uint64, error) {
+	if i >= blocks {
+		return make([]byte, f.blockSize), 0, nil
+	}
+
+	// Blocks within the file are read in full, as holes read as zeros.
+	buf := make([]byte, f.stride())
+	n, err := f.file.ReadAt(buf, f.position(i))
+	if n < len(buf) {
+		if err == nil || errors.Is(err, io.EOF) {
+			return nil, 0, fmt.Errorf("reading block %d: file is truncated: %w", i, ErrTampered)
+		}
+		return nil, 0, err
+	}
+
+	generation := binary.LittleEndian.Uint64(buf)
+	if generation == 0 {
+		for _, b := range buf {
+			if b != 0 {
+				return nil, 0, fmt.Errorf("decrypting block %d: %w", i, ErrTampered)
+			}
+		}
+		return make([]byte, f.blockSize), 0, nil
+	}
+	// Generations are never written past what the nonce holds, so higher
+	// ones would pass for the same generation.
+	plain, err := f.aead.Open(buf[generationSize:generationSize], f.nonce(i, generation), buf[generationSize:], nil)
+	if err != nil || generation > math.MaxUint32 {
+		return nil, 0, fmt.Errorf("decrypting block %d: %w", i, ErrTampered)
+	}
+	return plain, generation, nil
+}
+
+// staleGeneration returns the generation stored for block `i` past the end of
+// the file, left by a write whose header was not written, or zero if there is
+// none.
+func (f *EncryptedFile) staleGeneration(i int64) (uint64, error) {
+	buf := make([]byte, generationSize)
+	n, err := f.file.ReadAt(buf, f.position(i))
+	if n < len(buf) {
+		if err == nil || errors.Is(err, io.EOF) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	generation := binary.LittleEndian.Uint64(buf)
+	if generation > math.MaxUint32 {
+		return 0, fmt.Errorf("reading block %d: %w", i, ErrTampered)
+	}
+	return generation, nil
+}
+
+// writeBlock writes `plain` as block `i` at generation `generation`.
+func (f *EncryptedFile) writeBlock(i int64, plain []byte, generation uint64) error {
+	buf := make([]byte, generationSize, f.stride())
+	binary.LittleEndian.PutUint64(buf, generation)
+	buf = f.aead.Seal(buf, f.nonce(i, generation), plain, nil)
+	_, err := f.file.WriteAt(buf, f.position(i))
+	return err
+}
+
+// Sync syncs the underlying file, if it has a Sync method, so that an
+// EncryptedFile can be the DataFile of a Journaled store.
+func (f *EncryptedFile) Sync() error {
+	if syncer, ok := f.file.(interface{ Sync() error }); ok {
+		return syncer.Sync()
+	}
+	return nil
+}
+
+// Close closes the underlying file, if it is an io.Closer.
+func (f *EncryptedFile) Close() error {
+	if closer, ok := f.file.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// ReadAt decrypts the values at `off` into `p`.
+func (f *EncryptedFile) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, fmt.Errorf("negative offset %d", off)
+	}
+
+	f.mu.Lock()
+	err := f.load()
+	blocks := f.blocks
+	f.mu.Unlock()
+	if err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for n < len(p) {
+		pos := off + int64(n)
+		i := pos / f.blockSize
+		plain, _, err := f.readBlock(i, blocks)
+		if err != nil {
+			return n, err
+		}
+		n += copy(p[n:], plain[pos-i*f.blockSize:])
+	}
+	return n, nil
+}
+
+// WriteAt encrypts `p` into the blocks it overlaps, merging it with the values
+// of blocks it covers only partly.
+func (f *EncryptedFile) WriteAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, fmt.Errorf("negative offset %d", off)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.load(); err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for n < len(p) {
+		pos := off + int64(n)
+		i := pos / f.blockSize
+		plain, generation, err := f.readBlock(i, f.blocks)
+		if err != nil {
+			return n, err
+		}
+		if i >= f.blocks {
+			// The block may have been written before, with the header
+			// failing to grow after it, so its nonce must not be reused.
+			if generation, err = f.staleGeneration(i); err != nil {
+				return n, err
+			}
+		}
+		if generation == math.MaxUint32 {
+			return n, fmt.Errorf("block %d was written too often", i)
+		}
+
+		copied := copy(plain[pos-i*f.blockSize:], p[n:])
+		if err := f.writeBlock(i, plain, generation+1); err != nil {
+			return n, err
+		}
+		// The header is written after the block, so that a failure in
+		// between leaves a block past the end, which reads as zeros, rather
+		// than a missing block within it.
+		if err := f.grow(i + 1); err != nil {
+			return n, err
+		}
+		n += copied
+	}
+	return n, nil
+}
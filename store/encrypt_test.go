@@ -0,0 +1,163 @@
+package store_test
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aertje/sparse-store/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newGCM(t *testing.T) cipher.AEAD {
+	t.Helper()
+	block, err := aes.NewCipher(bytes.Repeat([]byte{7}, 32))
+	require.NoError(t, err)
+	aead, err := cipher.NewGCM(block)
+	require.NoError(t, err)
+	return aead
+}
+
+func newEncryptedFile(t *testing.T) (*store.EncryptedFile, *os.File) {
+	t.Helper()
+	file, err := os.Create(filepath.Join(t.TempDir(), "data"))
+	require.NoError(t, err)
+	t.Cleanup(func() { file.Close() })
+
+	f, err := store.NewEncryptedFile(file, newGCM(t), 16)
+	require.NoError(t, err)
+	return f, file
+}
+
+func TestEncryptedFile(t *testing.T) {
+	f, file := newEncryptedFile(t)
+	secret := []byte("the quick brown fox jumps over the lazy dog")
+
+	tier := store.NewFileTier(f)
+	require.NoError(t, tier.Write(secret, 10))
+	p := make([]byte, len(secret))
+	missing, err := tier.Read(p, 10)
+	require.NoError(t, err)
+	assert.Empty(t, missing)
+	assert.Equal(t, secret, p)
+
+	raw, err := os.ReadFile(file.Name())
+	require.NoError(t, err)
+	assert.NotContains(t, string(raw), "fox")
+
+	// Unwritten values read as zeros, and partial writes keep the rest of
+	// their block.
+	_, err = f.WriteAt([]byte("cat"), 26)
+	require.NoError(t, err)
+	p = make([]byte, 60)
+	_, err = f.ReadAt(p, 0)
+	require.NoError(t, err)
+	assert.Equal(t, append(append(make([]byte, 10), "the quick brown cat jumps over the lazy dog"...), make([]byte, 7)...), p)
+
+	// Rewriting a block encrypts it with another nonce.
+	_, err = f.WriteAt([]byte("fox"), 26)
+	require.NoError(t, err)
+	rewritten, err := os.ReadFile(file.Name())
+	require.NoError(t, err)
+	assert.NotEqual(t, raw, rewritten)
+}
+
+func TestEncryptedFileTampered(t *testing.T) {
+	f, file := newEncryptedFile(t)
+	s := store.NewStore(store.WithWriteThrough(f))
+	require.NoError(t, s.Set([]byte("secret values"), 0))
+
+	raw, err := os.ReadFile(file.Name())
+	require.NoError(t, err)
+	raw[len(raw)/2] ^= 1
+	_, err = file.WriteAt(raw, 0)
+	require.NoError(t, err)
+
+	_, err = f.ReadAt(make([]byte, 13), 0)
+	assert.ErrorContains(t, err, "decrypting block 0")
+	assert.ErrorIs(t, err, store.ErrTampered)
+}
+
+func TestEncryptedFileTruncated(t *testing.T) {
+	f, file := newEncryptedFile(t)
+	_, err := f.WriteAt([]byte("secret values spanning blocks"), 0)
+	require.NoError(t, err)
+	raw, err := os.ReadFile(file.Name())
+	require.NoError(t, err)
+
+	// The file can be opened again.
+	block, err := aes.NewCipher(bytes.Repeat([]byte{7}, 32))
+	require.NoError(t, err)
+	aead, err := cipher.NewGCM(block)
+	require.NoError(t, err)
+	reopened, err := store.NewEncryptedFile(file, aead, 16)
+	require.NoError(t, err)
+	p := make([]byte, 29)
+	_, err = reopened.ReadAt(p, 0)
+	require.NoError(t, err)
+	assert.Equal(t, "secret values spanning blocks", string(p))
+
+	// Truncating the file drops blocks within it.
+	require.NoError(t, file.Truncate(int64(len(raw)-1)))
+	_, err = f.ReadAt(p, 0)
+	assert.ErrorIs(t, err, store.ErrTampered)
+	require.NoError(t, file.Truncate(10))
+	reopened, err = store.NewEncryptedFile(file, aead, 16)
+	require.NoError(t, err)
+	_, err = reopened.ReadAt(p, 0)
+	assert.ErrorIs(t, err, store.ErrTampered)
+
+	// Zeroing the generation of a block does not make it a hole.
+	_, err = file.WriteAt(raw, 0)
+	require.NoError(t, err)
+	// The generation of the first block follows the header.
+	_, err = file.WriteAt(make([]byte, 8), int64(8+8+aead.Overhead()))
+	require.NoError(t, err)
+	_, err = f.ReadAt(p, 0)
+	assert.ErrorIs(t, err, store.ErrTampered)
+}
+
+// failingHeader is a file failing the first write of the header.
+type failingHeader struct {
+	*os.File
+	failed bool
+}
+
+func (f *failingHeader) WriteAt(p []byte, off int64) (int, error) {
+	if off == 0 && !f.failed {
+		f.failed = true
+		return 0, errors.New("header write failed")
+	}
+	return f.File.WriteAt(p, off)
+}
+
+func TestEncryptedFileHeaderFailed(t *testing.T) {
+	_, file := newEncryptedFile(t)
+	aead := newGCM(t)
+	f, err := store.NewEncryptedFile(&failingHeader{File: file}, aead, 16)
+	require.NoError(t, err)
+
+	// The block is written, but the header is not.
+	_, err = f.WriteAt([]byte("first"), 0)
+	assert.Error(t, err)
+	_, err = f.WriteAt([]byte("second"), 0)
+	require.NoError(t, err)
+
+	// The second write used the next generation, rather than reusing the
+	// nonce of the first.
+	generation := make([]byte, 8)
+	_, err = file.ReadAt(generation, int64(8+8+aead.Overhead()))
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), binary.LittleEndian.Uint64(generation))
+
+	p := make([]byte, 6)
+	_, err = f.ReadAt(p, 0)
+	require.NoError(t, err)
+	assert.Equal(t, "second", string(p))
+}
@@ -0,0 +1,217 @@
+package store
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Evictor determines which extents are evicted first when the store exceeds
+// its maximum occupancy, so that policies other than the EvictionPolicy ones
+// can be plugged in.
+type Evictor interface {
+	// EvictsBefore reports whether extent `a` should be evicted before extent
+	// `b`.
+	EvictsBefore(a, b ExtentUsage) bool
+}
+
+// ExtentUsage describes how an extent was used, for an Evictor. The ticks
+// count the writes and reads of the store, so they only compare with each
+// other.
+type ExtentUsage struct {
+	Range
+	// Set is the tick at which the extent was set, and Accessed the tick at
+	// which it was last set or read.
+	Set      int
+	Accessed int
+	// Uses is the number of times the extent was set or read, plus the Uses of
+	// the extent evicted last when it was set, so that new extents compete
+	// with those that were used often long ago. Merged extents keep the
+	// highest number of their parts.
+	Uses int
+	// Reads is the number of times the extent was read since it was set.
+	Reads int
+}
+
+// EvictionObserver is implemented by Evictors that keep state across
+// evictions, such as ARC. The store tells them about its writes and evictions,
+// while it is being modified, so they must not call back into it.
+type EvictionObserver interface {
+	Evictor
+	// Written is called for every write, before the extents it makes the store
+	// exceed its maximum occupancy with are evicted.
+	Written(r Range)
+	// Evicting is called with all extents before they are ordered for
+	// eviction, along with the occupancy and the maximum occupancy.
+	Evicting(extents []ExtentUsage, occupancy, maxOccupancy int64)
+	// Evicted is called for every extent evicted, in order.
+	Evicted(extent ExtentUsage)
+}
+
+// EvictionPolicy is one of the built-in Evictors.
+type EvictionPolicy int
+
+const (
+	// EvictOldest evicts the extents that were set least recently first.
+	EvictOldest EvictionPolicy = iota
+	// EvictLowestOffset evicts the extents with the lowest offset first, which
+	// suits data that is consumed front to back.
+	EvictLowestOffset
+	// EvictLRU evicts the extents that were set or read least recently first.
+	// Get and Has count as reads for the extents overlapping the requested
+	// range.
+	EvictLRU
+	// EvictLFU evicts the extents with the fewest Uses first, and of those the
+	// least recently used. Frequently read extents thus survive scans that
+	// read many others once, which would flush them under EvictLRU, until the
+	// extents evicted were used as often. A new extent is evicted right away
+	// if all others were used more often than the last one evicted.
+	EvictLFU
+)
+
+func (p EvictionPolicy) String() string {
+	switch p {
+	case EvictOldest:
+		return "oldest"
+	case EvictLowestOffset:
+		return "lowest offset"
+	case EvictLRU:
+		return "LRU"
+	case EvictLFU:
+		return "LFU"
+	default:
+		return fmt.Sprintf("EvictionPolicy(%d)", int(p))
+	}
+}
+
+// WithMaxOccupancy caps the occupancy of the store at `maxOccupancy`. When a
+// write makes the store exceed it, whole extents are evicted according to
+// `policy`, an EvictionPolicy or another Evictor, until it no longer does. In
+// lazy mode the cap is enforced on compaction.
+func WithMaxOccupancy[T any](maxOccupancy int64, policy Evictor) Option[T] {
+	return func(c *Store[T]) {
+		c.maxOccupancy = maxOccupancy
+		c.evictionPolicy = policy
+		c.evictionObserver, _ = policy.(EvictionObserver)
+	}
+}
+
+// EvictLRU evicts the `n` least recently used extents that hold no pinned
+// values, and returns the number of values evicted.
+func (c *Store[T]) EvictLRU(n int) int64 {
+	defer c.enter("evict-lru")()
+	c.mutate()
+	c.recorder.record("evict-lru", n)
+	c.Compact()
+
+	occupancy := c.occupancy
+	c.evictBy(EvictLRU, func(evicted int) bool {
+		return evicted >= n
+	})
+	c.publish()
+
+	return occupancy - c.occupancy
+}
+
+// EvictsBefore implements Evictor.
+func (p EvictionPolicy) EvictsBefore(a, b ExtentUsage) bool {
+	switch p {
+	case EvictLowestOffset:
+		return a.Offset < b.Offset
+	case EvictLRU:
+		return a.Accessed < b.Accessed
+	case EvictLFU:
+		if a.Uses != b.Uses {
+			return a.Uses < b.Uses
+		}
+		return a.Accessed < b.Accessed
+	default:
+		return a.Set < b.Set
+	}
+}
+
+// usage returns the usage of `e` for an Evictor.
+func (e entry[T]) usage() ExtentUsage {
+	return ExtentUsage{
+		Range:    Range{Offset: e.offset, Length: e.size()},
+		Set:      e.order,
+		Accessed: e.accessed,
+		Uses:     e.uses,
+		Reads:    e.reads,
+	}
+}
+
+// evict evicts entries until the occupancy is within the configured maximum.
+func (c *Store[T]) evict() {
+	if c.maxOccupancy <= 0 {
+		return
+	}
+
+	c.evictBy(c.evictionPolicy, func(int) bool {
+		return c.occupancy <= c.maxOccupancy
+	})
+}
+
+// evictBy evicts entries in the order given by `policy` until `done` returns
+// true. It is consulted before every eviction with the number of entries
+// evicted so far.
+func (c *Store[T]) evictBy(policy Evictor, done func(evicted int) bool) {
+	if done(0) {
+		return
+	}
+
+	candidates := make([]int, len(c.entries))
+	usages := make([]ExtentUsage, len(c.entries))
+	for i := range candidates {
+		candidates[i] = i
+		usages[i] = c.entries[i].usage()
+	}
+	observer, _ := policy.(EvictionObserver)
+	if observer != nil {
+		observer.Evicting(usages, c.occupancy, c.maxOccupancy)
+	}
+	slices.SortFunc(candidates, func(i, j int) int {
+		switch {
+		case policy.EvictsBefore(usages[i], usages[j]):
+			return -1
+		case policy.EvictsBefore(usages[j], usages[i]):
+			return 1
+		default:
+			return 0
+		}
+	})
+
+	evicted := make([]bool, len(c.entries))
+	n := 0
+	for _, i := range candidates {
+		if done(n) {
+			break
+		}
+		if c.pinned.overlaps(c.entries[i].offset, c.entries[i].end()) {
+			continue
+		}
+		n++
+		evicted[i] = true
+		c.evictedUses = c.entries[i].uses
+		if observer != nil {
+			observer.Evicted(usages[i])
+		}
+		c.occupancy -= c.entries[i].size()
+		c.hooks.evict(c.entries[i].offset, c.entries[i].size())
+		c.unseal(c.entries[i].offset, c.entries[i].size())
+		if c.debugging() {
+			c.debug("evicted extent", "offset", c.entries[i].offset, "length", c.entries[i].size(), "policy", policy)
+		}
+		c.unmark(c.entries[i])
+		c.flushEntry(c.entries[i])
+		c.release(c.entries[i])
+	}
+
+	kept := c.entries[:0]
+	for i, e := range c.entries {
+		if !evicted[i] {
+			kept = append(kept, e)
+		}
+	}
+	clear(c.entries[len(kept):])
+	c.entries = kept
+}
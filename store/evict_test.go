@@ -0,0 +1,182 @@
+package store_test
+
+import (
+	"testing"
+
+	"github.com/aertje/sparse-store/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStoreMaxOccupancy(t *testing.T) {
+	for _, tc := range []struct {
+		name              string
+		policy            store.EvictionPolicy
+		content           []entry
+		expectedOccupancy int64
+		expectedContent   []byte
+	}{
+		{
+			name:   "oldest",
+			policy: store.EvictOldest,
+			content: []entry{
+				{offset: 4, data: []byte{4, 5}},
+				{offset: 0, data: []byte{0, 1}},
+				{offset: 8, data: []byte{8, 9}},
+			},
+			expectedOccupancy: 4,
+			expectedContent:   []byte{0, 1, 0, 0, 0, 0, 0, 0, 8, 9},
+		},
+		{
+			name:   "lowest offset",
+			policy: store.EvictLowestOffset,
+			content: []entry{
+				{offset: 4, data: []byte{4, 5}},
+				{offset: 0, data: []byte{0, 1}},
+				{offset: 8, data: []byte{8, 9}},
+			},
+			expectedOccupancy: 4,
+			expectedContent:   []byte{0, 0, 0, 0, 4, 5, 0, 0, 8, 9},
+		},
+		{
+			name:   "within cap",
+			policy: store.EvictOldest,
+			content: []entry{
+				{offset: 0, data: []byte{0, 1}},
+				{offset: 1, data: []byte{10, 2, 3}},
+			},
+			expectedOccupancy: 4,
+			expectedContent:   []byte{0, 10, 2, 3},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s := store.NewStore(
+				store.WithMinContiguous[byte](1),
+				store.WithMaxOccupancy[byte](4, tc.policy),
+			)
+
+			for _, entry := range tc.content {
+				s.Set(entry.data, entry.offset)
+			}
+
+			assert.Equal(t, tc.expectedOccupancy, s.Occupancy())
+			data := make([]byte, len(tc.expectedContent))
+			s.Get(data, 0)
+			assert.Equal(t, tc.expectedContent, data)
+		})
+	}
+}
+
+func TestStoreEvictLRU(t *testing.T) {
+	s := store.NewStore(store.WithMinContiguous[byte](1))
+
+	s.Set([]byte{0, 1}, 0)
+	s.Set([]byte{4, 5}, 4)
+	s.Set([]byte{8, 9}, 8)
+
+	// Reading the first extent makes the second one the least recently used.
+	assert.True(t, s.Has(2, 0))
+
+	assert.Equal(t, int64(2), s.EvictLRU(1))
+	assert.False(t, s.Has(2, 4))
+	assert.True(t, s.Has(2, 0))
+	assert.True(t, s.Has(2, 8))
+
+	// Reading the last extent makes the first one the least recently used.
+	data := make([]byte, 2)
+	assert.True(t, s.Get(data, 8))
+
+	assert.Equal(t, int64(2), s.EvictLRU(1))
+	assert.Equal(t, int64(2), s.Occupancy())
+	assert.True(t, s.Has(2, 8))
+}
+
+func TestStoreMaxOccupancyLRU(t *testing.T) {
+	s := store.NewStore(
+		store.WithMinContiguous[byte](1),
+		store.WithMaxOccupancy[byte](4, store.EvictLRU),
+	)
+
+	s.Set([]byte{0, 1}, 0)
+	s.Set([]byte{4, 5}, 4)
+	assert.True(t, s.Has(2, 0))
+	s.Set([]byte{8, 9}, 8)
+
+	assert.Equal(t, int64(4), s.Occupancy())
+	assert.True(t, s.Has(2, 0))
+	assert.False(t, s.Has(2, 4))
+	assert.True(t, s.Has(2, 8))
+}
+
+func TestStoreMaxOccupancyLFU(t *testing.T) {
+	for _, tc := range []struct {
+		policy      store.EvictionPolicy
+		hotSurvives bool
+	}{
+		{policy: store.EvictLRU, hotSurvives: false},
+		{policy: store.EvictLFU, hotSurvives: true},
+	} {
+		t.Run(tc.policy.String(), func(t *testing.T) {
+			s := store.NewStore(
+				store.WithMinContiguous[byte](1),
+				store.WithMaxOccupancy[byte](8, tc.policy),
+			)
+
+			s.Set([]byte{0, 1}, 0)
+			data := make([]byte, 2)
+			for i := 0; i < 10; i++ {
+				assert.True(t, s.Get(data, 0))
+			}
+
+			// A scan reads every extent once, which flushes the frequently read
+			// one under LRU only.
+			for offset := int64(4); offset < 40; offset += 4 {
+				s.Set([]byte{2, 3}, offset)
+				s.Get(data, offset)
+			}
+
+			assert.Equal(t, int64(8), s.Occupancy())
+			assert.Equal(t, tc.hotSurvives, s.Has(2, 0))
+			assert.True(t, s.Has(2, 36))
+		})
+	}
+}
+
+// highestOffset evicts the extents with the highest offset first.
+type highestOffset struct{}
+
+func (highestOffset) EvictsBefore(a, b store.ExtentUsage) bool {
+	return a.Offset > b.Offset
+}
+
+func TestStoreMaxOccupancyEvictor(t *testing.T) {
+	s := store.NewStore(
+		store.WithMinContiguous[byte](1),
+		store.WithMaxOccupancy[byte](4, highestOffset{}),
+	)
+
+	s.Set([]byte{8, 9}, 8)
+	s.Set([]byte{0, 1}, 0)
+	s.Set([]byte{4, 5}, 4)
+
+	assert.Equal(t, []store.Range{{Offset: 0, Length: 2}, {Offset: 4, Length: 2}}, s.Extents())
+}
+
+func TestStoreMaxOccupancyLFUAging(t *testing.T) {
+	s := store.NewStore(
+		store.WithMinContiguous[byte](1),
+		store.WithMaxOccupancy[byte](8, store.EvictLFU),
+	)
+	s.Set([]byte{0, 1}, 0)
+	data := make([]byte, 2)
+	for i := 0; i < 10; i++ {
+		s.Get(data, 0)
+	}
+
+	// The uses of new extents catch up with those of the one read often, so
+	// that a long enough scan evicts it after all.
+	for offset := int64(4); offset < 400; offset += 4 {
+		s.Set([]byte{2, 3}, offset)
+		s.Get(data, offset)
+	}
+	assert.False(t, s.Has(2, 0))
+}
@@ -0,0 +1,45 @@
+package store
+
+// Expire removes the data whose TTL has passed, and returns the number of
+// values removed. Stale data is also removed whenever the store is read, Expire
+// allows reclaiming it without reading.
+func (c *Store[T]) Expire() int64 {
+	defer c.enter("expire")()
+	c.mutate()
+	c.recorder.record("expire")
+	c.Compact()
+
+	occupancy := c.occupancy
+	c.expire()
+
+	return occupancy - c.occupancy
+}
+
+// expire removes the entries that are stale.
+func (c *Store[T]) expire() {
+	if !c.expiring || c.frozen {
+		return
+	}
+
+	now := c.now()
+	kept := c.entries[:0]
+	for _, e := range c.entries {
+		if !e.expires.IsZero() && !now.Before(e.expires) {
+			c.occupancy -= e.size()
+			c.hooks.evict(e.offset, e.size())
+			c.unseal(e.offset, e.size())
+			if c.debugging() {
+				c.debug("expired extent", "offset", e.offset, "length", e.size())
+			}
+			c.unmark(e)
+			c.flushEntry(e)
+			c.release(e)
+			continue
+		}
+		kept = append(kept, e)
+	}
+	clear(c.entries[len(kept):])
+	c.entries = kept
+
+	c.publish()
+}
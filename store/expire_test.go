@@ -0,0 +1,66 @@
+package store_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aertje/sparse-store/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStoreSetWithTTL(t *testing.T) {
+	s := store.NewStore[byte]()
+
+	s.SetWithTTL([]byte{0, 1}, 0, time.Millisecond)
+	s.SetWithTTL([]byte{2, 3}, 2, time.Hour)
+	s.Set([]byte{4, 5}, 4)
+
+	assert.True(t, s.Has(6, 0))
+	time.Sleep(5 * time.Millisecond)
+
+	assert.False(t, s.Has(2, 0))
+	assert.Equal(t, int64(4), s.Occupancy())
+
+	data := make([]byte, 6)
+	assert.False(t, s.Get(data, 0))
+	assert.Equal(t, []byte{0, 0, 2, 3, 4, 5}, data)
+}
+
+func TestStoreSetWithTTLOverwritten(t *testing.T) {
+	s := store.NewStore[byte]()
+
+	s.SetWithTTL([]byte{0, 1, 2}, 0, time.Millisecond)
+	s.Set([]byte{10}, 1)
+	time.Sleep(5 * time.Millisecond)
+
+	assert.Equal(t, int64(2), s.Expire())
+	assert.Equal(t, int64(1), s.Occupancy())
+	assert.True(t, s.Has(1, 1))
+}
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func TestStoreWithClock(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	s := store.NewStore(store.WithClock[byte](clock))
+
+	s.SetWithTTL([]byte{0, 1}, 0, time.Minute)
+	s.SetWithTTL([]byte{2, 3}, 2, time.Hour)
+
+	clock.now = clock.now.Add(time.Minute - 1)
+	assert.True(t, s.Has(4, 0))
+
+	clock.now = clock.now.Add(1)
+	assert.False(t, s.Has(4, 0))
+	assert.True(t, s.Has(2, 2))
+
+	clock.now = clock.now.Add(time.Hour)
+	assert.Equal(t, int64(2), s.Expire())
+	assert.Equal(t, int64(0), s.Occupancy())
+}
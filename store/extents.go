@@ -0,0 +1,55 @@
+package store
+
+// Range is a range of values at `Offset` with length `Length`.
+type Range struct {
+	Offset int64
+	Length int64
+}
+
+// End returns the offset just past the range.
+func (r Range) End() int64 {
+	return r.Offset + r.Length
+}
+
+// Extents returns the ranges of the extents the store holds, in order. Adjacent
+// extents are returned separately.
+func (c *Store[T]) Extents() []Range {
+	defer c.enter("extents")()
+	c.Compact()
+	c.expire()
+
+	extents := make([]Range, len(c.entries))
+	for i, e := range c.entries {
+		extents[i] = Range{Offset: e.offset, Length: e.size()}
+	}
+
+	return extents
+}
+
+// Gaps returns the ranges within the range at `offset` with length `length`
+// that the store holds no values for, in order.
+func (c *Store[T]) Gaps(length, offset int64) []Range {
+	defer c.enter("gaps")()
+	c.Compact()
+	c.expire()
+
+	var gaps []Range
+	end := endOf(length, offset)
+	pos := offset
+	i := c.entries.Search(offset)
+	// The entry before the first one at or after the offset may cover it.
+	if i > 0 {
+		pos = max(pos, c.entries[i-1].end())
+	}
+	for ; i < len(c.entries) && c.entries[i].offset < end; i++ {
+		if c.entries[i].offset > pos {
+			gaps = append(gaps, Range{Offset: pos, Length: c.entries[i].offset - pos})
+		}
+		pos = max(pos, c.entries[i].end())
+	}
+	if pos < end {
+		gaps = append(gaps, Range{Offset: pos, Length: end - pos})
+	}
+
+	return gaps
+}
@@ -0,0 +1,40 @@
+package store_test
+
+import (
+	"testing"
+
+	"github.com/aertje/sparse-store/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStoreExtents(t *testing.T) {
+	s := store.NewStore(store.WithMinContiguous[byte](2))
+
+	s.Set([]byte{1, 2, 3}, 5)
+	s.Set([]byte{1}, 0)
+	s.Set([]byte{1}, 1)
+	s.Set([]byte{1}, 2)
+
+	assert.Equal(t, []store.Range{
+		{Offset: 0, Length: 2},
+		{Offset: 2, Length: 1},
+		{Offset: 5, Length: 3},
+	}, s.Extents())
+}
+
+func TestStoreGaps(t *testing.T) {
+	s := store.NewStore(store.WithMinContiguous[byte](1))
+	s.Set([]byte{1, 2, 3}, 5)
+	s.Set([]byte{1, 2}, 8)
+	s.Set([]byte{1}, 12)
+
+	assert.Equal(t, []store.Range{
+		{Offset: 0, Length: 5},
+		{Offset: 10, Length: 2},
+		{Offset: 13, Length: 7},
+	}, s.Gaps(20, 0))
+	assert.Equal(t, []store.Range{{Offset: 10, Length: 1}}, s.Gaps(5, 6))
+	assert.Nil(t, s.Gaps(4, 5))
+	assert.Nil(t, s.Gaps(0, 0))
+	assert.Equal(t, []store.Range{{Offset: 30, Length: 5}}, s.Gaps(5, 30))
+}
@@ -0,0 +1,120 @@
+package store
+
+import "slices"
+
+// FindFunc returns the offset of the first value at or after `offset` for which
+// `fn` returns true, skipping gaps, and whether there is one. `fn` is called
+// once for a run stored in constant space.
+func (c *Store[T]) FindFunc(offset int64, fn func(T) bool) (int64, bool) {
+	c.Compact()
+	c.expire()
+
+	for i := c.from(offset); i < len(c.entries); i++ {
+		e := c.entries[i]
+		e = e.slice(max(e.offset, offset), e.end())
+		if e.run {
+			if fn(e.value) {
+				return e.offset, true
+			}
+			continue
+		}
+		if j := slices.IndexFunc(e.data, fn); j >= 0 {
+			return e.offset + int64(j), true
+		}
+	}
+	return 0, false
+}
+
+// Find returns the offset of the first value at or after `offset` equal to
+// `value` in `s`, skipping gaps, and whether there is one.
+func Find[T comparable](s *Store[T], value T, offset int64) (int64, bool) {
+	return s.FindFunc(offset, func(v T) bool {
+		return v == value
+	})
+}
+
+// Index returns the offset of the first occurrence of `sub` in `s` at or after
+// `offset`, and whether there is one. An occurrence may span adjacent extents,
+// but not gaps.
+func Index[T comparable](s *Store[T], sub []T, offset int64) (int64, bool) {
+	if len(sub) == 0 {
+		return offset, true
+	}
+	s.Compact()
+	s.expire()
+
+	m := len(sub)
+	// tail holds the last values before tailEnd, up to m-1 of them, for
+	// occurrences that start in one extent and end in the next.
+	var tail []T
+	var tailEnd int64
+	search := func(data []T, offset int64) (int64, bool) {
+		if offset != tailEnd {
+			tail = tail[:0]
+		}
+
+		// Occurrences starting in the tail.
+		if len(tail) > 0 {
+			boundary := append(slices.Clone(tail), data[:min(len(data), m-1)]...)
+			if j := indexOf(boundary[:min(len(boundary), len(tail)+m-1)], sub); j >= 0 {
+				return offset - int64(len(tail)) + int64(j), true
+			}
+		}
+		if j := indexOf(data, sub); j >= 0 {
+			return offset + int64(j), true
+		}
+
+		tail = append(tail, data...)
+		tail = tail[max(0, len(tail)-(m-1)):]
+		tailEnd = offset + int64(len(data))
+		return 0, false
+	}
+
+	for i := s.from(offset); i < len(s.entries); i++ {
+		e := s.entries[i]
+		e = e.slice(max(e.offset, offset), e.end())
+		if !e.run {
+			if found, ok := search(e.data, e.offset); ok {
+				return found, true
+			}
+			continue
+		}
+
+		// An occurrence within a run is found among its first m values, and
+		// one ending after it among its last m values, so those are all that
+		// need to be searched.
+		n := min(e.runLength, int64(2*m))
+		values := make([]T, n)
+		for j := range values {
+			values[j] = e.value
+		}
+		if found, ok := search(values[:min(n, int64(m))], e.offset); ok {
+			return found, true
+		}
+		if n > int64(m) {
+			if found, ok := search(values[m:], e.end()-(n-int64(m))); ok {
+				return found, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// indexOf returns the index of the first occurrence of `sub` in `data`, or -1.
+func indexOf[T comparable](data, sub []T) int {
+	for i := 0; i+len(sub) <= len(data); i++ {
+		if slices.Equal(data[i:i+len(sub)], sub) {
+			return i
+		}
+	}
+	return -1
+}
+
+// from returns the index of the first entry ending after `offset`.
+func (c *Store[T]) from(offset int64) int {
+	i := c.entries.Search(offset)
+	if i > 0 && c.entries[i-1].end() > offset {
+		i--
+	}
+	return i
+}
@@ -0,0 +1,103 @@
+package store_test
+
+import (
+	"math/rand"
+	"slices"
+	"testing"
+
+	"github.com/aertje/sparse-store/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStoreFind(t *testing.T) {
+	s := store.NewStore(store.WithMinContiguous[byte](1))
+	s.Set([]byte{1, 2, 3}, 10)
+	s.Fill(0xff, 1<<40, 20)
+
+	offset, ok := store.Find(s, 3, 0)
+	assert.True(t, ok)
+	assert.Equal(t, int64(12), offset)
+
+	offset, ok = s.FindFunc(13, func(v byte) bool { return v > 2 })
+	assert.True(t, ok)
+	assert.Equal(t, int64(20), offset)
+
+	_, ok = store.Find(s, 2, 12)
+	assert.False(t, ok)
+}
+
+func TestIndex(t *testing.T) {
+	s := store.NewStore(store.WithMinContiguous[byte](1))
+	s.Set([]byte{0xaa, 0x55}, 5)
+	// The marker spans adjacent extents.
+	s.Set([]byte{0xaa}, 20)
+	s.Set([]byte{0x55, 0x01}, 21)
+	s.Fill(7, 1<<40, 100)
+
+	offset, ok := store.Index(s, []byte{0xaa, 0x55}, 0)
+	assert.True(t, ok)
+	assert.Equal(t, int64(5), offset)
+
+	offset, ok = store.Index(s, []byte{0xaa, 0x55}, 6)
+	assert.True(t, ok)
+	assert.Equal(t, int64(20), offset)
+
+	// The end of the run is searched without expanding it.
+	s.Set([]byte{8}, 100+1<<40)
+	offset, ok = store.Index(s, []byte{7, 7, 8}, 0)
+	assert.True(t, ok)
+	assert.Equal(t, int64(98+1<<40), offset)
+
+	_, ok = store.Index(s, []byte{0x55, 0xaa}, 0)
+	assert.False(t, ok)
+}
+
+func TestIndexRandom(t *testing.T) {
+	for seed := int64(0); seed < 20; seed++ {
+		r := rand.New(rand.NewSource(seed))
+		s := store.NewStore(store.WithMinContiguous[byte](1))
+		want := make([]int, 128)
+		for i := range want {
+			want[i] = -1
+		}
+		for i := 0; i < 30; i++ {
+			offset, length := r.Intn(120), 1+r.Intn(8)
+			value := byte(r.Intn(2))
+			if r.Intn(3) == 0 {
+				s.Fill(value, int64(length), int64(offset))
+			} else {
+				p := make([]byte, length)
+				for j := range p {
+					p[j] = byte(r.Intn(2))
+				}
+				value = 255
+				s.Set(p, int64(offset))
+				for j := range p {
+					want[offset+j] = int(p[j])
+				}
+			}
+			if value != 255 {
+				for j := offset; j < offset+length; j++ {
+					want[j] = int(value)
+				}
+			}
+		}
+
+		sub := []int{r.Intn(2), r.Intn(2), r.Intn(2)}
+		from := int64(r.Intn(20))
+		expected := int64(-1)
+		for i := int(from); i+len(sub) <= len(want); i++ {
+			if slices.Equal(want[i:i+len(sub)], sub) {
+				expected = int64(i)
+				break
+			}
+		}
+
+		bsub := []byte{byte(sub[0]), byte(sub[1]), byte(sub[2])}
+		offset, ok := store.Index(s, bsub, from)
+		assert.Equal(t, expected >= 0, ok, "seed %d", seed)
+		if ok {
+			assert.Equal(t, expected, offset, "seed %d", seed)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package store
+
+import "errors"
+
+// ErrFrozen is returned by writes to a frozen store.
+var ErrFrozen = errors.New("store is frozen")
+
+// Freeze makes the store read-only, so that it can be read from multiple
+// goroutines without locking, for instance once it is built at startup. It
+// compacts the store, expires stale values, and writes back the values set
+// with WithWriteBack first; if that fails, the store is not frozen and the
+// error is returned.
+//
+// Once frozen, writes return ErrFrozen, and Delete, Clear, EvictLRU, Expire,
+// Shrink and Transform panic with it. Reads no longer count as accesses for
+// eviction, nor are they recorded or counted in the heatmap, and values set
+// with a TTL no longer expire.
+func (c *Store[T]) Freeze() error {
+	defer c.enter("freeze")()
+	if c.frozen {
+		return nil
+	}
+	c.Compact()
+	c.expire()
+	if err := c.Flush(); err != nil {
+		return err
+	}
+	c.frozen = true
+	return nil
+}
+
+// Frozen reports whether the store was frozen.
+func (c *Store[T]) Frozen() bool {
+	return c.frozen
+}
+
+// mutate panics if the store is frozen, before a mutation.
+func (c *Store[T]) mutate() {
+	if c.frozen {
+		panic(ErrFrozen)
+	}
+}
+
+// access counts a read of the values in [offset, end) as an access, unless the
+// store is frozen.
+func (c *Store[T]) access(offset, end int64) {
+	if c.frozen {
+		return
+	}
+	c.accessCount++
+	if c.heatmap != nil {
+		// Reads past the values are not counted, so that they do not grow
+		// the heatmap without bounds.
+		c.heatmap.add(offset, min(end, c.length))
+	}
+}
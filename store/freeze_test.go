@@ -0,0 +1,75 @@
+package store_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aertje/sparse-store/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStoreFreeze(t *testing.T) {
+	s := store.NewStore(store.WithMinContiguous[byte](4), store.WithLazyCompaction[byte](10, 100))
+	require.NoError(t, s.Set([]byte{1, 2, 3}, 0))
+	require.NoError(t, s.Set([]byte{4, 5}, 10))
+
+	require.NoError(t, s.Freeze())
+	assert.True(t, s.Frozen())
+
+	assert.ErrorIs(t, s.Set([]byte{6}, 20), store.ErrFrozen)
+	assert.ErrorIs(t, s.SetOwned([]byte{6}, 20), store.ErrFrozen)
+	assert.PanicsWithValue(t, store.ErrFrozen, func() { s.Delete(1, 0) })
+	assert.PanicsWithValue(t, store.ErrFrozen, func() { s.Clear() })
+	assert.PanicsWithValue(t, store.ErrFrozen, func() { s.EvictLRU(1) })
+
+	data := make([]byte, 3)
+	assert.True(t, s.Get(data, 0))
+	assert.Equal(t, []byte{1, 2, 3}, data)
+	assert.False(t, s.Has(1, 20))
+	assert.Equal(t, int64(5), s.Occupancy())
+}
+
+func TestStoreFreezeConcurrentReads(t *testing.T) {
+	s := store.NewStore(store.WithMinContiguous[byte](4), store.WithMaxOccupancy[byte](1000, store.EvictLRU))
+	for i := int64(0); i < 100; i++ {
+		require.NoError(t, s.Set([]byte{byte(i), byte(i)}, i*4))
+	}
+	require.NoError(t, s.Freeze())
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			data := make([]byte, 2)
+			for i := int64(0); i < 100; i++ {
+				assert.True(t, s.Get(data, i*4))
+				assert.Equal(t, []byte{byte(i), byte(i)}, data)
+				assert.True(t, s.Has(2, i*4))
+				assert.False(t, s.Has(3, i*4))
+			}
+		}()
+	}
+	// Rejected writes do not race with the reads.
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			assert.ErrorIs(t, s.SetWithTTL([]byte{1}, 1, time.Minute), store.ErrFrozen)
+			assert.ErrorIs(t, s.SetWithPriority([]byte{1}, 1, 1), store.ErrFrozen)
+		}
+	}()
+	wg.Wait()
+}
+
+func TestStoreFreezeFlushes(t *testing.T) {
+	f := &file{}
+	s := store.NewStore(store.WithWriteBack(f))
+	require.NoError(t, s.Set([]byte{1, 2}, 3))
+
+	require.NoError(t, s.Freeze())
+	assert.Equal(t, []byte{0, 0, 0, 1, 2}, f.data)
+	assert.NoError(t, s.Flush())
+}
@@ -0,0 +1,75 @@
+package store
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"strconv"
+	"sync"
+)
+
+// WithConcurrencyCheck makes the store panic when it is used from another
+// goroutine while an operation is in progress, which would otherwise corrupt
+// its extents silently. It is meant for debugging, as it slows down every
+// operation. Reads of a frozen store are not checked.
+func WithConcurrencyCheck[T any]() Option[T] {
+	return func(c *Store[T]) {
+		c.guard = &concurrencyGuard{}
+	}
+}
+
+// concurrencyGuard tracks the goroutine running an operation on a store.
+// Operations may call each other within that goroutine.
+type concurrencyGuard struct {
+	mu    sync.Mutex
+	owner int64
+	op    string
+	depth int
+}
+
+// enter records that operation `op` started, and panics if another goroutine
+// is running one.
+func (g *concurrencyGuard) enter(op string) {
+	id := goroutineID()
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.depth > 0 && g.owner != id {
+		panic(fmt.Sprintf("store: concurrent %s in goroutine %d while goroutine %d is in %s; a store must not be used from multiple goroutines without synchronization", op, id, g.owner, g.op))
+	}
+	if g.depth == 0 {
+		g.owner, g.op = id, op
+	}
+	g.depth++
+}
+
+// leave records that the latest operation entered finished.
+func (g *concurrencyGuard) leave() {
+	g.mu.Lock()
+	g.depth--
+	g.mu.Unlock()
+}
+
+// goroutineID returns the ID of the current goroutine, from its stack trace.
+func goroutineID() int64 {
+	var buf [64]byte
+	b := buf[:runtime.Stack(buf[:], false)]
+	b = bytes.TrimPrefix(b, []byte("goroutine "))
+	if i := bytes.IndexByte(b, ' '); i >= 0 {
+		b = b[:i]
+	}
+	id, _ := strconv.ParseInt(string(b), 10, 64)
+	return id
+}
+
+// noGuard is returned by enter when there is nothing to leave.
+func noGuard() {}
+
+// enter records that operation `op` started, if WithConcurrencyCheck is used,
+// and returns the function recording that it finished.
+func (c *Store[T]) enter(op string) func() {
+	if c.guard == nil || c.frozen {
+		return noGuard
+	}
+	c.guard.enter(op)
+	return c.guard.leave
+}
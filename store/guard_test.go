@@ -0,0 +1,50 @@
+package store_test
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/aertje/sparse-store/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStoreConcurrencyCheck(t *testing.T) {
+	inSet, done := make(chan struct{}), make(chan struct{})
+	var s *store.Store[byte]
+	s = store.NewStore(
+		store.WithConcurrencyCheck[byte](),
+		store.WithLazyCompaction[byte](1, 100),
+		store.WithHooks[byte](store.Hooks{OnSet: func(offset, length int64) {
+			if offset == 10 {
+				close(inSet)
+				<-done
+			}
+		}}),
+	)
+
+	// Operations calling each other in the same goroutine are fine.
+	require.NoError(t, s.Set([]byte{1, 2}, 0))
+	assert.True(t, s.Has(2, 0))
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		s.Set([]byte{3}, 10)
+	}()
+
+	<-inSet
+	msg := func() (msg any) {
+		defer func() { msg = recover() }()
+		s.Get(make([]byte, 2), 0)
+		return nil
+	}()
+	assert.Contains(t, msg, "store: concurrent get in goroutine")
+	assert.Contains(t, msg, "is in set")
+	close(done)
+	wg.Wait()
+
+	// The store is usable again once the operation finished.
+	assert.True(t, s.Has(1, 10))
+}
@@ -0,0 +1,65 @@
+package store
+
+import (
+	"cmp"
+	"slices"
+)
+
+// HasAll returns true if the store contains the values of all of `ranges`, as
+// Has would for each of them.
+func (c *Store[T]) HasAll(ranges ...Range) bool {
+	return !slices.Contains(c.HasWhich(ranges...), false)
+}
+
+// HasWhich returns whether the store contains the values of each of `ranges`,
+// as Has would, in a single pass over the extents rather than one per range.
+func (c *Store[T]) HasWhich(ranges ...Range) []bool {
+	defer c.enter("has-which")()
+	if !c.frozen {
+		for _, r := range ranges {
+			c.recorder.record("has", r.Offset, r.Length)
+		}
+	}
+	c.Compact()
+	c.expire()
+
+	// The ranges are checked in order of offset, so that the extents before
+	// them can be skipped for good.
+	order := make([]int, len(ranges))
+	for k := range order {
+		order[k] = k
+	}
+	slices.SortFunc(order, func(a, b int) int {
+		return cmp.Compare(ranges[a].Offset, ranges[b].Offset)
+	})
+
+	has := make([]bool, len(ranges))
+	i := 0
+	for _, k := range order {
+		offset, length := ranges[k].Offset, ranges[k].Length
+		end := endOf(length, offset)
+		c.access(offset, end)
+		if length == 0 {
+			has[k] = c.countHas(c.emptyPolicy != EmptyReject)
+			continue
+		}
+		if c.presence != nil && offset >= 0 {
+			has[k] = c.countHas(c.presence.Contains(offset, end) && end-offset == length)
+			continue
+		}
+
+		for i < len(c.entries) && c.entries[i].end() < offset {
+			i++
+		}
+		completeTo := offset
+		for j := i; j < len(c.entries); j++ {
+			if c.entries[j].offset > end || completeTo < c.entries[j].offset {
+				break
+			}
+			c.touch(j, offset, length)
+			completeTo = c.entries[j].end()
+		}
+		has[k] = c.countHas(completeTo >= end && end-offset == length)
+	}
+	return has
+}
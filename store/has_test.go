@@ -0,0 +1,52 @@
+package store_test
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/aertje/sparse-store/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHasWhich(t *testing.T) {
+	s := store.NewStore[byte]()
+	require.NoError(t, s.Set([]byte{1, 2, 3, 4}, 0))
+	require.NoError(t, s.Set([]byte{5, 6}, 10))
+
+	assert.Equal(t, []bool{true, false, true, true, false}, s.HasWhich(
+		store.Range{Offset: 10, Length: 2},
+		store.Range{Offset: 3, Length: 2},
+		store.Range{Offset: 1, Length: 3},
+		store.Range{Offset: 7, Length: 0},
+		store.Range{Offset: -1, Length: 1},
+	))
+	assert.True(t, s.HasAll(store.Range{Offset: 0, Length: 4}, store.Range{Offset: 11, Length: 1}))
+	assert.False(t, s.HasAll(store.Range{Offset: 0, Length: 4}, store.Range{Offset: 4, Length: 1}))
+	assert.True(t, s.HasAll())
+	assert.Empty(t, s.HasWhich())
+}
+
+func TestHasWhichRandom(t *testing.T) {
+	for name, opts := range map[string][]store.Option[byte]{
+		"extents":  {store.WithMinContiguous[byte](1)},
+		"presence": {store.WithMinContiguous[byte](1), store.WithPresenceBitmap[byte]()},
+	} {
+		t.Run(name, func(t *testing.T) {
+			r := rand.New(rand.NewSource(1))
+			s := store.NewStore(opts...)
+			for i := 0; i < 200; i++ {
+				require.NoError(t, s.Set(make([]byte, 1+r.Intn(16)), r.Int63n(4096)))
+			}
+
+			ranges := make([]store.Range, 500)
+			expected := make([]bool, len(ranges))
+			for i := range ranges {
+				ranges[i] = store.Range{Offset: r.Int63n(4200) - 50, Length: r.Int63n(32)}
+				expected[i] = s.Has(ranges[i].Length, ranges[i].Offset)
+			}
+			assert.Equal(t, expected, s.HasWhich(ranges...))
+			assert.Contains(t, expected, true)
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package store
+
+import "fmt"
+
+// WithHeatmap makes the store count reads per block of `blockSize` values, as
+// returned by Heatmap. Reads past the length of the store are not counted. It
+// panics if `blockSize` is not positive.
+func WithHeatmap[T any](blockSize int64) Option[T] {
+	if blockSize <= 0 {
+		panic(fmt.Sprintf("store: WithHeatmap: invalid block size %d", blockSize))
+	}
+	return func(c *Store[T]) {
+		c.heatmap = &heatmap{blockSize: blockSize}
+	}
+}
+
+// heatmap counts accesses per block.
+type heatmap struct {
+	blockSize int64
+	counts    []int64
+}
+
+// add counts an access to the values in [from, to).
+func (h *heatmap) add(from, to int64) {
+	from = max(from, 0)
+	if from >= to {
+		return
+	}
+
+	first, last := from/h.blockSize, (to-1)/h.blockSize
+	if n := last + 1; int64(len(h.counts)) < n {
+		h.counts = append(h.counts, make([]int64, n-int64(len(h.counts)))...)
+	}
+	for i := first; i <= last; i++ {
+		h.counts[i]++
+	}
+}
+
+// Heatmap returns the number of times Get or Has read values in each block
+// configured with WithHeatmap, covering at least [0, Length()). It returns nil
+// if the store has no heatmap.
+func (c *Store[T]) Heatmap() []int64 {
+	if c.heatmap == nil {
+		return nil
+	}
+
+	blocks := (c.length + c.heatmap.blockSize - 1) / c.heatmap.blockSize
+	counts := make([]int64, max(blocks, int64(len(c.heatmap.counts))))
+	copy(counts, c.heatmap.counts)
+	return counts
+}
+
+// ResetHeatmap sets all counts of the heatmap to zero.
+func (c *Store[T]) ResetHeatmap() {
+	if c.heatmap != nil {
+		clear(c.heatmap.counts)
+	}
+}
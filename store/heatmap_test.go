@@ -0,0 +1,28 @@
+package store_test
+
+import (
+	"testing"
+
+	"github.com/aertje/sparse-store/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStoreHeatmap(t *testing.T) {
+	s := store.NewStore(store.WithHeatmap[byte](4))
+	s.Set(make([]byte, 20), 0)
+
+	s.Get(make([]byte, 2), 0)
+	s.Get(make([]byte, 4), 2)
+	s.Has(1, 30)
+	s.Has(0, 8)
+	s.Has(2, 19)
+	// Reads past the length of the store are not counted.
+	s.Get(make([]byte, 1), 1<<50)
+
+	assert.Equal(t, []int64{2, 1, 0, 0, 1}, s.Heatmap())
+
+	s.ResetHeatmap()
+	assert.Equal(t, []int64{0, 0, 0, 0, 0}, s.Heatmap())
+	assert.Nil(t, store.NewStore[byte]().Heatmap())
+	assert.Panics(t, func() { store.WithHeatmap[byte](0) })
+}
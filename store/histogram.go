@@ -0,0 +1,53 @@
+package store
+
+import (
+	"fmt"
+	"math/bits"
+	"strings"
+)
+
+// Histogram counts lengths in power-of-two buckets: bucket `i` holds the
+// number of lengths in [2^i, 2^(i+1)).
+type Histogram []int64
+
+// add counts `length`, which must be positive.
+func (h *Histogram) add(length int64) {
+	i := bits.Len64(uint64(length)) - 1
+	if len(*h) <= i {
+		*h = append(*h, make(Histogram, i+1-len(*h))...)
+	}
+	(*h)[i]++
+}
+
+// String returns the non-empty buckets, one per line.
+func (h Histogram) String() string {
+	var b strings.Builder
+	for i, n := range h {
+		if n > 0 {
+			fmt.Fprintf(&b, "[%d, %d): %d\n", int64(1)<<i, int64(1)<<(i+1), n)
+		}
+	}
+	return b.String()
+}
+
+// Histograms returns histograms of the lengths of the extents the store holds,
+// and of the gaps between them in [0, Length()). Adjacent extents are counted
+// separately.
+func (c *Store[T]) Histograms() (extents, gaps Histogram) {
+	c.Compact()
+	c.expire()
+
+	var end int64
+	for _, e := range c.entries {
+		if e.offset > end {
+			gaps.add(e.offset - end)
+		}
+		extents.add(e.size())
+		end = e.end()
+	}
+	if c.length > end {
+		gaps.add(c.length - end)
+	}
+
+	return extents, gaps
+}
@@ -0,0 +1,22 @@
+package store_test
+
+import (
+	"testing"
+
+	"github.com/aertje/sparse-store/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStoreHistograms(t *testing.T) {
+	s := store.NewStore(store.WithMinContiguous[byte](1))
+	s.Set(make([]byte, 1), 2)
+	s.Set(make([]byte, 3), 3)
+	s.Set(make([]byte, 10), 20)
+	s.Set(make([]byte, 1), 40)
+	s.Delete(1, 40)
+
+	extents, gaps := s.Histograms()
+	assert.Equal(t, store.Histogram{1, 1, 0, 1}, extents)
+	assert.Equal(t, store.Histogram{0, 1, 0, 2}, gaps)
+	assert.Equal(t, "[1, 2): 1\n[2, 4): 1\n[8, 16): 1\n", extents.String())
+}
@@ -0,0 +1,47 @@
+package store
+
+// Hooks holds functions that are called with the offset and length of the
+// values affected by mutations of the store. Any of them may be nil. They are
+// called while the store is being modified, so they must not call back into it.
+type Hooks struct {
+	// OnSet is called for every write.
+	OnSet func(offset, length int64)
+	// OnMerge is called whenever extents are merged, with the resulting
+	// extent.
+	OnMerge func(offset, length int64)
+	// OnEvict is called for every extent that is evicted or expires.
+	OnEvict func(offset, length int64)
+	// OnDelete is called for every part of an extent removed by Delete.
+	OnDelete func(offset, length int64)
+}
+
+// WithHooks makes the store call `hooks` on mutations.
+func WithHooks[T any](hooks Hooks) Option[T] {
+	return func(c *Store[T]) {
+		c.hooks = hooks
+	}
+}
+
+func (h *Hooks) set(offset, length int64) {
+	if h.OnSet != nil {
+		h.OnSet(offset, length)
+	}
+}
+
+func (h *Hooks) merge(offset, length int64) {
+	if h.OnMerge != nil {
+		h.OnMerge(offset, length)
+	}
+}
+
+func (h *Hooks) evict(offset, length int64) {
+	if h.OnEvict != nil {
+		h.OnEvict(offset, length)
+	}
+}
+
+func (h *Hooks) delete(offset, length int64) {
+	if h.OnDelete != nil {
+		h.OnDelete(offset, length)
+	}
+}
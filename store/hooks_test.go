@@ -0,0 +1,70 @@
+package store_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/aertje/sparse-store/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStoreHooks(t *testing.T) {
+	var events []string
+	record := func(event string) func(offset, length int64) {
+		return func(offset, length int64) {
+			events = append(events, fmt.Sprintf("%s %d+%d", event, offset, length))
+		}
+	}
+
+	s := store.NewStore(
+		store.WithMinContiguous[byte](4),
+		store.WithMaxOccupancy[byte](6, store.EvictLowestOffset),
+		store.WithHooks[byte](store.Hooks{
+			OnSet:    record("set"),
+			OnMerge:  record("merge"),
+			OnEvict:  record("evict"),
+			OnDelete: record("delete"),
+		}),
+	)
+
+	s.Set([]byte{0, 1}, 0)
+	s.Set([]byte{2, 3}, 2)
+	s.Set([]byte{4, 5, 6}, 10)
+	s.Set([]byte{7, 8}, 20)
+	assert.Equal(t, int64(3), s.Delete(4, 9))
+
+	assert.Equal(t, []string{
+		"set 0+2",
+		"merge 0+4",
+		"set 2+2",
+		"evict 0+4",
+		"set 10+3",
+		"set 20+2",
+		"delete 10+3",
+	}, events)
+}
+
+func TestStoreDelete(t *testing.T) {
+	s := store.NewStore(store.WithPresenceBitmap[byte]())
+	s.Set([]byte{0, 1, 2, 3, 4, 5}, 0)
+
+	assert.Equal(t, int64(2), s.Delete(2, 2))
+	assert.Equal(t, int64(0), s.Delete(2, 2))
+	assert.Equal(t, int64(4), s.Occupancy())
+	assert.Equal(t, int64(6), s.Length())
+	assert.False(t, s.Has(1, 2))
+
+	data := make([]byte, 6)
+	assert.False(t, s.Get(data, 0))
+	assert.Equal(t, []byte{0, 1, 0, 0, 4, 5}, data)
+}
+
+func TestStoreDeleteNegativeLength(t *testing.T) {
+	s := store.NewStore[byte]()
+	s.Set(make([]byte, 20), 0)
+
+	assert.Equal(t, int64(0), s.Delete(-5, 10))
+	assert.Equal(t, int64(20), s.Occupancy())
+	assert.Equal(t, int64(20), s.Length())
+	assert.True(t, s.Has(20, 0))
+}
@@ -0,0 +1,106 @@
+package store
+
+import (
+	"bytes"
+	"errors"
+	"io"
+)
+
+// importChunk is the number of bytes ImportSparse reads at a time.
+const importChunk = 1 << 20
+
+// ImportSparse returns a byte store, configured with `opts`, holding the bytes
+// read from `r` until EOF, except for runs of at least `minHole` zero bytes,
+// which are left out as holes. Shorter runs are held as they are. The length
+// of the store is the number of bytes read, including a trailing hole. This
+// restores the sparseness of inputs that arrive dense, such as disk dumps.
+func ImportSparse(r io.Reader, minHole int64, opts ...Option[byte]) (*Store[byte], error) {
+	minHole = max(minHole, 1)
+	s := NewStore(opts...)
+	buf := make([]byte, importChunk)
+
+	// carried is the length of the run of zeros at the end of the chunks read
+	// so far, which may continue in the next one.
+	var offset, carried int64
+	// flushCarried sets the carried zeros unless they are a hole.
+	flushCarried := func() error {
+		n := carried
+		carried = 0
+		if n == 0 || n >= minHole {
+			return nil
+		}
+		return s.SetOwned(make([]byte, n), offset-n)
+	}
+	// set sets the values of `p` at `at` in the chunk.
+	set := func(p []byte, at int) error {
+		if len(p) == 0 {
+			return nil
+		}
+		return s.SetOwned(bytes.Clone(p), offset+int64(at))
+	}
+
+	for {
+		n, err := io.ReadFull(r, buf)
+		p := buf[:n]
+		if n > 0 && p[0] != 0 {
+			if err := flushCarried(); err != nil {
+				return nil, err
+			}
+		}
+
+		// from is the start of the values of the chunk not set yet.
+		from := 0
+		for i := 0; i < len(p); {
+			if p[i] != 0 {
+				i++
+				continue
+			}
+			j := i + 1
+			for j < len(p) && p[j] == 0 {
+				j++
+			}
+
+			switch {
+			case j == len(p):
+				// The run may continue in the next chunk.
+				if err := set(p[from:i], from); err != nil {
+					return nil, err
+				}
+				carried += int64(j - i)
+				from = j
+			case int64(j-i)+carried >= minHole:
+				if err := set(p[from:i], from); err != nil {
+					return nil, err
+				}
+				carried = 0
+				from = j
+			case carried > 0:
+				// The zeros of this chunk are set along with the data
+				// following them, and those carried over on their own.
+				if err := flushCarried(); err != nil {
+					return nil, err
+				}
+			}
+			i = j
+		}
+		if err := set(p[from:], from); err != nil {
+			return nil, err
+		}
+		offset += int64(n)
+
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := flushCarried(); err != nil {
+		return nil, err
+	}
+	if err := s.Truncate(offset); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
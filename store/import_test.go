@@ -0,0 +1,51 @@
+package store_test
+
+import (
+	"bytes"
+	"testing"
+	"testing/iotest"
+
+	"github.com/aertje/sparse-store/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImportSparse(t *testing.T) {
+	data := []byte("ab\x00\x00cd\x00\x00\x00\x00\x00ef\x00\x00\x00\x00")
+	s, err := store.ImportSparse(bytes.NewReader(data), 4)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(data)), s.Length())
+	assert.Equal(t, []store.Range{{Offset: 0, Length: 6}, {Offset: 11, Length: 2}}, s.Extents())
+
+	got := make([]byte, 6)
+	require.True(t, s.Get(got, 0))
+	assert.Equal(t, data[:6], got)
+
+	s, err = store.ImportSparse(iotest.OneByteReader(bytes.NewReader(data)), 1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(6), s.Occupancy())
+}
+
+func TestImportSparseAcrossChunks(t *testing.T) {
+	// Runs of zeros spanning the 1 MiB chunks the input is read in: a hole,
+	// and a run too short to be one.
+	const mib = 1 << 20
+	data := make([]byte, 3*mib)
+	data[0] = 1
+	data[mib-10] = 2
+	data[mib+4] = 3
+	data[2*mib+100] = 4
+
+	s, err := store.ImportSparse(bytes.NewReader(data), 64)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3*mib), s.Length())
+	assert.Equal(t, []store.Range{
+		{Offset: 0, Length: 1},
+		{Offset: mib - 10, Length: 15},
+		{Offset: 2*mib + 100, Length: 1},
+	}, s.Extents())
+
+	got := make([]byte, 15)
+	require.True(t, s.Get(got, mib-10))
+	assert.Equal(t, data[mib-10:mib+5], got)
+}
@@ -0,0 +1,24 @@
+package store
+
+// Instrumentation observes the operations of a store, for example to trace or
+// measure them. The otelstore package implements it with OpenTelemetry.
+type Instrumentation interface {
+	// Compact is called when a compaction of `extents` extents starts, and
+	// returns a function that is called with the number of extents left when
+	// it ends. Only lazy compaction compacts the whole store; eager writes
+	// are merged in place.
+	Compact(extents int) (end func(extents int))
+	// Set is called for every write of `length` values.
+	Set(length int64)
+	// Get is called for every read of `length` values, with whether all of
+	// them were present.
+	Get(length int64, hit bool)
+}
+
+// WithInstrumentation makes the store report its operations to
+// `instrumentation`. Without it, the store does not pay for any.
+func WithInstrumentation[T any](instrumentation Instrumentation) Option[T] {
+	return func(c *Store[T]) {
+		c.instrumentation = instrumentation
+	}
+}
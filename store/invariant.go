@@ -0,0 +1,74 @@
+package store
+
+import (
+	"fmt"
+	"strings"
+)
+
+// WithInvariantChecks makes the store validate its extents after every
+// mutation, and panic with a dump of them if they are inconsistent, to catch
+// bugs in compaction early. It is meant for development and tests, as every
+// check takes time linear in the number of extents.
+func WithInvariantChecks[T any]() Option[T] {
+	return func(c *Store[T]) {
+		c.invariantChecks = true
+	}
+}
+
+// checkInvariants panics if the extents are not sorted and disjoint, or do not
+// add up to the occupancy, length or pending volume recorded.
+func (c *Store[T]) checkInvariants() {
+	if !c.invariantChecks {
+		return
+	}
+	if violation := c.violation(); violation != "" {
+		panic(fmt.Sprintf("store: invariant violated: %s\n%s", violation, c.dump()))
+	}
+}
+
+// violation returns a description of the first invariant violated, if any.
+func (c *Store[T]) violation() string {
+	var occupancy int64
+	for i, e := range c.entries {
+		switch {
+		case e.size() <= 0:
+			return fmt.Sprintf("extent %d is empty", i)
+		case e.run && e.data != nil:
+			return fmt.Sprintf("run %d holds data", i)
+		case i > 0 && c.entries[i-1].end() > e.offset:
+			return fmt.Sprintf("extent %d overlaps or precedes extent %d", i-1, i)
+		case e.offset < c.start || e.end() > c.length:
+			return fmt.Sprintf("extent %d is outside [%d, %d)", i, c.start, c.length)
+		case c.presence != nil && e.offset >= 0 && !c.presence.Contains(e.offset, e.end()):
+			return fmt.Sprintf("extent %d is missing from the presence index", i)
+		}
+		occupancy += e.size()
+	}
+	if occupancy != c.occupancy {
+		return fmt.Sprintf("extents hold %d values, occupancy is %d", occupancy, c.occupancy)
+	}
+
+	var volume int64
+	for _, e := range c.pending {
+		volume += e.size()
+	}
+	if volume != c.pendingVolume {
+		return fmt.Sprintf("pending extents hold %d values, pending volume is %d", volume, c.pendingVolume)
+	}
+	return ""
+}
+
+// dump returns a listing of the extents and pending extents, one per line.
+func (c *Store[T]) dump() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "start %d, length %d, occupancy %d\n", c.start, c.length, c.occupancy)
+	for _, list := range []struct {
+		name    string
+		entries entries[T]
+	}{{"extent", c.entries}, {"pending", c.pending}} {
+		for i, e := range list.entries {
+			fmt.Fprintf(&b, "%s %d: [%d, %d) order %d priority %d run %t\n", list.name, i, e.offset, e.end(), e.order, e.priority, e.run)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,52 @@
+package store_test
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/aertje/sparse-store/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStoreInvariantChecks(t *testing.T) {
+	for name, opts := range map[string][]store.Option[byte]{
+		"eager":    {store.WithMinContiguous[byte](8)},
+		"lazy":     {store.WithMinContiguous[byte](8), store.WithLazyCompaction[byte](4, 64)},
+		"bounded":  {store.WithMaxContiguous[byte](16), store.WithMaxOccupancy[byte](100, store.EvictLRU)},
+		"zeroRuns": {store.WithZeroRuns[byte](4), store.WithPresenceBitmap[byte]()},
+		"dedup":    {store.WithDedup[byte](4), store.WithSignedOffsets[byte]()},
+	} {
+		t.Run(name, func(t *testing.T) {
+			s := store.NewStore(append(opts, store.WithInvariantChecks[byte]())...)
+			r := rand.New(rand.NewSource(1))
+			assert.NotPanics(t, func() {
+				for i := 0; i < 2000; i++ {
+					offset := r.Int63n(300) - 20
+					switch r.Intn(10) {
+					case 0:
+						s.Get(make([]byte, r.Intn(20)), offset)
+					case 1:
+						s.Delete(r.Int63n(40), offset)
+					case 2:
+						s.Fill(0, r.Int63n(20), offset)
+					case 3:
+						s.SetWithTTL([]byte{1, 2}, offset, time.Hour)
+					case 4:
+						s.SetWithPriority([]byte{3, 4, 5}, offset, r.Intn(3))
+					case 5:
+						s.Shrink()
+					case 6:
+						s.Transform(func(offset int64, data []byte) {})
+					default:
+						p := make([]byte, r.Intn(30))
+						for j := range p {
+							p[j] = byte(r.Intn(3))
+						}
+						s.Set(p, offset)
+					}
+				}
+			})
+		})
+	}
+}
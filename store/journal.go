@@ -0,0 +1,268 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Journaled is a byte store persisted to a data file, which holds the values
+// at their own offsets, and a journal of the ranges written to it. Every write
+// is recorded in the journal before the data is written, and committed after,
+// so that a crash in the middle of a write never leaves the ranges recorded
+// inconsistent with the data: on open, the ranges of writes that were not
+// committed are dropped, along with the values they may have overwritten.
+//
+// All values are held in memory as well, in a store that is read from
+// directly. Values it evicts or expires are only restored on the next open.
+type Journaled struct {
+	store   *Store[byte]
+	data    DataFile
+	journal *os.File
+	path    string
+}
+
+// DataFile is the data file of a Journaled store, such as an *os.File, or an
+// EncryptedFile to keep the values encrypted at rest.
+type DataFile interface {
+	File
+	// Sync makes the values written durable.
+	Sync() error
+	Close() error
+}
+
+// OpenJournaled opens the store persisted to the files at `dataPath` and
+// `journalPath`, creating them if needed, and recovers from any write that was
+// interrupted. The values are loaded into a store configured with `opts`.
+func OpenJournaled(dataPath, journalPath string, opts ...Option[byte]) (*Journaled, error) {
+	data, err := os.OpenFile(dataPath, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return OpenJournaledFile(data, journalPath, opts...)
+}
+
+// OpenJournaledFile is OpenJournaled for the data file `data`, which it closes
+// on failure, and on Close.
+func OpenJournaledFile(data DataFile, journalPath string, opts ...Option[byte]) (*Journaled, error) {
+	j := &Journaled{store: NewStore(opts...), data: data, path: journalPath}
+	present, err := recoverJournal(journalPath)
+	if err == nil {
+		err = j.load(present)
+	}
+	if err == nil {
+		// Start over with a journal holding only what was recovered.
+		err = j.checkpoint(present)
+	}
+	if err != nil {
+		data.Close()
+		return nil, err
+	}
+	return j, nil
+}
+
+// recoverJournal returns the ranges recorded by the committed writes in the
+// journal at `path`.
+func recoverJournal(path string) (rangeSet, error) {
+	var present rangeSet
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return present, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	// pending is the write waiting for its commit record.
+	var pending *Range
+	br := bufio.NewReader(f)
+	for line := 1; ; line++ {
+		text, err := br.ReadString('\n')
+		if err == io.EOF {
+			// A record without its newline was torn by the crash.
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		op, r, err := parseJournalRecord(text)
+		if err != nil {
+			return nil, fmt.Errorf("journal line %d: %w", line, err)
+		}
+		if op == "commit" && pending != nil {
+			present.add(pending.Offset, pending.End())
+			pending = nil
+			continue
+		}
+		if pending != nil {
+			// The write was interrupted, so its values are unknown.
+			present.take(pending.Offset, pending.End())
+			pending = nil
+		}
+		switch op {
+		case "set":
+			pending = &r
+		case "delete":
+			present.take(r.Offset, r.End())
+		}
+	}
+	if pending != nil {
+		present.take(pending.Offset, pending.End())
+	}
+
+	return present, nil
+}
+
+// parseJournalRecord parses a record of the journal.
+func parseJournalRecord(text string) (string, Range, error) {
+	fields := strings.Fields(text)
+	if len(fields) == 1 && fields[0] == "commit" {
+		return "commit", Range{}, nil
+	}
+	if len(fields) != 3 || (fields[0] != "set" && fields[0] != "delete") {
+		return "", Range{}, fmt.Errorf("invalid record %q", strings.TrimSpace(text))
+	}
+
+	offset, err1 := strconv.ParseInt(fields[1], 10, 64)
+	length, err2 := strconv.ParseInt(fields[2], 10, 64)
+	if err1 != nil || err2 != nil {
+		return "", Range{}, fmt.Errorf("invalid record %q", strings.TrimSpace(text))
+	}
+	return fields[0], Range{Offset: offset, Length: length}, nil
+}
+
+// load reads the values in `present` from the data file into the store.
+func (j *Journaled) load(present rangeSet) error {
+	for _, r := range present {
+		p := make([]byte, r.Length)
+		if _, err := j.data.ReadAt(p, r.Offset); err != nil {
+			return fmt.Errorf("loading %d values at %d: %w", r.Length, r.Offset, err)
+		}
+		if err := j.store.SetOwned(p, r.Offset); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Store returns the store holding the values, to read from. It must not be
+// written to directly.
+func (j *Journaled) Store() *Store[byte] {
+	return j.store
+}
+
+// Set writes `p` at `offset`, durably: once Set returns, the values survive a
+// crash. The store holds a copy of `p`, so it may be reused. If Set fails, the
+// values at `offset` are dropped, as the data file may hold part of `p`, or the
+// store may not be able to hold them, such as with WithByteBudget.
+func (j *Journaled) Set(p []byte, offset int64) error {
+	if err := j.record("set %d %d\n", offset, len(p)); err != nil {
+		return err
+	}
+
+	_, err := j.data.WriteAt(p, offset)
+	if err == nil {
+		err = j.data.Sync()
+	}
+	if err == nil {
+		err = j.record("commit\n")
+	}
+	if err != nil {
+		j.store.Delete(int64(len(p)), offset)
+		return err
+	}
+
+	// The write is committed already, so it is deleted durably if the store
+	// fails to hold it, rather than reappearing on the next open.
+	if err := j.store.SetOwned(bytes.Clone(p), offset); err != nil {
+		j.store.Delete(int64(len(p)), offset)
+		return errors.Join(err, j.record("delete %d %d\n", offset, len(p)))
+	}
+	return nil
+}
+
+// Delete removes the `length` values at `offset`, and returns the number of
+// values removed. The space in the data file is not reclaimed.
+func (j *Journaled) Delete(length, offset int64) (int64, error) {
+	if err := j.record("delete %d %d\n", offset, length); err != nil {
+		return 0, err
+	}
+	return j.store.Delete(length, offset), nil
+}
+
+// record appends a record to the journal, and syncs it.
+func (j *Journaled) record(format string, args ...any) error {
+	if _, err := fmt.Fprintf(j.journal, format, args...); err != nil {
+		return err
+	}
+	return j.journal.Sync()
+}
+
+// Checkpoint replaces the journal with one recording only the ranges the data
+// file holds, so that it does not grow without bounds.
+func (j *Journaled) Checkpoint() error {
+	present, err := recoverJournal(j.path)
+	if err != nil {
+		return err
+	}
+	return j.checkpoint(present)
+}
+
+// checkpoint atomically replaces the journal with one recording `present`,
+// and opens it for appending.
+func (j *Journaled) checkpoint(present rangeSet) error {
+	tmp, err := os.CreateTemp(filepath.Dir(j.path), filepath.Base(j.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	for _, r := range present {
+		fmt.Fprintf(w, "set %d %d\ncommit\n", r.Offset, r.Length)
+	}
+	err = w.Flush()
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), j.path)
+	}
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if j.journal != nil {
+		j.journal.Close()
+	}
+	j.journal = tmp
+	return syncDir(filepath.Dir(j.path))
+}
+
+// syncDir syncs the directory at `path`, so that a rename in it is durable.
+func syncDir(path string) error {
+	dir, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+	return dir.Sync()
+}
+
+// Close closes the data file and the journal.
+func (j *Journaled) Close() error {
+	err := j.data.Close()
+	if jerr := j.journal.Close(); err == nil {
+		err = jerr
+	}
+	return err
+}
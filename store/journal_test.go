@@ -0,0 +1,126 @@
+package store_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aertje/sparse-store/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJournaled(t *testing.T) {
+	dir := t.TempDir()
+	dataPath, journalPath := filepath.Join(dir, "data"), filepath.Join(dir, "journal")
+
+	j, err := store.OpenJournaled(dataPath, journalPath)
+	require.NoError(t, err)
+	require.NoError(t, j.Set([]byte("hello"), 0))
+	require.NoError(t, j.Set([]byte("world"), 10))
+	removed, err := j.Delete(2, 13)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), removed)
+	require.NoError(t, j.Close())
+
+	j, err = store.OpenJournaled(dataPath, journalPath)
+	require.NoError(t, err)
+	defer j.Close()
+	assert.Equal(t, []store.Range{{Offset: 0, Length: 5}, {Offset: 10, Length: 3}}, coalesced(j.Store().Extents()))
+	p := make([]byte, 3)
+	require.True(t, j.Store().Get(p, 10))
+	assert.Equal(t, "wor", string(p))
+
+	require.NoError(t, j.Checkpoint())
+	journal, err := os.ReadFile(journalPath)
+	require.NoError(t, err)
+	assert.Equal(t, "set 0 5\ncommit\nset 10 3\ncommit\n", string(journal))
+}
+
+func TestJournaledRecovery(t *testing.T) {
+	dir := t.TempDir()
+	dataPath, journalPath := filepath.Join(dir, "data"), filepath.Join(dir, "journal")
+	require.NoError(t, os.WriteFile(dataPath, []byte("abcdefghijXXXXXXXXXX"), 0o644))
+
+	// A write was interrupted after overwriting part of the first range, and
+	// the record of a second one was torn.
+	require.NoError(t, os.WriteFile(journalPath, []byte(
+		"set 0 10\ncommit\nset 8 4\ndelete 0 1\nset 15 5\nset 1",
+	), 0o644))
+
+	j, err := store.OpenJournaled(dataPath, journalPath)
+	require.NoError(t, err)
+	defer j.Close()
+	assert.Equal(t, []store.Range{{Offset: 1, Length: 7}}, coalesced(j.Store().Extents()))
+
+	journal, err := os.ReadFile(journalPath)
+	require.NoError(t, err)
+	assert.Equal(t, "set 1 7\ncommit\n", string(journal))
+
+	// Writes continue after the recovered journal.
+	require.NoError(t, j.Set([]byte("xy"), 8))
+	journal, err = os.ReadFile(journalPath)
+	require.NoError(t, err)
+	assert.Equal(t, "set 1 7\ncommit\nset 8 2\ncommit\n", string(journal))
+}
+
+func TestJournaledInvalid(t *testing.T) {
+	dir := t.TempDir()
+	journalPath := filepath.Join(dir, "journal")
+	require.NoError(t, os.WriteFile(journalPath, []byte("set 0 10\nbogus\n"), 0o644))
+
+	_, err := store.OpenJournaled(filepath.Join(dir, "data"), journalPath)
+	assert.ErrorContains(t, err, "journal line 2")
+}
+
+func TestJournaledSet(t *testing.T) {
+	dir := t.TempDir()
+	dataPath, journalPath := filepath.Join(dir, "data"), filepath.Join(dir, "journal")
+
+	j, err := store.OpenJournaled(dataPath, journalPath, store.WithByteBudget[byte](8))
+	require.NoError(t, err)
+
+	// The store holds a copy of the values.
+	p := []byte("hello")
+	require.NoError(t, j.Set(p, 0))
+	copy(p, "jello")
+	got := make([]byte, 5)
+	require.True(t, j.Store().Get(got, 0))
+	assert.Equal(t, "hello", string(got))
+
+	// Values the store cannot hold are dropped from the journal as well.
+	assert.ErrorIs(t, j.Set([]byte("too much"), 10), store.ErrFull)
+	assert.False(t, j.Store().Has(1, 10))
+	require.NoError(t, j.Close())
+
+	j, err = store.OpenJournaled(dataPath, journalPath, store.WithByteBudget[byte](8))
+	require.NoError(t, err)
+	defer j.Close()
+	assert.Equal(t, []store.Range{{Offset: 0, Length: 5}}, coalesced(j.Store().Extents()))
+}
+
+func TestJournaledEncrypted(t *testing.T) {
+	dir := t.TempDir()
+	journalPath := filepath.Join(dir, "journal")
+	f, file := newEncryptedFile(t)
+
+	j, err := store.OpenJournaledFile(f, journalPath)
+	require.NoError(t, err)
+	require.NoError(t, j.Set([]byte("secret"), 4))
+
+	raw, err := os.ReadFile(file.Name())
+	require.NoError(t, err)
+	assert.NotContains(t, string(raw), "secret")
+	require.NoError(t, j.Close())
+
+	file, err = os.OpenFile(file.Name(), os.O_RDWR, 0)
+	require.NoError(t, err)
+	reopened, err := store.NewEncryptedFile(file, newGCM(t), 16)
+	require.NoError(t, err)
+	j, err = store.OpenJournaledFile(reopened, journalPath)
+	require.NoError(t, err)
+	defer j.Close()
+	p := make([]byte, 6)
+	require.True(t, j.Store().Get(p, 4))
+	assert.Equal(t, "secret", string(p))
+}
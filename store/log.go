@@ -0,0 +1,27 @@
+package store
+
+import (
+	"context"
+	"log/slog"
+)
+
+// WithLogger makes the store log its compactions, merges, evictions and
+// memory pressure to `logger`, at debug level.
+func WithLogger[T any](logger *slog.Logger) Option[T] {
+	return func(c *Store[T]) {
+		c.logger = logger
+	}
+}
+
+// debug logs `msg` at debug level. Callers building expensive arguments should
+// check debugging first.
+func (c *Store[T]) debug(msg string, args ...any) {
+	if c.debugging() {
+		c.logger.Debug(msg, args...)
+	}
+}
+
+// debugging reports whether debug logging is enabled.
+func (c *Store[T]) debugging() bool {
+	return c.logger != nil && c.logger.Enabled(context.Background(), slog.LevelDebug)
+}
@@ -0,0 +1,48 @@
+package store_test
+
+import (
+	"bytes"
+	"log/slog"
+	"testing"
+
+	"github.com/aertje/sparse-store/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStoreWithLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+
+	s := store.NewStore(
+		store.WithLogger[byte](logger),
+		store.WithLazyCompaction[byte](100, 1<<10),
+		store.WithMaxOccupancy[byte](4, store.EvictLowestOffset),
+	)
+	s.Set([]byte{0, 1}, 0)
+	s.Set([]byte{2, 3}, 2)
+	s.Set([]byte{4}, 10)
+	s.Compact()
+
+	assert.Equal(t, `level=DEBUG msg=compacting extents=0 pending=3 pendingVolume=5
+level=DEBUG msg="merged extents" offset=0 length=4 extents=2 allocated=true
+level=DEBUG msg="evicted extent" offset=0 length=4 policy="lowest offset"
+level=DEBUG msg=compacted extents=1 occupancy=1
+`, buf.String())
+}
+
+func TestStoreWithLoggerDisabled(t *testing.T) {
+	var buf bytes.Buffer
+	s := store.NewStore(store.WithLogger[byte](slog.New(slog.NewTextHandler(&buf, nil))))
+	s.Set([]byte{0, 1}, 0)
+	s.Set([]byte{2, 3}, 2)
+
+	assert.Empty(t, buf.String())
+}
@@ -0,0 +1,154 @@
+package store
+
+import (
+	"cmp"
+	"slices"
+)
+
+// Manager owns stores keyed by name, such as the key of the object whose
+// ranges they cache, and caps their total occupancy. When the stores exceed
+// it, extents are evicted from the least recently used stores first, the
+// least recently used extents of each first.
+//
+// The cap is enforced after every write to one of the stores, whenever a
+// store is got from the manager, and by Enforce. Like Store, Manager is not
+// safe for concurrent use, and its stores must not be written to
+// concurrently either.
+type Manager[T any] struct {
+	opts         []Option[T]
+	maxOccupancy int64
+	stores       map[string]*managedStore[T]
+	// tick counts the uses of stores.
+	tick uint64
+}
+
+// managedStore is a store owned by a Manager.
+type managedStore[T any] struct {
+	store *Store[T]
+	used  uint64
+}
+
+// NewManager returns a manager capping the total occupancy of its stores at
+// `maxOccupancy`, or not at all if it is zero. Stores are created with `opts`.
+func NewManager[T any](maxOccupancy int64, opts ...Option[T]) *Manager[T] {
+	return &Manager[T]{opts: opts, maxOccupancy: maxOccupancy, stores: map[string]*managedStore[T]{}}
+}
+
+// GetOrCreate returns the store named `name`, creating it if there is none,
+// after enforcing the cap, which may evict from that store as well. Getting
+// the store and writing to it count as uses of it.
+func (m *Manager[T]) GetOrCreate(name string) *Store[T] {
+	ms, ok := m.stores[name]
+	if !ok {
+		ms = &managedStore[T]{}
+		ms.store = NewStore(append(slices.Clip(m.opts), withOnWrite[T](func() {
+			m.use(ms)
+			m.Enforce()
+		}))...)
+		m.stores[name] = ms
+	}
+	m.use(ms)
+
+	m.Enforce()
+	return ms.store
+}
+
+// withOnWrite makes the store call `fn` once every write is complete, so that
+// it may call back into the store.
+func withOnWrite[T any](fn func()) Option[T] {
+	return func(c *Store[T]) {
+		c.onWrite = fn
+	}
+}
+
+// use marks `ms` as the most recently used store.
+func (m *Manager[T]) use(ms *managedStore[T]) {
+	m.tick++
+	ms.used = m.tick
+}
+
+// Get returns the store named `name`, if there is one. Unlike GetOrCreate, it
+// neither counts as a use nor enforces the cap.
+func (m *Manager[T]) Get(name string) (*Store[T], bool) {
+	ms, ok := m.stores[name]
+	if !ok {
+		return nil, false
+	}
+	return ms.store, true
+}
+
+// Drop clears the store named `name` and removes it from the manager. It
+// returns false if there is no such store. The store must not be used
+// afterwards.
+func (m *Manager[T]) Drop(name string) bool {
+	ms, ok := m.stores[name]
+	if !ok {
+		return false
+	}
+	ms.store.Clear()
+	delete(m.stores, name)
+	return true
+}
+
+// Range calls `fn` for every store, in order of name, until it returns false.
+func (m *Manager[T]) Range(fn func(name string, s *Store[T]) bool) {
+	names := make([]string, 0, len(m.stores))
+	for name := range m.stores {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		if !fn(name, m.stores[name].store) {
+			return
+		}
+	}
+}
+
+// Len returns the number of stores.
+func (m *Manager[T]) Len() int {
+	return len(m.stores)
+}
+
+// Occupancy returns the total occupancy of the stores.
+func (m *Manager[T]) Occupancy() int64 {
+	var occupancy int64
+	for _, ms := range m.stores {
+		occupancy += ms.store.Occupancy()
+	}
+	return occupancy
+}
+
+// Enforce evicts extents until the total occupancy of the stores no longer
+// exceeds the cap, and returns the number of values evicted.
+func (m *Manager[T]) Enforce() int64 {
+	if m.maxOccupancy <= 0 {
+		return 0
+	}
+	occupancy := m.Occupancy()
+	if occupancy <= m.maxOccupancy {
+		return 0
+	}
+
+	lru := make([]*managedStore[T], 0, len(m.stores))
+	for _, ms := range m.stores {
+		lru = append(lru, ms)
+	}
+	slices.SortFunc(lru, func(a, b *managedStore[T]) int {
+		return cmp.Compare(a.used, b.used)
+	})
+
+	var evicted int64
+	for _, ms := range lru {
+		for occupancy > m.maxOccupancy && ms.store.Occupancy() > 0 {
+			n := ms.store.EvictLRU(1)
+			if n == 0 {
+				// The rest of the extents are pinned.
+				break
+			}
+			occupancy -= n
+			evicted += n
+		}
+	}
+	return evicted
+}
@@ -0,0 +1,59 @@
+package store_test
+
+import (
+	"testing"
+
+	"github.com/aertje/sparse-store/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManager(t *testing.T) {
+	m := store.NewManager[byte](10, store.WithMinContiguous[byte](1))
+
+	a := m.GetOrCreate("a")
+	a.Set([]byte{1, 2, 3}, 0)
+	a.Set([]byte{4, 5, 6}, 10)
+	b := m.GetOrCreate("b")
+	b.Set([]byte{1, 2, 3, 4}, 0)
+	assert.Same(t, a, m.GetOrCreate("a"))
+	assert.Equal(t, int64(10), m.Occupancy())
+
+	// Writing to c evicts from b, which was used least recently.
+	c := m.GetOrCreate("c")
+	c.Set([]byte{1, 2, 3, 4}, 0)
+	assert.Zero(t, m.Enforce())
+	assert.Zero(t, b.Occupancy())
+	assert.Equal(t, int64(6), a.Occupancy())
+
+	// Then from the least recently used extents of a.
+	a.Get(make([]byte, 3), 0)
+	c.Set([]byte{5}, 4)
+	assert.Equal(t, int64(8), m.Occupancy())
+	assert.True(t, a.Has(3, 0))
+	assert.False(t, a.Has(1, 10))
+
+	var names []string
+	m.Range(func(name string, s *store.Store[byte]) bool {
+		names = append(names, name)
+		return true
+	})
+	assert.Equal(t, []string{"a", "b", "c"}, names)
+
+	assert.True(t, m.Drop("b"))
+	assert.False(t, m.Drop("b"))
+	_, ok := m.Get("b")
+	assert.False(t, ok)
+	assert.Equal(t, 2, m.Len())
+}
+
+func TestManagerWrites(t *testing.T) {
+	m := store.NewManager[byte](4, store.WithMinContiguous[byte](1))
+
+	// The cap is enforced on writes, without getting the store again, and
+	// applies to the store written to as well.
+	s := m.GetOrCreate("a")
+	s.Set([]byte{1, 2, 3}, 0)
+	s.Set([]byte{4, 5, 6}, 10)
+	assert.Equal(t, int64(3), m.Occupancy())
+	assert.True(t, s.Has(3, 10))
+}
@@ -0,0 +1,163 @@
+package store
+
+import (
+	"cmp"
+	"errors"
+	"slices"
+	"unsafe"
+)
+
+// ErrFull is returned by writes that would exceed the byte budget.
+var ErrFull = errors.New("store is full")
+
+// MemoryUsage is a breakdown of the memory held by a store, in bytes.
+type MemoryUsage struct {
+	// Data is the size of the values held in extents.
+	Data int64
+	// Retained is the size of the arrays backing the extents, including their
+	// unused capacity and any arena blocks.
+	Retained int64
+	// Overhead is the size of the bookkeeping: the store itself, its index of
+	// extents and the presence index, if any.
+	Overhead int64
+}
+
+// Total returns the total number of bytes held.
+func (m MemoryUsage) Total() int64 {
+	return m.Retained + m.Overhead
+}
+
+// MemoryUsage returns the memory held by the store. Where data was given to Set,
+// only the part of its backing array from the start of the slice onwards is
+// visible to the store, and counted.
+func (c *Store[T]) MemoryUsage() MemoryUsage {
+	size := int64(unsafe.Sizeof(*new(T)))
+	entrySize := int64(unsafe.Sizeof(entry[T]{}))
+
+	usage := MemoryUsage{
+		Overhead: int64(unsafe.Sizeof(*c)) + int64(cap(c.entries)+cap(c.pending))*entrySize,
+	}
+	if sized, ok := c.presence.(interface{ SizeInBytes() int64 }); ok {
+		usage.Overhead += sized.SizeInBytes()
+	}
+
+	var blocks [][2]uintptr
+	if c.arena != nil {
+		for _, block := range c.arena.blocks {
+			start := uintptr(unsafe.Pointer(unsafe.SliceData(block)))
+			blocks = append(blocks, [2]uintptr{start, start + uintptr(cap(block))*uintptr(size)})
+			usage.Retained += int64(cap(block)) * size
+		}
+		slices.SortFunc(blocks, func(a, b [2]uintptr) int {
+			return cmp.Compare(a[0], b[0])
+		})
+	}
+
+	// Slices sharing a backing array share where their capacity ends, so count
+	// the largest capacity seen for each such end.
+	retained := make(map[uintptr]int64)
+	count := func(data []T) {
+		if cap(data) == 0 {
+			return
+		}
+		usage.Data += int64(len(data)) * size
+
+		start := uintptr(unsafe.Pointer(unsafe.SliceData(data)))
+		i, _ := slices.BinarySearchFunc(blocks, start, func(b [2]uintptr, p uintptr) int {
+			return cmp.Compare(b[0], p)
+		})
+		if i < len(blocks) && blocks[i][0] == start || i > 0 && start < blocks[i-1][1] {
+			return
+		}
+
+		end := start + uintptr(cap(data))*uintptr(size)
+		retained[end] = max(retained[end], int64(cap(data))*size)
+	}
+	for _, e := range c.entries {
+		count(e.data)
+	}
+	for _, e := range c.pending {
+		count(e.data)
+	}
+	for _, n := range retained {
+		usage.Retained += n
+	}
+
+	return usage
+}
+
+// WithSoftMemoryLimit makes the store call `onPressure` with its memory usage
+// after every write that leaves its total memory usage at or above `limit`
+// bytes. Writes never fail because of it: the callback is expected to relieve
+// the pressure, for instance by evicting data.
+func WithSoftMemoryLimit[T any](limit int64, onPressure func(s *Store[T], usage MemoryUsage)) Option[T] {
+	return func(c *Store[T]) {
+		c.softMemoryLimit = limit
+		c.onPressure = onPressure
+	}
+}
+
+// checkPressure calls the pressure callback if the write of `e` left the
+// memory usage at or above the soft limit.
+func (c *Store[T]) checkPressure(e entry[T]) {
+	if c.onPressure == nil || c.inPressure {
+		return
+	}
+
+	// Account for the most a write can grow the usage by: retaining or
+	// copying its data, which may be rounded up to the next power of two,
+	// merging it, and the entries for it.
+	size := int64(unsafe.Sizeof(*new(T)))
+	entrySize := int64(unsafe.Sizeof(entry[T]{}))
+	c.memoryBound += int64(max(cap(e.data), 2*len(e.data))+2*c.mergeLimit())*size + 2*entrySize
+	if c.memoryBound < c.softMemoryLimit {
+		return
+	}
+
+	usage := c.MemoryUsage()
+	if usage.Total() >= c.softMemoryLimit {
+		if c.debugging() {
+			c.debug("memory pressure", "data", usage.Data, "retained", usage.Retained, "overhead", usage.Overhead, "limit", c.softMemoryLimit)
+		}
+		c.inPressure = true
+		c.onPressure(c, usage)
+		c.inPressure = false
+		usage = c.MemoryUsage()
+	}
+	c.memoryBound = usage.Total()
+}
+
+// WithByteBudget makes writes fail with ErrFull if they would make the values
+// held by the store, its occupancy times the size of a value, exceed `budget`
+// bytes. Unlike the other limits, this is enforced before anything is
+// written.
+func WithByteBudget[T any](budget int64) Option[T] {
+	return func(c *Store[T]) {
+		c.byteBudget = budget
+	}
+}
+
+// checkBudget returns ErrFull if writing `e` would exceed the byte budget.
+func (c *Store[T]) checkBudget(e entry[T]) error {
+	if c.byteBudget <= 0 {
+		return nil
+	}
+
+	size := int64(unsafe.Sizeof(*new(T)))
+	// Assuming nothing is overwritten gives an upper bound that is cheap to
+	// compute, even with pending entries. Only if that is over budget does the
+	// actual growth need to be determined.
+	occupancy := c.occupancy + c.pendingVolume + e.size()
+	if occupancy*size <= c.byteBudget {
+		return nil
+	}
+
+	occupancy = c.Occupancy() + e.size() - c.Coverage(e.size(), e.offset)
+	if occupancy*size > c.byteBudget {
+		if c.debugging() {
+			c.debug("rejected write over budget", "offset", e.offset, "length", e.size(), "budget", c.byteBudget)
+		}
+		return ErrFull
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package store_test
+
+import (
+	"testing"
+
+	"github.com/aertje/sparse-store/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStoreMemoryUsage(t *testing.T) {
+	s := store.NewStore(store.WithMinContiguous[int64](1))
+
+	data := make([]int64, 100)
+	s.Set(data, 0)
+	s.Set(make([]int64, 10), 1000)
+	// Overwriting the middle of the first extent splits it, but both parts
+	// still share its backing array.
+	s.Set(make([]int64, 10), 40)
+
+	usage := s.MemoryUsage()
+	assert.Equal(t, int64(110*8), usage.Data)
+	assert.Equal(t, int64((100+10+10)*8), usage.Retained)
+	assert.Positive(t, usage.Overhead)
+	assert.Equal(t, usage.Retained+usage.Overhead, usage.Total())
+}
+
+func TestStoreMemoryUsageArena(t *testing.T) {
+	s := store.NewStore(store.WithArena[byte](1 << 10))
+
+	for i := int64(0); i < 10; i++ {
+		s.Set(make([]byte, 100), i*200)
+	}
+
+	usage := s.MemoryUsage()
+	assert.Equal(t, int64(1000), usage.Data)
+	assert.Equal(t, int64(1<<10), usage.Retained)
+}
+
+func TestStoreSoftMemoryLimit(t *testing.T) {
+	var pressured []int64
+	s := store.NewStore(
+		store.WithMinContiguous[byte](1),
+		store.WithSoftMemoryLimit(1<<12, func(s *store.Store[byte], usage store.MemoryUsage) {
+			pressured = append(pressured, usage.Total())
+			s.EvictLRU(1)
+		}),
+	)
+
+	for i := int64(0); i < 64; i++ {
+		s.Set(make([]byte, 1<<8), i<<8)
+	}
+
+	assert.NotEmpty(t, pressured)
+	for _, total := range pressured {
+		assert.GreaterOrEqual(t, total, int64(1<<12))
+	}
+	assert.Equal(t, int64(64-len(pressured))<<8, s.Occupancy())
+	assert.Equal(t, int64(64<<8), s.Length())
+}
+
+func TestStoreByteBudget(t *testing.T) {
+	s := store.NewStore(store.WithByteBudget[int32](40))
+
+	assert.NoError(t, s.Set(make([]int32, 8), 0))
+	assert.ErrorIs(t, s.Set(make([]int32, 3), 8), store.ErrFull)
+	assert.ErrorIs(t, s.Fill(1, 3, 100), store.ErrFull)
+
+	// Overwriting data only counts what is added.
+	assert.NoError(t, s.Set(make([]int32, 4), 6))
+	assert.Equal(t, int64(10), s.Occupancy())
+	assert.Equal(t, int64(10), s.Length())
+	assert.ErrorIs(t, s.Set([]int32{1}, 10), store.ErrFull)
+	assert.Equal(t, int64(10), s.Length())
+}
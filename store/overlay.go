@@ -0,0 +1,128 @@
+package store
+
+import (
+	"errors"
+	"io"
+)
+
+// flattenChunk is the number of values Flatten reads at a time.
+const flattenChunk = 1 << 16
+
+// Base is the read-only layer of an Overlay. Tiers such as StoreTier and
+// FileTier can be used as a base.
+type Base[T any] interface {
+	// Read populates `p` with the values at `offset` the base holds, and
+	// returns the ranges of those it does not hold, in order.
+	Read(p []T, offset int64) (missing []Range, err error)
+}
+
+// ReaderAtBase is a base holding the first `Size` values of a ReaderAt, such as
+// a disk image.
+type ReaderAtBase struct {
+	ReaderAt io.ReaderAt
+	Size     int64
+}
+
+// Read reads the values below Size from the ReaderAt.
+func (b ReaderAtBase) Read(p []byte, offset int64) ([]Range, error) {
+	end := endOf(int64(len(p)), offset)
+	from, to := max(offset, 0), min(end, b.Size)
+	if from >= to {
+		return []Range{{Offset: offset, Length: int64(len(p))}}, nil
+	}
+
+	n, err := b.ReaderAt.ReadAt(p[from-offset:to-offset], from)
+	if err != nil && !(errors.Is(err, io.EOF) && int64(n) == to-from) {
+		return nil, err
+	}
+
+	var missing []Range
+	if from > offset {
+		missing = append(missing, Range{Offset: offset, Length: from - offset})
+	}
+	if to < end {
+		missing = append(missing, Range{Offset: to, Length: end - to})
+	}
+	return missing, nil
+}
+
+// Overlay combines a read-only base with a writable delta store, the way disk
+// image snapshots do: writes go to the delta, and reads prefer the values of
+// the delta over those of the base.
+type Overlay[T any] struct {
+	base  Base[T]
+	delta *Store[T]
+}
+
+// NewOverlay returns an overlay writing to `delta` over `base`.
+func NewOverlay[T any](base Base[T], delta *Store[T]) *Overlay[T] {
+	return &Overlay[T]{base: base, delta: delta}
+}
+
+// Delta returns the delta store.
+func (o *Overlay[T]) Delta() *Store[T] {
+	return o.delta
+}
+
+// Set sets `p` at `offset` in the delta.
+func (o *Overlay[T]) Set(p []T, offset int64) error {
+	return o.delta.Set(p, offset)
+}
+
+// Get populates `p` with the values at `offset`, from the delta where it holds
+// them and from the base elsewhere. It returns false if neither holds all of
+// them.
+func (o *Overlay[T]) Get(p []T, offset int64) (bool, error) {
+	if o.delta.Get(p, offset) {
+		return true, nil
+	}
+
+	complete := true
+	for _, gap := range o.delta.Gaps(int64(len(p)), offset) {
+		missing, err := o.base.Read(p[gap.Offset-offset:gap.End()-offset], gap.Offset)
+		if err != nil {
+			return false, err
+		}
+		if len(missing) > 0 {
+			complete = false
+		}
+	}
+	return complete, nil
+}
+
+// Flatten returns a store, configured with `opts`, holding the first `length`
+// values of the overlay that either the delta or the base holds. The overlay is
+// left unchanged.
+func (o *Overlay[T]) Flatten(length int64, opts ...Option[T]) (*Store[T], error) {
+	flat := NewStore(opts...)
+	extents := present(o.delta)
+	for offset := int64(0); offset < length; offset += flattenChunk {
+		chunk := make([]T, min(flattenChunk, length-offset))
+		o.delta.Get(chunk, offset)
+
+		// Hold the values from the delta, and those the base holds in its
+		// gaps.
+		var held rangeSet
+		for _, r := range extents {
+			held.add(max(r.Offset, offset), min(r.End(), offset+int64(len(chunk))))
+		}
+		for _, gap := range o.delta.Gaps(int64(len(chunk)), offset) {
+			missing, err := o.base.Read(chunk[gap.Offset-offset:gap.End()-offset], gap.Offset)
+			if err != nil {
+				return nil, err
+			}
+			pos := gap.Offset
+			for _, m := range append(missing, Range{Offset: gap.End()}) {
+				held.add(pos, m.Offset)
+				pos = m.End()
+			}
+		}
+
+		for _, r := range held {
+			if err := flat.Set(chunk[r.Offset-offset:r.End()-offset], r.Offset); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return flat, nil
+}
@@ -0,0 +1,72 @@
+package store_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/aertje/sparse-store/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOverlay(t *testing.T) {
+	base := store.ReaderAtBase{ReaderAt: strings.NewReader("hello, world"), Size: 12}
+	o := store.NewOverlay[byte](base, store.NewStore[byte]())
+
+	require.NoError(t, o.Set([]byte("HELLO"), 0))
+	require.NoError(t, o.Set([]byte("!!"), 12))
+
+	p := make([]byte, 14)
+	ok, err := o.Get(p, 0)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "HELLO, world!!", string(p))
+
+	// Values beyond the base and the delta are missing.
+	ok, err = o.Get(make([]byte, 4), 12)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	// The base is left unchanged.
+	assert.Equal(t, int64(7), o.Delta().Occupancy())
+}
+
+func TestOverlayFlatten(t *testing.T) {
+	base := store.NewStore[byte]()
+	base.Set([]byte("abcdef"), 0)
+	base.Set([]byte("uvwxyz"), 20)
+	o := store.NewOverlay[byte](store.StoreTier[byte]{Store: base}, store.NewStore[byte]())
+	require.NoError(t, o.Set([]byte("XYZ"), 4))
+	require.NoError(t, o.Set([]byte("123"), 10))
+
+	flat, err := o.Flatten(26)
+	require.NoError(t, err)
+	assert.Equal(t, []store.Range{{Offset: 0, Length: 7}, {Offset: 10, Length: 3}, {Offset: 20, Length: 6}}, coalesced(flat.Extents()))
+
+	p := make([]byte, 7)
+	require.True(t, flat.Get(p, 0))
+	assert.Equal(t, "abcdXYZ", string(p))
+	p = make([]byte, 6)
+	require.True(t, flat.Get(p, 20))
+	assert.Equal(t, "uvwxyz", string(p))
+
+	// Flattening only covers the given length.
+	flat, err = o.Flatten(22)
+	require.NoError(t, err)
+	assert.Equal(t, int64(22), flat.Length())
+	assert.False(t, flat.Has(1, 22))
+}
+
+func TestReaderAtBase(t *testing.T) {
+	base := store.ReaderAtBase{ReaderAt: bytes.NewReader([]byte("abc")), Size: 3}
+	p := make([]byte, 6)
+	missing, err := base.Read(p, -2)
+	require.NoError(t, err)
+	assert.Equal(t, []store.Range{{Offset: -2, Length: 2}, {Offset: 3, Length: 1}}, missing)
+	assert.Equal(t, "abc", string(p[2:5]))
+
+	missing, err = base.Read(p, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []store.Range{{Offset: 10, Length: 6}}, missing)
+}
@@ -0,0 +1,45 @@
+package store
+
+import (
+	"runtime"
+	"sync"
+)
+
+// WithParallelGet makes Get calls for at least `minLength` values copy them
+// with up to GOMAXPROCS goroutines, each copying an equal part of the range.
+// This speeds up assembling large images, where copying is the bottleneck.
+func WithParallelGet[T any](minLength int) Option[T] {
+	return func(c *Store[T]) {
+		c.parallelGet = minLength
+	}
+}
+
+// readParallel copies the values of `es`, which must be sorted and must not
+// overlap, into `p`, which holds the values from `offset` onwards, splitting
+// `p` into parts copied concurrently.
+func readParallel[T any](es entries[T], p []T, offset int64) {
+	n := runtime.GOMAXPROCS(0)
+	if n == 1 {
+		for _, e := range es {
+			e.read(p, offset)
+		}
+		return
+	}
+	size := (len(p) + n - 1) / n
+
+	var wg sync.WaitGroup
+	for from := 0; from < len(p); from += size {
+		part, at := p[from:min(from+size, len(p))], offset+int64(from)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for _, e := range es[es.first(at):] {
+				if e.offset >= at+int64(len(part)) {
+					break
+				}
+				e.read(part, at)
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,60 @@
+package store_test
+
+import (
+	"math/rand"
+	"runtime"
+	"testing"
+
+	"github.com/aertje/sparse-store/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParallelGet(t *testing.T) {
+	// Several goroutines even on a single CPU.
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4))
+	r := rand.New(rand.NewSource(1))
+	parallel := store.NewStore(store.WithParallelGet[int](1), store.WithMinContiguous[int](1))
+	sequential := store.NewStore(store.WithMinContiguous[int](1))
+	for i := 0; i < 300; i++ {
+		p := make([]int, 1+r.Intn(64))
+		for j := range p {
+			p[j] = r.Int()
+		}
+		offset := r.Int63n(8192)
+		require.NoError(t, parallel.Set(p, offset))
+		require.NoError(t, sequential.Set(p, offset))
+	}
+	require.NoError(t, parallel.Fill(-1, 1000, 9000))
+	require.NoError(t, sequential.Fill(-1, 1000, 9000))
+
+	for i := 0; i < 200; i++ {
+		offset := r.Int63n(10000) - 100
+		want := make([]int, r.Intn(2000))
+		got := make([]int, len(want))
+		assert.Equal(t, sequential.Get(want, offset), parallel.Get(got, offset))
+		assert.Equal(t, want, got, "offset %d", offset)
+	}
+	assert.Equal(t, sequential.Stats().Served, parallel.Stats().Served)
+}
+
+func BenchmarkStoreGetParallel(b *testing.B) {
+	for name, opts := range map[string][]store.Option[byte]{
+		"sequential": nil,
+		"parallel":   {store.WithParallelGet[byte](1 << 20)},
+	} {
+		b.Run(name, func(b *testing.B) {
+			s := store.NewStore(opts...)
+			for offset := int64(0); offset < 256<<20; offset += 16 << 20 {
+				s.SetOwned(make([]byte, 16<<20), offset)
+			}
+			p := make([]byte, 256<<20)
+
+			b.SetBytes(int64(len(p)))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				s.Get(p, 0)
+			}
+		})
+	}
+}
@@ -0,0 +1,204 @@
+package store
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+)
+
+// persistMagic starts the encoding of a store, followed by its version.
+const (
+	persistMagic   = "SPST"
+	persistVersion = 1
+)
+
+// persistChunk is the number of values of an extent decoded at a time, so that
+// the memory allocated for an extent grows with the values read rather than
+// with the size its encoding claims.
+const persistChunk = 1 << 16
+
+// Kinds of extents in the encoding of a store.
+const (
+	persistData byte = iota
+	persistRun
+)
+
+// ErrFormat is returned when reading an encoding of a store that is invalid.
+var ErrFormat = errors.New("invalid store encoding")
+
+// WriteTo writes the length and extents of the store to `w`, with their values
+// encoded by the codec configured with WithCodec. Runs stored in constant
+// space are written as such. Expiry times are not written.
+func (c *Store[T]) WriteTo(w io.Writer) (int64, error) {
+	codec, err := c.codecOrDefault()
+	if err != nil {
+		return 0, err
+	}
+	c.Compact()
+	c.expire()
+
+	cw := &countingWriter{w: w}
+	header := append([]byte(persistMagic), persistVersion)
+	header = binary.AppendUvarint(header, uint64(c.length))
+	header = binary.AppendUvarint(header, uint64(len(c.entries)))
+	if _, err := cw.Write(header); err != nil {
+		return cw.n, err
+	}
+
+	for _, e := range c.entries {
+		kind, data := persistData, e.data
+		if e.run {
+			kind, data = persistRun, []T{e.value}
+		}
+		record := binary.AppendVarint([]byte{kind}, e.offset)
+		record = binary.AppendUvarint(record, uint64(e.size()))
+		if _, err := cw.Write(record); err != nil {
+			return cw.n, err
+		}
+		if err := codec.Encode(cw, data); err != nil {
+			return cw.n, err
+		}
+	}
+
+	return cw.n, nil
+}
+
+// ReadFrom reads a store written by WriteTo from `r`, decoding its values with
+// the codec configured with WithCodec, and sets them. The length of the store
+// is extended to that of the one read. It reads no further than the end of the
+// encoding, one byte at a time where `r` is not an io.ByteReader, so it is
+// best given a bufio.Reader.
+func (c *Store[T]) ReadFrom(r io.Reader) (int64, error) {
+	codec, err := c.codecOrDefault()
+	if err != nil {
+		return 0, err
+	}
+
+	cr := &countingReader{r: byteReader(r)}
+	header := make([]byte, len(persistMagic)+1)
+	if _, err := io.ReadFull(cr, header); err != nil {
+		return cr.n, err
+	}
+	if string(header[:len(persistMagic)]) != persistMagic || header[len(persistMagic)] != persistVersion {
+		return cr.n, fmt.Errorf("%w: unknown header %q", ErrFormat, header)
+	}
+	length, err := binary.ReadUvarint(cr)
+	if err != nil {
+		return cr.n, err
+	}
+	if length > math.MaxInt64 {
+		return cr.n, fmt.Errorf("%w: length %d", ErrFormat, length)
+	}
+	count, err := binary.ReadUvarint(cr)
+	if err != nil {
+		return cr.n, err
+	}
+
+	for i := uint64(0); i < count; i++ {
+		kind, err := cr.ReadByte()
+		if err != nil {
+			return cr.n, err
+		}
+		offset, err := binary.ReadVarint(cr)
+		if err != nil {
+			return cr.n, err
+		}
+		size, err := binary.ReadUvarint(cr)
+		if err != nil {
+			return cr.n, err
+		}
+		if size > math.MaxInt64 || checkBounds("read", int64(size), offset, true) != nil {
+			return cr.n, fmt.Errorf("%w: extent of %d values at %d", ErrFormat, size, offset)
+		}
+
+		switch kind {
+		case persistData:
+			data, decodeErr := decodeChunked(codec, cr, int64(size))
+			if decodeErr != nil {
+				return cr.n, decodeErr
+			}
+			err = c.SetOwned(data, offset)
+		case persistRun:
+			value := make([]T, 1)
+			if err := codec.Decode(cr, value); err != nil {
+				return cr.n, err
+			}
+			err = c.Fill(value[0], int64(size), offset)
+		default:
+			return cr.n, fmt.Errorf("%w: unknown extent kind %d", ErrFormat, kind)
+		}
+		if err != nil {
+			return cr.n, err
+		}
+	}
+
+	return cr.n, c.extendTo(int64(length))
+}
+
+// decodeChunked decodes `size` values with `codec` from `r`, persistChunk
+// values at a time.
+func decodeChunked[T any](codec Codec[T], r io.Reader, size int64) ([]T, error) {
+	data := make([]T, 0, min(size, persistChunk))
+	for int64(len(data)) < size {
+		n := int(min(size-int64(len(data)), persistChunk))
+		data = slices.Grow(data, n)
+		if err := codec.Decode(r, data[len(data):len(data)+n]); err != nil {
+			return nil, err
+		}
+		data = data[:len(data)+n]
+	}
+	return data, nil
+}
+
+// MarshalBinary returns the encoding of the store written by WriteTo.
+func (c *Store[T]) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	_, err := c.WriteTo(&buf)
+	return buf.Bytes(), err
+}
+
+// UnmarshalBinary sets the values of the store encoded in `data`, as read by
+// ReadFrom.
+func (c *Store[T]) UnmarshalBinary(data []byte) error {
+	_, err := c.ReadFrom(bytes.NewReader(data))
+	return err
+}
+
+// countingWriter counts the bytes written to `w`.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.n += int64(n)
+	return n, err
+}
+
+// countingReader counts the bytes read from `r`.
+type countingReader struct {
+	r interface {
+		io.Reader
+		io.ByteReader
+	}
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
+func (r *countingReader) ReadByte() (byte, error) {
+	b, err := r.r.ReadByte()
+	if err == nil {
+		r.n++
+	}
+	return b, err
+}
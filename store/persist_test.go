@@ -0,0 +1,131 @@
+package store_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"slices"
+	"testing"
+
+	"github.com/aertje/sparse-store/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStoreWriteTo(t *testing.T) {
+	s := store.NewStore(store.WithMinContiguous[uint32](1))
+	s.Set([]uint32{1, 2, 3}, 2)
+	s.Fill(7, 1<<30, 100)
+	s.Set(nil, 1<<31)
+
+	var buf bytes.Buffer
+	n, err := s.WriteTo(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, int64(buf.Len()), n)
+	// The run is written as a single value.
+	assert.Less(t, n, int64(64))
+
+	// Trailing data is left unread.
+	buf.WriteString("trailer")
+	read := store.NewStore[uint32]()
+	r := bufio.NewReader(&buf)
+	_, err = read.ReadFrom(r)
+	require.NoError(t, err)
+	rest, _ := io.ReadAll(r)
+	assert.Equal(t, "trailer", string(rest))
+
+	assert.Equal(t, s.Extents(), read.Extents())
+	assert.Equal(t, int64(1<<31), read.Length())
+	data := make([]uint32, 4)
+	assert.False(t, read.Get(data, 1))
+	assert.Equal(t, []uint32{0, 1, 2, 3}, data)
+	assert.True(t, read.Get(data, 1<<30))
+	assert.Equal(t, []uint32{7, 7, 7, 7}, data)
+}
+
+func TestStoreCodec(t *testing.T) {
+	s := store.NewStore(store.WithCodec[string](store.StringCodec{}))
+	s.Set([]string{"a", "", "ccc"}, 5)
+
+	encoded, err := s.MarshalBinary()
+	require.NoError(t, err)
+	read := store.NewStore(store.WithCodec[string](store.StringCodec{}))
+	require.NoError(t, read.UnmarshalBinary(encoded))
+
+	data := make([]string, 3)
+	assert.True(t, read.Get(data, 5))
+	assert.Equal(t, []string{"a", "", "ccc"}, data)
+
+	// Strings cannot be persisted without a codec.
+	_, err = store.NewStore[string]().MarshalBinary()
+	assert.Error(t, err)
+}
+
+func TestStoreGobCodec(t *testing.T) {
+	type sample struct {
+		Name   string
+		Values []float64
+	}
+	codec := store.WithCodec[sample](store.GobCodec[sample]{})
+
+	s := store.NewStore(codec, store.WithMinContiguous[sample](1))
+	s.Set([]sample{{Name: "a", Values: []float64{1.5}}}, 0)
+	s.Set([]sample{{Name: "b"}, {Name: "c", Values: []float64{2, 3}}}, 10)
+
+	var buf bytes.Buffer
+	_, err := s.WriteTo(&buf)
+	require.NoError(t, err)
+	read := store.NewStore(codec)
+	_, err = read.ReadFrom(&buf)
+	require.NoError(t, err)
+
+	data := make([]sample, 2)
+	assert.True(t, read.Get(data, 10))
+	assert.Equal(t, []sample{{Name: "b"}, {Name: "c", Values: []float64{2, 3}}}, data)
+	assert.Equal(t, []store.Range{{Offset: 0, Length: 1}, {Offset: 10, Length: 2}}, read.Extents())
+}
+
+func TestStoreReadFromInvalid(t *testing.T) {
+	_, err := store.NewStore[byte]().ReadFrom(bytes.NewReader([]byte("nope!")))
+	assert.ErrorIs(t, err, store.ErrFormat)
+}
+
+func TestStoreReadFromCorruptSize(t *testing.T) {
+	header := append([]byte("SPST\x01"), binary.AppendUvarint(nil, 10)...)
+	header = binary.AppendUvarint(header, 1)
+
+	// A size larger than the input fails at its end, without allocating it.
+	huge := binary.AppendUvarint(binary.AppendVarint(append(slices.Clone(header), 0), 0), 1<<40)
+	_, err := store.NewStore[uint64]().ReadFrom(bytes.NewReader(append(huge, 1, 2, 3)))
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+
+	overflow := binary.AppendUvarint(binary.AppendVarint(append(slices.Clone(header), 0), 1), math.MaxInt64)
+	_, err = store.NewStore[byte]().ReadFrom(bytes.NewReader(overflow))
+	assert.ErrorIs(t, err, store.ErrFormat)
+
+	str := binary.AppendUvarint(binary.AppendVarint(append(slices.Clone(header), 0), 0), 1)
+	str = binary.AppendUvarint(str, 1<<40)
+	_, err = store.NewStore(store.WithCodec[string](store.StringCodec{})).ReadFrom(bytes.NewReader(append(str, 'a')))
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
+
+func TestStoreGobCodecLarge(t *testing.T) {
+	codec := store.WithCodec[int](store.GobCodec[int]{})
+	values := make([]int, 100000)
+	for i := range values {
+		values[i] = i
+	}
+	s := store.NewStore(codec)
+	s.Set(values, 0)
+
+	encoded, err := s.MarshalBinary()
+	require.NoError(t, err)
+	read := store.NewStore(codec)
+	require.NoError(t, read.UnmarshalBinary(encoded))
+
+	data := make([]int, len(values))
+	assert.True(t, read.Get(data, 0))
+	assert.Equal(t, values, data)
+}
@@ -0,0 +1,220 @@
+package store
+
+// Persistent is an immutable store. Set returns a new version of the store and
+// leaves the receiver untouched, sharing all data not covered by the write with
+// it. Versions can be kept and read concurrently without locking.
+//
+// The zero value is an empty store.
+type Persistent[T any] struct {
+	root   *node[T]
+	length int64
+}
+
+// node is a node of a treap of extents ordered by offset. Nodes are never
+// modified once they are reachable from a Persistent, updates copy the path to
+// the changed nodes instead.
+type node[T any] struct {
+	offset   int64
+	data     []T
+	priority uint64
+
+	left, right *node[T]
+	// occupancy is the number of values held in the subtree.
+	occupancy int64
+}
+
+func (n *node[T]) end() int64 {
+	return n.offset + int64(len(n.data))
+}
+
+func newNode[T any](data []T, offset int64, left, right *node[T]) *node[T] {
+	n := &node[T]{offset: offset, data: data, priority: priority(offset), left: left, right: right}
+	n.occupancy = int64(len(data)) + n.left.size() + n.right.size()
+	return n
+}
+
+// with returns a copy of the node with the children `left` and `right`.
+func (n *node[T]) with(left, right *node[T]) *node[T] {
+	return newNode(n.data, n.offset, left, right)
+}
+
+func (n *node[T]) size() int64 {
+	if n == nil {
+		return 0
+	}
+	return n.occupancy
+}
+
+// priority derives the heap priority of a node from its offset, which is
+// unique within a tree, using the SplitMix64 finalizer.
+func priority(offset int64) uint64 {
+	x := uint64(offset) + 0x9e3779b97f4a7c15
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
+
+// split returns the nodes of `n` with an offset before `offset`, and the rest.
+func split[T any](n *node[T], offset int64) (*node[T], *node[T]) {
+	if n == nil {
+		return nil, nil
+	}
+	if n.offset < offset {
+		l, r := split(n.right, offset)
+		return n.with(n.left, l), r
+	}
+	l, r := split(n.left, offset)
+	return l, n.with(r, n.right)
+}
+
+// join returns the union of `l` and `r`, all of whose nodes come after those
+// of `l`.
+func join[T any](l, r *node[T]) *node[T] {
+	switch {
+	case l == nil:
+		return r
+	case r == nil:
+		return l
+	case l.priority > r.priority:
+		return l.with(l.left, join(l.right, r))
+	default:
+		return r.with(join(l, r.left), r.right)
+	}
+}
+
+// removeLast returns `n` without its last node, and that node.
+func removeLast[T any](n *node[T]) (*node[T], *node[T]) {
+	if n.right == nil {
+		return n.left, n
+	}
+	rest, last := removeLast(n.right)
+	return n.with(n.left, rest), last
+}
+
+// Length returns the offset just past the last value ever set.
+func (c *Persistent[T]) Length() int64 {
+	return c.length
+}
+
+// Occupancy returns the number of values held.
+func (c *Persistent[T]) Occupancy() int64 {
+	return c.root.size()
+}
+
+// Set returns a version of the store with `p` written at `offset`. `p` is
+// copied, so it can be reused by the caller. It panics with a BoundsError if
+// the end of the write overflows.
+func (c *Persistent[T]) Set(p []T, offset int64) *Persistent[T] {
+	if err := checkBounds("set", int64(len(p)), offset, true); err != nil {
+		panic(err)
+	}
+	end := offset + int64(len(p))
+	next := &Persistent[T]{root: c.root, length: max(c.length, end)}
+	if len(p) == 0 {
+		return next
+	}
+
+	// Clip the last extent before the write, which is the only one that can
+	// overlap its start, and keep what it holds past the write.
+	l, r := split(c.root, offset)
+	var tail *node[T]
+	if l != nil {
+		var last *node[T]
+		l, last = removeLast(l)
+		if last.end() > end {
+			tail = newNode[T](last.data[end-last.offset:], end, nil, nil)
+		}
+		l = join(l, newNode[T](last.data[:min(last.end(), offset)-last.offset], last.offset, nil, nil))
+	}
+
+	// Drop the extents covered by the write, except for what the last of
+	// them holds past its end.
+	covered, r := split(r, end)
+	if covered != nil {
+		_, last := removeLast(covered)
+		if last.end() > end {
+			tail = newNode[T](last.data[end-last.offset:], end, nil, nil)
+		}
+	}
+
+	data := make([]T, len(p))
+	copy(data, p)
+	l = join(l, newNode(data, offset, nil, nil))
+	if tail != nil {
+		l = join(l, tail)
+	}
+	next.root = join(l, r)
+
+	return next
+}
+
+// Get reads the values from `offset` onwards into `p`, and returns whether all
+// of them were set.
+func (c *Persistent[T]) Get(p []T, offset int64) bool {
+	end := endOf(int64(len(p)), offset)
+	next := offset
+	c.each(offset, end, func(n *node[T]) {
+		from := max(n.offset, offset)
+		if from != next {
+			next = -1
+		} else if next >= 0 {
+			next = min(n.end(), end)
+		}
+		copy(p[from-offset:], n.data[from-n.offset:])
+	})
+	return next == end && end-offset == int64(len(p))
+}
+
+// Has returns whether all `length` values at `offset` are set.
+func (c *Persistent[T]) Has(length, offset int64) bool {
+	end := endOf(length, offset)
+	next := offset
+	c.each(offset, end, func(n *node[T]) {
+		if n.offset > next {
+			return
+		}
+		next = max(next, n.end())
+	})
+	return next >= end && end-offset == length
+}
+
+// Extents returns the ranges of the extents the store holds, in order.
+func (c *Persistent[T]) Extents() []Range {
+	var extents []Range
+	c.each(c.first(), c.length, func(n *node[T]) {
+		extents = append(extents, Range{Offset: n.offset, Length: int64(len(n.data))})
+	})
+	return extents
+}
+
+// first returns the offset of the first extent.
+func (c *Persistent[T]) first() int64 {
+	n := c.root
+	if n == nil {
+		return 0
+	}
+	for n.left != nil {
+		n = n.left
+	}
+	return n.offset
+}
+
+// each calls `fn` in order for the extents overlapping [from, to).
+func (c *Persistent[T]) each(from, to int64, fn func(*node[T])) {
+	var walk func(n *node[T])
+	walk = func(n *node[T]) {
+		if n == nil {
+			return
+		}
+		if n.offset > from {
+			walk(n.left)
+		}
+		if n.offset < to && n.end() > from {
+			fn(n)
+		}
+		if n.end() < to {
+			walk(n.right)
+		}
+	}
+	walk(c.root)
+}
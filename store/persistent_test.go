@@ -0,0 +1,91 @@
+package store_test
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/aertje/sparse-store/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPersistentVersions(t *testing.T) {
+	var empty store.Persistent[byte]
+	v1 := empty.Set([]byte{1, 2, 3, 4}, 2)
+	v2 := v1.Set([]byte{5, 6}, 3)
+	v3 := v2.Set([]byte{7}, 8)
+
+	assert.Equal(t, int64(0), empty.Occupancy())
+	assert.False(t, empty.Has(1, 0))
+
+	data := make([]byte, 4)
+	assert.True(t, v1.Get(data, 2))
+	assert.Equal(t, []byte{1, 2, 3, 4}, data)
+	assert.True(t, v2.Get(data, 2))
+	assert.Equal(t, []byte{1, 5, 6, 4}, data)
+	assert.Equal(t, []store.Range{{Offset: 2, Length: 1}, {Offset: 3, Length: 2}, {Offset: 5, Length: 1}}, v2.Extents())
+
+	assert.Equal(t, int64(5), v3.Occupancy())
+	assert.Equal(t, int64(9), v3.Length())
+	assert.False(t, v3.Has(7, 2))
+	assert.Equal(t, int64(6), v1.Length())
+}
+
+func TestPersistentSetCopiesInput(t *testing.T) {
+	p := []byte{1, 2}
+	s := new(store.Persistent[byte]).Set(p, 0)
+	p[0] = 3
+
+	data := make([]byte, 2)
+	assert.True(t, s.Get(data, 0))
+	assert.Equal(t, []byte{1, 2}, data)
+}
+
+func TestPersistentRandom(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+
+	type version struct {
+		s       *store.Persistent[byte]
+		model   []byte
+		present []bool
+	}
+	versions := []version{{s: &store.Persistent[byte]{}, model: make([]byte, 256), present: make([]bool, 256)}}
+
+	for i := 0; i < 1000; i++ {
+		// Branch off any earlier version.
+		v := versions[r.Intn(len(versions))]
+		next := version{model: append([]byte(nil), v.model...), present: append([]bool(nil), v.present...)}
+
+		offset := r.Int63n(200)
+		p := make([]byte, r.Intn(50))
+		for j := range p {
+			p[j] = byte(r.Intn(255) + 1)
+			next.model[offset+int64(j)] = p[j]
+			next.present[offset+int64(j)] = true
+		}
+		next.s = v.s.Set(p, offset)
+		versions = append(versions, next)
+	}
+
+	for _, v := range versions {
+		var occupancy int64
+		for _, ok := range v.present {
+			if ok {
+				occupancy++
+			}
+		}
+		assert.Equal(t, occupancy, v.s.Occupancy())
+
+		data := make([]byte, len(v.model))
+		v.s.Get(data, 0)
+		assert.Equal(t, v.model, data)
+
+		from := r.Int63n(int64(len(v.model)))
+		to := from + r.Int63n(int64(len(v.model))-from)
+		complete := true
+		for _, ok := range v.present[from:to] {
+			complete = complete && ok
+		}
+		assert.Equal(t, complete, v.s.Has(to-from, from))
+		assert.Equal(t, complete, v.s.Get(data[:to-from], from))
+	}
+}
@@ -0,0 +1,32 @@
+package store
+
+import "slices"
+
+// Pin pins the `length` values at `offset`, so that the extents holding them
+// are never evicted, whatever the eviction policy, for values needed on every
+// request such as file headers and indexes. Eviction removes whole extents, so
+// the values merged into an extent with pinned ones are kept too. Pinned values
+// can still be deleted, and still expire.
+func (c *Store[T]) Pin(length, offset int64) {
+	defer c.enter("pin")()
+	if !c.frozen {
+		c.recorder.record("pin", offset, length)
+	}
+	c.pinned.add(offset, endOf(length, offset))
+}
+
+// Unpin unpins the `length` values at `offset`, however often they were
+// pinned.
+func (c *Store[T]) Unpin(length, offset int64) {
+	defer c.enter("unpin")()
+	if !c.frozen {
+		c.recorder.record("unpin", offset, length)
+	}
+	c.pinned.take(offset, endOf(length, offset))
+}
+
+// Pinned returns the ranges of the pinned values, in order.
+func (c *Store[T]) Pinned() []Range {
+	defer c.enter("pinned")()
+	return slices.Clone(c.pinned)
+}
@@ -0,0 +1,68 @@
+package store_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/aertje/sparse-store/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPin(t *testing.T) {
+	for name, policy := range map[string]store.Evictor{
+		"oldest":        store.EvictOldest,
+		"lowest offset": store.EvictLowestOffset,
+		"LRU":           store.EvictLRU,
+		"LFU":           store.EvictLFU,
+		"ARC":           store.NewARC(),
+	} {
+		t.Run(name, func(t *testing.T) {
+			s := store.NewStore(store.WithMinContiguous[byte](1), store.WithMaxOccupancy[byte](6, policy))
+			s.Pin(2, 0)
+			require.NoError(t, s.Set([]byte{1, 2}, 0))
+			for offset := int64(4); offset < 100; offset += 4 {
+				require.NoError(t, s.Set([]byte{3, 4}, offset))
+				s.Get(make([]byte, 2), offset)
+			}
+			assert.True(t, s.Has(2, 0))
+			assert.Equal(t, int64(6), s.Occupancy())
+		})
+	}
+}
+
+func TestUnpin(t *testing.T) {
+	var buf bytes.Buffer
+	s := store.NewStore(store.WithMinContiguous[byte](1), store.WithRecorder(store.NewRecorder[byte](&buf)))
+	s.Pin(4, 0)
+	s.Pin(2, 10)
+	s.Unpin(2, 2)
+	assert.Equal(t, []store.Range{{Offset: 0, Length: 2}, {Offset: 10, Length: 2}}, s.Pinned())
+
+	require.NoError(t, s.Set([]byte{1, 2}, 0))
+	require.NoError(t, s.Set([]byte{3, 4}, 4))
+	require.NoError(t, s.Set([]byte{5, 6}, 10))
+	// Only the unpinned extent can be evicted.
+	assert.Equal(t, int64(2), s.EvictLRU(3))
+	assert.Equal(t, []store.Range{{Offset: 0, Length: 2}, {Offset: 10, Length: 2}}, s.Extents())
+
+	s.Unpin(100, 0)
+	assert.Empty(t, s.Pinned())
+	assert.Equal(t, int64(4), s.EvictLRU(3))
+
+	replayed := store.NewStore(store.WithMinContiguous[byte](1))
+	require.NoError(t, store.Replay(&buf, replayed))
+	assert.Empty(t, replayed.Extents())
+	assert.Empty(t, replayed.Pinned())
+}
+
+func TestManagerPinned(t *testing.T) {
+	m := store.NewManager[byte](2, store.WithMinContiguous[byte](1))
+	s := m.GetOrCreate("a")
+	s.Pin(4, 0)
+	require.NoError(t, s.Set([]byte{1, 2, 3, 4}, 0))
+
+	// Enforcing the cap stops at pinned extents.
+	assert.Zero(t, m.Enforce())
+	assert.Equal(t, int64(4), m.Occupancy())
+}
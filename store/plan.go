@@ -0,0 +1,79 @@
+package store
+
+// PlanOptions configures the requests planned by Plan. Zero values disable the
+// corresponding constraint.
+type PlanOptions struct {
+	// MinRequest is the minimum length of a request. Shorter requests are
+	// extended, within the target range, even if that means fetching values
+	// that are present again.
+	MinRequest int64
+	// MaxRequest is the maximum length of a request. Longer requests are
+	// split up.
+	MaxRequest int64
+	// Alignment is the multiple requests start and end at, except at the
+	// bounds of the target range.
+	Alignment int64
+	// MaxGap is the maximum number of present values between two requests
+	// for them to be coalesced into one, fetching those values again.
+	MaxGap int64
+}
+
+// Plan returns the requests, in order, that fetch the values missing from the
+// range at `offset` with length `length`. Use Plan(s.Length(), 0, opts) to
+// plan for everything up to the length of the store.
+func (c *Store[T]) Plan(length, offset int64, opts PlanOptions) []Range {
+	end := endOf(length, offset)
+
+	var plan []Range
+	for _, gap := range c.Gaps(length, offset) {
+		from, to := gap.Offset, gap.End()
+		if a := opts.Alignment; a > 1 {
+			from = max(offset, alignDown(from, a))
+			to = min(end, max(to, alignUp(to, a)))
+		}
+		if to-from < opts.MinRequest {
+			to = min(end, endOf(opts.MinRequest, from))
+			from = max(offset, to-opts.MinRequest)
+		}
+
+		if n := len(plan); n > 0 && from-plan[n-1].End() <= opts.MaxGap {
+			plan[n-1].Length = max(plan[n-1].End(), to) - plan[n-1].Offset
+			continue
+		}
+		plan = append(plan, Range{Offset: from, Length: to - from})
+	}
+
+	if opts.MaxRequest <= 0 {
+		return plan
+	}
+
+	var split []Range
+	for _, r := range plan {
+		for r.Length > opts.MaxRequest {
+			// Split at an aligned offset, if there is one.
+			cut := r.Offset + opts.MaxRequest
+			if a := opts.Alignment; a > 1 && alignDown(cut, a) > r.Offset {
+				cut = alignDown(cut, a)
+			}
+			split = append(split, Range{Offset: r.Offset, Length: cut - r.Offset})
+			r = Range{Offset: cut, Length: r.End() - cut}
+		}
+		split = append(split, r)
+	}
+	return split
+}
+
+// alignUp rounds `offset` up to a multiple of `alignment`, or down near the
+// end of the offset domain, where there is no multiple above it.
+func alignUp(offset, alignment int64) int64 {
+	return alignDown(endOf(alignment-1, offset), alignment)
+}
+
+// alignDown rounds `offset` down to a multiple of `alignment`.
+func alignDown(offset, alignment int64) int64 {
+	m := offset % alignment
+	if m < 0 {
+		m += alignment
+	}
+	return offset - m
+}
@@ -0,0 +1,53 @@
+package store_test
+
+import (
+	"testing"
+
+	"github.com/aertje/sparse-store/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStorePlan(t *testing.T) {
+	s := store.NewStore(store.WithMinContiguous[byte](1))
+	s.Set(make([]byte, 10), 10)
+	s.Set(make([]byte, 2), 22)
+	s.Set(make([]byte, 30), 40)
+
+	for _, tc := range []struct {
+		name     string
+		opts     store.PlanOptions
+		expected []store.Range
+	}{
+		{
+			name:     "gaps",
+			expected: []store.Range{{Offset: 0, Length: 10}, {Offset: 20, Length: 2}, {Offset: 24, Length: 16}, {Offset: 70, Length: 30}},
+		},
+		{
+			name:     "coalesced",
+			opts:     store.PlanOptions{MaxGap: 2},
+			expected: []store.Range{{Offset: 0, Length: 10}, {Offset: 20, Length: 20}, {Offset: 70, Length: 30}},
+		},
+		{
+			name:     "aligned",
+			opts:     store.PlanOptions{Alignment: 8},
+			expected: []store.Range{{Offset: 0, Length: 40}, {Offset: 64, Length: 36}},
+		},
+		{
+			name:     "min request",
+			opts:     store.PlanOptions{MinRequest: 8},
+			expected: []store.Range{{Offset: 0, Length: 10}, {Offset: 20, Length: 20}, {Offset: 70, Length: 30}},
+		},
+		{
+			name:     "max request",
+			opts:     store.PlanOptions{MaxRequest: 12, Alignment: 4},
+			expected: []store.Range{{Offset: 0, Length: 12}, {Offset: 20, Length: 12}, {Offset: 32, Length: 8}, {Offset: 68, Length: 12}, {Offset: 80, Length: 12}, {Offset: 92, Length: 8}},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, s.Plan(100, 0, tc.opts))
+		})
+	}
+
+	assert.Nil(t, s.Plan(10, 10, store.PlanOptions{}))
+	assert.Equal(t, []store.Range{{Offset: 5, Length: 3}}, s.Plan(3, 5, store.PlanOptions{Alignment: 4, MinRequest: 4}))
+}
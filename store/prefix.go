@@ -0,0 +1,41 @@
+package store
+
+// GetPrefix populates `p` with the data at `offset` as Get does, and returns
+// the number of values from the start of `p` that are present, up to the
+// first missing one, like a short read.
+func (c *Store[T]) GetPrefix(p []T, offset int64) int {
+	if c.Get(p, offset) {
+		return len(p)
+	}
+
+	gaps := c.Gaps(int64(len(p)), offset)
+	if len(gaps) == 0 {
+		// The rest of `p` is past the end of the offset domain.
+		return int(endOf(int64(len(p)), offset) - offset)
+	}
+	return int(gaps[0].Offset - offset)
+}
+
+// GetMask populates `p` with the data at `offset` as Get does, sets the
+// elements of `mask` to whether the values at the same positions of `p` are
+// present, and returns the number of values present. `mask` must be at least
+// as long as `p`.
+func (c *Store[T]) GetMask(p []T, mask []bool, offset int64) int {
+	mask = mask[:len(p)]
+	if c.Get(p, offset) {
+		for i := range mask {
+			mask[i] = true
+		}
+		return len(p)
+	}
+
+	clear(mask)
+	n := 0
+	c.within(int64(len(p)), offset, func(e entry[T]) {
+		for i := e.offset; i < e.end(); i++ {
+			mask[i-offset] = true
+		}
+		n += int(e.size())
+	})
+	return n
+}
@@ -0,0 +1,40 @@
+package store_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/aertje/sparse-store/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStoreGetPrefix(t *testing.T) {
+	s := store.NewStore(store.WithMinContiguous[byte](1))
+	s.Set([]byte{1, 2}, 0)
+	s.Set([]byte{3}, 2)
+	s.Set([]byte{5}, 4)
+
+	p := make([]byte, 5)
+	assert.Equal(t, 3, s.GetPrefix(p, 0))
+	assert.Equal(t, []byte{1, 2, 3, 0, 5}, p)
+	assert.Equal(t, 2, s.GetPrefix(make([]byte, 2), 1))
+	assert.Equal(t, 0, s.GetPrefix(make([]byte, 2), 3))
+
+	s.Set([]byte{9}, math.MaxInt64-1)
+	assert.Equal(t, 1, s.GetPrefix(make([]byte, 4), math.MaxInt64-1))
+}
+
+func TestStoreGetMask(t *testing.T) {
+	s := store.NewStore(store.WithMinContiguous[float32](1))
+	s.Set([]float32{1, 2}, 1)
+	s.Fill(7, 1<<40, 5)
+
+	p := make([]float32, 7)
+	mask := make([]bool, 8)
+	assert.Equal(t, 4, s.GetMask(p, mask, 0))
+	assert.Equal(t, []float32{0, 1, 2, 0, 0, 7, 7}, p)
+	assert.Equal(t, []bool{false, true, true, false, false, true, true, false}, mask)
+
+	assert.Equal(t, 2, s.GetMask(p[:2], mask, 1))
+	assert.Equal(t, []bool{true, true}, mask[:2])
+}
@@ -0,0 +1,44 @@
+package store_test
+
+import (
+	"testing"
+
+	"github.com/aertje/sparse-store/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStoreSetWithPriority(t *testing.T) {
+	for name, opts := range map[string][]store.Option[byte]{
+		"eager": {store.WithMinContiguous[byte](4)},
+		"lazy":  {store.WithMinContiguous[byte](4), store.WithLazyCompaction[byte](10, 100)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			s := store.NewStore(opts...)
+
+			// Verified values are not overwritten by a later prefetch.
+			require.NoError(t, s.SetWithPriority([]byte{1, 1, 1}, 2, 10))
+			require.NoError(t, s.Set([]byte{2, 2, 2, 2, 2, 2, 2}, 0))
+			require.NoError(t, s.SetWithPriority([]byte{3, 3}, 3, 5))
+
+			data := make([]byte, 7)
+			assert.True(t, s.Get(data, 0))
+			assert.Equal(t, []byte{2, 2, 1, 1, 1, 2, 2}, data)
+
+			// Writes of at least the same priority do overwrite them.
+			require.NoError(t, s.SetWithPriority([]byte{4}, 4, 10))
+			assert.True(t, s.Get(data, 0))
+			assert.Equal(t, []byte{2, 2, 1, 1, 4, 2, 2}, data)
+			assert.Equal(t, int64(7), s.Occupancy())
+		})
+	}
+}
+
+func TestStoreSetWithPriorityWriteThrough(t *testing.T) {
+	f := &file{}
+	s := store.NewStore(store.WithWriteThrough(f))
+
+	require.NoError(t, s.SetWithPriority([]byte{1, 1}, 2, 1))
+	require.NoError(t, s.Set([]byte{2, 2, 2, 2, 2, 2}, 0))
+	assert.Equal(t, []byte{2, 2, 1, 1, 2, 2}, f.data)
+}
@@ -0,0 +1,81 @@
+package store
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Progress returns how much of the values in [Start(), Length()) are present,
+// as `buckets` levels, one byte per bucket: 0 for buckets whose values are all
+// missing, 255 for buckets whose values are all present, and proportionally in
+// between otherwise, so that 0 and 255 are exact. A download would truncate the
+// store to its size first. It suits rendering a progress strip, with a
+// resolution independent of the size of the store. It returns nil if `buckets`
+// is not positive.
+func (c *Store[T]) Progress(buckets int) []byte {
+	defer c.enter("progress")()
+	if buckets <= 0 {
+		return nil
+	}
+	c.Compact()
+	c.expire()
+
+	levels := make([]byte, 0, buckets)
+	c.eachCell(buckets, func(present, size int64) {
+		switch {
+		case present == 0:
+			levels = append(levels, 0)
+		case present == size:
+			levels = append(levels, 255)
+		default:
+			levels = append(levels, byte(1+present*253/size))
+		}
+	})
+	return levels
+}
+
+// EncodeProgress returns the levels returned by Progress, run-length encoded as
+// comma-separated runs of "count:level", with the count in decimal and the
+// level in hexadecimal, such as "120:ff,3:80,877:0", to send to a browser. As
+// downloads are mostly runs of complete or missing buckets, the string stays
+// short however many buckets there are.
+func (c *Store[T]) EncodeProgress(buckets int) string {
+	var b strings.Builder
+	levels := c.Progress(buckets)
+	for i := 0; i < len(levels); {
+		j := i + 1
+		for j < len(levels) && levels[j] == levels[i] {
+			j++
+		}
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(strconv.Itoa(j - i))
+		b.WriteByte(':')
+		b.WriteString(strconv.FormatUint(uint64(levels[i]), 16))
+		i = j
+	}
+	return b.String()
+}
+
+// DecodeProgress returns the levels encoded by EncodeProgress. It fails with
+// ErrFormat if `s` is not such an encoding.
+func DecodeProgress(s string) ([]byte, error) {
+	levels := []byte{}
+	if s == "" {
+		return levels, nil
+	}
+	for _, run := range strings.Split(s, ",") {
+		count, level, ok := strings.Cut(run, ":")
+		n, err1 := strconv.Atoi(count)
+		l, err2 := strconv.ParseUint(level, 16, 8)
+		if !ok || err1 != nil || err2 != nil || n <= 0 {
+			return nil, fmt.Errorf("%w: invalid progress run %q", ErrFormat, run)
+		}
+		for ; n > 0; n-- {
+			levels = append(levels, byte(l))
+		}
+	}
+	return levels, nil
+}
@@ -0,0 +1,40 @@
+package store_test
+
+import (
+	"testing"
+
+	"github.com/aertje/sparse-store/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStoreProgress(t *testing.T) {
+	s := store.NewStore[byte]()
+	assert.Equal(t, []byte{0, 0}, s.Progress(2))
+	assert.Equal(t, "2:0", s.EncodeProgress(2))
+	assert.Equal(t, "", s.EncodeProgress(0))
+	assert.Nil(t, s.Progress(-1))
+	assert.Equal(t, "", s.EncodeProgress(-1))
+
+	require.NoError(t, s.Truncate(1000))
+	require.NoError(t, s.Set(make([]byte, 300), 0))
+	require.NoError(t, s.Set(make([]byte, 5), 500))
+	require.NoError(t, s.Set(make([]byte, 100), 900))
+
+	levels := s.Progress(10)
+	assert.Equal(t, []byte{255, 255, 255, 0, 0, 13, 0, 0, 0, 255}, levels)
+	encoded := s.EncodeProgress(10)
+	assert.Equal(t, "3:ff,2:0,1:d,3:0,1:ff", encoded)
+
+	decoded, err := store.DecodeProgress(encoded)
+	require.NoError(t, err)
+	assert.Equal(t, levels, decoded)
+	decoded, err = store.DecodeProgress("")
+	require.NoError(t, err)
+	assert.Empty(t, decoded)
+
+	for _, invalid := range []string{"3", "0:ff", "1:100", "x:1", "1:ff,"} {
+		_, err := store.DecodeProgress(invalid)
+		assert.ErrorIs(t, err, store.ErrFormat, "%q", invalid)
+	}
+}
@@ -0,0 +1,46 @@
+package store
+
+// Readahead decides how many values a Cache reads ahead. It is called with the
+// lock of the cache held, so it can keep state without synchronization.
+type Readahead interface {
+	// Readahead is called for every read of `length` values at `offset`, and
+	// returns the number of values following them to fetch along with them.
+	Readahead(offset, length int64) int64
+}
+
+// FixedReadahead reads ahead a fixed number of values on every read.
+type FixedReadahead int64
+
+// Readahead returns r.
+func (r FixedReadahead) Readahead(offset, length int64) int64 {
+	return int64(r)
+}
+
+// AdaptiveReadahead reads ahead only while reads are sequential, each starting
+// where the previous one ended. The window starts at a minimum and doubles
+// with every sequential read, up to a maximum. Any other read resets it.
+type AdaptiveReadahead struct {
+	min, max int64
+
+	next   int64
+	window int64
+}
+
+// NewAdaptiveReadahead returns an adaptive readahead with a window growing from
+// `minWindow` to `maxWindow` values.
+func NewAdaptiveReadahead(minWindow, maxWindow int64) *AdaptiveReadahead {
+	return &AdaptiveReadahead{min: minWindow, max: maxWindow, next: -1}
+}
+
+// Readahead returns the window after updating it for a read of `length`
+// values at `offset`.
+func (r *AdaptiveReadahead) Readahead(offset, length int64) int64 {
+	if offset == r.next {
+		r.window = min(max(2*r.window, r.min), r.max)
+	} else {
+		r.window = 0
+	}
+	r.next = offset + length
+
+	return r.window
+}
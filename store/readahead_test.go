@@ -0,0 +1,38 @@
+package store_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aertje/sparse-store/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdaptiveReadahead(t *testing.T) {
+	r := store.NewAdaptiveReadahead(4, 16)
+
+	assert.Equal(t, int64(0), r.Readahead(0, 2))
+	assert.Equal(t, int64(4), r.Readahead(2, 2))
+	assert.Equal(t, int64(8), r.Readahead(4, 2))
+	assert.Equal(t, int64(16), r.Readahead(6, 2))
+	assert.Equal(t, int64(16), r.Readahead(8, 2))
+	assert.Equal(t, int64(0), r.Readahead(50, 2))
+	assert.Equal(t, int64(4), r.Readahead(52, 2))
+}
+
+func TestCacheReadahead(t *testing.T) {
+	src := &source{}
+	c := store.NewCache(store.NewStore[byte](), src, store.WithReadahead[byte](store.FixedReadahead(8)))
+
+	p := make([]byte, 4)
+	require.NoError(t, c.Get(context.Background(), p, 0))
+	assert.Equal(t, []byte{0, 1, 2, 3}, p)
+	require.NoError(t, c.Get(context.Background(), p, 4))
+	assert.Equal(t, []byte{4, 5, 6, 7}, p)
+	assert.Equal(t, []store.Range{{Offset: 0, Length: 12}, {Offset: 12, Length: 4}}, src.fetched)
+
+	// Reading ahead past the end of the source does not fail the read.
+	require.NoError(t, c.Get(context.Background(), p, 96))
+	assert.Equal(t, []byte{96, 97, 98, 99}, p)
+}
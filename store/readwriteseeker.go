@@ -0,0 +1,141 @@
+package store
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// GapMode is what a ReadWriteSeeker does with reads of missing values.
+type GapMode int
+
+const (
+	// GapError makes reads stop at the first missing value: Read returns the
+	// values before it, or an error wrapping ErrMissing if it is the first,
+	// and ReadAt returns the values before it with such an error.
+	GapError GapMode = iota
+	// GapZero makes reads return zeros for missing values, as for the holes of
+	// a sparse file.
+	GapZero
+)
+
+// ReadWriteSeeker implements io.Reader, io.Writer, io.Seeker, io.ReaderAt and
+// io.WriterAt over a byte store, so that it can stand in for an *os.File. The
+// size of the file is the length of the store: reads stop there with io.EOF,
+// writes past it extend it, and seeking past it and writing leaves a gap.
+// Missing values are read according to its GapMode. Writes store a copy of the
+// values written.
+type ReadWriteSeeker struct {
+	store *Store[byte]
+	gaps  GapMode
+	pos   int64
+}
+
+var _ interface {
+	io.ReadWriteSeeker
+	io.ReaderAt
+	io.WriterAt
+} = (*ReadWriteSeeker)(nil)
+
+// NewReadWriteSeeker returns a ReadWriteSeeker positioned at the start of `s`,
+// reading missing values according to `gaps`.
+func NewReadWriteSeeker(s *Store[byte], gaps GapMode) *ReadWriteSeeker {
+	return &ReadWriteSeeker{store: s, gaps: gaps}
+}
+
+// Store returns the underlying store.
+func (f *ReadWriteSeeker) Store() *Store[byte] {
+	return f.store
+}
+
+// Read reads up to len(`p`) values at the current position, and advances it by
+// the number of values read.
+func (f *ReadWriteSeeker) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if f.pos >= f.store.Length() {
+		return 0, io.EOF
+	}
+
+	p = p[:min(int64(len(p)), f.store.Length()-f.pos)]
+	n := f.read(p, f.pos)
+	if n == 0 {
+		return 0, fmt.Errorf("reading at %d: %w", f.pos, ErrMissing)
+	}
+	f.pos += int64(n)
+	return n, nil
+}
+
+// ReadAt reads len(`p`) values at `offset`, without changing the position. It
+// returns fewer with io.EOF if the store ends before, and with an error
+// wrapping ErrMissing if it reaches a missing value in GapError mode.
+func (f *ReadWriteSeeker) ReadAt(p []byte, offset int64) (int, error) {
+	if offset < 0 {
+		return 0, errors.New("store.ReadWriteSeeker.ReadAt: negative offset")
+	}
+
+	want := int(min(int64(len(p)), max(f.store.Length()-offset, 0)))
+	n := f.read(p[:want], offset)
+	switch {
+	case n < want:
+		return n, fmt.Errorf("reading at %d: %w", offset+int64(n), ErrMissing)
+	case n < len(p):
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// Write writes `p` at the current position, and advances it.
+func (f *ReadWriteSeeker) Write(p []byte) (int, error) {
+	n, err := f.WriteAt(p, f.pos)
+	f.pos += int64(n)
+	return n, err
+}
+
+// WriteAt writes `p` at `offset`, without changing the position.
+func (f *ReadWriteSeeker) WriteAt(p []byte, offset int64) (int, error) {
+	if offset < 0 {
+		return 0, errors.New("store.ReadWriteSeeker.WriteAt: negative offset")
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if err := f.store.SetOwned(bytes.Clone(p), offset); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Seek sets the position to `offset` relative to the start, the current
+// position or the end of the store, according to `whence`, and returns it.
+// Seeking past the end is allowed.
+func (f *ReadWriteSeeker) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.pos
+	case io.SeekEnd:
+		offset += f.store.Length()
+	default:
+		return 0, errors.New("store.ReadWriteSeeker.Seek: invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("store.ReadWriteSeeker.Seek: negative position")
+	}
+	f.pos = offset
+	return offset, nil
+}
+
+// read reads the values at `offset` into `p` according to the GapMode, and
+// returns the number of values read.
+func (f *ReadWriteSeeker) read(p []byte, offset int64) int {
+	if f.gaps == GapZero {
+		// Get leaves the elements of missing values as they are.
+		clear(p)
+		f.store.Get(p, offset)
+		return len(p)
+	}
+	return f.store.GetPrefix(p, offset)
+}
@@ -0,0 +1,75 @@
+package store_test
+
+import (
+	"io"
+	"testing"
+	"testing/iotest"
+
+	"github.com/aertje/sparse-store/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadWriteSeeker(t *testing.T) {
+	s := store.NewStore[byte]()
+	f := store.NewReadWriteSeeker(s, store.GapError)
+	assert.Same(t, s, f.Store())
+
+	n, err := f.Write([]byte("hello"))
+	require.NoError(t, err)
+	assert.Equal(t, 5, n)
+	pos, err := f.Seek(2, io.SeekCurrent)
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), pos)
+	_, err = f.Write([]byte("world"))
+	require.NoError(t, err)
+	assert.Equal(t, []store.Range{{Offset: 0, Length: 5}, {Offset: 7, Length: 5}}, s.Extents())
+
+	pos, err = f.Seek(-9, io.SeekEnd)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), pos)
+	p := make([]byte, 8)
+	n, err = f.Read(p)
+	assert.NoError(t, err)
+	assert.Equal(t, "lo", string(p[:n]))
+	_, err = f.Read(p)
+	assert.ErrorIs(t, err, store.ErrMissing)
+
+	n, err = f.ReadAt(p, 3)
+	assert.ErrorIs(t, err, store.ErrMissing)
+	assert.ErrorContains(t, err, "reading at 5")
+	assert.Equal(t, 2, n)
+	n, err = f.ReadAt(p, 8)
+	assert.ErrorIs(t, err, io.EOF)
+	assert.Equal(t, "orld", string(p[:n]))
+
+	_, err = f.Seek(-1, io.SeekStart)
+	assert.Error(t, err)
+	_, err = f.WriteAt([]byte("x"), -1)
+	assert.Error(t, err)
+
+	// Writes copy the values.
+	data := []byte("!")
+	_, err = f.WriteAt(data, 12)
+	require.NoError(t, err)
+	data[0] = '?'
+	_, err = f.Seek(7, io.SeekStart)
+	require.NoError(t, err)
+	rest, err := io.ReadAll(f)
+	assert.NoError(t, err)
+	assert.Equal(t, "world!", string(rest))
+}
+
+func TestReadWriteSeekerGapZero(t *testing.T) {
+	s := store.NewStore[byte]()
+	require.NoError(t, s.Set([]byte("ab"), 0))
+	require.NoError(t, s.Set([]byte("ef"), 4))
+	f := store.NewReadWriteSeeker(s, store.GapZero)
+
+	p := []byte("xxxxxxxx")
+	n, err := f.ReadAt(p, 0)
+	assert.ErrorIs(t, err, io.EOF)
+	assert.Equal(t, "ab\x00\x00ef", string(p[:n]))
+
+	assert.NoError(t, iotest.TestReader(f, []byte("ab\x00\x00ef")))
+}
@@ -0,0 +1,232 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Recorder writes the operations on a store to an io.Writer, one per line, so
+// that they can be replayed with Replay. Along with the mutations, it records
+// reads, as they affect LRU eviction. Values are encoded with encoding/binary,
+// so T must be a fixed-size type.
+//
+// TTLs are recorded relative to the time of the write, and expire relative to
+// the time of the replay.
+type Recorder[T any] struct {
+	w   io.Writer
+	err error
+}
+
+// NewRecorder returns a recorder that writes to `w`.
+func NewRecorder[T any](w io.Writer) *Recorder[T] {
+	return &Recorder[T]{w: w}
+}
+
+// Err returns the first error encountered while recording, after which the
+// recorder stops recording.
+func (r *Recorder[T]) Err() error {
+	return r.err
+}
+
+// WithRecorder makes the store record its operations with `recorder`.
+func WithRecorder[T any](recorder *Recorder[T]) Option[T] {
+	return func(c *Store[T]) {
+		c.recorder = recorder
+	}
+}
+
+// record writes an operation with its arguments.
+func (r *Recorder[T]) record(op string, args ...any) {
+	if r == nil || r.err != nil {
+		return
+	}
+
+	var b strings.Builder
+	b.WriteString(op)
+	for _, arg := range args {
+		fmt.Fprintf(&b, " %v", arg)
+	}
+	b.WriteByte('\n')
+
+	_, r.err = io.WriteString(r.w, b.String())
+}
+
+// recordSet records the write of `e`.
+func (r *Recorder[T]) recordSet(e entry[T], now time.Time) {
+	if r == nil || r.err != nil {
+		return
+	}
+
+	values := e.data
+	if e.run {
+		values = []T{e.value}
+	}
+	var buf bytes.Buffer
+	if r.err = binary.Write(&buf, binary.LittleEndian, values); r.err != nil {
+		return
+	}
+	h := fnv.New64a()
+	h.Write(buf.Bytes())
+	// Fields are separated by spaces, so empty data needs a placeholder.
+	data := "-"
+	if buf.Len() > 0 {
+		data = base64.StdEncoding.EncodeToString(buf.Bytes())
+	}
+
+	switch {
+	case e.run:
+		r.record("fill", e.offset, e.runLength, fmt.Sprintf("%016x", h.Sum64()), data)
+	case e.priority != 0:
+		r.record("set-priority", e.offset, e.priority, len(e.data), fmt.Sprintf("%016x", h.Sum64()), data)
+	case !e.expires.IsZero():
+		r.record("set-ttl", e.offset, e.expires.Sub(now).Nanoseconds(), len(e.data), fmt.Sprintf("%016x", h.Sum64()), data)
+	default:
+		r.record("set", e.offset, len(e.data), fmt.Sprintf("%016x", h.Sum64()), data)
+	}
+}
+
+// Replay applies the operations recorded by a Recorder and read from `r` to
+// `s`. It returns an error if the recording is malformed, or if any recorded
+// data does not match its hash.
+func Replay[T any](r io.Reader, s *Store[T]) error {
+	br := bufio.NewReader(r)
+	for line := 1; ; line++ {
+		text, err := br.ReadString('\n')
+		if err == io.EOF && text == "" {
+			return nil
+		}
+		if err != nil && err != io.EOF {
+			return err
+		}
+
+		if err := replay(strings.Fields(text), s); err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+	}
+}
+
+// replay applies the operation in `fields` to `s`.
+func replay[T any](fields []string, s *Store[T]) error {
+	if len(fields) == 0 {
+		return errors.New("empty operation")
+	}
+
+	op, args := fields[0], fields[1:]
+	// n is the number of integer arguments of the operation. Writes are
+	// followed by the hash and data of their values.
+	n, ok := map[string]int{
+		"set": 2, "set-ttl": 3, "set-priority": 3, "fill": 2,
+		"get": 2, "has": 2, "delete": 2, "evict-lru": 1, "pin": 2, "unpin": 2,
+		"clear": 0, "compact": 0, "expire": 0, "truncate": 1,
+	}[op]
+	if !ok {
+		return fmt.Errorf("unknown operation %q", op)
+	}
+	write := op == "set" || op == "set-ttl" || op == "set-priority" || op == "fill"
+	want := n
+	if write {
+		want += 2
+	}
+	if len(args) != want {
+		return fmt.Errorf("%s: expected %d arguments, got %d", op, want, len(args))
+	}
+
+	ints := make([]int64, n)
+	for i := range ints {
+		var err error
+		if ints[i], err = strconv.ParseInt(args[i], 10, 64); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	var values []T
+	if write {
+		length := ints[n-1]
+		if op == "fill" {
+			length = 1
+		}
+		var err error
+		if values, err = decodeValues[T](args[n], args[n+1], length); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	switch op {
+	case "set":
+		s.Set(values, ints[0])
+	case "set-ttl":
+		s.SetWithTTL(values, ints[0], time.Duration(ints[1]))
+	case "set-priority":
+		s.SetWithPriority(values, ints[0], int(ints[1]))
+	case "fill":
+		s.Fill(values[0], ints[1], ints[0])
+	case "get":
+		if ints[1] < 0 {
+			return fmt.Errorf("get: negative length %d", ints[1])
+		}
+		s.Get(make([]T, ints[1]), ints[0])
+	case "has":
+		s.Has(ints[1], ints[0])
+	case "delete":
+		s.Delete(ints[1], ints[0])
+	case "evict-lru":
+		s.EvictLRU(int(ints[0]))
+	case "pin":
+		s.Pin(ints[1], ints[0])
+	case "unpin":
+		s.Unpin(ints[1], ints[0])
+	case "clear":
+		s.Clear()
+	case "compact":
+		s.Compact()
+	case "expire":
+		s.Expire()
+	case "truncate":
+		return s.Truncate(ints[0])
+	}
+
+	return nil
+}
+
+// decodeValues decodes `length` values from `data`, and checks them against
+// `hash`. The data must hold exactly `length` values.
+func decodeValues[T any](hash, data string, length int64) ([]T, error) {
+	if length < 0 {
+		return nil, fmt.Errorf("negative length %d", length)
+	}
+	var b []byte
+	if data != "-" {
+		var err error
+		if b, err = base64.StdEncoding.DecodeString(data); err != nil {
+			return nil, err
+		}
+	}
+	h := fnv.New64a()
+	h.Write(b)
+	if sum := fmt.Sprintf("%016x", h.Sum64()); sum != hash {
+		return nil, fmt.Errorf("hash mismatch: got %s, recorded %s", sum, hash)
+	}
+
+	// Check the length against the data before allocating it.
+	size := binary.Size(*new(T))
+	if size < 0 {
+		return nil, fmt.Errorf("values of type %T have no fixed size", *new(T))
+	}
+	if size > 0 && (length > int64(len(b)) || length*int64(size) != int64(len(b))) {
+		return nil, fmt.Errorf("%d bytes of data for %d values", len(b), length)
+	}
+	values := make([]T, length)
+	if err := binary.Read(bytes.NewReader(b), binary.LittleEndian, values); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
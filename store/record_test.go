@@ -0,0 +1,105 @@
+package store_test
+
+import (
+	"bytes"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aertje/sparse-store/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStoreRecordReplay(t *testing.T) {
+	opts := []store.Option[uint16]{
+		store.WithMinContiguous[uint16](8),
+		store.WithMaxOccupancy[uint16](100, store.EvictLRU),
+		store.WithLazyCompaction[uint16](4, 64),
+	}
+
+	var buf bytes.Buffer
+	recorder := store.NewRecorder[uint16](&buf)
+	s := store.NewStore(append(opts, store.WithRecorder(recorder))...)
+
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 200; i++ {
+		offset := r.Int63n(200)
+		switch r.Intn(9) {
+		case 0:
+			s.Get(make([]uint16, r.Intn(20)), offset)
+		case 1:
+			s.Has(r.Int63n(20), offset)
+		case 2:
+			s.Delete(r.Int63n(20), offset)
+		case 3:
+			s.Fill(uint16(r.Intn(1000)), r.Int63n(20), offset)
+		case 4:
+			s.SetWithTTL([]uint16{1, 2}, offset, time.Hour)
+		case 5:
+			s.EvictLRU(1)
+		case 6:
+			s.SetWithPriority([]uint16{3, 4, 5}, offset, r.Intn(3))
+		default:
+			p := make([]uint16, r.Intn(20))
+			for j := range p {
+				p[j] = uint16(r.Intn(1000))
+			}
+			s.Set(p, offset)
+		}
+	}
+	require.NoError(t, recorder.Err())
+
+	replayed := store.NewStore(opts...)
+	require.NoError(t, store.Replay(&buf, replayed))
+
+	assert.Equal(t, s.Extents(), replayed.Extents())
+	want, got := make([]uint16, 256), make([]uint16, 256)
+	s.Get(want, 0)
+	replayed.Get(got, 0)
+	assert.Equal(t, want, got)
+}
+
+func TestReplayErrors(t *testing.T) {
+	var buf bytes.Buffer
+	store.NewStore(store.WithRecorder(store.NewRecorder[byte](&buf))).Set([]byte{1, 2}, 0)
+	recorded := buf.String()
+	fields := strings.Fields(recorded)
+
+	s := store.NewStore[byte]()
+	assert.NoError(t, store.Replay(strings.NewReader(recorded), s))
+	assert.Equal(t, []store.Range{{Offset: 0, Length: 2}}, s.Extents())
+
+	corrupt := strings.Join([]string{fields[0], fields[1], fields[2], fields[3], "AQM="}, " ")
+	assert.ErrorContains(t, store.Replay(strings.NewReader(corrupt), s), "line 1: set: hash mismatch")
+	assert.ErrorContains(t, store.Replay(strings.NewReader("get 0\n"), s), "expected 2 arguments")
+	assert.ErrorContains(t, store.Replay(strings.NewReader("clear\nresize 1\n"), s), `line 2: unknown operation "resize"`)
+
+	// Lengths must be positive, and match the data.
+	assert.ErrorContains(t, store.Replay(strings.NewReader("get 0 -1\n"), s), "get: negative length -1")
+	for _, length := range []string{"-1", "5", "1"} {
+		set := strings.Join([]string{fields[0], fields[1], length, fields[3], fields[4]}, " ")
+		assert.Error(t, store.Replay(strings.NewReader(set), s), length)
+	}
+}
+
+func TestStoreRecordTransform(t *testing.T) {
+	var buf bytes.Buffer
+	s := store.NewStore(store.WithRecorder(store.NewRecorder[byte](&buf)))
+	s.Set([]byte{1, 2, 3}, 0)
+	s.Fill(7, 4, 10)
+	require.NoError(t, s.Transform(func(offset int64, data []byte) {
+		for i := range data {
+			data[i] *= 2
+		}
+	}))
+
+	replayed := store.NewStore[byte]()
+	require.NoError(t, store.Replay(&buf, replayed))
+	want, got := make([]byte, 14), make([]byte, 14)
+	s.Get(want, 0)
+	replayed.Get(got, 0)
+	assert.Equal(t, want, got)
+	assert.Equal(t, byte(14), got[10])
+}
@@ -0,0 +1,63 @@
+package store
+
+import (
+	"math/bits"
+	"strings"
+)
+
+// partialBlocks are the blocks for cells that are partially present, from
+// least to most present.
+var partialBlocks = []rune("▁▂▃▄▅▆▇")
+
+// RenderMap renders which values in [Start(), Length()) are present as a bar of
+// `width` cells: █ for cells whose values are all present, · for cells whose
+// values are all missing, and blocks of increasing height in between.
+func (c *Store[T]) RenderMap(width int) string {
+	c.Compact()
+	c.expire()
+
+	var b strings.Builder
+	c.eachCell(width, func(present, size int64) {
+		switch {
+		case present == 0:
+			b.WriteRune('·')
+		case present == size:
+			b.WriteRune('█')
+		default:
+			b.WriteRune(partialBlocks[present*int64(len(partialBlocks))/size])
+		}
+	})
+
+	return b.String()
+}
+
+// eachCell calls `fn` with the number of values present in every cell out of
+// `width`, in order, and the number of values the cell covers.
+func (c *Store[T]) eachCell(width int, fn func(present, size int64)) {
+	i := 0
+	for cell := 0; cell < width; cell++ {
+		from, to := c.cell(cell, width)
+
+		var present int64
+		for ; i < len(c.entries) && c.entries[i].end() <= from; i++ {
+		}
+		for j := i; j < len(c.entries) && c.entries[j].offset < to; j++ {
+			present += min(c.entries[j].end(), to) - max(c.entries[j].offset, from)
+		}
+		fn(present, to-from)
+	}
+}
+
+// cell returns the range of values covered by cell `i` out of `width`. Every
+// cell covers at least one value, so that cells overlap if there are fewer
+// values than cells.
+func (c *Store[T]) cell(i, width int) (int64, int64) {
+	offset := func(i int) int64 {
+		hi, lo := bits.Mul64(uint64(c.length-c.start), uint64(i))
+		q, _ := bits.Div64(hi, lo, uint64(width))
+		return c.start + int64(q)
+	}
+
+	from, to := offset(i), offset(i+1)
+	return from, max(to, from+1)
+}
@@ -0,0 +1,25 @@
+package store_test
+
+import (
+	"testing"
+
+	"github.com/aertje/sparse-store/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStoreRenderMap(t *testing.T) {
+	s := store.NewStore[byte]()
+	assert.Equal(t, "····", s.RenderMap(4))
+
+	s.Set(make([]byte, 10), 0)
+	s.Set(make([]byte, 3), 14)
+	s.Set(make([]byte, 10), 30)
+
+	assert.Equal(t, "█▅▄·▄█", s.RenderMap(6))
+	assert.Equal(t, "█▃·█", s.RenderMap(4))
+	assert.Equal(t, "", s.RenderMap(0))
+
+	small := store.NewStore[byte]()
+	small.Set([]byte{1}, 1)
+	assert.Equal(t, "··██", small.RenderMap(4))
+}
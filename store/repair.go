@@ -0,0 +1,91 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+)
+
+// errNoReadRepair is returned by Verify on caches without read repair.
+var errNoReadRepair = errors.New("store.Cache.Verify: read repair is not configured")
+
+// WithReadRepair makes the cache verify a `sample` fraction, between 0 and 1,
+// of the reads served entirely from the store against the fetcher, as Verify
+// does, so that stale values are found and repaired. Verification fails
+// silently, but the repaired values are returned by the read. `onMismatch`, if
+// not nil, is called with every range of values repaired, with the lock of the
+// cache held.
+func WithReadRepair[T comparable](sample float64, onMismatch func(r Range)) CacheOption[T] {
+	return func(c *Cache[T]) {
+		c.repairSample = sample
+		c.onMismatch = onMismatch
+		c.equal = func(a, b T) bool {
+			return a == b
+		}
+	}
+}
+
+// Verify fetches the `length` values at `offset`, compares them with those the
+// store holds, and overwrites those that differ, which it returns the ranges
+// of. Values the store does not hold are not stored. It requires
+// WithReadRepair, and returns a BoundsError for a negative length.
+func (c *Cache[T]) Verify(ctx context.Context, length, offset int64) ([]Range, error) {
+	_, mismatches, err := c.verify(ctx, length, offset)
+	return mismatches, err
+}
+
+// verify is Verify, and returns the values fetched as well.
+func (c *Cache[T]) verify(ctx context.Context, length, offset int64) ([]T, []Range, error) {
+	if c.equal == nil {
+		return nil, nil, errNoReadRepair
+	}
+	if length < 0 {
+		return nil, nil, &BoundsError{Op: "verify", Offset: offset, Length: length, Err: ErrNegativeLength}
+	}
+
+	cached := make([]T, length)
+	held := make([]bool, length)
+	c.mu.Lock()
+	c.store.GetMask(cached, held, offset)
+	c.mu.Unlock()
+
+	values, err := c.fetcher.Fetch(ctx, offset, length)
+	if int64(len(values)) < length {
+		if err == nil {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, nil, fmt.Errorf("fetching %d values at %d: %w", length, offset, err)
+	}
+	values = values[:length]
+
+	var mismatches []Range
+	for i := range values {
+		if !held[i] || c.equal(values[i], cached[i]) {
+			continue
+		}
+		if n := len(mismatches); n > 0 && mismatches[n-1].End() == offset+int64(i) {
+			mismatches[n-1].Length++
+		} else {
+			mismatches = append(mismatches, Range{Offset: offset + int64(i), Length: 1})
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, r := range mismatches {
+		if err := c.store.Set(values[r.Offset-offset:r.End()-offset], r.Offset); err != nil {
+			return nil, nil, err
+		}
+		if c.onMismatch != nil {
+			c.onMismatch(r)
+		}
+	}
+	return values, mismatches, nil
+}
+
+// sampleRepair reports whether a read served from the store is verified.
+func (c *Cache[T]) sampleRepair() bool {
+	return c.repairSample > 0 && rand.Float64() < c.repairSample
+}
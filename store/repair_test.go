@@ -0,0 +1,67 @@
+package store_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/aertje/sparse-store/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCacheVerify(t *testing.T) {
+	src := &source{}
+	s := store.NewStore[byte]()
+	// Stale values at 12 and 13, and at 16.
+	require.NoError(t, s.Set([]byte{10, 11, 0, 0, 14, 15, 0}, 10))
+	require.NoError(t, s.Set([]byte{30}, 30))
+
+	var reported []store.Range
+	c := store.NewCache(s, src, store.WithReadRepair[byte](0, func(r store.Range) {
+		reported = append(reported, r)
+	}))
+	mismatches, err := c.Verify(context.Background(), 30, 5)
+	require.NoError(t, err)
+	assert.Equal(t, []store.Range{{Offset: 12, Length: 2}, {Offset: 16, Length: 1}}, mismatches)
+	assert.Equal(t, mismatches, reported)
+
+	c.Do(func(s *store.Store[byte]) {
+		p := make([]byte, 7)
+		assert.True(t, s.Get(p, 10))
+		assert.Equal(t, []byte{10, 11, 12, 13, 14, 15, 16}, p)
+		// Values the store does not hold are not stored.
+		assert.Equal(t, int64(8), s.Occupancy())
+	})
+
+	// Sources ending early fail verification.
+	_, err = c.Verify(context.Background(), 10, 95)
+	assert.Error(t, err)
+	_, err = c.Verify(context.Background(), -1, 0)
+	assert.ErrorIs(t, err, store.ErrNegativeLength)
+
+	_, err = store.NewCache(s, src).Verify(context.Background(), 1, 0)
+	assert.Error(t, err)
+}
+
+func TestCacheReadRepair(t *testing.T) {
+	src := &source{}
+	s := store.NewStore[byte]()
+	require.NoError(t, s.Set(bytes.Repeat([]byte{0xff}, 4), 20))
+
+	var reported []store.Range
+	c := store.NewCache(s, src, store.WithReadRepair[byte](1, func(r store.Range) {
+		reported = append(reported, r)
+	}))
+
+	// The read is served from the store, then repaired.
+	p := make([]byte, 2)
+	require.NoError(t, c.Get(context.Background(), p, 21))
+	assert.Equal(t, []byte{21, 22}, p)
+	assert.Equal(t, []store.Range{{Offset: 21, Length: 2}}, reported)
+	assert.Equal(t, []store.Range{{Offset: 21, Length: 2}}, src.fetched)
+
+	// Reads that fetch are not verified.
+	require.NoError(t, c.Get(context.Background(), p, 50))
+	assert.Len(t, src.fetched, 2)
+}
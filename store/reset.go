@@ -0,0 +1,33 @@
+package store
+
+// Reset empties the store for reuse, for instance from a sync.Pool, so that it
+// behaves like a new store with the same options. Unlike Clear, it keeps the
+// memory the store allocated, such as the capacity of its extent table and the
+// blocks of its arena, for the values set next, so values read from it without
+// copying must no longer be used. It also resets the operation counters, the
+// heatmap, any batches and the pinned values.
+func (c *Store[T]) Reset() {
+	defer c.enter("reset")()
+	c.mutate()
+	c.recorder.record("clear")
+	c.empty()
+	if c.arena != nil {
+		c.arena.rewind()
+	}
+
+	c.insertCount = 0
+	c.accessCount = 0
+	c.batches = 0
+	c.evictedUses = 0
+	c.pinned = c.pinned[:0]
+	c.prioritized = false
+	c.expiring = false
+	c.inPressure = false
+	c.backingErr = nil
+	if c.heatmap != nil {
+		c.heatmap.counts = c.heatmap.counts[:0]
+	}
+	c.stats.reset()
+
+	c.publish()
+}
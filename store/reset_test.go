@@ -0,0 +1,70 @@
+package store_test
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/aertje/sparse-store/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReset(t *testing.T) {
+	s := store.NewStore(store.WithArena[byte](1<<10), store.WithHeatmap[byte](4), store.WithInvariantChecks[byte]())
+	require.NoError(t, s.Set([]byte{1, 2, 3}, 0))
+	require.NoError(t, s.Set([]byte{4, 5}, 8))
+	s.Get(make([]byte, 3), 0)
+	s.BeginBatch()
+
+	s.Reset()
+	assert.Zero(t, s.Length())
+	assert.Zero(t, s.Occupancy())
+	assert.Empty(t, s.Extents())
+	assert.Equal(t, store.Stats{}, s.Stats())
+	assert.Empty(t, s.Heatmap())
+
+	// The store is usable as new, outside of a batch.
+	require.NoError(t, s.Set([]byte{6, 7}, 2))
+	assert.Equal(t, []store.Range{{Offset: 2, Length: 2}}, s.Extents())
+	p := make([]byte, 2)
+	assert.True(t, s.Get(p, 2))
+	assert.Equal(t, []byte{6, 7}, p)
+
+	require.NoError(t, s.Freeze())
+	assert.PanicsWithValue(t, store.ErrFrozen, s.Reset)
+}
+
+func TestResetReusesArena(t *testing.T) {
+	s := store.NewStore(store.WithArena[byte](1 << 16))
+	chunk := make([]byte, 100)
+	fill := func() {
+		for offset := int64(0); offset < 1<<16; offset += 200 {
+			s.Set(chunk, offset)
+		}
+	}
+	fill()
+
+	// Neither the blocks of the arena nor the extent table are allocated again.
+	allocs := testing.AllocsPerRun(10, func() {
+		s.Reset()
+		fill()
+	})
+	assert.Zero(t, allocs)
+	assert.Equal(t, int64(32800), s.Occupancy())
+}
+
+func TestResetPool(t *testing.T) {
+	pool := sync.Pool{New: func() any {
+		return store.NewStore[int](store.WithArena[int](1 << 10))
+	}}
+	for i := 0; i < 10; i++ {
+		s := pool.Get().(*store.Store[int])
+		require.NoError(t, s.Set([]int{i, i}, int64(i)))
+		p := make([]int, 2)
+		assert.True(t, s.Get(p, int64(i)))
+		assert.Equal(t, []int{i, i}, p)
+		assert.Equal(t, int64(i+2), s.Length())
+		s.Reset()
+		pool.Put(s)
+	}
+}
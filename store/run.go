@@ -0,0 +1,111 @@
+package store
+
+// size returns the number of values in the entry.
+func (e entry[T]) size() int64 {
+	if e.run {
+		return e.runLength
+	}
+	return int64(len(e.data))
+}
+
+// slice returns the part of the entry between the absolute offsets `from` and
+// `to`.
+func (e entry[T]) slice(from, to int64) entry[T] {
+	if e.run {
+		e.runLength = to - from
+	} else {
+		e.data = e.data[from-e.offset : to-e.offset]
+	}
+	e.offset = from
+	return e
+}
+
+// read copies the part of the entry that overlaps `p`, which holds the values
+// from `offset` onwards, into `p`.
+func (e entry[T]) read(p []T, offset int64) {
+	from := max(e.offset, offset)
+	to := min(e.end(), endOf(int64(len(p)), offset))
+	if from >= to {
+		return
+	}
+
+	dst := p[from-offset : to-offset]
+	if e.run {
+		for i := range dst {
+			dst[i] = e.value
+		}
+		return
+	}
+	copy(dst, e.data[from-e.offset:])
+}
+
+// Fill sets the `length` values at `offset` to `value`. The run is stored in
+// constant space, no matter its length, but counts towards the byte budget as
+// if it were not.
+func (c *Store[T]) Fill(value T, length, offset int64) error {
+	return c.set(entry[T]{offset: offset, run: true, runLength: length, value: value})
+}
+
+// WithZeroRuns makes Set store runs of at least `minRun` zero values the way
+// Fill does, in constant space.
+func WithZeroRuns[T comparable](minRun int) Option[T] {
+	return func(c *Store[T]) {
+		var zero T
+		c.minZeroRun = minRun
+		c.isZero = func(v T) bool {
+			return v == zero
+		}
+	}
+}
+
+// splitZeroRuns calls `set` for the parts of `e` that are runs of at least
+// minZeroRun zero values, as runs, and for the data in between. The parts share
+// the backing array of `e`, so none of them owns it.
+func (c *Store[T]) splitZeroRuns(e entry[T], set func(entry[T])) {
+	data := e.data
+	e.owned = false
+
+	start := 0
+	for i := 0; i < len(data); {
+		if !c.isZero(data[i]) {
+			i++
+			continue
+		}
+
+		j := i + 1
+		for j < len(data) && c.isZero(data[j]) {
+			j++
+		}
+		if j-i < c.minZeroRun {
+			i = j
+			continue
+		}
+
+		if start < i {
+			part := e
+			part.offset = e.offset + int64(start)
+			part.data = data[start:i:i]
+			set(part)
+		}
+
+		run := e
+		run.offset = e.offset + int64(i)
+		run.data = nil
+		run.run = true
+		run.runLength = int64(j - i)
+		set(run)
+
+		start = j
+		i = j
+	}
+
+	if start == 0 {
+		// There were no runs, so it remains a single entry.
+		set(e)
+	} else if start < len(data) {
+		part := e
+		part.offset = e.offset + int64(start)
+		part.data = data[start:]
+		set(part)
+	}
+}
@@ -0,0 +1,40 @@
+package store_test
+
+import (
+	"testing"
+
+	"github.com/aertje/sparse-store/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStoreFill(t *testing.T) {
+	s := store.NewStore[byte]()
+
+	s.Fill(7, 1<<40, 0)
+	s.Set([]byte{1, 2}, 1)
+	s.Fill(8, 2, 4)
+
+	assert.Equal(t, int64(1<<40), s.Length())
+	assert.Equal(t, int64(1<<40), s.Occupancy())
+
+	data := make([]byte, 8)
+	assert.True(t, s.Get(data, 0))
+	assert.Equal(t, []byte{7, 1, 2, 7, 8, 8, 7, 7}, data)
+
+	assert.True(t, s.Get(data, 1<<40-8))
+	assert.Equal(t, []byte{7, 7, 7, 7, 7, 7, 7, 7}, data)
+}
+
+func TestStoreZeroRuns(t *testing.T) {
+	s := store.NewStore(store.WithZeroRuns[int](3), store.WithMinContiguous[int](1))
+
+	in := []int{1, 0, 0, 0, 0, 2, 0, 0, 3, 0, 0, 0}
+	s.Set(in, 2)
+	s.Set([]int{4}, 4)
+
+	assert.Equal(t, int64(12), s.Occupancy())
+	data := make([]int, 14)
+	assert.False(t, s.Get(data, 0))
+	assert.Equal(t, []int{0, 0, 1, 0, 4, 0, 0, 2, 0, 0, 3, 0, 0, 0}, data)
+	assert.True(t, s.Has(12, 2))
+}
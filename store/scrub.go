@@ -0,0 +1,285 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"hash/crc32"
+	"math"
+	"sync"
+	"time"
+)
+
+// castagnoli is the table of the CRC-32C checksums of blocks.
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// scrubInterval is the interval at which a Scrubber scrubs a slice of its
+// bandwidth.
+const scrubInterval = 100 * time.Millisecond
+
+// WithChecksums makes the store keep a CRC-32C checksum of every block of
+// `blockSize` values holding any, for Scrub to detect values that changed
+// without being set, such as slices given to Set that were modified later, or
+// memory corruption in stores that live for weeks. The checksums of the blocks
+// a write overlaps are computed when it is made, or, in lazy mode and during
+// batches, when it is compacted, so values modified before then go undetected.
+// It panics if `blockSize` is not positive.
+func WithChecksums(blockSize int64) Option[byte] {
+	if blockSize <= 0 {
+		panic(fmt.Sprintf("store: WithChecksums: invalid block size %d", blockSize))
+	}
+	return func(c *Store[byte]) {
+		c.checksums = &checksums[byte]{
+			blockSize: blockSize,
+			sum: func(p []byte) uint32 {
+				return crc32.Checksum(p, castagnoli)
+			},
+			sums: map[int64]uint32{},
+			next: math.MinInt64,
+		}
+	}
+}
+
+// checksums holds the checksums of the blocks of a store.
+type checksums[T any] struct {
+	blockSize int64
+	sum       func(p []T) uint32
+	// sums holds the checksums of the blocks, by block index.
+	sums map[int64]uint32
+	// stale holds the values written or removed since the checksums were last
+	// computed.
+	stale rangeSet
+	// next is the offset the next scrub starts from.
+	next int64
+}
+
+// block returns the index of the block holding the value at `offset`.
+func (cs *checksums[T]) block(offset int64) int64 {
+	b := offset / cs.blockSize
+	if offset < 0 && offset%cs.blockSize != 0 {
+		b--
+	}
+	return b
+}
+
+// forget forgets the checksums of the blocks overlapping the `length` values
+// at `offset`.
+func (cs *checksums[T]) forget(offset, length int64) {
+	first, last := cs.block(offset), cs.block(offset+length-1)
+	if last-first >= int64(len(cs.sums)) {
+		for b := range cs.sums {
+			if b >= first && b <= last {
+				delete(cs.sums, b)
+			}
+		}
+		return
+	}
+	for b := first; b <= last; b++ {
+		delete(cs.sums, b)
+	}
+}
+
+// unseal records that the `length` values at `offset` were written or
+// removed, for seal to compute the checksums of their blocks again, if the
+// store keeps checksums.
+func (c *Store[T]) unseal(offset, length int64) {
+	if c.checksums != nil && length > 0 {
+		c.checksums.stale.add(offset, offset+length)
+	}
+}
+
+// seal computes the checksums of the blocks holding values written or removed
+// since it was last called, once they are all compacted.
+func (c *Store[T]) seal() {
+	cs := c.checksums
+	if cs == nil || len(cs.stale) == 0 || len(c.pending) > 0 {
+		return
+	}
+
+	buf := make([]T, cs.blockSize)
+	for _, r := range cs.stale {
+		cs.forget(r.Offset, r.Length)
+		for offset := r.Offset; ; {
+			b, ok := c.nextBlock(offset)
+			if !ok || b*cs.blockSize >= r.End() {
+				break
+			}
+			cs.sums[b], _, _ = c.sumBlock(b, buf)
+			offset = (b + 1) * cs.blockSize
+		}
+	}
+	cs.stale = cs.stale[:0]
+}
+
+// ScrubBudget limits the work of a call to Scrub. Zero values disable the
+// corresponding limit.
+type ScrubBudget struct {
+	// Values is the number of values the blocks scrubbed may span.
+	Values int64
+	// Duration is the time Scrub may take. It is checked between blocks.
+	Duration time.Duration
+}
+
+// ScrubReport is the outcome of a call to Scrub.
+type ScrubReport struct {
+	// Values is the number of values the blocks scrubbed span.
+	Values int64
+	// Verified is the number of blocks verified.
+	Verified int
+	// Corrupt holds the ranges of the values of the blocks that did not match
+	// their checksum, which were deleted.
+	Corrupt []Range
+}
+
+// Scrub verifies the blocks holding values against their checksum, in order
+// from the block the previous call stopped at, until `budget` is spent or every block holding
+// values was scrubbed once. The values of blocks that do not match are deleted,
+// so that a Cache fetches them again, and reported. Blocks that would exceed
+// the budget are left for the next call, unless nothing was scrubbed yet, so
+// that every call makes progress. Scrub panics unless WithChecksums is used.
+func (c *Store[T]) Scrub(budget ScrubBudget) ScrubReport {
+	defer c.enter("scrub")()
+	c.mutate()
+	cs := c.checksums
+	if cs == nil {
+		panic("store: Scrub without WithChecksums")
+	}
+	c.Compact()
+	c.expire()
+
+	var report ScrubReport
+	start := c.now()
+	buf := make([]T, cs.blockSize)
+	var first int64
+	wrapped := false
+	for n := 0; ; n++ {
+		if budget.Duration > 0 && n > 0 && c.now().Sub(start) >= budget.Duration {
+			break
+		}
+		if budget.Values > 0 && n > 0 && report.Values+cs.blockSize > budget.Values {
+			break
+		}
+
+		b, ok := c.nextBlock(cs.next)
+		if !ok && cs.next != math.MinInt64 {
+			cs.next = math.MinInt64
+			b, ok = c.nextBlock(cs.next)
+			wrapped = true
+		}
+		if !ok || (wrapped && n > 0 && b >= first) {
+			break
+		}
+		if n == 0 {
+			first = b
+		}
+		c.scrubBlock(b, buf, &report)
+		cs.next = (b + 1) * cs.blockSize
+	}
+	return report
+}
+
+// nextBlock returns the index of the first block from `offset` on holding
+// values, if there is one.
+func (c *Store[T]) nextBlock(offset int64) (int64, bool) {
+	i := c.entries.first(offset)
+	for i < len(c.entries) && c.entries[i].end() <= offset {
+		i++
+	}
+	if i == len(c.entries) {
+		return 0, false
+	}
+	return c.checksums.block(max(offset, c.entries[i].offset)), true
+}
+
+// sumBlock returns the checksum of block `b`, reading it into `buf`, and the
+// range of its values from the first to the last.
+func (c *Store[T]) sumBlock(b int64, buf []T) (sum uint32, lo, hi int64) {
+	cs := c.checksums
+	from, to := b*cs.blockSize, (b+1)*cs.blockSize
+
+	// Missing values read as zero values, as the checksum of a block is
+	// computed again whenever values are added to or removed from it.
+	clear(buf)
+	lo, hi = to, from
+	for i := c.entries.first(from); i < len(c.entries) && c.entries[i].offset < to; i++ {
+		e := c.entries[i]
+		e.read(buf, from)
+		lo, hi = min(lo, max(e.offset, from)), max(hi, min(e.end(), to))
+	}
+	return cs.sum(buf), lo, hi
+}
+
+// scrubBlock verifies block `b`, reading it into `buf`, and adds the outcome to
+// `report`.
+func (c *Store[T]) scrubBlock(b int64, buf []T, report *ScrubReport) {
+	cs := c.checksums
+	report.Values += cs.blockSize
+	report.Verified++
+
+	sum, lo, hi := c.sumBlock(b, buf)
+	expected, ok := cs.sums[b]
+	if !ok {
+		// Blocks always have a checksum once compacted, but a missing one is
+		// not taken for corruption.
+		cs.sums[b] = sum
+		return
+	}
+	if sum != expected {
+		if c.debugging() {
+			c.debug("corrupt block", "offset", b*cs.blockSize, "length", cs.blockSize)
+		}
+		c.Delete(hi-lo, lo)
+		report.Corrupt = append(report.Corrupt, Range{Offset: lo, Length: hi - lo})
+	}
+}
+
+// Scrubber scrubs a store in the background, at a bandwidth low enough not to
+// compete with its users. Stores that live for weeks can so detect corrupt
+// values before they are read.
+type Scrubber[T any] struct {
+	store     *Store[T]
+	mu        sync.Locker
+	bandwidth int64
+	onCorrupt func(Range)
+}
+
+// NewScrubber returns a scrubber scrubbing `bandwidth` values of `s` per
+// second, with `mu`, the lock its users hold, locked during every scrub.
+// `onCorrupt`, if not nil, is called without the lock held with the ranges of
+// the corrupt values deleted, for instance to fetch them again.
+func NewScrubber[T any](s *Store[T], mu sync.Locker, bandwidth int64, onCorrupt func(Range)) *Scrubber[T] {
+	return &Scrubber[T]{store: s, mu: mu, bandwidth: bandwidth, onCorrupt: onCorrupt}
+}
+
+// Run scrubs the store, a slice of the bandwidth at a time, until `ctx` is
+// done, and returns its error. Values scrubbed beyond the bandwidth, as whole
+// blocks are, are made up for by pausing.
+func (sc *Scrubber[T]) Run(ctx context.Context) error {
+	ticker := time.NewTicker(scrubInterval)
+	defer ticker.Stop()
+
+	perTick := max(sc.bandwidth*int64(scrubInterval)/int64(time.Second), 1)
+	var allowance int64
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		// Allowance left unused, for lack of blocks to scrub, is not saved.
+		allowance = min(allowance+perTick, perTick)
+		if allowance <= 0 {
+			continue
+		}
+		sc.mu.Lock()
+		report := sc.store.Scrub(ScrubBudget{Values: allowance})
+		sc.mu.Unlock()
+		allowance -= report.Values
+
+		if sc.onCorrupt != nil {
+			for _, r := range report.Corrupt {
+				sc.onCorrupt(r)
+			}
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package store_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aertje/sparse-store/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStoreScrub(t *testing.T) {
+	s := store.NewStore(store.WithChecksums(4))
+	p := []byte("abcdefghij")
+	require.NoError(t, s.Set(p, 0))
+	require.NoError(t, s.Set([]byte("xy"), 20))
+
+	// Values modified behind the back of the store are corrupt, even before
+	// the first scrub, and blocks holding values partly only span those
+	// values.
+	p[5] = 'F'
+	p[9] = 'J'
+	report := s.Scrub(store.ScrubBudget{})
+	assert.Equal(t, int64(16), report.Values)
+	assert.Equal(t, 4, report.Verified)
+	assert.Equal(t, []store.Range{{Offset: 4, Length: 4}, {Offset: 8, Length: 2}}, report.Corrupt)
+	assert.Equal(t, int64(6), s.Occupancy())
+	assert.False(t, s.Has(1, 5))
+
+	// Values set, deleted or transformed are checksummed again.
+	require.NoError(t, s.Set([]byte("Y"), 21))
+	require.NoError(t, s.Set([]byte("mnop"), 8))
+	s.Delete(1, 0)
+	require.NoError(t, s.Transform(func(offset int64, data []byte) {
+		data[0]++
+	}))
+	assert.Equal(t, store.ScrubReport{Values: 12, Verified: 3}, s.Scrub(store.ScrubBudget{}))
+}
+
+func TestStoreScrubLazy(t *testing.T) {
+	s := store.NewStore(store.WithChecksums(4), store.WithLazyCompaction[byte](100, 1000))
+	p := []byte("abcdefgh")
+	require.NoError(t, s.Set(p, 0))
+
+	// Values are checksummed when compacted.
+	p[1] = 'B'
+	s.Compact()
+	p[5] = 'F'
+	report := s.Scrub(store.ScrubBudget{})
+	assert.Equal(t, []store.Range{{Offset: 4, Length: 4}}, report.Corrupt)
+	assert.True(t, s.Has(4, 0))
+}
+
+func TestStoreScrubBudget(t *testing.T) {
+	s := store.NewStore(store.WithChecksums(4))
+	require.NoError(t, s.Set(make([]byte, 16), 0))
+
+	// Every call makes progress, and resumes where the previous one stopped,
+	// wrapping around at the end.
+	assert.Equal(t, int64(4), s.Scrub(store.ScrubBudget{Values: 1}).Values)
+	assert.Equal(t, int64(8), s.Scrub(store.ScrubBudget{Values: 8}).Values)
+	assert.Equal(t, store.ScrubReport{Values: 8, Verified: 2}, s.Scrub(store.ScrubBudget{Values: 8}))
+	assert.Equal(t, store.ScrubReport{Values: 16, Verified: 4}, s.Scrub(store.ScrubBudget{}))
+
+	s.Reset()
+	assert.Equal(t, store.ScrubReport{}, s.Scrub(store.ScrubBudget{}))
+	assert.Panics(t, func() { store.NewStore[byte]().Scrub(store.ScrubBudget{}) })
+	assert.Panics(t, func() { store.WithChecksums(0) })
+}
+
+func TestScrubber(t *testing.T) {
+	s := store.NewStore(store.WithChecksums(4))
+	p := make([]byte, 8)
+	require.NoError(t, s.Set(p, 0))
+	var mu sync.Mutex
+	p[0] = 1
+
+	corrupt := make(chan store.Range, 1)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	done := make(chan error)
+	go func() {
+		done <- store.NewScrubber(s, &mu, 1000, func(r store.Range) {
+			corrupt <- r
+		}).Run(ctx)
+	}()
+
+	select {
+	case r := <-corrupt:
+		assert.Equal(t, store.Range{Offset: 0, Length: 4}, r)
+	case <-ctx.Done():
+		t.Fatal("corruption not detected")
+	}
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, int64(4), s.Occupancy())
+}
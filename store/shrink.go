@@ -0,0 +1,44 @@
+package store
+
+// Shrink reallocates the data of extents that retain more than twice the
+// capacity they need, which happens when they are partially overwritten, and
+// trims the spare capacity of the store's index. It returns the capacity
+// released, in values. Only the capacity visible to the store is considered:
+// the part of a slice given to Set that precedes it is not.
+func (c *Store[T]) Shrink() int64 {
+	defer c.enter("shrink")()
+	c.mutate()
+	c.Compact()
+
+	var released int64
+	for i := range c.entries {
+		e := &c.entries[i]
+		if e.backing <= 2*len(e.data) {
+			continue
+		}
+
+		// Allocate exactly, rather than from the pool or arena, which would
+		// retain spare capacity again.
+		data := make([]T, len(e.data))
+		copy(data, e.data)
+
+		released += int64(e.backing - len(data))
+		if e.shared != nil {
+			c.release(*e)
+			e.shared = nil
+		}
+		e.data = data
+		e.owned = true
+		e.backing = len(data)
+	}
+
+	if cap(c.entries) > 2*len(c.entries) {
+		c.entries = append(entries[T](nil), c.entries...)
+	}
+	if len(c.pending) == 0 {
+		c.pending = nil
+	}
+	c.publish()
+
+	return released
+}
@@ -0,0 +1,26 @@
+package store_test
+
+import (
+	"testing"
+
+	"github.com/aertje/sparse-store/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStoreShrink(t *testing.T) {
+	s := store.NewStore(store.WithMinContiguous[byte](1))
+
+	s.Set(make([]byte, 100), 0)
+	s.Set([]byte{1, 2}, 200)
+	// Overwriting most of the first extent leaves its tail retaining the
+	// whole slice.
+	s.Set(make([]byte, 90), 0)
+
+	assert.Equal(t, int64(90), s.Shrink())
+	assert.Equal(t, int64(0), s.Shrink())
+
+	data := make([]byte, 2)
+	assert.True(t, s.Get(data, 200))
+	assert.Equal(t, []byte{1, 2}, data)
+	assert.Equal(t, int64(102), s.Occupancy())
+}
@@ -0,0 +1,62 @@
+package store_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/aertje/sparse-store/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStoreNegativeOffsets(t *testing.T) {
+	for name, opts := range map[string][]store.Option[int32]{
+		"eager":    {store.WithMinContiguous[int32](1)},
+		"lazy":     {store.WithMinContiguous[int32](1), store.WithLazyCompaction[int32](10, 100)},
+		"bitmap":   {store.WithPresenceBitmap[int32]()},
+		"dedup":    {store.WithDedup[int32](4)},
+		"codec":    {store.WithCodec[int32](store.GobCodec[int32]{})},
+		"zeroRuns": {store.WithZeroRuns[int32](2)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			s := store.NewStore(opts...)
+			require.NoError(t, s.Set([]int32{1, 2, 3, 4, 5, 6, 7, 8, 9}, -5))
+			require.NoError(t, s.Set([]int32{0, 0, 0}, -20))
+			s.Delete(2, -4)
+
+			assert.Equal(t, int64(-20), s.Start())
+			assert.Equal(t, int64(4), s.Length())
+			assert.Equal(t, int64(10), s.Occupancy())
+			assert.True(t, s.Has(4, -2))
+			assert.False(t, s.Has(4, -5))
+			assert.Equal(t, int64(3), s.Coverage(5, -5))
+			assert.Equal(t, []store.Range{{Offset: -17, Length: 12}, {Offset: -4, Length: 2}}, s.Gaps(18, -19))
+
+			data := make([]int32, 7)
+			assert.False(t, s.Get(data, -5))
+			assert.Equal(t, []int32{1, 0, 0, 4, 5, 6, 7}, data)
+
+			var buf bytes.Buffer
+			_, err := s.WriteTo(&buf)
+			require.NoError(t, err)
+			read := store.NewStore(opts...)
+			_, err = read.ReadFrom(&buf)
+			require.NoError(t, err)
+			assert.Equal(t, s.Extents(), read.Extents())
+		})
+	}
+}
+
+func TestStoreSignedOffsets(t *testing.T) {
+	s := store.NewStore(store.WithStrictBounds[byte](), store.WithSignedOffsets[byte]())
+	require.NoError(t, s.Set([]byte{1, 2}, -1))
+	assert.ErrorIs(t, s.Fill(1, -1, -10), store.ErrNegativeLength)
+
+	has, err := s.Checked().Has(2, -1)
+	require.NoError(t, err)
+	assert.True(t, has)
+
+	require.NoError(t, s.Set([]byte{3, 4}, -5))
+	assert.Equal(t, int64(-5), s.Start())
+	assert.Equal(t, "██··██", s.RenderMap(6))
+}
@@ -0,0 +1,88 @@
+package store
+
+import "io"
+
+// Snapshot freezes the store, as Freeze does, and returns a view of it that
+// only has the read methods, to hand to consumers that must not modify it.
+func (c *Store[T]) Snapshot() (SnapshotView[T], error) {
+	if err := c.Freeze(); err != nil {
+		return SnapshotView[T]{}, err
+	}
+	return SnapshotView[T]{store: c}, nil
+}
+
+// SnapshotView holds the reads of a frozen store. It can be used from multiple
+// goroutines without locking.
+type SnapshotView[T any] struct {
+	store *Store[T]
+}
+
+// Length is like Store.Length.
+func (v SnapshotView[T]) Length() int64 {
+	return v.store.Length()
+}
+
+// Start is like Store.Start.
+func (v SnapshotView[T]) Start() int64 {
+	return v.store.Start()
+}
+
+// Occupancy is like Store.Occupancy.
+func (v SnapshotView[T]) Occupancy() int64 {
+	return v.store.Occupancy()
+}
+
+// Has is like Store.Has.
+func (v SnapshotView[T]) Has(length, offset int64) bool {
+	return v.store.Has(length, offset)
+}
+
+// HasAll is like Store.HasAll.
+func (v SnapshotView[T]) HasAll(ranges ...Range) bool {
+	return v.store.HasAll(ranges...)
+}
+
+// HasWhich is like Store.HasWhich.
+func (v SnapshotView[T]) HasWhich(ranges ...Range) []bool {
+	return v.store.HasWhich(ranges...)
+}
+
+// Coverage is like Store.Coverage.
+func (v SnapshotView[T]) Coverage(length, offset int64) int64 {
+	return v.store.Coverage(length, offset)
+}
+
+// Get is like Store.Get.
+func (v SnapshotView[T]) Get(p []T, offset int64) bool {
+	return v.store.Get(p, offset)
+}
+
+// GetPrefix is like Store.GetPrefix.
+func (v SnapshotView[T]) GetPrefix(p []T, offset int64) int {
+	return v.store.GetPrefix(p, offset)
+}
+
+// GetMask is like Store.GetMask.
+func (v SnapshotView[T]) GetMask(p []T, mask []bool, offset int64) int {
+	return v.store.GetMask(p, mask, offset)
+}
+
+// Gaps is like Store.Gaps.
+func (v SnapshotView[T]) Gaps(length, offset int64) []Range {
+	return v.store.Gaps(length, offset)
+}
+
+// Extents is like Store.Extents.
+func (v SnapshotView[T]) Extents() []Range {
+	return v.store.Extents()
+}
+
+// FindFunc is like Store.FindFunc.
+func (v SnapshotView[T]) FindFunc(offset int64, fn func(T) bool) (int64, bool) {
+	return v.store.FindFunc(offset, fn)
+}
+
+// WriteTo is like Store.WriteTo.
+func (v SnapshotView[T]) WriteTo(w io.Writer) (int64, error) {
+	return v.store.WriteTo(w)
+}
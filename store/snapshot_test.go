@@ -0,0 +1,42 @@
+package store_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/aertje/sparse-store/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStoreSnapshot(t *testing.T) {
+	s := store.NewStore(store.WithLazyCompaction[byte](10, 100))
+	require.NoError(t, s.Set([]byte{1, 2, 3}, 2))
+	require.NoError(t, s.Set([]byte{4}, 8))
+
+	v, err := s.Snapshot()
+	require.NoError(t, err)
+	assert.True(t, s.Frozen())
+
+	assert.Equal(t, int64(9), v.Length())
+	assert.Equal(t, int64(4), v.Occupancy())
+	assert.True(t, v.Has(3, 2))
+	assert.Equal(t, int64(2), v.Coverage(5, 4))
+	assert.Equal(t, []store.Range{{Offset: 2, Length: 3}, {Offset: 8, Length: 1}}, v.Extents())
+	assert.Equal(t, []store.Range{{Offset: 5, Length: 3}}, v.Gaps(6, 3))
+
+	data := make([]byte, 4)
+	assert.False(t, v.Get(data, 2))
+	assert.Equal(t, 3, v.GetPrefix(data, 2))
+	offset, ok := v.FindFunc(0, func(b byte) bool { return b > 3 })
+	assert.True(t, ok)
+	assert.Equal(t, int64(8), offset)
+
+	var buf bytes.Buffer
+	_, err = v.WriteTo(&buf)
+	require.NoError(t, err)
+	restored := store.NewStore[byte]()
+	_, err = restored.ReadFrom(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, v.Extents(), restored.Extents())
+}
@@ -0,0 +1,143 @@
+package store
+
+import (
+	"encoding/json"
+	"sync/atomic"
+)
+
+// Stats holds counters of the operations on a store, along with its size as of
+// the last operation.
+type Stats struct {
+	Sets int64
+
+	// Gets is the number of calls to Get, of which Hits found all values
+	// present and Misses did not.
+	Gets   int64
+	Hits   int64
+	Misses int64
+	// Served is the number of values Get copied out, including those of
+	// misses.
+	Served int64
+
+	// Has is the number of calls to Has, of which HasHits found all values
+	// present and HasMisses did not.
+	Has       int64
+	HasHits   int64
+	HasMisses int64
+
+	Occupancy int64
+	Extents   int64
+}
+
+// statsJSON is Stats with the names of its fields in JSON.
+type statsJSON struct {
+	Sets      int64 `json:"sets"`
+	Gets      int64 `json:"gets"`
+	Hits      int64 `json:"hits"`
+	Misses    int64 `json:"misses"`
+	Served    int64 `json:"served"`
+	Has       int64 `json:"has"`
+	HasHits   int64 `json:"has_hits"`
+	HasMisses int64 `json:"has_misses"`
+	Occupancy int64 `json:"occupancy"`
+	Extents   int64 `json:"extents"`
+}
+
+// MarshalJSON encodes the stats as a JSON object with the names storeexpvar
+// publishes them under, such as "has_hits", which are kept stable.
+func (s Stats) MarshalJSON() ([]byte, error) {
+	return json.Marshal(statsJSON(s))
+}
+
+// UnmarshalJSON decodes stats encoded by MarshalJSON.
+func (s *Stats) UnmarshalJSON(data []byte) error {
+	var j statsJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	*s = Stats(j)
+	return nil
+}
+
+// stats holds the counters behind Stats. They are atomic so that Stats can be
+// called concurrently with the other methods, for example by a metrics
+// endpoint.
+type stats struct {
+	sets   atomic.Int64
+	gets   atomic.Int64
+	hits   atomic.Int64
+	misses atomic.Int64
+	served atomic.Int64
+
+	has       atomic.Int64
+	hasHits   atomic.Int64
+	hasMisses atomic.Int64
+
+	occupancy atomic.Int64
+	extents   atomic.Int64
+}
+
+// Stats returns the operation counters of the store. Unlike the other methods,
+// it is safe to call concurrently with them. In lazy mode and during batches,
+// the occupancy and number of extents are those as of the last compaction.
+func (c *Store[T]) Stats() Stats {
+	return Stats{
+		Sets:      c.stats.sets.Load(),
+		Gets:      c.stats.gets.Load(),
+		Hits:      c.stats.hits.Load(),
+		Misses:    c.stats.misses.Load(),
+		Served:    c.stats.served.Load(),
+		Has:       c.stats.has.Load(),
+		HasHits:   c.stats.hasHits.Load(),
+		HasMisses: c.stats.hasMisses.Load(),
+		Occupancy: c.stats.occupancy.Load(),
+		Extents:   c.stats.extents.Load(),
+	}
+}
+
+// reset zeroes the counters.
+func (s *stats) reset() {
+	for _, n := range []*atomic.Int64{
+		&s.sets, &s.gets, &s.hits, &s.misses, &s.served,
+		&s.has, &s.hasHits, &s.hasMisses, &s.occupancy, &s.extents,
+	} {
+		n.Store(0)
+	}
+}
+
+// publish updates the size of the store reported by Stats, after a mutation,
+// computes the checksums of the blocks it changed with WithChecksums, and
+// checks the invariants with WithInvariantChecks.
+func (c *Store[T]) publish() {
+	c.seal()
+	c.stats.occupancy.Store(c.occupancy)
+	c.stats.extents.Store(int64(len(c.entries)))
+	c.checkInvariants()
+}
+
+// countGet counts a call to Get for `length` values, of which `served` were
+// copied out, and whether it was a hit.
+func (c *Store[T]) countGet(length, served int64, hit bool) bool {
+	if c.instrumentation != nil {
+		c.instrumentation.Get(length, hit)
+	}
+	c.stats.gets.Add(1)
+	c.stats.served.Add(served)
+	if hit {
+		c.stats.hits.Add(1)
+	} else {
+		c.stats.misses.Add(1)
+	}
+	return hit
+}
+
+// countHas counts a call to Has, and whether it was a hit.
+func (c *Store[T]) countHas(hit bool) bool {
+	c.stats.has.Add(1)
+	if hit {
+		c.stats.hasHits.Add(1)
+	} else {
+		c.stats.hasMisses.Add(1)
+	}
+	return hit
+}
@@ -0,0 +1,39 @@
+package store_test
+
+import (
+	"testing"
+
+	"github.com/aertje/sparse-store/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStoreStats(t *testing.T) {
+	s := store.NewStore(store.WithMinContiguous[byte](1))
+
+	s.Set([]byte{0, 1}, 0)
+	s.Set([]byte{2, 3}, 4)
+
+	data := make([]byte, 2)
+	s.Get(data, 0)
+	s.Get(data, 1)
+	s.Get(data, 4)
+	s.Has(2, 4)
+	s.Has(3, 4)
+
+	assert.Equal(t, store.Stats{
+		Sets:      2,
+		Gets:      3,
+		Hits:      2,
+		Misses:    1,
+		Served:    5,
+		Has:       2,
+		HasHits:   1,
+		HasMisses: 1,
+		Occupancy: 4,
+		Extents:   2,
+	}, s.Stats())
+
+	s.Clear()
+	assert.Equal(t, int64(0), s.Stats().Occupancy)
+	assert.Equal(t, int64(0), s.Stats().Extents)
+}
@@ -1,17 +1,73 @@
 package store
 
 import (
-	"sort"
-
+	"cmp"
+	"container/heap"
+	"log/slog"
+	"math"
 	"slices"
+	"sort"
+	"time"
 )
 
 const defaultMinContiguous = 16 << 10 // 16 Ki
 
+const (
+	// expectedExtents is the number of extents a store with an expected
+	// length is tuned to hold once complete.
+	expectedExtents = 1 << 10
+	// maxExpectedMinContiguous bounds the merge threshold derived from an
+	// expected length.
+	maxExpectedMinContiguous = 16 << 20 // 16 Mi
+)
+
 type entry[T any] struct {
 	order  int
 	offset int64
 	data   []T
+
+	// priority takes precedence over order in deciding which of overlapping
+	// entries holds the values.
+	priority int
+
+	// accessed is the access tick at which the entry was last set or read.
+	accessed int
+	// uses is the number of times the entry was set or read, plus the uses of
+	// the entry evicted last when it was set.
+	uses int
+	// reads is the number of times the entry was read since it was set.
+	reads int
+	// expires is the time after which the entry is stale, or zero if it never
+	// is.
+	expires time.Time
+	// owned is set if data was allocated by the store and is not referenced
+	// elsewhere, so that it can be modified or recycled.
+	owned bool
+	// backing is the capacity of the slice data was originally resliced from,
+	// as far as it is visible.
+	backing int
+
+	// run is set for entries that hold runLength repetitions of value rather
+	// than data.
+	run       bool
+	runLength int64
+	value     T
+
+	// shared is the block holding data, if it is shared with other entries
+	// as configured with WithDedup.
+	shared *dedupBlock[T]
+}
+
+func (e entry[T]) end() int64 {
+	return e.offset + e.size()
+}
+
+// overrides reports whether `e` holds the values where it overlaps `other`.
+func (e entry[T]) overrides(other entry[T]) bool {
+	if e.priority != other.priority {
+		return e.priority > other.priority
+	}
+	return e.order > other.order
 }
 
 type entries[T any] []entry[T]
@@ -22,13 +78,114 @@ func (e entries[T]) Search(x int64) int {
 	})
 }
 
+// first returns the index of the first entry that ends at or after `offset`.
+// Entries do not overlap, so only the one before the first at or after
+// `offset` may reach it.
+func (e entries[T]) first(offset int64) int {
+	i := e.Search(offset)
+	if i > 0 && e[i-1].end() >= offset {
+		i--
+	}
+	return i
+}
+
 type Store[T any] struct {
-	minContiguous int
+	minContiguous  int
+	maxContiguous  int
+	copyOnSet      bool
+	expectedLength int64
+
+	lazy             bool
+	maxPending       int
+	maxPendingVolume int64
+	// batches is the number of batches running, during which Set defers
+	// compaction as in lazy mode.
+	batches int
+
+	// parallelGet is the number of values from which Get copies them in
+	// parallel, or 0 if it never does.
+	parallelGet int
+
+	maxOccupancy   int64
+	evictionPolicy Evictor
+	// evictionObserver is the eviction policy, if it is an EvictionObserver.
+	evictionObserver EvictionObserver
+	// evictedUses is the number of uses of the extent evicted last, which new
+	// extents start from.
+	evictedUses int
+	// pinned holds the values whose extents are never evicted.
+	pinned rangeSet
 
 	entries     entries[T]
 	insertCount int
+	accessCount int
 	occupancy   int64
 	length      int64
+	start       int64
+
+	// pending holds the entries set in lazy mode that have not been compacted
+	// yet, in insertion order.
+	pending       entries[T]
+	pendingVolume int64
+
+	buffers bufferPool[T]
+	arena   *arena[T]
+
+	minZeroRun int
+	isZero     func(T) bool
+
+	dedup *dedupTable[T]
+	codec Codec[T]
+
+	frozen bool
+	guard  *concurrencyGuard
+
+	invariantChecks bool
+	emptyPolicy     EmptyPolicy
+
+	strictBounds  bool
+	signedOffsets bool
+	// prioritized is set once a write with a priority other than zero is made.
+	prioritized bool
+
+	presence PresenceIndex
+
+	softMemoryLimit int64
+	onPressure      func(*Store[T], MemoryUsage)
+	// memoryBound is an upper bound on the total memory usage, so that it
+	// only needs to be measured when it may have exceeded the soft limit.
+	memoryBound int64
+	inPressure  bool
+
+	byteBudget int64
+
+	blockSize int64
+	alignMode AlignMode
+
+	clock           Clock
+	stats           stats
+	instrumentation Instrumentation
+	hooks           Hooks
+	logger          *slog.Logger
+	recorder        *Recorder[T]
+	heatmap         *heatmap
+	// onWrite is called once a write is complete, by the Manager owning the
+	// store, if any.
+	onWrite func()
+
+	// backing is the function values are written through or back with, and
+	// dirty holds the values that still need to be written back.
+	backing    func(p []T, offset int64) error
+	writeBack  bool
+	dirty      rangeSet
+	backingErr error
+
+	// expiring is set once an entry with a TTL was set, so that reads only
+	// look for stale entries if there can be any.
+	expiring bool
+
+	// checksums holds the checksums of the blocks, if WithChecksums is used.
+	checksums *checksums[T]
 }
 
 type Option[T any] func(*Store[T])
@@ -39,19 +196,102 @@ func WithMinContiguous[T any](minContiguous int) Option[T] {
 	}
 }
 
-func NewStore[T any](opts ...Option[T]) *Store[T] {
-	cache := &Store[T]{
-		minContiguous: defaultMinContiguous,
+// WithExpectedLength declares the length the store is expected to reach, such
+// as the size of a download. The store then allocates its index and presence
+// bitmap up front, and, unless WithMinContiguous is used, merges extents up to
+// a size suited to that length.
+func WithExpectedLength[T any](length int64) Option[T] {
+	return func(c *Store[T]) {
+		c.expectedLength = length
+	}
+}
+
+// WithMaxContiguous caps the size of every extent at `maxContiguous` values.
+// Merges never produce larger extents, and data set in one go is split up if
+// needed, which bounds the size of individual allocations.
+func WithMaxContiguous[T any](maxContiguous int) Option[T] {
+	return func(c *Store[T]) {
+		c.maxContiguous = maxContiguous
+	}
+}
+
+// WithCopyOnSet makes Set copy the data it is given, so that the caller is free
+// to modify or reuse it afterwards.
+func WithCopyOnSet[T any]() Option[T] {
+	return func(c *Store[T]) {
+		c.copyOnSet = true
 	}
+}
+
+// WithArena makes the store allocate the data it holds from blocks of
+// `blockSize` values, rather than allocating every extent separately. Set
+// copies the data it is given into the arena, as with WithCopyOnSet. The
+// blocks are only released as a whole by Clear, or reused after Reset, so this
+// suits stores that are cleared periodically rather than ones that are
+// overwritten a lot.
+func WithArena[T any](blockSize int) Option[T] {
+	return func(c *Store[T]) {
+		c.copyOnSet = true
+		c.arena = &arena[T]{blockSize: blockSize}
+	}
+}
+
+// WithPresenceBitmap makes the store keep a bitmap of the values it contains,
+// so that Has and Coverage take time proportional to the requested range
+// rather than to the number of extents. The bitmap takes one bit per value up
+// to the length of the store, so this suits dense stores only.
+func WithPresenceBitmap[T any]() Option[T] {
+	return WithPresenceIndex[T](&bitmap{})
+}
+
+// WithPresenceIndex makes the store keep track of the values it contains in
+// `index`, which must be empty, and answer Has and Coverage from it.
+func WithPresenceIndex[T any](index PresenceIndex) Option[T] {
+	return func(c *Store[T]) {
+		c.presence = index
+	}
+}
+
+// WithLazyCompaction makes Set only record the new extent. Compaction is
+// deferred until `maxPending` extents or a total of `maxPendingVolume` values
+// are pending, until Compact is called, or until the store is read.
+func WithLazyCompaction[T any](maxPending int, maxPendingVolume int64) Option[T] {
+	return func(c *Store[T]) {
+		c.lazy = true
+		c.maxPending = maxPending
+		c.maxPendingVolume = maxPendingVolume
+	}
+}
+
+func NewStore[T any](opts ...Option[T]) *Store[T] {
+	cache := &Store[T]{}
 
 	for _, opt := range opts {
 		opt(cache)
 	}
 
+	if cache.minContiguous == 0 {
+		cache.minContiguous = defaultMinContiguous
+		// Merge larger extents for longer stores, so that they end up with a
+		// bounded number of extents.
+		if cache.expectedLength > 0 {
+			cache.minContiguous = int(min(max(cache.expectedLength/expectedExtents, defaultMinContiguous), maxExpectedMinContiguous))
+		}
+	}
+	if cache.expectedLength > 0 {
+		n := (cache.expectedLength + int64(cache.mergeLimit()) - 1) / int64(cache.mergeLimit())
+		cache.entries = make(entries[T], 0, min(n, expectedExtents))
+		if b, ok := cache.presence.(*bitmap); ok {
+			b.grow(cache.expectedLength)
+		}
+	}
+
 	return cache
 }
 
 func (c *Store[T]) Occupancy() int64 {
+	c.Compact()
+	c.expire()
 	return c.occupancy
 }
 
@@ -59,48 +299,113 @@ func (c *Store[T]) Length() int64 {
 	return c.length
 }
 
+// Start returns the lowest offset ever set, or zero if none below zero was, so
+// that [Start(), Length()) covers every value ever set.
+func (c *Store[T]) Start() int64 {
+	return c.start
+}
+
 // Has returns true if the cache contains data at `offset` with length
-// `length`.
+// `length`. Empty ranges are handled according to the EmptyPolicy.
+//
+// With a presence index, Has is answered from the index, and does not count as
+// an access for LRU eviction.
 func (c *Store[T]) Has(length, offset int64) bool {
-	if len(c.entries) == 0 && length > 0 {
-		return false
+	defer c.enter("has")()
+	if !c.frozen {
+		c.recorder.record("has", offset, length)
+	}
+	c.Compact()
+	c.expire()
+	end := endOf(length, offset)
+	c.access(offset, end)
+	if length == 0 {
+		return c.countHas(c.emptyPolicy != EmptyReject)
+	}
+
+	// Presence indexes need not track negative offsets.
+	if c.presence != nil && offset >= 0 {
+		return c.countHas(c.presence.Contains(offset, end) && end-offset == length)
+	}
+
+	if len(c.entries) == 0 {
+		return c.countHas(false)
 	}
 
 	completeTo := offset
-	for _, entry := range c.entries {
-		// If the entry is before the requested range, skip it.
-		if entry.offset+int64(len(entry.data)) < offset {
-			continue
-		}
+	for i := c.entries.first(offset); i < len(c.entries); i++ {
+		entry := c.entries[i]
 		// If the entry starts after the requested range, or if there
 		// is a gap between the previous entry and this one, we're done.
-		if entry.offset > offset+length || completeTo < entry.offset {
+		if entry.offset > end || completeTo < entry.offset {
 			break
 		}
 
-		completeTo = entry.offset + int64(len(entry.data))
+		c.touch(i, offset, length)
+		completeTo = entry.end()
 	}
 
 	// If the cache contains the complete range, return true.
-	return completeTo >= offset+length
+	// Values past the end of the offset domain are never present.
+	return c.countHas(completeTo >= end && end-offset == length)
+}
+
+// Coverage returns the number of values the cache contains in the range at
+// `offset` with length `length`.
+func (c *Store[T]) Coverage(length, offset int64) int64 {
+	defer c.enter("coverage")()
+	c.Compact()
+	c.expire()
+	end := endOf(length, offset)
+
+	if c.presence != nil && offset >= 0 {
+		return c.presence.Count(offset, end)
+	}
+
+	var coverage int64
+	for _, entry := range c.entries {
+		if entry.offset >= end {
+			break
+		}
+		coverage += max(0, min(entry.end(), end)-max(entry.offset, offset))
+	}
+
+	return coverage
 }
 
 // Get populates `p` with the data at `offset`. If the cache does not contain the
-// complete data for this range, Get returns false.
+// complete data for this range, Get returns false. Empty ranges are handled
+// according to the EmptyPolicy.
 func (c *Store[T]) Get(p []T, offset int64) bool {
-	if len(c.entries) == 0 && len(p) > 0 {
-		return false
+	defer c.enter("get")()
+	if !c.frozen {
+		c.recorder.record("get", offset, len(p))
+	}
+	c.Compact()
+	c.expire()
+	end := endOf(int64(len(p)), offset)
+	c.access(offset, end)
+	if len(p) == 0 {
+		return c.countGet(0, 0, c.emptyPolicy != EmptyReject)
+	}
+
+	if len(c.entries) == 0 {
+		return c.countGet(int64(len(p)), 0, false)
 	}
 
 	// The logic for completeTo is the same as in Has, but we have to continue
 	// iterating over the entries to populate `p`.
 	completeTo := offset
 	complete := true
-	for _, entry := range c.entries {
-		if entry.offset+int64(len(entry.data)) < offset {
-			continue
-		}
-		if entry.offset > offset+int64(len(p)) {
+	var served int64
+	// With WithParallelGet, the values of the entries from `first` to `i` are
+	// copied after the loop.
+	parallel := c.parallelGet > 0 && len(p) >= c.parallelGet
+	first := c.entries.first(offset)
+	i := first
+	for ; i < len(c.entries); i++ {
+		entry := c.entries[i]
+		if entry.offset > end {
 			break
 		}
 
@@ -108,86 +413,641 @@ func (c *Store[T]) Get(p []T, offset int64) bool {
 			complete = false
 		}
 
-		offsetDelta := entry.offset - offset
-		if offsetDelta < 0 {
-			copy(p, entry.data[-offsetDelta:])
-		} else {
-			copy(p[offsetDelta:], entry.data)
+		c.touch(i, offset, int64(len(p)))
+		if !parallel {
+			entry.read(p, offset)
 		}
+		served += max(0, min(entry.end(), end)-max(entry.offset, offset))
 
-		completeTo = entry.offset + int64(len(entry.data))
+		completeTo = entry.end()
+	}
+	if parallel {
+		readParallel(c.entries[first:i], p, offset)
 	}
 
-	return complete && completeTo >= offset+int64(len(p))
+	return c.countGet(int64(len(p)), served, complete && completeTo >= end && end-offset == int64(len(p)))
 }
 
-// Set sets the cache data at `offset` to `p`. If the cache already contains
-// data at `offset`, it is overwritten.
-func (c *Store[T]) Set(p []T, offset int64) {
+// Clear removes all data from the store, and releases the arena if there is
+// one.
+func (c *Store[T]) Clear() {
+	defer c.enter("clear")()
+	c.mutate()
+	c.recorder.record("clear")
+	c.empty()
+	if c.arena != nil {
+		c.arena.reset()
+	}
+
+	c.publish()
+}
+
+// empty removes all extents and pending extents, and the values to write back.
+func (c *Store[T]) empty() {
+	for _, e := range c.entries {
+		c.release(e)
+	}
+	for _, e := range c.pending {
+		c.release(e)
+	}
+	clear(c.entries)
+	clear(c.pending)
+
+	c.entries = c.entries[:0]
+	c.pending = c.pending[:0]
+	c.pendingVolume = 0
+	c.occupancy = 0
+	c.length = 0
+	c.start = 0
+	c.memoryBound = 0
+	c.dirty = c.dirty[:0]
+
+	if c.presence != nil {
+		c.presence.Clear()
+	}
+	if c.checksums != nil {
+		clear(c.checksums.sums)
+		c.checksums.stale = c.checksums.stale[:0]
+		c.checksums.next = math.MinInt64
+	}
+}
+
+// Delete removes the `length` values at `offset`, and returns the number of
+// values removed. It does not reduce the length of the store. A negative
+// length removes nothing.
+func (c *Store[T]) Delete(length, offset int64) int64 {
+	defer c.enter("delete")()
+	c.mutate()
+	c.recorder.record("delete", offset, length)
+	if length < 0 {
+		return 0
+	}
+	length, offset, ok := c.alignDelete(length, offset)
+	if !ok {
+		return 0
+	}
+	c.Compact()
+
+	end := endOf(length, offset)
 	i := c.entries.Search(offset)
-	c.entries = slices.Insert(c.entries, i, entry[T]{c.insertCount, offset, p})
-	c.insertCount++
+	if i > 0 && c.entries[i-1].end() > offset {
+		i--
+	}
+	j := i
+	for j < len(c.entries) && c.entries[j].offset < end {
+		j++
+	}
+	if i == j {
+		return 0
+	}
+
+	// Only the first and last entry can extend beyond the range, the parts
+	// that do are kept.
+	var kept entries[T]
+	first, last := c.entries[i], c.entries[j-1]
+	if first.offset < offset {
+		kept = append(kept, first.slice(first.offset, offset))
+	}
+	if last.end() > end {
+		kept = append(kept, last.slice(end, last.end()))
+	}
+	// If both parts come from the same entry, they share its backing array.
+	if i == j-1 && len(kept) == 2 {
+		kept[0].owned = false
+		kept[1].owned = false
+		if first.shared != nil {
+			first.shared.refs++
+		}
+	}
+
+	var removed int64
+	for k, e := range c.entries[i:j] {
+		from, to := max(e.offset, offset), min(e.end(), end)
+		removed += to - from
+		c.hooks.delete(from, to-from)
+		c.unseal(from, to-from)
+		if (k == 0 && first.offset < offset) || (k == j-i-1 && last.end() > end) {
+			continue
+		}
+		c.release(e)
+	}
+
+	c.entries = slices.Replace(c.entries, i, j, kept...)
+	c.dirty.take(offset, end)
+	c.occupancy -= removed
+	if c.presence != nil {
+		c.presence.Remove(offset, end)
+	}
+	c.publish()
+
+	return removed
+}
+
+// touch marks entry `i` as accessed if it overlaps the range at `offset` with
+// length `length`.
+func (c *Store[T]) touch(i int, offset, length int64) {
+	if c.frozen {
+		return
+	}
+	e := &c.entries[i]
+	if e.offset < endOf(length, offset) && e.end() > offset {
+		e.accessed = c.accessCount
+		e.uses++
+		e.reads++
+	}
+}
+
+// Set sets the cache data at `offset` to `p`. If the cache already contains
+// data at `offset`, it is overwritten. An empty `p` is handled according to
+// the EmptyPolicy.
+//
+// Unless WithCopyOnSet is used, the store retains `p` rather than copying it,
+// so the caller must not modify it afterwards.
+//
+// Set returns ErrFull, and leaves the store unchanged, if the data would exceed
+// the budget configured with WithByteBudget.
+func (c *Store[T]) Set(p []T, offset int64) error {
+	return c.set(entry[T]{offset: offset, data: p})
+}
+
+// SetOwned is like Set, but transfers ownership of `p` to the store, even with
+// WithCopyOnSet. The store neither copies `p` nor leaves it untouched: it may
+// modify or recycle it, so the caller must not use it afterwards.
+func (c *Store[T]) SetOwned(p []T, offset int64) error {
+	return c.set(entry[T]{offset: offset, data: p, owned: true})
+}
+
+// SetWithPriority is like Set, but the values are only overwritten by later
+// writes of at least `priority`, such as speculative prefetches with a lower
+// priority than verified values. Set writes with priority zero.
+func (c *Store[T]) SetWithPriority(p []T, offset int64, priority int) error {
+	return c.set(entry[T]{offset: offset, data: p, priority: priority})
+}
+
+// SetWithTTL is like Set, but the data expires after `ttl`. Expired data is
+// removed when the store is read, or by Expire.
+func (c *Store[T]) SetWithTTL(p []T, offset int64, ttl time.Duration) error {
+	return c.set(entry[T]{offset: offset, data: p, expires: c.now().Add(ttl)})
+}
+
+// set inserts `e`, filling in its order and access tick. Unless `e` is owned,
+// its data is copied if the store is configured to do so.
+func (c *Store[T]) set(e entry[T]) error {
+	defer c.enter("set")()
+	if c.frozen {
+		return ErrFrozen
+	}
+	if err := checkBounds("set", e.size(), e.offset, c.signedOffsets || !c.strictBounds); err != nil {
+		return err
+	}
+	if e.size() == 0 {
+		switch c.emptyPolicy {
+		case EmptyIgnore:
+			return nil
+		case EmptyReject:
+			return &BoundsError{Op: "set", Offset: e.offset, Err: ErrEmpty}
+		}
+	}
+	// The flags are only set for writes that are not rejected, so that
+	// rejected writes to a frozen store do not race with its readers.
+	if e.priority != 0 {
+		c.prioritized = true
+	}
+	if !e.expires.IsZero() {
+		c.expiring = true
+	}
+	if c.recorder != nil {
+		c.recorder.recordSet(e, c.now())
+	}
+	e, err := c.align(e)
+	if err != nil {
+		return err
+	}
+	if err := c.checkBudget(e); err != nil {
+		return err
+	}
+	if err := c.writeThrough(e); err != nil {
+		return err
+	}
+	if c.evictionObserver != nil && e.size() > 0 {
+		c.evictionObserver.Written(Range{Offset: e.offset, Length: e.size()})
+	}
+	if c.writeBack {
+		c.dirty.add(e.offset, e.end())
+	}
+
+	set := c.setSplit
+	if c.dedup != nil {
+		set = c.setDeduplicated
+	}
+	if c.minZeroRun > 0 && !e.run {
+		c.splitZeroRuns(e, set)
+	} else {
+		set(e)
+	}
+
+	c.checkPressure(e)
+
+	if e.size() > 0 {
+		c.hooks.set(e.offset, e.size())
+		c.unseal(e.offset, e.size())
+	}
+	c.stats.sets.Add(1)
+	c.publish()
+	if c.instrumentation != nil {
+		c.instrumentation.Set(e.size())
+	}
+	if c.onWrite != nil && e.size() > 0 {
+		c.onWrite()
+	}
+
+	return nil
+}
+
+// setSplit is set, after splitting off zero runs.
+func (c *Store[T]) setSplit(e entry[T]) {
+	// Split up data larger than the maximum extent size. The chunks share the
+	// backing array, so none of them owns it.
+	for c.maxContiguous > 0 && len(e.data) > c.maxContiguous {
+		chunk := e
+		chunk.data = e.data[:c.maxContiguous:c.maxContiguous]
+		chunk.owned = false
+		if e.shared != nil {
+			e.shared.refs++
+		}
+		c.setSplit(chunk)
+
+		e.offset += int64(c.maxContiguous)
+		e.data = e.data[c.maxContiguous:]
+		e.owned = false
+	}
+
+	if c.presence != nil {
+		c.presence.Add(e.offset, e.end())
+	}
+
+	// Data set at or after the end cannot overlap anything.
+	atEnd := e.offset >= c.length
 
 	// If the length increased, update it.
-	if c.length < offset+int64(len(p)) {
-		c.length = offset + int64(len(p))
+	if c.length < e.end() {
+		c.length = e.end()
+	}
+	if c.start > e.offset && e.size() > 0 {
+		c.start = e.offset
+	}
+
+	e.order = c.insertCount
+	e.accessed = c.accessCount
+	e.uses = c.evictedUses + 1
+	e.backing = cap(e.data)
+	c.insertCount++
+	c.accessCount++
+
+	if c.lazy || c.batches > 0 {
+		c.pending = append(c.pending, c.retain(e))
+		c.pendingVolume += e.size()
+		if c.batches == 0 && (len(c.pending) >= c.maxPending || c.pendingVolume >= c.maxPendingVolume) {
+			c.Compact()
+		}
+		return
+	}
+
+	if atEnd {
+		c.append(e)
+		return
+	}
+
+	i := c.entries.Search(e.offset)
+	if c.extend(i, e) {
+		return
+	}
+
+	c.insert(i, c.retain(e))
+	c.evict()
+}
+
+// append adds `e`, which must not overlap any entry, after the last entry.
+// Unless it needs to be merged with that entry, this takes neither a search
+// nor compaction.
+func (c *Store[T]) append(e entry[T]) {
+	i := len(c.entries)
+	if c.extend(i, e) {
+		return
+	}
+
+	if i > 0 && compatible(c.entries[i-1], e) && c.entries[i-1].size()+e.size() <= int64(c.mergeLimit()) {
+		c.insert(i, c.retain(e))
+	} else if e.size() > 0 {
+		c.entries = append(c.entries, c.retain(e))
+		c.occupancy += e.size()
+	}
+
+	c.evict()
+}
+
+// insert inserts `e` at index `i` and compacts the entries it overlaps or
+// adjoins. As the other entries are compacted already, they are not affected.
+func (c *Store[T]) insert(i int, e entry[T]) {
+	lo := i
+	if lo > 0 && c.entries[lo-1].end() >= e.offset {
+		lo--
+	}
+	hi := i
+	for hi < len(c.entries) && c.entries[hi].offset <= e.end() {
+		hi++
+	}
+
+	window := make(entries[T], 0, hi-lo+1)
+	window = append(window, c.entries[lo:i]...)
+	window = append(window, e)
+	window = append(window, c.entries[i:hi]...)
+
+	for _, e := range c.entries[lo:hi] {
+		c.occupancy -= e.size()
+	}
+	compacted := c.merge(c.resolve(window))
+	for _, e := range compacted {
+		c.occupancy += e.size()
+	}
+
+	c.entries = slices.Replace(c.entries, lo, hi, compacted...)
+}
+
+// retain returns `e` with its data copied if the store is configured to do so
+// and it is not owned yet.
+func (c *Store[T]) retain(e entry[T]) entry[T] {
+	if c.copyOnSet && !e.owned && e.shared == nil && !e.run {
+		data := c.alloc(len(e.data))
+		copy(data, e.data)
+		e.data = data
+		e.owned = true
+		e.backing = cap(data)
+	}
+	return e
+}
+
+// extend appends the data of `e` to the entry before index `i`, if that entry
+// has the spare capacity for it and the two would be merged anyway. This saves
+// allocating, copying and compacting for sequential writes. It reports whether
+// `e` was appended.
+func (c *Store[T]) extend(i int, e entry[T]) bool {
+	if i == 0 {
+		return false
+	}
+
+	prev := &c.entries[i-1]
+	n := len(e.data)
+	if !prev.owned || !compatible(*prev, e) || cap(prev.data)-len(prev.data) < n ||
+		len(prev.data)+n > c.mergeLimit() {
+		return false
+	}
+	// The next entry would need to be resolved or merged as well.
+	if i < len(c.entries) && c.entries[i].offset <= e.end() {
+		return false
+	}
+
+	prev.data = append(prev.data, e.data...)
+	prev.order = e.order
+	prev.accessed = e.accessed
+	prev.uses = max(prev.uses, e.uses)
+	prev.reads = max(prev.reads, e.reads)
+	c.release(e)
+	c.hooks.merge(prev.offset, int64(len(prev.data)))
+	if c.debugging() {
+		c.debug("extended extent in place", "offset", prev.offset, "length", len(prev.data))
 	}
 
-	// Update the occupancy optimistically. If the entry is compacted, the
-	// occupancy will be updated again.
-	c.occupancy += int64(len(p))
+	c.occupancy += int64(n)
+	c.evict()
+
+	return true
+}
+
+// Compact compacts any extents recorded by Set in lazy mode. It is a no-op if
+// there are none.
+func (c *Store[T]) Compact() {
+	defer c.enter("compact")()
+	if len(c.pending) == 0 {
+		return
+	}
+	c.recorder.record("compact")
+
+	// The pending entries are in insertion order, a stable sort keeps entries
+	// set at the same offset in that order.
+	slices.SortStableFunc(c.pending, func(a, b entry[T]) int {
+		return cmp.Compare(a.offset, b.offset)
+	})
+
+	merged := make(entries[T], 0, len(c.entries)+len(c.pending))
+	i, j := 0, 0
+	for i < len(c.entries) || j < len(c.pending) {
+		if j == len(c.pending) || (i < len(c.entries) && c.entries[i].offset <= c.pending[j].offset) {
+			merged = append(merged, c.entries[i])
+			i++
+		} else {
+			merged = append(merged, c.pending[j])
+			j++
+		}
+	}
+
+	if c.debugging() {
+		c.debug("compacting", "extents", len(c.entries), "pending", len(c.pending), "pendingVolume", c.pendingVolume)
+	}
+
+	c.entries = merged
+	c.pending = c.pending[:0]
+	c.pendingVolume = 0
+
+	if c.instrumentation != nil {
+		end := c.instrumentation.Compact(len(c.entries))
+		defer func() { end(len(c.entries)) }()
+	}
 
 	c.compact()
+	c.publish()
+	if c.debugging() {
+		c.debug("compacted", "extents", len(c.entries), "occupancy", c.occupancy)
+	}
 }
 
-// compact compacts the cache by merging adjacent entries and removing
-// overlapping entries.
+// compact compacts the whole cache by resolving overlapping entries and merging
+// adjacent ones. Where entries overlap, the one set most recently wins.
 func (c *Store[T]) compact() {
-	for i := 0; i < len(c.entries)-1; i++ {
-		// We use references here as we want to update the entries in place
-		// when reslicing.
-		current := &c.entries[i]
-		next := &c.entries[i+1]
-
-		currentMin := current.offset
-		currentMax := current.offset + int64(len(current.data))
-		nextMin := next.offset
-		nextMax := next.offset + int64(len(next.data))
-
-		if nextMin < currentMax {
-			// If the current entry encompasses the next entry, copy if needed.
-			if nextMax <= currentMax {
-				// If the next entry has a higher order, copy.
-				if current.order < next.order {
-					copy(current.data[nextMin-currentMin:], next.data)
-				}
-
-				c.entries = append(c.entries[:i+1], c.entries[i+2:]...)
-				c.occupancy -= int64(len(next.data))
-				i--
-				continue
-			} else {
-				// If the entries overlap reslice so that they become contiguous.
-				c.occupancy -= currentMax - nextMin
-				if current.order < next.order {
-					current.data = current.data[:nextMin-currentMin]
-					currentMax = nextMin
-				} else {
-					next.data = next.data[currentMax-nextMin:]
-					next.offset = currentMax
-					nextMin = currentMax
-				}
-			}
-		}
-
-		// If the entries are contiguous and small enough, combine them.
-		if currentMax == nextMin && int(nextMax-currentMin) <= c.minContiguous {
-			newData := make([]T, nextMax-currentMin)
-			copy(newData, current.data)
-			copy(newData[currentMax-currentMin:], next.data)
-			c.entries[i] = entry[T]{current.order, currentMin, newData}
-			c.entries = append(c.entries[:i+1], c.entries[i+2:]...)
-			i--
+	c.entries = c.resolve(c.entries)
+	c.entries = c.merge(c.entries)
+
+	c.occupancy = 0
+	for _, e := range c.entries {
+		c.occupancy += e.size()
+	}
+
+	c.evict()
+}
+
+// resolve returns the non-overlapping entries visible in `sorted`, which must
+// be sorted by offset. It sweeps over the entries while keeping the ones that
+// cover the current position in a heap, so that the most recently set one can
+// be picked for every segment.
+func (c *Store[T]) resolve(sorted entries[T]) entries[T] {
+	resolved := make(entries[T], 0, len(sorted))
+	// sources holds the index in sorted of the entry each segment came from.
+	sources := make([]int, 0, len(sorted))
+	active := &activeEntries[T]{entries: sorted}
+
+	var pos int64
+	i := 0
+	for i < len(sorted) || active.Len() > 0 {
+		if active.Len() == 0 {
+			pos = sorted[i].offset
+		}
+		for i < len(sorted) && sorted[i].offset <= pos {
+			heap.Push(active, i)
+			i++
+		}
+		// Entries that ended before the current position are dropped only once
+		// they surface.
+		for active.Len() > 0 && sorted[active.top()].end() <= pos {
+			heap.Pop(active)
+		}
+		if active.Len() == 0 {
+			continue
+		}
+
+		source := active.top()
+		top := sorted[source]
+		end := top.end()
+		if i < len(sorted) && sorted[i].offset < end {
+			end = sorted[i].offset
+		}
+
+		// Extend the previous segment if it came from the same entry.
+		if n := len(resolved); n > 0 && sources[n-1] == source && resolved[n-1].end() == pos {
+			resolved[n-1] = top.slice(resolved[n-1].offset, end)
+		} else {
+			resolved = append(resolved, top.slice(pos, end))
+			sources = append(sources, source)
+		}
+
+		pos = end
+	}
+
+	// Entries that were split up share their backing array between the
+	// segments, so none of them owns it anymore. Owned entries that were
+	// overwritten entirely can be recycled.
+	segments := make([]int, len(sorted))
+	for _, source := range sources {
+		segments[source]++
+	}
+	for i, source := range sources {
+		if segments[source] > 1 {
+			resolved[i].owned = false
 		}
 	}
+	for source, n := range segments {
+		if n > 1 && sorted[source].shared != nil {
+			sorted[source].shared.refs += n - 1
+		}
+	}
+	for source, n := range segments {
+		if n == 0 {
+			c.release(sorted[source])
+		}
+	}
+
+	return resolved
+}
+
+// compatible reports whether entry `b` directly follows entry `a`, and whether
+// they are alike enough to be merged. Runs are never merged, as that would
+// defeat their purpose.
+func compatible[T any](a, b entry[T]) bool {
+	return a.end() == b.offset && a.expires.Equal(b.expires) && !a.run && !b.run && a.shared == nil && b.shared == nil && a.priority == b.priority
+}
+
+// mergeLimit returns the maximum size of an entry produced by merging.
+func (c *Store[T]) mergeLimit() int {
+	if c.maxContiguous > 0 {
+		return min(c.minContiguous, c.maxContiguous)
+	}
+	return c.minContiguous
 }
+
+// merge combines runs of contiguous entries in `resolved` as long as the
+// combined entry is not larger than the merge limit.
+func (c *Store[T]) merge(resolved entries[T]) entries[T] {
+	merged := resolved[:0]
+	for i := 0; i < len(resolved); {
+		// Find the longest run of contiguous entries starting at i that fits.
+		j := i + 1
+		for j < len(resolved) && compatible(resolved[j-1], resolved[j]) &&
+			int(resolved[j].end()-resolved[i].offset) <= c.mergeLimit() {
+			j++
+		}
+
+		if j-i == 1 {
+			merged = append(merged, resolved[i])
+			i = j
+			continue
+		}
+
+		combined := resolved[i]
+		length := int(resolved[j-1].end() - combined.offset)
+		run := resolved[i:j]
+		if combined.owned && cap(combined.data) >= length {
+			// The first entry has room for the others, so only they need to be
+			// copied.
+			combined.data = combined.data[:length]
+			run = run[1:]
+		} else {
+			combined.data = c.alloc(length)
+			combined.owned = true
+		}
+
+		combined.backing = cap(combined.data)
+		for _, e := range run {
+			e.read(combined.data, combined.offset)
+			c.release(e)
+		}
+		for _, e := range resolved[i:j] {
+			combined.order = max(combined.order, e.order)
+			combined.accessed = max(combined.accessed, e.accessed)
+			combined.uses = max(combined.uses, e.uses)
+			combined.reads = max(combined.reads, e.reads)
+		}
+		c.hooks.merge(combined.offset, int64(length))
+		if c.debugging() {
+			c.debug("merged extents", "offset", combined.offset, "length", length, "extents", j-i, "allocated", len(run) == j-i)
+		}
+		merged = append(merged, combined)
+		i = j
+	}
+
+	return merged
+}
+
+// activeEntries is a max-heap of indices into entries, ordered by the priority
+// and then the insertion order of the entries they refer to.
+type activeEntries[T any] struct {
+	entries entries[T]
+	indices []int
+}
+
+func (a *activeEntries[T]) Len() int { return len(a.indices) }
+
+func (a *activeEntries[T]) Less(i, j int) bool {
+	return a.entries[a.indices[i]].overrides(a.entries[a.indices[j]])
+}
+
+func (a *activeEntries[T]) Swap(i, j int) { a.indices[i], a.indices[j] = a.indices[j], a.indices[i] }
+
+func (a *activeEntries[T]) Push(x any) { a.indices = append(a.indices, x.(int)) }
+
+func (a *activeEntries[T]) Pop() any {
+	n := len(a.indices)
+	x := a.indices[n-1]
+	a.indices = a.indices[:n-1]
+	return x
+}
+
+func (a *activeEntries[T]) top() int { return a.indices[0] }
@@ -28,6 +28,10 @@ func TestStoreSet(t *testing.T) {
 			name: "never merge",
 			opt:  store.WithMinContiguous[byte](1),
 		},
+		{
+			name: "lazy",
+			opt:  store.WithLazyCompaction[byte](2, 1<<10),
+		},
 	} {
 		{
 			for _, tc := range []struct {
@@ -342,6 +346,211 @@ func TestStoreGetAndHas(t *testing.T) {
 	}
 }
 
+func TestStoreRandom(t *testing.T) {
+	for _, topt := range []struct {
+		name string
+		opts []store.Option[byte]
+	}{
+		{
+			name: "default",
+		},
+		{
+			name: "never merge",
+			opts: []store.Option[byte]{store.WithMinContiguous[byte](1)},
+		},
+		{
+			name: "lazy",
+			opts: []store.Option[byte]{store.WithLazyCompaction[byte](16, 1<<10)},
+		},
+		{
+			name: "max contiguous",
+			opts: []store.Option[byte]{store.WithMaxContiguous[byte](8)},
+		},
+		{
+			name: "arena",
+			opts: []store.Option[byte]{store.WithArena[byte](1 << 10)},
+		},
+		{
+			name: "presence bitmap",
+			opts: []store.Option[byte]{store.WithPresenceBitmap[byte]()},
+		},
+		{
+			name: "zero runs",
+			opts: []store.Option[byte]{store.WithZeroRuns[byte](4), store.WithMinContiguous[byte](64)},
+		},
+		{
+			name: "copy on set",
+			opts: []store.Option[byte]{store.WithCopyOnSet[byte](), store.WithMinContiguous[byte](64)},
+		},
+	} {
+		t.Run(topt.name, func(t *testing.T) {
+			r := rand.New(rand.NewSource(1))
+			s := store.NewStore(topt.opts...)
+
+			// The model holds the expected content, with present values
+			// tracked separately.
+			model := make([]byte, 256)
+			present := make([]bool, 256)
+
+			for i := 0; i < 1000; i++ {
+				offset := r.Int63n(200)
+				if r.Intn(10) == 0 {
+					length := r.Int63n(50)
+					var removed int64
+					for j := offset; j < offset+length; j++ {
+						if present[j] {
+							removed++
+						}
+						model[j] = 0
+						present[j] = false
+					}
+					assert.Equal(t, removed, s.Delete(length, offset))
+					continue
+				}
+
+				p := make([]byte, r.Intn(50))
+				// Mix in runs of a single value, as set by Fill or consisting
+				// of zeros.
+				kind := r.Intn(5)
+				for j := range p {
+					switch kind {
+					case 0:
+						p[j] = 0
+					case 1:
+						p[j] = byte(len(p))
+					default:
+						p[j] = byte(r.Intn(255) + 1)
+					}
+					model[offset+int64(j)] = p[j]
+					present[offset+int64(j)] = true
+				}
+				if kind == 1 {
+					s.Fill(byte(len(p)), int64(len(p)), offset)
+				} else {
+					s.Set(p, offset)
+				}
+
+				if i%10 != 0 {
+					continue
+				}
+
+				var occupancy int64
+				for _, ok := range present {
+					if ok {
+						occupancy++
+					}
+				}
+				assert.Equal(t, occupancy, s.Occupancy())
+
+				data := make([]byte, len(model))
+				s.Get(data, 0)
+				assert.Equal(t, model, data)
+
+				from := r.Int63n(int64(len(model)))
+				to := from + r.Int63n(int64(len(model))-from)
+				var coverage int64
+				for _, ok := range present[from:to] {
+					if ok {
+						coverage++
+					}
+				}
+				assert.Equal(t, coverage, s.Coverage(to-from, from))
+				assert.Equal(t, coverage == to-from, s.Has(to-from, from))
+				var missing int64
+				for _, gap := range s.Gaps(to-from, from) {
+					missing += gap.Length
+				}
+				assert.Equal(t, to-from-coverage, missing)
+			}
+		})
+	}
+}
+
+func TestStoreLazyCompaction(t *testing.T) {
+	s := store.NewStore(store.WithLazyCompaction[byte](3, 1<<10))
+
+	s.Set([]byte{1, 2}, 0)
+	s.Set([]byte{3}, 1)
+	assert.Equal(t, int64(2), s.Length())
+
+	s.Compact()
+	assert.Equal(t, int64(2), s.Occupancy())
+
+	data := make([]byte, 2)
+	assert.True(t, s.Get(data, 0))
+	assert.Equal(t, []byte{1, 3}, data)
+}
+
+func TestStoreSetRetainsInput(t *testing.T) {
+	s := store.NewStore[byte]()
+
+	in := []byte{0, 1, 2}
+	s.Set(in, 0)
+	s.Set([]byte{10}, 1)
+
+	// Overwriting part of the data must not modify the caller's slice.
+	assert.Equal(t, []byte{0, 1, 2}, in)
+
+	data := make([]byte, 3)
+	assert.True(t, s.Get(data, 0))
+	assert.Equal(t, []byte{0, 10, 2}, data)
+}
+
+func TestStoreCopyOnSet(t *testing.T) {
+	s := store.NewStore(store.WithCopyOnSet[byte]())
+
+	in := []byte{0, 1, 2}
+	s.Set(in, 0)
+	in[1] = 10
+
+	data := make([]byte, 3)
+	assert.True(t, s.Get(data, 0))
+	assert.Equal(t, []byte{0, 1, 2}, data)
+}
+
+func TestStoreSetOwned(t *testing.T) {
+	s := store.NewStore(store.WithCopyOnSet[byte]())
+
+	s.SetOwned([]byte{0, 1, 2}, 0)
+	s.Set([]byte{3, 4}, 3)
+	s.SetOwned([]byte{10}, 1)
+
+	data := make([]byte, 5)
+	assert.True(t, s.Get(data, 0))
+	assert.Equal(t, []byte{0, 10, 2, 3, 4}, data)
+}
+
+func TestStoreSetAtEnd(t *testing.T) {
+	s := store.NewStore(store.WithMinContiguous[byte](4))
+
+	s.Set([]byte{0, 1}, 0)
+	s.Set([]byte{2, 3}, 2)
+	s.Set([]byte{4, 5}, 4)
+	s.Set(nil, 8)
+	s.Set([]byte{8}, 8)
+	s.Set([]byte{10}, 10)
+
+	assert.Equal(t, int64(11), s.Length())
+	assert.Equal(t, int64(8), s.Occupancy())
+	data := make([]byte, 11)
+	assert.False(t, s.Get(data, 0))
+	assert.Equal(t, []byte{0, 1, 2, 3, 4, 5, 0, 0, 8, 0, 10}, data)
+	assert.True(t, s.Has(6, 0))
+}
+
+func TestStoreMaxContiguous(t *testing.T) {
+	s := store.NewStore(store.WithMaxContiguous[byte](4))
+
+	s.Set([]byte{0, 1, 2, 3, 4, 5}, 0)
+	s.Set([]byte{6, 7}, 6)
+	s.Set([]byte{8, 9, 10}, 8)
+
+	assert.Equal(t, int64(11), s.Occupancy())
+	data := make([]byte, 11)
+	assert.True(t, s.Get(data, 0))
+	assert.Equal(t, []byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, data)
+}
+
 func BenchmarkStoreSet(b *testing.B) {
 	s := store.NewStore[byte]()
 
@@ -353,3 +562,86 @@ func BenchmarkStoreSet(b *testing.B) {
 		b.StopTimer()
 	}
 }
+
+func BenchmarkStoreSetSequential(b *testing.B) {
+	s := store.NewStore[byte]()
+	buf := make([]byte, 1<<8)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		s.Set(buf, int64(i*len(buf)))
+	}
+}
+
+func BenchmarkStoreSetSequentialNoMerge(b *testing.B) {
+	s := store.NewStore(store.WithMinContiguous[byte](1))
+	buf := make([]byte, 1<<8)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		s.Set(buf, int64(i*len(buf)))
+	}
+}
+
+func TestStoreGetAndHasManyExtents(t *testing.T) {
+	s := store.NewStore(store.WithMinContiguous[byte](1))
+	// Extents of 4 values every 8, at -400 to 400.
+	for offset := int64(-400); offset < 400; offset += 8 {
+		assert.NoError(t, s.Set([]byte{1, 2, 3, 4}, offset))
+	}
+	assert.Len(t, s.Extents(), 100)
+
+	for offset := int64(-404); offset < 404; offset++ {
+		in := (offset%8+8)%8 < 4 && offset >= -400 && offset < 400
+		assert.Equal(t, in, s.Has(1, offset), "offset %d", offset)
+
+		p := []byte{0}
+		assert.Equal(t, in, s.Get(p, offset), "offset %d", offset)
+		if in {
+			assert.Equal(t, byte((offset%8+8)%8+1), p[0], "offset %d", offset)
+		}
+	}
+
+	assert.True(t, s.Has(4, 96))
+	assert.False(t, s.Has(5, 96))
+	p := make([]byte, 12)
+	assert.False(t, s.Get(p, 98))
+	assert.Equal(t, []byte{3, 4, 0, 0, 0, 0, 1, 2, 3, 4, 0, 0}, p)
+}
+
+func BenchmarkStoreGet(b *testing.B) {
+	s := store.NewStore(store.WithMinContiguous[byte](1))
+	buf := make([]byte, 1<<8)
+	for i := 0; i < 1<<14; i++ {
+		s.Set(buf, int64(i*2*len(buf)))
+	}
+	p := make([]byte, len(buf))
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s.Get(p, int64(i%(1<<14)*2*len(buf)))
+	}
+}
+
+func TestStoreExpectedLength(t *testing.T) {
+	const length = 64 << 20
+	chunk := make([]byte, 16<<10)
+
+	s := store.NewStore(store.WithExpectedLength[byte](length), store.WithPresenceBitmap[byte]())
+	for offset := int64(0); offset < 1<<20; offset += int64(len(chunk)) {
+		s.Set(chunk, offset)
+	}
+
+	// 64 Mi spread over at most 1 Ki extents merges up to 64 Ki.
+	assert.Len(t, s.Extents(), 16)
+	assert.True(t, s.Has(1<<20, 0))
+	assert.Equal(t, int64(1<<20), s.Coverage(length, 0))
+
+	explicit := store.NewStore(store.WithExpectedLength[byte](length), store.WithMinContiguous[byte](16<<10))
+	for offset := int64(0); offset < 1<<20; offset += int64(len(chunk)) {
+		explicit.Set(chunk, offset)
+	}
+
+	assert.Len(t, explicit.Extents(), 64)
+}
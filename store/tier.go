@@ -0,0 +1,169 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+)
+
+// Tier is a level of a Tiered store, such as memory or disk.
+type Tier[T any] interface {
+	// Read populates `p` with the values at `offset` the tier holds, and
+	// returns the ranges of those it does not hold, in order.
+	Read(p []T, offset int64) (missing []Range, err error)
+	// Write stores `p` at `offset`. It must not retain `p`. It may return
+	// ErrFull if the tier has no room for it.
+	Write(p []T, offset int64) error
+}
+
+// StoreTier is a tier holding values in a store, with the capacity and
+// eviction it is configured with.
+type StoreTier[T any] struct {
+	Store *Store[T]
+}
+
+// Read reads from the store.
+func (t StoreTier[T]) Read(p []T, offset int64) ([]Range, error) {
+	if t.Store.Get(p, offset) {
+		return nil, nil
+	}
+	return t.Store.Gaps(int64(len(p)), offset), nil
+}
+
+// Write sets a copy of `p` in the store.
+func (t StoreTier[T]) Write(p []T, offset int64) error {
+	return t.Store.Set(slices.Clone(p), offset)
+}
+
+// File is the file a FileTier keeps its values in.
+type File interface {
+	io.ReaderAt
+	io.WriterAt
+}
+
+// FileTier is a tier holding values in a file, at their own offset. It keeps
+// track of the values written in memory, evicting them as a store configured
+// with `opts` would. Evicted values are forgotten, but their space in the file
+// is not reclaimed.
+type FileTier struct {
+	file File
+	// index holds the values written, without their data.
+	index *Store[struct{}]
+}
+
+// NewFileTier returns a tier holding values in `file`, which is assumed to
+// hold none yet. Options such as WithMaxOccupancy configure its capacity and
+// eviction.
+func NewFileTier(file File, opts ...Option[struct{}]) *FileTier {
+	return &FileTier{file: file, index: NewStore(opts...)}
+}
+
+// Read reads the values the tier holds from the file.
+func (t *FileTier) Read(p []byte, offset int64) ([]Range, error) {
+	var missing []Range
+	if !t.index.Get(make([]struct{}, len(p)), offset) {
+		missing = t.index.Gaps(int64(len(p)), offset)
+	}
+
+	// Read the ranges between the missing ones.
+	pos := offset
+	for _, r := range append(missing, Range{Offset: offset + int64(len(p))}) {
+		if r.Offset > pos {
+			if _, err := t.file.ReadAt(p[pos-offset:r.Offset-offset], pos); err != nil {
+				return nil, err
+			}
+		}
+		pos = r.End()
+	}
+
+	return missing, nil
+}
+
+// Write writes `p` to the file.
+func (t *FileTier) Write(p []byte, offset int64) error {
+	if _, err := t.file.WriteAt(p, offset); err != nil {
+		return err
+	}
+	return t.index.Set(make([]struct{}, len(p)), offset)
+}
+
+// Tiered reads through a number of tiers, from the fastest to the slowest, to
+// a source. Values missing from a tier are read from the next one, and copied
+// into the tiers above it. Like Cache, it is safe for concurrent use, and
+// does not hold its lock while fetching from the source.
+type Tiered[T any] struct {
+	mu     sync.Mutex
+	tiers  []Tier[T]
+	source Fetcher[T]
+}
+
+// NewTiered returns a tiered store reading through `tiers` to `source`.
+func NewTiered[T any](source Fetcher[T], tiers ...Tier[T]) *Tiered[T] {
+	return &Tiered[T]{tiers: tiers, source: source}
+}
+
+// Get populates `p` with the values at `offset`. Tiers that are full are
+// skipped when copying values into them.
+func (t *Tiered[T]) Get(ctx context.Context, p []T, offset int64) error {
+	missing := []Range{{Offset: offset, Length: int64(len(p))}}
+
+	t.mu.Lock()
+	for i, tier := range t.tiers {
+		var next []Range
+		for _, r := range missing {
+			m, err := tier.Read(p[r.Offset-offset:r.End()-offset], r.Offset)
+			if err != nil {
+				t.mu.Unlock()
+				return fmt.Errorf("reading tier %d: %w", i, err)
+			}
+
+			// Copy the values found into the tiers above.
+			pos := r.Offset
+			for _, gap := range append(m, Range{Offset: r.End()}) {
+				if gap.Offset > pos {
+					if err := t.write(i, p[pos-offset:gap.Offset-offset], pos); err != nil {
+						t.mu.Unlock()
+						return err
+					}
+				}
+				pos = gap.End()
+			}
+			next = append(next, m...)
+		}
+		missing = next
+	}
+	t.mu.Unlock()
+
+	for _, r := range missing {
+		values, err := t.source.Fetch(ctx, r.Offset, r.Length)
+		if err == nil && int64(len(values)) < r.Length {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return fmt.Errorf("fetching %d values at %d: %w", r.Length, r.Offset, err)
+		}
+
+		copy(p[r.Offset-offset:], values[:r.Length])
+		t.mu.Lock()
+		err = t.write(len(t.tiers), values[:r.Length], r.Offset)
+		t.mu.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// write writes `p` to the tiers above tier `n`.
+func (t *Tiered[T]) write(n int, p []T, offset int64) error {
+	for i, tier := range t.tiers[:n] {
+		if err := tier.Write(p, offset); err != nil && !errors.Is(err, ErrFull) {
+			return fmt.Errorf("writing tier %d: %w", i, err)
+		}
+	}
+	return nil
+}
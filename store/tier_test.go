@@ -0,0 +1,58 @@
+package store_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aertje/sparse-store/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readerFile is an in-memory store.File.
+type readerFile struct {
+	file
+}
+
+func (f *readerFile) ReadAt(p []byte, offset int64) (int, error) {
+	return copy(p, f.data[offset:]), nil
+}
+
+func TestTiered(t *testing.T) {
+	src := &source{}
+	memory := store.NewStore(store.WithMaxOccupancy[byte](4, store.EvictLRU))
+	disk := store.NewFileTier(&readerFile{}, store.WithMaxOccupancy[struct{}](16, store.EvictLRU))
+	tiered := store.NewTiered[byte](src, store.StoreTier[byte]{Store: memory}, disk)
+
+	p := make([]byte, 4)
+	require.NoError(t, tiered.Get(context.Background(), p, 0))
+	assert.Equal(t, []byte{0, 1, 2, 3}, p)
+	require.NoError(t, tiered.Get(context.Background(), p, 4))
+	assert.Equal(t, []byte{4, 5, 6, 7}, p)
+	assert.Len(t, src.fetched, 2)
+
+	// The first values were evicted from memory, but not from disk.
+	assert.False(t, memory.Has(4, 0))
+	require.NoError(t, tiered.Get(context.Background(), p, 0))
+	assert.Equal(t, []byte{0, 1, 2, 3}, p)
+	assert.Len(t, src.fetched, 2)
+	assert.True(t, memory.Has(4, 0))
+
+	// Values partially on disk are fetched only where missing.
+	p = make([]byte, 12)
+	require.NoError(t, tiered.Get(context.Background(), p, 2))
+	assert.Equal(t, []byte{2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13}, p)
+	assert.Equal(t, store.Range{Offset: 8, Length: 6}, src.fetched[2])
+}
+
+func TestFileTierEviction(t *testing.T) {
+	disk := store.NewFileTier(&readerFile{}, store.WithMaxOccupancy[struct{}](4, store.EvictOldest), store.WithMinContiguous[struct{}](1))
+	require.NoError(t, disk.Write([]byte{1, 2, 3}, 0))
+	require.NoError(t, disk.Write([]byte{4, 5}, 3))
+
+	p := make([]byte, 5)
+	missing, err := disk.Read(p, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []store.Range{{Offset: 0, Length: 3}}, missing)
+	assert.Equal(t, []byte{0, 0, 0, 4, 5}, p)
+}
@@ -0,0 +1,109 @@
+package store
+
+import "errors"
+
+// Transform calls `fn` with the offset and values of every extent, in order,
+// for it to modify the values in place. Values the store does not own, such as
+// slices given to Set, are copied first, so they are left untouched. Runs
+// stored in constant space are expanded, a chunk at a time.
+//
+// The values are written through, or marked to be written back, and recorded
+// by a Recorder, as if they were set. Transform returns the errors writing them through, in which case
+// the store holds the values as transformed nonetheless.
+func (c *Store[T]) Transform(fn func(offset int64, data []T)) error {
+	defer c.enter("transform")()
+	c.mutate()
+	c.Compact()
+	c.expire()
+
+	var err error
+	transformed := make(entries[T], 0, len(c.entries))
+	for _, e := range c.entries {
+		for _, part := range c.expand(e) {
+			if !part.owned {
+				data := c.alloc(len(part.data))
+				copy(data, part.data)
+				if part.shared != nil {
+					c.release(part)
+					part.shared = nil
+				}
+				part.data = data
+				part.owned = true
+				part.backing = cap(data)
+			}
+			fn(part.offset, part.data)
+			c.unseal(part.offset, part.size())
+			// The transformed values are recorded as written, so that
+			// replaying the recording reproduces them.
+			c.recorder.recordSet(part, c.now())
+
+			err = errors.Join(err, c.writeThrough(part))
+			if c.writeBack {
+				c.dirty.add(part.offset, part.end())
+			}
+			transformed = append(transformed, part)
+		}
+	}
+	c.entries = transformed
+	c.publish()
+
+	return err
+}
+
+// Map returns a new store, configured with `opts`, holding the values `fn`
+// returns for every extent of `s`, given its offset and values, at the same
+// offset. `fn` must not modify the values it is given, and the slices it
+// returns are retained as with Set. Runs are expanded, a chunk at a time.
+func Map[T, U any](s *Store[T], fn func(offset int64, data []T) []U, opts ...Option[U]) (*Store[U], error) {
+	s.Compact()
+	s.expire()
+
+	mapped := NewStore(opts...)
+	for _, e := range s.entries {
+		for _, part := range s.expand(e) {
+			if err := mapped.Set(fn(part.offset, part.data), part.offset); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return mapped, nil
+}
+
+// expand returns `e` as entries holding data, splitting runs into chunks of at
+// most the merge limit.
+func (c *Store[T]) expand(e entry[T]) entries[T] {
+	if !e.run {
+		return entries[T]{e}
+	}
+
+	var parts entries[T]
+	for offset := e.offset; offset < e.end(); {
+		part := e.slice(offset, min(e.end(), offset+int64(c.mergeLimit())))
+		data := c.alloc(int(part.size()))
+		part.read(data, part.offset)
+		part.run = false
+		part.runLength = 0
+		part.data = data
+		part.owned = true
+		part.backing = cap(data)
+		parts = append(parts, part)
+		offset = part.end()
+	}
+	return parts
+}
+
+// Reduce calls `fn` with the accumulator, starting at `acc`, and the offset
+// and values of every extent of `s`, in order, and returns the accumulator it
+// returns last. `fn` must not modify the values. Runs are expanded, a chunk at
+// a time, and gaps are skipped.
+func Reduce[T, A any](s *Store[T], acc A, fn func(acc A, offset int64, data []T) A) A {
+	s.Compact()
+	s.expire()
+
+	for _, e := range s.entries {
+		for _, part := range s.expand(e) {
+			acc = fn(acc, part.offset, part.data)
+		}
+	}
+	return acc
+}
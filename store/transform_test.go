@@ -0,0 +1,93 @@
+package store_test
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/aertje/sparse-store/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStoreTransform(t *testing.T) {
+	s := store.NewStore[int]()
+
+	in := []int{1, 2, 3}
+	s.Set(in, 2)
+	s.Fill(5, 4, 10)
+
+	var offsets []int64
+	require.NoError(t, s.Transform(func(offset int64, data []int) {
+		offsets = append(offsets, offset)
+		for i := range data {
+			data[i] *= 10
+		}
+	}))
+
+	assert.Equal(t, []int64{2, 10}, offsets)
+	// The slice given to Set is left untouched.
+	assert.Equal(t, []int{1, 2, 3}, in)
+	data := make([]int, 12)
+	assert.False(t, s.Get(data, 2))
+	assert.Equal(t, []int{10, 20, 30, 0, 0, 0, 0, 0, 50, 50, 50, 50}, data)
+	assert.Equal(t, int64(7), s.Occupancy())
+}
+
+func TestStoreTransformWriteBack(t *testing.T) {
+	f := &file{}
+	s := store.NewStore(store.WithWriteBack(f))
+
+	s.Set([]byte{1, 2}, 0)
+	require.NoError(t, s.Flush())
+	require.NoError(t, s.Transform(func(offset int64, data []byte) {
+		data[0] = 9
+	}))
+
+	assert.Equal(t, int64(2), s.Dirty())
+	require.NoError(t, s.Flush())
+	assert.Equal(t, []byte{9, 2}, f.data)
+}
+
+func TestMap(t *testing.T) {
+	s := store.NewStore(store.WithMinContiguous[int](1))
+	s.Set([]int{1, 2}, 0)
+	s.Set([]int{3}, 5)
+
+	mapped, err := store.Map(s, func(offset int64, data []int) []string {
+		out := make([]string, len(data))
+		for i, v := range data {
+			out[i] = strconv.Itoa(v * 2)
+		}
+		return out
+	}, store.WithMinContiguous[string](1))
+	require.NoError(t, err)
+
+	assert.Equal(t, []store.Range{{Offset: 0, Length: 2}, {Offset: 5, Length: 1}}, mapped.Extents())
+	data := make([]string, 6)
+	assert.False(t, mapped.Get(data, 0))
+	assert.Equal(t, []string{"2", "4", "", "", "", "6"}, data)
+
+	// The original store is unchanged.
+	ints := make([]int, 2)
+	assert.True(t, s.Get(ints, 0))
+	assert.Equal(t, []int{1, 2}, ints)
+}
+
+func TestReduce(t *testing.T) {
+	s := store.NewStore[int]()
+	s.Set([]int{1, 2}, 0)
+	s.Fill(3, 4, 100)
+
+	sum := store.Reduce(s, 0, func(acc int, offset int64, data []int) int {
+		for _, v := range data {
+			acc += v
+		}
+		return acc
+	})
+	assert.Equal(t, 15, sum)
+
+	ends := store.Reduce(s, []int64(nil), func(acc []int64, offset int64, data []int) []int64 {
+		return append(acc, offset+int64(len(data)))
+	})
+	assert.Equal(t, []int64{2, 104}, ends)
+}
@@ -0,0 +1,27 @@
+package store
+
+// Truncate sets the length of the store to `length`, like truncating a file:
+// the values at and after it are deleted, and if it is past the end, the store
+// is extended as by an empty write there. With WithBlockSize, shrinking the
+// store must delete whole blocks, or it fails with ErrUnaligned. A negative
+// length fails with a BoundsError.
+func (c *Store[T]) Truncate(length int64) error {
+	defer c.enter("truncate")()
+	if length < 0 {
+		return &BoundsError{Op: "truncate", Length: length, Err: ErrNegativeLength}
+	}
+	if length >= c.length {
+		return c.extendTo(length)
+	}
+	c.mutate()
+	if !c.aligned(c.length-length, length) {
+		return ErrUnaligned
+	}
+
+	c.Delete(c.length-length, length)
+	c.recorder.record("truncate", length)
+	c.length = length
+	c.start = min(c.start, length)
+	c.publish()
+	return nil
+}
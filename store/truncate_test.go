@@ -0,0 +1,47 @@
+package store_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/aertje/sparse-store/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTruncate(t *testing.T) {
+	var buf bytes.Buffer
+	s := store.NewStore(store.WithInvariantChecks[byte](), store.WithRecorder(store.NewRecorder[byte](&buf)))
+	require.NoError(t, s.Set([]byte{1, 2, 3, 4}, 0))
+	require.NoError(t, s.Set([]byte{5, 6}, 6))
+
+	require.NoError(t, s.Truncate(3))
+	assert.Equal(t, int64(3), s.Length())
+	assert.Equal(t, int64(3), s.Occupancy())
+	assert.Equal(t, []store.Range{{Offset: 0, Length: 3}}, s.Extents())
+
+	require.NoError(t, s.Truncate(10))
+	assert.Equal(t, int64(10), s.Length())
+	assert.Equal(t, []store.Range{{Offset: 3, Length: 7}}, s.Gaps(10, 0))
+
+	replayed := store.NewStore[byte]()
+	require.NoError(t, store.Replay(&buf, replayed))
+	assert.Equal(t, s.Length(), replayed.Length())
+	assert.Equal(t, s.Extents(), replayed.Extents())
+}
+
+func TestTruncateBlocks(t *testing.T) {
+	s := store.NewStore(store.WithBlockSize[byte](4, store.RejectUnaligned))
+	require.NoError(t, s.Set(make([]byte, 8), 0))
+	assert.ErrorIs(t, s.Truncate(6), store.ErrUnaligned)
+	assert.Equal(t, int64(8), s.Length())
+	require.NoError(t, s.Truncate(4))
+	assert.Equal(t, []store.Range{{Offset: 0, Length: 4}}, s.Extents())
+}
+
+func TestTruncateNegative(t *testing.T) {
+	s := store.NewStore[byte]()
+	require.NoError(t, s.Set(make([]byte, 8), 0))
+	assert.ErrorIs(t, s.Truncate(-5), store.ErrNegativeLength)
+	assert.Equal(t, int64(8), s.Length())
+}
@@ -0,0 +1,105 @@
+package store
+
+import (
+	"fmt"
+	"unsafe"
+)
+
+// TypedView reinterprets a byte store as a store of values of a fixed-size
+// type, such as float32 or int16, in the native byte order, without copying or
+// converting them: the value at offset `i` is held by the bytes at offset `i`
+// times its size.
+//
+// It is unsafe in the ways reinterpreting memory is: values are neither
+// converted between byte orders nor checked, so it suits bytes written in the
+// native order, such as by the TypedView itself.
+type TypedView[T any] struct {
+	bytes *Store[byte]
+	size  int64
+}
+
+// Reinterpret returns a typed view of `b` as a store of T. T must be a numeric type
+// of fixed size, or an array or struct of such types without padding.
+func Reinterpret[T any](b *Store[byte]) (*TypedView[T], error) {
+	size, err := width[T]()
+	if err != nil {
+		return nil, err
+	}
+	if size != int64(unsafe.Sizeof(*new(T))) {
+		return nil, fmt.Errorf("%T has padding", *new(T))
+	}
+	return &TypedView[T]{bytes: b, size: size}, nil
+}
+
+// asBytes returns the memory of `p` as bytes.
+func asBytes[T any](p []T) []byte {
+	return unsafe.Slice((*byte)(unsafe.Pointer(unsafe.SliceData(p))), uintptr(len(p))*unsafe.Sizeof(*new(T)))
+}
+
+// Bytes returns the underlying byte store.
+func (v *TypedView[T]) Bytes() *Store[byte] {
+	return v.bytes
+}
+
+// Length returns the number of values up to the length of the byte store,
+// including the last one if only partly set.
+func (v *TypedView[T]) Length() int64 {
+	return alignUp(v.bytes.Length(), v.size) / v.size
+}
+
+// Set sets the values at `offset` to `p`. The byte store retains the memory
+// of `p` as it would retain a byte slice given to Set.
+func (v *TypedView[T]) Set(p []T, offset int64) error {
+	return v.bytes.Set(asBytes(p), offset*v.size)
+}
+
+// Get reads the values from `offset` onwards into `p`, directly from the byte
+// store, and returns whether all of them were set.
+func (v *TypedView[T]) Get(p []T, offset int64) bool {
+	return v.bytes.Get(asBytes(p), offset*v.size)
+}
+
+// Has returns whether all `length` values at `offset` are set.
+func (v *TypedView[T]) Has(length, offset int64) bool {
+	return v.bytes.Has(length*v.size, offset*v.size)
+}
+
+// Extents returns the ranges of values held entirely by the extents of the
+// byte store, in order. Adjacent extents are coalesced.
+func (v *TypedView[T]) Extents() []Range {
+	var extents []Range
+	for _, r := range present(v.bytes) {
+		first := alignUp(r.Offset, v.size) / v.size
+		last := alignDown(r.End(), v.size) / v.size
+		if first < last {
+			extents = append(extents, Range{Offset: first, Length: last - first})
+		}
+	}
+	return extents
+}
+
+// Slice returns the `length` values at `offset` as a slice of the memory of
+// the byte store, without copying them, if a single extent holds all of them
+// and its memory is suitably aligned for T. Otherwise it returns false, and
+// Get is to be used instead. The slice must not be modified.
+func (v *TypedView[T]) Slice(length, offset int64) ([]T, bool) {
+	b := v.bytes
+	b.Compact()
+	b.expire()
+
+	from, to := offset*v.size, (offset+length)*v.size
+	i := b.entries.Search(from + 1)
+	if i == 0 || length <= 0 {
+		return nil, false
+	}
+	e := b.entries[i-1]
+	if e.run || e.offset > from || e.end() < to {
+		return nil, false
+	}
+
+	data := e.data[from-e.offset : to-e.offset]
+	if uintptr(unsafe.Pointer(unsafe.SliceData(data)))%unsafe.Alignof(*new(T)) != 0 {
+		return nil, false
+	}
+	return unsafe.Slice((*T)(unsafe.Pointer(unsafe.SliceData(data))), length), true
+}
@@ -0,0 +1,59 @@
+package store_test
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/aertje/sparse-store/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTypedView(t *testing.T) {
+	b := store.NewStore(store.WithMinContiguous[byte](1))
+	raw := binary.NativeEndian.AppendUint32(nil, math.Float32bits(1.5))
+	raw = binary.NativeEndian.AppendUint32(raw, math.Float32bits(-2))
+	b.Set(raw, 8)
+
+	v, err := store.Reinterpret[float32](b)
+	require.NoError(t, err)
+	require.NoError(t, v.Set([]float32{3.25}, 5))
+	// Bytes holding part of a value only.
+	b.Set([]byte{1, 2}, 40)
+
+	assert.Equal(t, int64(11), v.Length())
+	assert.Equal(t, []store.Range{{Offset: 2, Length: 2}, {Offset: 5, Length: 1}}, v.Extents())
+	assert.True(t, v.Has(2, 2))
+	assert.False(t, v.Has(2, 4))
+
+	data := make([]float32, 4)
+	assert.False(t, v.Get(data, 2))
+	assert.Equal(t, []float32{1.5, -2, 0, 3.25}, data)
+}
+
+func TestTypedViewSlice(t *testing.T) {
+	b := store.NewStore[byte]()
+	v, err := store.Reinterpret[int16](b)
+	require.NoError(t, err)
+	require.NoError(t, v.Set([]int16{1, 2, 3, 4}, 10))
+
+	slice, ok := v.Slice(2, 11)
+	require.True(t, ok)
+	assert.Equal(t, []int16{2, 3}, slice)
+
+	_, ok = v.Slice(2, 13)
+	assert.False(t, ok)
+}
+
+func TestReinterpretInvalid(t *testing.T) {
+	_, err := store.Reinterpret[string](store.NewStore[byte]())
+	assert.Error(t, err)
+
+	type padded struct {
+		A int8
+		B int64
+	}
+	_, err = store.Reinterpret[padded](store.NewStore[byte]())
+	assert.Error(t, err)
+}
@@ -0,0 +1,33 @@
+//go:build go1.23
+
+package store
+
+import (
+	"iter"
+	"slices"
+)
+
+// Values returns an iterator over the values the store holds, with their
+// offsets, in order. Gaps are skipped. The values are read from the extents in
+// place rather than copied, so the store must not be modified while iterating.
+func (c *Store[T]) Values() iter.Seq2[int64, T] {
+	return func(yield func(int64, T) bool) {
+		done := c.enter("values")
+		c.Compact()
+		c.expire()
+		entries := slices.Clone(c.entries)
+		done()
+
+		for _, e := range entries {
+			for i := int64(0); i < e.size(); i++ {
+				v := e.value
+				if !e.run {
+					v = e.data[i]
+				}
+				if !yield(e.offset+i, v) {
+					return
+				}
+			}
+		}
+	}
+}
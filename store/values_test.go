@@ -0,0 +1,41 @@
+//go:build go1.23
+
+package store_test
+
+import (
+	"testing"
+
+	"github.com/aertje/sparse-store/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValues(t *testing.T) {
+	s := store.NewStore(store.WithLazyCompaction[int](16, 0))
+	require.NoError(t, s.Set([]int{1, 2, 3}, -2))
+	require.NoError(t, s.Fill(7, 2, 10))
+	// Overwrites the middle value, pending until compaction.
+	require.NoError(t, s.Set([]int{9}, -1))
+
+	offsets := []int64{}
+	values := []int{}
+	for offset, v := range s.Values() {
+		offsets = append(offsets, offset)
+		values = append(values, v)
+	}
+	assert.Equal(t, []int64{-2, -1, 0, 10, 11}, offsets)
+	assert.Equal(t, []int{1, 9, 3, 7, 7}, values)
+
+	// Stopping early.
+	n := 0
+	for range s.Values() {
+		if n++; n == 2 {
+			break
+		}
+	}
+	assert.Equal(t, 2, n)
+
+	for range store.NewStore[int]().Values() {
+		t.Fatal("empty store yields values")
+	}
+}
@@ -0,0 +1,120 @@
+package store
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"hash"
+)
+
+// ErrCorrupt is returned when a piece does not match its digest.
+var ErrCorrupt = errors.New("piece is corrupt")
+
+// Verifier divides a byte store into pieces of a fixed size, and verifies
+// each piece against its expected digest once it is complete. Pieces that do
+// not match are deleted from the store, so that they are fetched again.
+type Verifier struct {
+	store     *Store[byte]
+	length    int64
+	pieceSize int64
+	digests   [][]byte
+	newHash   func() hash.Hash
+	verified  []bool
+}
+
+// NewVerifier returns a verifier for the `length` values of `s`, in pieces of
+// `pieceSize` values hashed with `newHash`. Only the last piece may be
+// shorter. `digests` holds the expected digest of every piece.
+func NewVerifier(s *Store[byte], length, pieceSize int64, digests [][]byte, newHash func() hash.Hash) (*Verifier, error) {
+	if pieceSize <= 0 {
+		return nil, fmt.Errorf("invalid piece size %d", pieceSize)
+	}
+	if n := (length + pieceSize - 1) / pieceSize; int64(len(digests)) != n {
+		return nil, fmt.Errorf("expected %d digests, got %d", n, len(digests))
+	}
+
+	return &Verifier{
+		store:     s,
+		length:    length,
+		pieceSize: pieceSize,
+		digests:   digests,
+		newHash:   newHash,
+		verified:  make([]bool, len(digests)),
+	}, nil
+}
+
+// Piece returns the range of piece `i`.
+func (v *Verifier) Piece(i int) Range {
+	offset := int64(i) * v.pieceSize
+	return Range{Offset: offset, Length: min(v.pieceSize, v.length-offset)}
+}
+
+// Set sets `p` at `offset` in the store, and verifies the pieces it completes.
+// It returns an error wrapping ErrCorrupt for the first piece that does not
+// match its digest, after deleting all of those that do not.
+func (v *Verifier) Set(p []byte, offset int64) error {
+	if err := v.store.Set(p, offset); err != nil {
+		return err
+	}
+	if len(p) == 0 {
+		return nil
+	}
+
+	var err error
+	first := max(offset, 0) / v.pieceSize
+	last := min(offset+int64(len(p)), v.length) - 1
+	for i := int(first); int64(i)*v.pieceSize <= last; i++ {
+		// Pieces that were verified are verified again, as `p` overwrote them.
+		v.verified[i] = false
+		if perr := v.verify(i); perr != nil && err == nil {
+			err = perr
+		}
+	}
+	return err
+}
+
+// verify verifies piece `i` if it is complete.
+func (v *Verifier) verify(i int) error {
+	piece := v.Piece(i)
+	// Most writes leave the piece incomplete, so it is only read once it is.
+	if !v.store.Has(piece.Length, piece.Offset) {
+		return nil
+	}
+	data := make([]byte, piece.Length)
+	if !v.store.Get(data, piece.Offset) {
+		return nil
+	}
+
+	h := v.newHash()
+	h.Write(data)
+	if !bytes.Equal(h.Sum(nil), v.digests[i]) {
+		v.store.Delete(piece.Length, piece.Offset)
+		return fmt.Errorf("piece %d: %w", i, ErrCorrupt)
+	}
+	v.verified[i] = true
+	return nil
+}
+
+// Verified reports whether piece `i` was verified and is still in the store.
+func (v *Verifier) Verified(i int) bool {
+	if !v.verified[i] {
+		return false
+	}
+	piece := v.Piece(i)
+	if !v.store.Has(piece.Length, piece.Offset) {
+		// The piece was evicted, and needs to be verified again.
+		v.verified[i] = false
+	}
+	return v.verified[i]
+}
+
+// Missing returns the indices of the pieces that are not verified, in order.
+func (v *Verifier) Missing() []int {
+	var missing []int
+	for i := range v.verified {
+		if !v.Verified(i) {
+			missing = append(missing, i)
+		}
+	}
+	return missing
+}
@@ -0,0 +1,85 @@
+package store_test
+
+import (
+	"crypto/sha256"
+	"runtime"
+	"testing"
+
+	"github.com/aertje/sparse-store/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func digests(data []byte, pieceSize int) [][]byte {
+	var digests [][]byte
+	for offset := 0; offset < len(data); offset += pieceSize {
+		sum := sha256.Sum256(data[offset:min(offset+pieceSize, len(data))])
+		digests = append(digests, sum[:])
+	}
+	return digests
+}
+
+func TestVerifier(t *testing.T) {
+	data := make([]byte, 25)
+	for i := range data {
+		data[i] = byte(i)
+	}
+
+	s := store.NewStore[byte]()
+	v, err := store.NewVerifier(s, 25, 10, digests(data, 10), sha256.New)
+	require.NoError(t, err)
+	assert.Equal(t, store.Range{Offset: 20, Length: 5}, v.Piece(2))
+	assert.Equal(t, []int{0, 1, 2}, v.Missing())
+
+	// Incomplete pieces are not verified.
+	require.NoError(t, v.Set(append([]byte(nil), data[0:15]...), 0))
+	assert.True(t, v.Verified(0))
+	assert.False(t, v.Verified(1))
+
+	// The last piece is shorter.
+	require.NoError(t, v.Set(append([]byte(nil), data[20:25]...), 20))
+	assert.Equal(t, []int{1}, v.Missing())
+
+	// A corrupt piece is deleted.
+	corrupt := append([]byte(nil), data[15:20]...)
+	corrupt[0] ^= 0xff
+	assert.ErrorIs(t, v.Set(corrupt, 15), store.ErrCorrupt)
+	assert.False(t, v.Verified(1))
+	assert.False(t, s.Has(1, 10))
+	assert.True(t, s.Has(10, 0))
+
+	require.NoError(t, v.Set(append([]byte(nil), data[10:20]...), 10))
+	assert.Empty(t, v.Missing())
+
+	// Overwriting a verified piece verifies it again.
+	assert.ErrorIs(t, v.Set([]byte{0xff}, 0), store.ErrCorrupt)
+	assert.Equal(t, []int{0}, v.Missing())
+
+	// Deleted pieces are no longer verified.
+	s.Delete(1, 24)
+	assert.Equal(t, []int{0, 2}, v.Missing())
+}
+
+func TestVerifierIncomplete(t *testing.T) {
+	const pieceSize = 1 << 20
+	data := make([]byte, pieceSize)
+	v, err := store.NewVerifier(store.NewStore[byte](), pieceSize, pieceSize, digests(data, pieceSize), sha256.New)
+	require.NoError(t, err)
+
+	// Writes leaving the piece incomplete do not read it.
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	for i := 0; i < 10; i++ {
+		require.NoError(t, v.Set([]byte{0}, int64(i)))
+	}
+	runtime.ReadMemStats(&after)
+	assert.Less(t, after.TotalAlloc-before.TotalAlloc, uint64(pieceSize))
+	assert.Equal(t, []int{0}, v.Missing())
+}
+
+func TestVerifierDigests(t *testing.T) {
+	_, err := store.NewVerifier(store.NewStore[byte](), 25, 10, make([][]byte, 2), sha256.New)
+	assert.Error(t, err)
+	_, err = store.NewVerifier(store.NewStore[byte](), 25, 0, nil, sha256.New)
+	assert.Error(t, err)
+}
@@ -0,0 +1,238 @@
+package store
+
+import (
+	"slices"
+	"sync"
+	"unsafe"
+)
+
+// Versioned is a store whose writes create numbered versions, built on
+// Persistent. Readers open views pinned at the current version, which are not
+// affected by later writes, and release them when done. A version is garbage
+// collected once it is neither current nor pinned by a view. Values that were
+// overwritten may still be retained by the arrays of the extents they were
+// part of, until GC copies those extents out.
+//
+// Versioned is safe for concurrent use. Writes are serialized, reads from
+// views take no locks.
+type Versioned[T any] struct {
+	mu      sync.Mutex
+	current *Persistent[T]
+	version uint64
+	// pinned holds the number of open views per version, and snapshots the
+	// versions they are pinned at.
+	pinned    map[uint64]int
+	snapshots map[uint64]*Persistent[T]
+}
+
+// NewVersioned returns an empty store at version 0.
+func NewVersioned[T any]() *Versioned[T] {
+	return &Versioned[T]{
+		current:   &Persistent[T]{},
+		pinned:    map[uint64]int{},
+		snapshots: map[uint64]*Persistent[T]{},
+	}
+}
+
+// Set writes `p` at `offset`, and returns the new version. `p` is copied.
+func (v *Versioned[T]) Set(p []T, offset int64) uint64 {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.current = v.current.Set(p, offset)
+	v.version++
+	return v.version
+}
+
+// Version returns the current version.
+func (v *Versioned[T]) Version() uint64 {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	return v.version
+}
+
+// View opens a view of the current version. It must be released when done.
+func (v *Versioned[T]) View() *View[T] {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.pinned[v.version]++
+	v.snapshots[v.version] = v.current
+	return &View[T]{Persistent: v.current, version: v.version, owner: v}
+}
+
+// Pinned returns the versions that have open views, in ascending order.
+func (v *Versioned[T]) Pinned() []uint64 {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	versions := make([]uint64, 0, len(v.pinned))
+	for version := range v.pinned {
+		versions = append(versions, version)
+	}
+	slices.Sort(versions)
+	return versions
+}
+
+// View is a read-only view of a version of a Versioned store. Its methods are
+// those of Persistent, except that Set does not affect the store.
+type View[T any] struct {
+	*Persistent[T]
+	version uint64
+	owner   *Versioned[T]
+}
+
+// Version returns the version the view is pinned at.
+func (w *View[T]) Version() uint64 {
+	return w.version
+}
+
+// Release releases the view, after which it must not be used anymore.
+// Releasing it more than once has no effect.
+func (w *View[T]) Release() {
+	if w.Persistent == nil {
+		return
+	}
+	w.Persistent = nil
+
+	w.owner.mu.Lock()
+	defer w.owner.mu.Unlock()
+
+	if w.owner.pinned[w.version]--; w.owner.pinned[w.version] == 0 {
+		delete(w.owner.pinned, w.version)
+		delete(w.owner.snapshots, w.version)
+	}
+}
+
+// Retention is a breakdown of the memory held by the values of a Versioned
+// store, in bytes.
+type Retention struct {
+	// Current is the size of the arrays the current version holds values in.
+	// Garbage is the part of them that holds values of none of its extents,
+	// some of which GC can reclaim.
+	Current, Garbage int64
+	// Snapshots is the size of the arrays retained only by views of other
+	// versions.
+	Snapshots int64
+	// Versions is the number of versions with open views, and Views the
+	// number of those views.
+	Versions, Views int
+}
+
+// Retention returns the memory held by the values of the current version and
+// of the versions pinned by views.
+func (v *Versioned[T]) Retention() Retention {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	size := int64(unsafe.Sizeof(*new(T)))
+	current := arrays(v.current.root)
+	snapshots := map[uintptr]array{}
+	for _, snapshot := range v.snapshots {
+		for key, a := range arrays(snapshot.root) {
+			snapshots[key] = snapshots[key].union(a)
+		}
+	}
+
+	r := Retention{Versions: len(v.pinned)}
+	for _, n := range v.pinned {
+		r.Views += n
+	}
+	for _, a := range current {
+		r.Current += a.capacity * size
+		r.Garbage += max(a.capacity-a.used, 0) * size
+	}
+	for key, a := range snapshots {
+		r.Snapshots += max(a.capacity-current[key].capacity, 0) * size
+	}
+	return r
+}
+
+// GC copies the extents of the current version out of arrays that hold
+// values it does not need, so that those arrays can be garbage collected, and
+// returns the number of bytes that frees. Arrays that views of other versions
+// hold values of as well are left alone, as copying from them would free
+// nothing.
+func (v *Versioned[T]) GC() int64 {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	shared := map[uintptr]bool{}
+	for _, snapshot := range v.snapshots {
+		for key := range arrays(snapshot.root) {
+			shared[key] = true
+		}
+	}
+
+	var freed int64
+	size := int64(unsafe.Sizeof(*new(T)))
+	for key, a := range arrays(v.current.root) {
+		if a.used < a.capacity && !shared[key] {
+			freed += (a.capacity - a.used) * size
+		} else {
+			shared[key] = true
+		}
+	}
+	if freed == 0 {
+		return 0
+	}
+
+	var rebuild func(n *node[T]) *node[T]
+	rebuild = func(n *node[T]) *node[T] {
+		if n == nil {
+			return nil
+		}
+		data := n.data
+		if !shared[arrayKey(data)] {
+			// Allocate exactly, slices.Clone may round up the capacity.
+			data = make([]T, len(n.data))
+			copy(data, n.data)
+		}
+		return newNode(data, n.offset, rebuild(n.left), rebuild(n.right))
+	}
+	v.current = &Persistent[T]{root: rebuild(v.current.root), length: v.current.length}
+
+	return freed
+}
+
+// array is the use of an array by the extents of a version.
+type array struct {
+	// capacity is the number of values of the array from the first extent
+	// onwards, and used the number of those that extents hold.
+	capacity, used int64
+}
+
+func (a array) union(b array) array {
+	return array{capacity: max(a.capacity, b.capacity), used: max(a.used, b.used)}
+}
+
+// arrays returns the arrays the extents of the tree at `root` hold values in,
+// by arrayKey.
+func arrays[T any](root *node[T]) map[uintptr]array {
+	arrays := map[uintptr]array{}
+	var walk func(n *node[T])
+	walk = func(n *node[T]) {
+		if n == nil {
+			return
+		}
+		if cap(n.data) > 0 {
+			key := arrayKey(n.data)
+			a := arrays[key]
+			a.capacity = max(a.capacity, int64(cap(n.data)))
+			a.used += int64(len(n.data))
+			arrays[key] = a
+		}
+		walk(n.left)
+		walk(n.right)
+	}
+	walk(root)
+	return arrays
+}
+
+// arrayKey identifies the array backing `data` by where its capacity ends,
+// which all slices of an array share.
+func arrayKey[T any](data []T) uintptr {
+	size := unsafe.Sizeof(*new(T))
+	return uintptr(unsafe.Pointer(unsafe.SliceData(data))) + uintptr(cap(data))*size
+}
@@ -0,0 +1,90 @@
+package store_test
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/aertje/sparse-store/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVersionedViews(t *testing.T) {
+	v := store.NewVersioned[byte]()
+	assert.Equal(t, uint64(1), v.Set([]byte{1, 2}, 0))
+
+	view := v.View()
+	assert.Equal(t, uint64(2), v.Set([]byte{3, 4}, 1))
+	other := v.View()
+	other2 := v.View()
+
+	data := make([]byte, 3)
+	assert.False(t, view.Get(data, 0))
+	assert.Equal(t, []byte{1, 2, 0}, data)
+	assert.True(t, other.Get(data, 0))
+	assert.Equal(t, []byte{1, 3, 4}, data)
+	assert.Equal(t, uint64(1), view.Version())
+	assert.Equal(t, []uint64{1, 2}, v.Pinned())
+
+	view.Release()
+	view.Release()
+	other.Release()
+	assert.Equal(t, []uint64{2}, v.Pinned())
+	other2.Release()
+	assert.Empty(t, v.Pinned())
+}
+
+func TestVersionedConcurrent(t *testing.T) {
+	v := store.NewVersioned[byte]()
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 1000; i++ {
+			v.Set([]byte{byte(i)}, int64(i%10))
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 1000; i++ {
+			view := v.View()
+			// Within a view, the occupancy only depends on its version.
+			assert.Equal(t, int64(min(view.Version(), 10)), view.Occupancy())
+			view.Release()
+		}
+	}()
+	wg.Wait()
+}
+
+func TestVersionedGC(t *testing.T) {
+	v := store.NewVersioned[int64]()
+	v.Set(make([]int64, 100), 0)
+	view := v.View()
+
+	// Overwriting most of the extent leaves its array retained by the rest.
+	v.Set(make([]int64, 90), 5)
+	assert.Equal(t, store.Retention{Current: 190 * 8, Garbage: 90 * 8, Versions: 1, Views: 1}, v.Retention())
+
+	// The view holds the array as well, so copying out of it frees nothing.
+	assert.Zero(t, v.GC())
+
+	view.Release()
+	assert.Equal(t, int64(90*8), v.GC())
+	assert.Equal(t, store.Retention{Current: 100 * 8}, v.Retention())
+	view = v.View()
+	defer view.Release()
+	assert.Equal(t, []store.Range{{Offset: 0, Length: 5}, {Offset: 5, Length: 90}, {Offset: 95, Length: 5}}, view.Extents())
+}
+
+func TestVersionedRetentionSnapshots(t *testing.T) {
+	v := store.NewVersioned[int64]()
+	v.Set(make([]int64, 100), 0)
+	view := v.View()
+	current := v.View()
+	v.Set(make([]int64, 100), 0)
+
+	assert.Equal(t, store.Retention{Current: 100 * 8, Snapshots: 100 * 8, Versions: 1, Views: 2}, v.Retention())
+	view.Release()
+	current.Release()
+	assert.Equal(t, store.Retention{Current: 100 * 8}, v.Retention())
+}
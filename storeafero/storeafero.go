@@ -0,0 +1,182 @@
+// Package storeafero holds sparse in-memory files for afero virtual file
+// systems in byte stores, without densifying them.
+//
+// File implements the afero.File interface, which only involves types of the
+// standard library, so the package does not depend on afero itself.
+package storeafero
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/aertje/sparse-store/store"
+)
+
+// File is an afero.File backed by a byte store. Its size is the length of the
+// store, and missing values are read according to its GapMode, as by a
+// store.ReadWriteSeeker. It serializes its operations, so the store must not be
+// used otherwise while the file is open.
+type File struct {
+	mu      sync.Mutex
+	name    string
+	rws     *store.ReadWriteSeeker
+	modTime time.Time
+	closed  bool
+}
+
+// NewFile returns a file named `name` backed by `s`, reading missing values
+// according to `gaps`.
+func NewFile(name string, s *store.Store[byte], gaps store.GapMode) *File {
+	return &File{name: name, rws: store.NewReadWriteSeeker(s, gaps), modTime: time.Now()}
+}
+
+// Store returns the store backing the file.
+func (f *File) Store() *store.Store[byte] {
+	return f.rws.Store()
+}
+
+// Name returns the name of the file, as given to NewFile.
+func (f *File) Name() string {
+	return f.name
+}
+
+// Close closes the file, after which its operations fail with os.ErrClosed. The
+// store is left as it is.
+func (f *File) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.check("close"); err != nil {
+		return err
+	}
+	f.closed = true
+	return nil
+}
+
+// Read implements io.Reader.
+func (f *File) Read(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.check("read"); err != nil {
+		return 0, err
+	}
+	return f.rws.Read(p)
+}
+
+// ReadAt implements io.ReaderAt.
+func (f *File) ReadAt(p []byte, offset int64) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.check("read"); err != nil {
+		return 0, err
+	}
+	return f.rws.ReadAt(p, offset)
+}
+
+// Seek implements io.Seeker.
+func (f *File) Seek(offset int64, whence int) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.check("seek"); err != nil {
+		return 0, err
+	}
+	return f.rws.Seek(offset, whence)
+}
+
+// Write implements io.Writer.
+func (f *File) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.check("write"); err != nil {
+		return 0, err
+	}
+	f.modTime = time.Now()
+	return f.rws.Write(p)
+}
+
+// WriteAt implements io.WriterAt.
+func (f *File) WriteAt(p []byte, offset int64) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.check("write"); err != nil {
+		return 0, err
+	}
+	f.modTime = time.Now()
+	return f.rws.WriteAt(p, offset)
+}
+
+// WriteString writes `s` at the current position.
+func (f *File) WriteString(s string) (int, error) {
+	return f.Write([]byte(s))
+}
+
+// Truncate changes the size of the file with store.Truncate, without changing
+// the position.
+func (f *File) Truncate(size int64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.check("truncate"); err != nil {
+		return err
+	}
+	if size < 0 {
+		return &fs.PathError{Op: "truncate", Path: f.name, Err: syscall.EINVAL}
+	}
+	f.modTime = time.Now()
+	return f.rws.Store().Truncate(size)
+}
+
+// Sync flushes the store to the writer configured with store.WithWriteBack, if
+// any.
+func (f *File) Sync() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.check("sync"); err != nil {
+		return err
+	}
+	return f.rws.Store().Flush()
+}
+
+// Stat returns the name, size and modification time of the file.
+func (f *File) Stat() (os.FileInfo, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.check("stat"); err != nil {
+		return nil, err
+	}
+	return fileInfo{name: filepath.Base(f.name), size: f.rws.Store().Length(), modTime: f.modTime}, nil
+}
+
+// Readdir fails, as the file is not a directory.
+func (f *File) Readdir(count int) ([]os.FileInfo, error) {
+	return nil, &fs.PathError{Op: "readdir", Path: f.name, Err: syscall.ENOTDIR}
+}
+
+// Readdirnames fails, as the file is not a directory.
+func (f *File) Readdirnames(n int) ([]string, error) {
+	return nil, &fs.PathError{Op: "readdirnames", Path: f.name, Err: syscall.ENOTDIR}
+}
+
+// check returns an error for operation `op` if the file is closed.
+func (f *File) check(op string) error {
+	if f.closed {
+		return &fs.PathError{Op: op, Path: f.name, Err: os.ErrClosed}
+	}
+	return nil
+}
+
+// fileInfo describes a File.
+type fileInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+}
+
+func (i fileInfo) Name() string       { return i.name }
+func (i fileInfo) Size() int64        { return i.size }
+func (i fileInfo) Mode() fs.FileMode  { return 0o644 }
+func (i fileInfo) ModTime() time.Time { return i.modTime }
+func (i fileInfo) IsDir() bool        { return false }
+func (i fileInfo) Sys() any           { return nil }
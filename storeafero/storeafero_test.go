@@ -0,0 +1,69 @@
+package storeafero_test
+
+import (
+	"io"
+	"os"
+	"syscall"
+	"testing"
+	"testing/iotest"
+
+	"github.com/aertje/sparse-store/store"
+	"github.com/aertje/sparse-store/storeafero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// aferoFile is a copy of the afero.File interface.
+type aferoFile interface {
+	io.Closer
+	io.Reader
+	io.ReaderAt
+	io.Seeker
+	io.Writer
+	io.WriterAt
+
+	Name() string
+	Readdir(count int) ([]os.FileInfo, error)
+	Readdirnames(n int) ([]string, error)
+	Stat() (os.FileInfo, error)
+	Sync() error
+	Truncate(size int64) error
+	WriteString(s string) (ret int, err error)
+}
+
+var _ aferoFile = (*storeafero.File)(nil)
+
+func TestFile(t *testing.T) {
+	s := store.NewStore[byte]()
+	f := storeafero.NewFile("/data/image.bin", s, store.GapZero)
+	assert.Same(t, s, f.Store())
+	assert.Equal(t, "/data/image.bin", f.Name())
+
+	_, err := f.WriteString("head")
+	require.NoError(t, err)
+	_, err = f.WriteAt([]byte("tail"), 1<<40)
+	require.NoError(t, err)
+	// Only the values written are held.
+	assert.Equal(t, int64(8), s.Occupancy())
+
+	info, err := f.Stat()
+	require.NoError(t, err)
+	assert.Equal(t, "image.bin", info.Name())
+	assert.Equal(t, int64(1<<40+4), info.Size())
+	assert.False(t, info.IsDir())
+
+	require.NoError(t, f.Truncate(6))
+	_, err = f.Seek(0, io.SeekStart)
+	require.NoError(t, err)
+	assert.NoError(t, iotest.TestReader(f, []byte("head\x00\x00")))
+	assert.Error(t, f.Truncate(-1))
+
+	_, err = f.Readdir(0)
+	assert.ErrorIs(t, err, syscall.ENOTDIR)
+	assert.NoError(t, f.Sync())
+
+	require.NoError(t, f.Close())
+	_, err = f.Read(make([]byte, 1))
+	assert.ErrorIs(t, err, os.ErrClosed)
+	assert.ErrorIs(t, f.Close(), os.ErrClosed)
+}
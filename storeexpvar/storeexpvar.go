@@ -0,0 +1,33 @@
+// Package storeexpvar publishes the stats of a store through expvar, so that
+// they show up on /debug/vars along with the other variables of a process.
+package storeexpvar
+
+import (
+	"expvar"
+
+	"github.com/aertje/sparse-store/store"
+)
+
+// Publish publishes the stats of `s` as expvar variables named `prefix`
+// followed by sets, gets, hits, misses, served, has, has_hits, has_misses,
+// occupancy and extents. Like expvar.Publish, it panics if any of the names is
+// already in use.
+func Publish[T any](prefix string, s *store.Store[T]) {
+	for name, stat := range map[string]func(store.Stats) int64{
+		"sets":       func(st store.Stats) int64 { return st.Sets },
+		"gets":       func(st store.Stats) int64 { return st.Gets },
+		"hits":       func(st store.Stats) int64 { return st.Hits },
+		"misses":     func(st store.Stats) int64 { return st.Misses },
+		"served":     func(st store.Stats) int64 { return st.Served },
+		"has":        func(st store.Stats) int64 { return st.Has },
+		"has_hits":   func(st store.Stats) int64 { return st.HasHits },
+		"has_misses": func(st store.Stats) int64 { return st.HasMisses },
+		"occupancy":  func(st store.Stats) int64 { return st.Occupancy },
+		"extents":    func(st store.Stats) int64 { return st.Extents },
+	} {
+		stat := stat
+		expvar.Publish(prefix+name, expvar.Func(func() any {
+			return stat(s.Stats())
+		}))
+	}
+}
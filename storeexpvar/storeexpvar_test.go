@@ -0,0 +1,35 @@
+package storeexpvar_test
+
+import (
+	"expvar"
+	"testing"
+
+	"github.com/aertje/sparse-store/store"
+	"github.com/aertje/sparse-store/storeexpvar"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPublish(t *testing.T) {
+	s := store.NewStore[byte]()
+	storeexpvar.Publish("cache.", s)
+
+	s.Set([]byte{0, 1, 2}, 0)
+	s.Get(make([]byte, 3), 0)
+	s.Get(make([]byte, 3), 1)
+	s.Has(3, 0)
+
+	for name, value := range map[string]string{
+		"cache.sets":       "1",
+		"cache.gets":       "2",
+		"cache.hits":       "1",
+		"cache.misses":     "1",
+		"cache.served":     "5",
+		"cache.has":        "1",
+		"cache.has_hits":   "1",
+		"cache.has_misses": "0",
+		"cache.occupancy":  "3",
+		"cache.extents":    "1",
+	} {
+		assert.Equal(t, value, expvar.Get(name).String(), name)
+	}
+}
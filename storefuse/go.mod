@@ -0,0 +1,16 @@
+module github.com/aertje/sparse-store/storefuse
+
+go 1.21
+
+require (
+	github.com/aertje/sparse-store v0.0.0-20261016073456-4f892ec5f604
+	github.com/hanwen/go-fuse/v2 v2.5.1
+	github.com/stretchr/testify v1.8.4
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	golang.org/x/sys v0.0.0-20220520151302-bc2c85ada10a // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
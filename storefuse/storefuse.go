@@ -0,0 +1,142 @@
+//go:build fuse
+
+// Package storefuse mounts a byte store as a single read-only file with FUSE,
+// so that unmodified tools can read a partially downloaded file. It is only
+// built with the fuse build tag.
+//
+// Reads of values that are present are served from the store, up to the first
+// hole, as a short read. Reads starting at a hole either fail with EIO, or
+// block until the values are set through the File.
+package storefuse
+
+import (
+	"context"
+	"sync"
+	"syscall"
+
+	"github.com/aertje/sparse-store/store"
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+// HolePolicy is what reads of values missing from the store do.
+type HolePolicy int
+
+const (
+	// FailOnHoles makes reads of holes fail with EIO.
+	FailOnHoles HolePolicy = iota
+	// BlockOnHoles makes reads of holes block until the values are set, or
+	// the read is interrupted.
+	BlockOnHoles
+)
+
+// File is a FUSE file serving the values of a store. Its size is the length
+// of the store. The store must only be written through the File while it is
+// mounted, so that blocked reads are woken up.
+type File struct {
+	fs.Inode
+
+	holes HolePolicy
+
+	mu    sync.Mutex
+	store *store.Store[byte]
+	// changed is closed, and replaced, when values are set.
+	changed chan struct{}
+}
+
+var (
+	_ fs.NodeGetattrer = (*File)(nil)
+	_ fs.NodeOpener    = (*File)(nil)
+	_ fs.NodeReader    = (*File)(nil)
+)
+
+// NewFile returns a file serving the values of `s`, with reads of holes
+// handled according to `holes`.
+func NewFile(s *store.Store[byte], holes HolePolicy) *File {
+	return &File{holes: holes, store: s, changed: make(chan struct{})}
+}
+
+// Set sets `p` at `offset` in the store, and wakes up the reads waiting for
+// the values.
+func (f *File) Set(p []byte, offset int64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.store.Set(p, offset); err != nil {
+		return err
+	}
+	close(f.changed)
+	f.changed = make(chan struct{})
+	return nil
+}
+
+// Getattr reports the file as read-only, with the length of the store as its
+// size.
+func (f *File) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	out.Mode = fuse.S_IFREG | 0o444
+	out.Size = uint64(f.store.Length())
+	return 0
+}
+
+// Open opens the file for reading only.
+func (f *File) Open(ctx context.Context, flags uint32) (fs.FileHandle, uint32, syscall.Errno) {
+	if flags&(syscall.O_WRONLY|syscall.O_RDWR) != 0 {
+		return nil, 0, syscall.EROFS
+	}
+	// Reads bypass the page cache, so that they ask for the values requested
+	// rather than whole pages, which may extend into holes, and so that holes
+	// filled in while the file is open are read.
+	return nil, fuse.FOPEN_DIRECT_IO, 0
+}
+
+// Read reads the values at `off`, up to the length of the store or the first
+// hole.
+func (f *File) Read(ctx context.Context, fh fs.FileHandle, dest []byte, off int64) (fuse.ReadResult, syscall.Errno) {
+	for {
+		f.mu.Lock()
+		n := min(int64(len(dest)), f.store.Length()-off)
+		if n <= 0 {
+			f.mu.Unlock()
+			return fuse.ReadResultData(nil), 0
+		}
+		if present := f.store.GetPrefix(dest[:n], off); present > 0 {
+			f.mu.Unlock()
+			return fuse.ReadResultData(dest[:present]), 0
+		}
+		changed := f.changed
+		f.mu.Unlock()
+
+		if f.holes == FailOnHoles {
+			return nil, syscall.EIO
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return nil, syscall.EINTR
+		}
+	}
+}
+
+// root is the directory holding the file.
+type root struct {
+	fs.Inode
+	name string
+	file *File
+}
+
+var _ fs.NodeOnAdder = (*root)(nil)
+
+func (r *root) OnAdd(ctx context.Context) {
+	child := r.NewPersistentInode(ctx, r.file, fs.StableAttr{Mode: fuse.S_IFREG})
+	r.AddChild(r.name, child, false)
+}
+
+// Mount mounts a directory at `dir` holding `f` as its only file, named
+// `name`. `opts` may be nil for the defaults. The file system is unmounted by
+// calling Unmount on the returned server.
+func Mount(dir, name string, f *File, opts *fs.Options) (*fuse.Server, error) {
+	return fs.Mount(dir, &root{name: name, file: f}, opts)
+}
@@ -0,0 +1,127 @@
+//go:build fuse
+
+package storefuse_test
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/aertje/sparse-store/store"
+	"github.com/aertje/sparse-store/storefuse"
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func read(t *testing.T, f *storefuse.File, ctx context.Context, n int, off int64) ([]byte, syscall.Errno) {
+	t.Helper()
+	res, errno := f.Read(ctx, nil, make([]byte, n), off)
+	if errno != 0 {
+		return nil, errno
+	}
+	data, status := res.Bytes(nil)
+	require.Equal(t, fuse.OK, status)
+	return data, 0
+}
+
+func TestFileFailOnHoles(t *testing.T) {
+	s := store.NewStore[byte]()
+	s.Set([]byte("hello"), 0)
+	s.Set([]byte("world"), 10)
+	f := storefuse.NewFile(s, storefuse.FailOnHoles)
+
+	data, errno := read(t, f, context.Background(), 5, 0)
+	assert.Zero(t, errno)
+	assert.Equal(t, "hello", string(data))
+
+	// Reads are cut off at the length of the store.
+	data, errno = read(t, f, context.Background(), 100, 12)
+	assert.Zero(t, errno)
+	assert.Equal(t, "rld", string(data))
+	data, errno = read(t, f, context.Background(), 100, 20)
+	assert.Zero(t, errno)
+	assert.Empty(t, data)
+
+	// Reads are cut off at the first hole, and fail if they start at one.
+	data, errno = read(t, f, context.Background(), 10, 2)
+	assert.Zero(t, errno)
+	assert.Equal(t, "llo", string(data))
+	_, errno = read(t, f, context.Background(), 10, 5)
+	assert.Equal(t, syscall.EIO, errno)
+}
+
+func TestFileBlockOnHoles(t *testing.T) {
+	s := store.NewStore[byte]()
+	s.Set([]byte("world"), 10)
+	f := storefuse.NewFile(s, storefuse.BlockOnHoles)
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		f.Set([]byte("hello"), 0)
+		time.Sleep(10 * time.Millisecond)
+		f.Set([]byte(",    "), 5)
+	}()
+	data, errno := read(t, f, context.Background(), 15, 0)
+	assert.Zero(t, errno)
+	assert.Equal(t, "hello", string(data))
+	data, errno = read(t, f, context.Background(), 10, 5)
+	assert.Zero(t, errno)
+	assert.Equal(t, ",    world", string(data))
+
+	// Interrupted reads fail.
+	s.Delete(5, 0)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, errno = read(t, f, ctx, 5, 0)
+	assert.Equal(t, syscall.EINTR, errno)
+}
+
+func TestMount(t *testing.T) {
+	s := store.NewStore[byte]()
+	s.Set([]byte("hello"), 0)
+	f := storefuse.NewFile(s, storefuse.FailOnHoles)
+
+	dir := t.TempDir()
+	server, err := storefuse.Mount(dir, "data", f, nil)
+	if err != nil {
+		t.Skipf("mounting: %v", err)
+	}
+	defer server.Unmount()
+
+	data, err := os.ReadFile(filepath.Join(dir, "data"))
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(data))
+}
+
+func TestMountHole(t *testing.T) {
+	s := store.NewStore[byte]()
+	s.Set([]byte("hello"), 0)
+	s.Set(nil, 8192)
+	f := storefuse.NewFile(s, storefuse.FailOnHoles)
+
+	dir := t.TempDir()
+	server, err := storefuse.Mount(dir, "data", f, nil)
+	if err != nil {
+		t.Skipf("mounting: %v", err)
+	}
+	defer server.Unmount()
+
+	file, err := os.Open(filepath.Join(dir, "data"))
+	require.NoError(t, err)
+	defer file.Close()
+
+	// The values before the hole are read, rather than the page holding
+	// them.
+	p := make([]byte, 5)
+	_, err = io.ReadFull(file, p)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(p))
+
+	_, err = file.Read(p)
+	assert.ErrorIs(t, err, syscall.EIO)
+}
@@ -0,0 +1,241 @@
+// Package storehttp exposes a byte store over HTTP, for debugging and for
+// access from other languages.
+//
+// The handler serves the values of the store at its root: GET reads them,
+// with a Range header for part of them, and PUT or PATCH writes the request
+// body, at the offset of a Content-Range header if any. GET /extents returns
+// the extents of the store as JSON.
+package storehttp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/aertje/sparse-store/store"
+)
+
+// DefaultMaxSize is the default maximum size of the bodies of requests and
+// responses, in bytes.
+const DefaultMaxSize = 64 << 20
+
+// Handler is an http.Handler exposing a store. It serializes the requests, so
+// the store must not be used otherwise while it serves them.
+type Handler struct {
+	// MaxResponseSize is the maximum number of values a GET serves,
+	// DefaultMaxSize if zero. Larger ranges are refused with 416, as they are
+	// buffered.
+	MaxResponseSize int64
+	// MaxRequestSize is the maximum size of the body of a PUT or PATCH,
+	// DefaultMaxSize if zero. Larger bodies are refused with 413.
+	MaxRequestSize int64
+
+	mu    sync.Mutex
+	store *store.Store[byte]
+}
+
+// NewHandler returns a handler exposing `s`.
+func NewHandler(s *store.Store[byte]) *Handler {
+	return &Handler{store: s}
+}
+
+// Extents is the response to GET /extents.
+type Extents struct {
+	Length  int64         `json:"length"`
+	Extents []store.Range `json:"extents"`
+}
+
+// ServeHTTP implements http.Handler.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == "/extents" && r.Method == http.MethodGet:
+		h.serveExtents(w)
+	case r.URL.Path != "/" && r.URL.Path != "":
+		http.NotFound(w, r)
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		h.serveGet(w, r)
+	case r.Method == http.MethodPut || r.Method == http.MethodPatch:
+		h.servePut(w, r)
+	default:
+		w.Header().Set("Allow", "GET, HEAD, PUT, PATCH")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *Handler) serveExtents(w http.ResponseWriter) {
+	h.mu.Lock()
+	extents := Extents{Length: h.store.Length(), Extents: h.store.Extents()}
+	h.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(extents)
+}
+
+// serveGet serves the values in the range requested. It responds with 404 if
+// any of them are missing.
+func (h *Handler) serveGet(w http.ResponseWriter, r *http.Request) {
+	h.mu.Lock()
+	length := h.store.Length()
+	spec := r.Header.Get("Range")
+	rng, ok := parseRange(spec, length)
+	if !ok {
+		h.mu.Unlock()
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", length))
+		http.Error(w, http.StatusText(http.StatusRequestedRangeNotSatisfiable), http.StatusRequestedRangeNotSatisfiable)
+		return
+	}
+	// Presence is checked before the values are buffered, so that ranges
+	// requested past the values do not allocate.
+	if !h.store.Has(rng.Length, rng.Offset) {
+		h.mu.Unlock()
+		http.Error(w, fmt.Sprintf("values missing in bytes %d-%d", rng.Offset, rng.End()-1), http.StatusNotFound)
+		return
+	}
+	if limit := maxSize(h.MaxResponseSize); rng.Length > limit {
+		h.mu.Unlock()
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", length))
+		http.Error(w, fmt.Sprintf("range of %d bytes exceeds %d bytes, request a smaller range", rng.Length, limit), http.StatusRequestedRangeNotSatisfiable)
+		return
+	}
+	data := make([]byte, rng.Length)
+	h.store.Get(data, rng.Offset)
+	h.mu.Unlock()
+
+	w.Header().Set("Accept-Ranges", "bytes")
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Length", strconv.FormatInt(rng.Length, 10))
+	status := http.StatusOK
+	if spec != "" {
+		status = http.StatusPartialContent
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", rng.Offset, rng.End()-1, length))
+	}
+	w.WriteHeader(status)
+	if r.Method != http.MethodHead {
+		w.Write(data)
+	}
+}
+
+// servePut writes the request body at the offset of its Content-Range, or at
+// the start of the store.
+func (h *Handler) servePut(w http.ResponseWriter, r *http.Request) {
+	var offset int64
+	length := int64(-1)
+	if header := r.Header.Get("Content-Range"); header != "" {
+		var ok bool
+		if offset, length, ok = parseContentRange(header); !ok {
+			http.Error(w, fmt.Sprintf("invalid Content-Range %q", header), http.StatusBadRequest)
+			return
+		}
+	}
+
+	limit := maxSize(h.MaxRequestSize)
+	if length > limit {
+		http.Error(w, fmt.Sprintf("Content-Range of %d bytes exceeds %d bytes", length, limit), http.StatusRequestEntityTooLarge)
+		return
+	}
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, limit))
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		http.Error(w, fmt.Sprintf("body exceeds %d bytes", limit), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if length >= 0 && int64(len(data)) != length {
+		http.Error(w, fmt.Sprintf("body has %d bytes, Content-Range %d", len(data), length), http.StatusBadRequest)
+		return
+	}
+
+	h.mu.Lock()
+	err = h.store.SetOwned(data, offset)
+	h.mu.Unlock()
+	var bounds *store.BoundsError
+	if errors.As(err, &bounds) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInsufficientStorage)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// maxSize returns `size`, or DefaultMaxSize if it is zero.
+func maxSize(size int64) int64 {
+	if size == 0 {
+		return DefaultMaxSize
+	}
+	return size
+}
+
+// parseRange parses a Range header for a single range of a store of `length`
+// values. An empty header is the whole store.
+func parseRange(spec string, length int64) (store.Range, bool) {
+	if spec == "" {
+		return store.Range{Offset: 0, Length: length}, true
+	}
+	spec, ok := strings.CutPrefix(spec, "bytes=")
+	if !ok || strings.Contains(spec, ",") {
+		return store.Range{}, false
+	}
+	first, last, ok := strings.Cut(spec, "-")
+	if !ok {
+		return store.Range{}, false
+	}
+
+	if first == "" {
+		// A suffix of the store.
+		n, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || n <= 0 || length == 0 {
+			return store.Range{}, false
+		}
+		n = min(n, length)
+		return store.Range{Offset: length - n, Length: n}, true
+	}
+
+	from, err := strconv.ParseInt(first, 10, 64)
+	if err != nil || from < 0 || from >= length {
+		return store.Range{}, false
+	}
+	to := length - 1
+	if last != "" {
+		if to, err = strconv.ParseInt(last, 10, 64); err != nil || to < from {
+			return store.Range{}, false
+		}
+		to = min(to, length-1)
+	}
+	return store.Range{Offset: from, Length: to - from + 1}, true
+}
+
+// parseContentRange parses a Content-Range header of a write, returning the
+// offset and length of the range. The total length is ignored.
+func parseContentRange(header string) (offset, length int64, ok bool) {
+	spec, ok := strings.CutPrefix(header, "bytes ")
+	if !ok {
+		return 0, 0, false
+	}
+	rng, _, ok := strings.Cut(spec, "/")
+	if !ok {
+		return 0, 0, false
+	}
+	first, last, ok := strings.Cut(rng, "-")
+	if !ok {
+		return 0, 0, false
+	}
+
+	from, err1 := strconv.ParseInt(first, 10, 64)
+	to, err2 := strconv.ParseInt(last, 10, 64)
+	if err1 != nil || err2 != nil || from < 0 || to < from || to-from == math.MaxInt64 {
+		return 0, 0, false
+	}
+	return from, to - from + 1, true
+}
@@ -0,0 +1,117 @@
+package storehttp_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aertje/sparse-store/store"
+	"github.com/aertje/sparse-store/storehttp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func do(t *testing.T, method, url string, header http.Header, body []byte) (*http.Response, []byte) {
+	t.Helper()
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	require.NoError(t, err)
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp, data
+}
+
+func TestHandler(t *testing.T) {
+	s := store.NewStore[byte]()
+	ts := httptest.NewServer(storehttp.NewHandler(s))
+	defer ts.Close()
+
+	resp, _ := do(t, http.MethodPut, ts.URL, nil, []byte("hello"))
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	resp, _ = do(t, http.MethodPatch, ts.URL, http.Header{"Content-Range": {"bytes 10-14/*"}}, []byte("world"))
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	resp, data := do(t, http.MethodGet, ts.URL, http.Header{"Range": {"bytes=10-"}}, nil)
+	assert.Equal(t, http.StatusPartialContent, resp.StatusCode)
+	assert.Equal(t, "bytes 10-14/15", resp.Header.Get("Content-Range"))
+	assert.Equal(t, "world", string(data))
+
+	resp, data = do(t, http.MethodGet, ts.URL, http.Header{"Range": {"bytes=-3"}}, nil)
+	assert.Equal(t, http.StatusPartialContent, resp.StatusCode)
+	assert.Equal(t, "rld", string(data))
+
+	resp, data = do(t, http.MethodGet, ts.URL, http.Header{"Range": {"bytes=1-3"}}, nil)
+	assert.Equal(t, "ell", string(data))
+
+	// Ranges with missing values, or outside of the store, are not served.
+	resp, _ = do(t, http.MethodGet, ts.URL, nil, nil)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	resp, _ = do(t, http.MethodGet, ts.URL, http.Header{"Range": {"bytes=15-"}}, nil)
+	assert.Equal(t, http.StatusRequestedRangeNotSatisfiable, resp.StatusCode)
+	assert.Equal(t, "bytes */15", resp.Header.Get("Content-Range"))
+
+	resp, data = do(t, http.MethodGet, ts.URL+"/extents", nil, nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	var extents storehttp.Extents
+	require.NoError(t, json.Unmarshal(data, &extents))
+	assert.Equal(t, storehttp.Extents{
+		Length:  15,
+		Extents: []store.Range{{Offset: 0, Length: 5}, {Offset: 10, Length: 5}},
+	}, extents)
+
+	resp, _ = do(t, http.MethodPut, ts.URL, http.Header{"Content-Range": {"bytes 5-9/*"}}, []byte(", "))
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	resp, _ = do(t, http.MethodPut, ts.URL, http.Header{"Content-Range": {"bytes 5-9/*"}}, []byte(",    "))
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	resp, data = do(t, http.MethodGet, ts.URL, nil, nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "hello,    world", string(data))
+
+	// Writes past the end of the offset domain are rejected.
+	resp, _ = do(t, http.MethodPut, ts.URL, http.Header{"Content-Range": {"bytes 9223372036854775806-9223372036854775807/*"}}, []byte("ab"))
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	resp, _ = do(t, http.MethodPut, ts.URL, http.Header{"Content-Range": {"bytes 0-9223372036854775807/*"}}, []byte("ab"))
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp, _ = do(t, http.MethodDelete, ts.URL, nil, nil)
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+}
+
+func TestHandlerLimits(t *testing.T) {
+	s := store.NewStore[byte]()
+	h := storehttp.NewHandler(s)
+	h.MaxResponseSize = 4
+	h.MaxRequestSize = 4
+	ts := httptest.NewServer(h)
+	defer ts.Close()
+
+	// A value far past the others does not make GET allocate up to it.
+	resp, _ := do(t, http.MethodPut, ts.URL, http.Header{"Content-Range": {"bytes 1099511627775-1099511627775/*"}}, []byte("x"))
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	resp, _ = do(t, http.MethodGet, ts.URL, nil, nil)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	resp, _ = do(t, http.MethodPut, ts.URL, nil, []byte("hello"))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+	resp, _ = do(t, http.MethodPut, ts.URL, http.Header{"Content-Range": {"bytes 0-4/*"}}, []byte("hello"))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+
+	resp, _ = do(t, http.MethodPut, ts.URL, nil, []byte("hell"))
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	resp, _ = do(t, http.MethodPut, ts.URL, http.Header{"Content-Range": {"bytes 4-7/*"}}, []byte("o, w"))
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	resp, data := do(t, http.MethodGet, ts.URL, http.Header{"Range": {"bytes=2-5"}}, nil)
+	assert.Equal(t, http.StatusPartialContent, resp.StatusCode)
+	assert.Equal(t, "llo,", string(data))
+	resp, _ = do(t, http.MethodGet, ts.URL, http.Header{"Range": {"bytes=0-7"}}, nil)
+	assert.Equal(t, http.StatusRequestedRangeNotSatisfiable, resp.StatusCode)
+}
@@ -0,0 +1,22 @@
+//go:build !(linux || darwin || freebsd)
+
+package storemmap
+
+import (
+	"os"
+
+	"github.com/aertje/sparse-store/store"
+)
+
+// writeMapped copies the values of `s` into `f` with WriteAt, where shared
+// mappings are not supported.
+func writeMapped(s *store.Store[byte], f *os.File) error {
+	for _, r := range s.Extents() {
+		data := make([]byte, r.Length)
+		s.Get(data, r.Offset)
+		if _, err := f.WriteAt(data, r.Offset); err != nil {
+			return err
+		}
+	}
+	return nil
+}
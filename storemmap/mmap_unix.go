@@ -0,0 +1,27 @@
+//go:build linux || darwin || freebsd
+
+package storemmap
+
+import (
+	"os"
+
+	"github.com/aertje/sparse-store/store"
+	"golang.org/x/sys/unix"
+)
+
+// writeMapped copies the values of `s` into `f`, which is as long as the
+// store, through a shared mapping of the file.
+func writeMapped(s *store.Store[byte], f *os.File) error {
+	data, err := unix.Mmap(int(f.Fd()), 0, int(s.Length()), unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED)
+	if err != nil {
+		return err
+	}
+	for _, r := range s.Extents() {
+		s.Get(data[r.Offset:r.End()], r.Offset)
+	}
+	err = unix.Msync(data, unix.MS_SYNC)
+	if uerr := unix.Munmap(data); err == nil {
+		err = uerr
+	}
+	return err
+}
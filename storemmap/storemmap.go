@@ -0,0 +1,127 @@
+// Package storemmap moves byte stores in and out of memory-mapped files, for
+// high-throughput local file access.
+//
+// View reads a mapped region, such as an *mmap.ReaderAt of
+// golang.org/x/exp/mmap, into a read-only store, optionally leaving out pages
+// of zeros as holes. WriteFile writes a store to a file through a shared
+// mapping, touching only the pages holding values, so that the rest of the
+// file stays a hole.
+package storemmap
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"github.com/aertje/sparse-store/store"
+)
+
+// viewChunk is the number of bytes View reads at a time, rounded down to whole
+// pages.
+const viewChunk = 1 << 20
+
+// ReaderAt is a read-only mapped region. *mmap.ReaderAt of
+// golang.org/x/exp/mmap implements it, without this package depending on the
+// experimental module.
+type ReaderAt interface {
+	ReadAt(p []byte, offset int64) (int, error)
+	Len() int
+}
+
+// View returns a read-only view of a store, configured with `opts`, holding
+// the bytes of `r` at the same offsets. If `zeroPages` is true, pages of zeros
+// are left out as holes, as a sparse file would have them. The length of the
+// store is the length of `r` either way.
+//
+// Despite its name, View copies the bytes onto the heap rather than referring
+// to the mapping, so that the view stays valid once `r` is closed. Only the
+// pages holding values are copied when `zeroPages` is true.
+func View(r ReaderAt, zeroPages bool, opts ...store.Option[byte]) (store.SnapshotView[byte], error) {
+	page := os.Getpagesize()
+	chunk := max(viewChunk/page, 1) * page
+	size := int64(r.Len())
+
+	s := store.NewStore(opts...)
+	var scratch []byte
+	for offset := int64(0); offset < size; offset += int64(chunk) {
+		// Without holes, every chunk is read into its own buffer, which the
+		// store retains. Otherwise the chunks are read into a reused buffer,
+		// and only the runs of pages holding values are copied out of it.
+		var buf []byte
+		if zeroPages {
+			if scratch == nil {
+				scratch = make([]byte, min(int64(chunk), size))
+			}
+			buf = scratch[:min(int64(chunk), size-offset)]
+		} else {
+			buf = make([]byte, min(int64(chunk), size-offset))
+		}
+		if _, err := r.ReadAt(buf, offset); err != nil {
+			return store.SnapshotView[byte]{}, fmt.Errorf("reading at %d: %w", offset, err)
+		}
+		if !zeroPages {
+			if err := s.Set(buf, offset); err != nil {
+				return store.SnapshotView[byte]{}, err
+			}
+			continue
+		}
+
+		// Set the runs of pages holding a value other than zero.
+		from := -1
+		for i := 0; ; i += page {
+			i = min(i, len(buf))
+			if i < len(buf) && !zero(buf[i:min(i+page, len(buf))]) {
+				if from < 0 {
+					from = i
+				}
+				continue
+			}
+			if from >= 0 {
+				if err := s.Set(bytes.Clone(buf[from:i]), offset+int64(from)); err != nil {
+					return store.SnapshotView[byte]{}, err
+				}
+				from = -1
+			}
+			if i == len(buf) {
+				break
+			}
+		}
+	}
+	if err := s.Truncate(size); err != nil {
+		return store.SnapshotView[byte]{}, err
+	}
+	return s.Snapshot()
+}
+
+// zero returns true if all bytes of `p` are zero.
+func zero(p []byte) bool {
+	for _, b := range p {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// WriteFile writes the values of `s` to the file at `path`, which is created
+// or truncated to the length of the store, at their offsets. Missing values
+// are left as holes where the file system supports them. Offsets must not be
+// negative.
+func WriteFile(s *store.Store[byte], path string) error {
+	if s.Start() < 0 {
+		return fmt.Errorf("writing %s: negative offset %d", path, s.Start())
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	err = f.Truncate(s.Length())
+	if err == nil && s.Occupancy() > 0 {
+		err = writeMapped(s, f)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,79 @@
+package storemmap_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aertje/sparse-store/store"
+	"github.com/aertje/sparse-store/storemmap"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestView(t *testing.T) {
+	page := int64(os.Getpagesize())
+	// A page of data, a page of zeros, and half a page ending in data.
+	data := make([]byte, 2*page+page/2)
+	copy(data, "first page")
+	data[len(data)-1] = 1
+
+	view, err := storemmap.View(bytes.NewReader(data), true)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(data)), view.Length())
+	assert.Equal(t, []store.Range{{Offset: 0, Length: page}, {Offset: 2 * page, Length: page / 2}}, view.Extents())
+	got := make([]byte, page)
+	assert.True(t, view.Get(got, 0))
+	assert.Equal(t, data[:page], got)
+
+	view, err = storemmap.View(bytes.NewReader(data), false)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(data)), view.Occupancy())
+
+	// Trailing zero pages are holes within the length.
+	view, err = storemmap.View(bytes.NewReader(make([]byte, page)), true)
+	require.NoError(t, err)
+	assert.Equal(t, page, view.Length())
+	assert.Zero(t, view.Occupancy())
+}
+
+func TestViewChunks(t *testing.T) {
+	page := os.Getpagesize()
+	// Values in pages of several chunks, which are read through one buffer.
+	data := make([]byte, 3<<20)
+	for i := 0; i < len(data); i += 4 * page {
+		data[i] = byte(i/page) | 1
+	}
+
+	view, err := storemmap.View(bytes.NewReader(data), true)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(data)/4), view.Occupancy())
+	got := make([]byte, len(data))
+	view.Get(got, 0)
+	assert.Equal(t, data, got)
+}
+
+func TestWriteFile(t *testing.T) {
+	s := store.NewStore[byte]()
+	require.NoError(t, s.Set([]byte("head"), 0))
+	require.NoError(t, s.Set([]byte("tail"), 1<<20))
+	path := filepath.Join(t.TempDir(), "file")
+
+	require.NoError(t, storemmap.WriteFile(s, path))
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	want := make([]byte, 1<<20+4)
+	copy(want, "head")
+	copy(want[1<<20:], "tail")
+	assert.Equal(t, want, data)
+
+	require.NoError(t, storemmap.WriteFile(store.NewStore[byte](), path))
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Zero(t, info.Size())
+
+	negative := store.NewStore(store.WithSignedOffsets[byte]())
+	require.NoError(t, negative.Set([]byte{1}, -1))
+	assert.Error(t, storemmap.WriteFile(negative, path))
+}
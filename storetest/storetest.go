@@ -0,0 +1,353 @@
+// Package storetest checks implementations of stores against a reference
+// model, for code wrapping or reimplementing parts of a store, such as
+// backends and codecs.
+//
+// DenseModel is a plain slice holding the values set, and whether they are
+// present. Generate returns random operations, and Check applies them to a
+// target and to a model, and reports the first operation for which they
+// differ.
+package storetest
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"slices"
+
+	"github.com/aertje/sparse-store/store"
+)
+
+// Target is the part of a store checked against the model. A *store.Store
+// implements it, as long as it neither evicts nor expires values.
+type Target[T any] interface {
+	Set(p []T, offset int64) error
+	Get(p []T, offset int64) bool
+	Has(length, offset int64) bool
+	Delete(length, offset int64) int64
+	Length() int64
+	Extents() []store.Range
+}
+
+var _ Target[byte] = (*DenseModel[byte])(nil)
+
+// DenseModel is the reference implementation of a store, holding the values in
+// a plain slice. It implements the reads and writes of a *store.Store, so that
+// it can stand in for one as an oracle in differential tests. Offsets must not
+// be negative.
+type DenseModel[T any] struct {
+	values  []T
+	present []bool
+}
+
+// NewDenseModel returns an empty model. The zero DenseModel is empty as well.
+func NewDenseModel[T any]() *DenseModel[T] {
+	return &DenseModel[T]{}
+}
+
+// Set sets the values at `offset` to `p`. It never fails.
+func (m *DenseModel[T]) Set(p []T, offset int64) error {
+	if end := int(offset) + len(p); end > len(m.values) {
+		m.values = append(m.values, make([]T, end-len(m.values))...)
+		m.present = append(m.present, make([]bool, end-len(m.present))...)
+	}
+	copy(m.values[offset:], p)
+	for i := range p {
+		m.present[int(offset)+i] = true
+	}
+	return nil
+}
+
+// Get populates `p` with the values at `offset`, and returns true if all of them
+// are present. Values that are not present are zero.
+func (m *DenseModel[T]) Get(p []T, offset int64) bool {
+	var zero T
+	complete := true
+	for i := range p {
+		if m.has(offset + int64(i)) {
+			p[i] = m.values[int(offset)+i]
+		} else {
+			p[i] = zero
+			complete = false
+		}
+	}
+	return complete
+}
+
+// Has returns true if the `length` values at `offset` are all present.
+func (m *DenseModel[T]) Has(length, offset int64) bool {
+	for i := offset; i < offset+length; i++ {
+		if !m.has(i) {
+			return false
+		}
+	}
+	return true
+}
+
+// Delete removes the `length` values at `offset`, and returns the number of
+// values removed.
+func (m *DenseModel[T]) Delete(length, offset int64) int64 {
+	var zero T
+	var removed int64
+	for i := offset; i < offset+length; i++ {
+		if m.has(i) {
+			m.values[i], m.present[i] = zero, false
+			removed++
+		}
+	}
+	return removed
+}
+
+// Coverage returns the number of values present of the `length` values at
+// `offset`.
+func (m *DenseModel[T]) Coverage(length, offset int64) int64 {
+	var coverage int64
+	for i := offset; i < offset+length; i++ {
+		if m.has(i) {
+			coverage++
+		}
+	}
+	return coverage
+}
+
+// Gaps returns the ranges of values missing of the `length` values at
+// `offset`, in order.
+func (m *DenseModel[T]) Gaps(length, offset int64) []store.Range {
+	var gaps []store.Range
+	for i := offset; i < offset+length; i++ {
+		if m.has(i) {
+			continue
+		}
+		if n := len(gaps); n > 0 && gaps[n-1].End() == i {
+			gaps[n-1].Length++
+		} else {
+			gaps = append(gaps, store.Range{Offset: i, Length: 1})
+		}
+	}
+	return gaps
+}
+
+// Occupancy returns the number of values present.
+func (m *DenseModel[T]) Occupancy() int64 {
+	return m.Coverage(m.Length(), 0)
+}
+
+// Clear removes all values, and resets the length.
+func (m *DenseModel[T]) Clear() {
+	m.values, m.present = nil, nil
+}
+
+// Length returns the end of the values ever set.
+func (m *DenseModel[T]) Length() int64 {
+	return int64(len(m.values))
+}
+
+// Extents returns the ranges of values present, in order.
+func (m *DenseModel[T]) Extents() []store.Range {
+	var extents []store.Range
+	for i, present := range m.present {
+		if !present {
+			continue
+		}
+		if n := len(extents); n > 0 && extents[n-1].End() == int64(i) {
+			extents[n-1].Length++
+		} else {
+			extents = append(extents, store.Range{Offset: int64(i), Length: 1})
+		}
+	}
+	return extents
+}
+
+// Apply applies the writes and deletes of `op` to the model.
+func (m *DenseModel[T]) Apply(op Op[T]) {
+	switch op.Kind {
+	case OpSet:
+		m.Set(op.Data, op.Offset)
+	case OpDelete:
+		m.Delete(op.Length, op.Offset)
+	}
+}
+
+// has returns true if the value at `offset` is present.
+func (m *DenseModel[T]) has(offset int64) bool {
+	return offset >= 0 && offset < int64(len(m.present)) && m.present[offset]
+}
+
+// OpKind is the kind of an operation.
+type OpKind int
+
+const (
+	OpSet OpKind = iota
+	OpGet
+	OpHas
+	OpDelete
+)
+
+func (k OpKind) String() string {
+	switch k {
+	case OpSet:
+		return "set"
+	case OpGet:
+		return "get"
+	case OpHas:
+		return "has"
+	case OpDelete:
+		return "delete"
+	default:
+		return fmt.Sprintf("OpKind(%d)", int(k))
+	}
+}
+
+// Op is an operation on a store, of `Length` values at `Offset`. The values
+// set are `Data`.
+type Op[T any] struct {
+	Kind   OpKind
+	Offset int64
+	Length int64
+	Data   []T
+}
+
+func (o Op[T]) String() string {
+	return fmt.Sprintf("%v of %d values at %d", o.Kind, o.Length, o.Offset)
+}
+
+// Config bounds the operations generated.
+type Config struct {
+	// MaxOffset is the offset below which the operations start.
+	MaxOffset int64
+	// MaxLength is the maximum number of values of an operation.
+	MaxLength int64
+}
+
+// Generate returns `n` random operations from `r`, half of them writes of
+// values returned by `value`.
+func Generate[T any](r *rand.Rand, n int, cfg Config, value func(r *rand.Rand) T) []Op[T] {
+	ops := make([]Op[T], n)
+	for i := range ops {
+		op := Op[T]{Offset: r.Int63n(cfg.MaxOffset), Length: r.Int63n(cfg.MaxLength + 1)}
+		switch r.Intn(6) {
+		case 0:
+			op.Kind = OpGet
+		case 1:
+			op.Kind = OpHas
+		case 2:
+			op.Kind = OpDelete
+		default:
+			op.Kind = OpSet
+			op.Data = make([]T, op.Length)
+			for j := range op.Data {
+				op.Data[j] = value(r)
+			}
+		}
+		ops[i] = op
+	}
+	return ops
+}
+
+// Check applies `ops` to `target` and to an empty model, and returns an error
+// describing the first operation for which the results differ, or after which
+// the values held do.
+func Check[T comparable](target Target[T], ops []Op[T]) error {
+	model := NewDenseModel[T]()
+	for i, op := range ops {
+		if err := apply(target, model, op); err != nil {
+			return fmt.Errorf("op %d, %v: %w", i, op, err)
+		}
+		if err := Equal(target, model); err != nil {
+			return fmt.Errorf("after op %d, %v: %w", i, op, err)
+		}
+	}
+	return nil
+}
+
+// apply applies `op` to `target` and `model`, and returns an error if the
+// results differ.
+func apply[T comparable](target Target[T], model *DenseModel[T], op Op[T]) error {
+	switch op.Kind {
+	case OpSet:
+		model.Set(op.Data, op.Offset)
+		if err := target.Set(slices.Clone(op.Data), op.Offset); err != nil {
+			return err
+		}
+	case OpGet:
+		want, got := make([]T, op.Length), make([]T, op.Length)
+		wantOK, gotOK := model.Get(want, op.Offset), target.Get(got, op.Offset)
+		if wantOK != gotOK {
+			return fmt.Errorf("get returned %t, want %t", gotOK, wantOK)
+		}
+		if gotOK && !slices.Equal(want, got) {
+			return fmt.Errorf("get returned %v, want %v", got, want)
+		}
+	case OpHas:
+		if want, got := model.Has(op.Length, op.Offset), target.Has(op.Length, op.Offset); want != got {
+			return fmt.Errorf("has returned %t, want %t", got, want)
+		}
+	case OpDelete:
+		if want, got := model.Delete(op.Length, op.Offset), target.Delete(op.Length, op.Offset); want != got {
+			return fmt.Errorf("delete removed %d values, want %d", got, want)
+		}
+	}
+	return nil
+}
+
+// Equal returns an error describing the first difference between the values
+// held by `target` and `model`, if any. Adjacent extents of the target are
+// compared as one.
+func Equal[T comparable](target Target[T], model *DenseModel[T]) error {
+	if want, got := model.Length(), target.Length(); want != got {
+		return fmt.Errorf("length is %d, want %d", got, want)
+	}
+	want, got := model.Extents(), coalesce(target.Extents())
+	if !slices.Equal(want, got) {
+		return fmt.Errorf("extents are %v, want %v", got, want)
+	}
+	for _, r := range want {
+		wantValues, gotValues := make([]T, r.Length), make([]T, r.Length)
+		model.Get(wantValues, r.Offset)
+		target.Get(gotValues, r.Offset)
+		for i := range wantValues {
+			if wantValues[i] != gotValues[i] {
+				return fmt.Errorf("value at %d is %v, want %v", r.Offset+int64(i), gotValues[i], wantValues[i])
+			}
+		}
+	}
+	return nil
+}
+
+// coalesce merges the adjacent ranges of `extents`.
+func coalesce(extents []store.Range) []store.Range {
+	var merged []store.Range
+	for _, r := range extents {
+		if n := len(merged); n > 0 && merged[n-1].End() == r.Offset {
+			merged[n-1].Length += r.Length
+		} else {
+			merged = append(merged, r)
+		}
+	}
+	return merged
+}
+
+// CheckCodec checks that a store persisted with `codec` after `ops` reads back
+// the same values.
+func CheckCodec[T comparable](codec store.Codec[T], ops []Op[T]) error {
+	s := store.NewStore(store.WithCodec(codec))
+	if err := Check[T](s, ops); err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if _, err := s.WriteTo(&buf); err != nil {
+		return fmt.Errorf("writing: %w", err)
+	}
+	restored := store.NewStore(store.WithCodec(codec))
+	if _, err := restored.ReadFrom(&buf); err != nil {
+		return fmt.Errorf("reading: %w", err)
+	}
+	model := NewDenseModel[T]()
+	for _, op := range ops {
+		model.Apply(op)
+	}
+	if err := Equal[T](restored, model); err != nil {
+		return fmt.Errorf("read back: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package storetest_test
+
+import (
+	"encoding/binary"
+	"math/rand"
+	"testing"
+
+	"github.com/aertje/sparse-store/store"
+	"github.com/aertje/sparse-store/storetest"
+	"github.com/stretchr/testify/assert"
+)
+
+var config = storetest.Config{MaxOffset: 200, MaxLength: 30}
+
+func value(r *rand.Rand) uint16 {
+	return uint16(r.Intn(4))
+}
+
+func TestCheck(t *testing.T) {
+	for name, opts := range map[string][]store.Option[uint16]{
+		"eager":    {store.WithMinContiguous[uint16](8)},
+		"lazy":     {store.WithMinContiguous[uint16](8), store.WithLazyCompaction[uint16](4, 64)},
+		"chunked":  {store.WithMaxContiguous[uint16](16)},
+		"zeroRuns": {store.WithZeroRuns[uint16](4)},
+		"dedup":    {store.WithDedup[uint16](4)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			ops := storetest.Generate(rand.New(rand.NewSource(1)), 1000, config, value)
+			assert.NoError(t, storetest.Check[uint16](store.NewStore(opts...), ops))
+		})
+	}
+}
+
+// lossy drops the last value of every write.
+type lossy struct {
+	*store.Store[uint16]
+}
+
+func (l lossy) Set(p []uint16, offset int64) error {
+	if len(p) > 1 {
+		p = p[:len(p)-1]
+	}
+	return l.Store.Set(p, offset)
+}
+
+func TestCheckMismatch(t *testing.T) {
+	ops := []storetest.Op[uint16]{
+		{Kind: storetest.OpSet, Offset: 4, Length: 2, Data: []uint16{1, 2}},
+	}
+	err := storetest.Check[uint16](lossy{store.NewStore[uint16]()}, ops)
+	assert.EqualError(t, err, "after op 0, set of 2 values at 4: length is 5, want 6")
+}
+
+func TestCheckCodec(t *testing.T) {
+	ops := storetest.Generate(rand.New(rand.NewSource(1)), 500, config, value)
+	assert.NoError(t, storetest.CheckCodec[uint16](store.BinaryCodec[uint16]{Order: binary.BigEndian}, ops))
+	assert.NoError(t, storetest.CheckCodec[uint16](store.GobCodec[uint16]{}, ops))
+}
+
+// ranges is the surface of a store that code under test may swap stores
+// behind.
+type ranges interface {
+	Set(p []byte, offset int64) error
+	Get(p []byte, offset int64) bool
+	Coverage(length, offset int64) int64
+	Gaps(length, offset int64) []store.Range
+	Occupancy() int64
+	Clear()
+}
+
+func TestDenseModel(t *testing.T) {
+	for _, s := range []ranges{store.NewStore[byte](), storetest.NewDenseModel[byte]()} {
+		assert.NoError(t, s.Set([]byte{1, 2, 3}, 2))
+		assert.NoError(t, s.Set([]byte{4}, 8))
+
+		data := make([]byte, 4)
+		assert.False(t, s.Get(data, 2))
+		assert.True(t, s.Get(data[:3], 2))
+		assert.Equal(t, []byte{1, 2, 3}, data[:3])
+		assert.Equal(t, int64(2), s.Coverage(5, 4))
+		assert.Equal(t, []store.Range{{Offset: 0, Length: 2}, {Offset: 5, Length: 3}}, s.Gaps(9, 0))
+		assert.Equal(t, int64(4), s.Occupancy())
+
+		s.Clear()
+		assert.Equal(t, int64(0), s.Occupancy())
+	}
+}